@@ -0,0 +1,239 @@
+// Package access decides what a signed-in customer may do with an account.
+//
+// An account is a customers row that owns a wallet and containers: the
+// customer's own (personal) account, or the billing account of an
+// organization they belong to. Plugins authorize here instead of comparing
+// customer IDs, so organization members share the organization's wallet and
+// containers according to their role. Membership lives in the auth plugin's
+// organizations and organization_members tables; this package only reads
+// them.
+//
+// Customer requests act on the caller's personal account unless they send
+// the X-Organization-ID header.
+package access
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"go_framework/internal/db"
+	internaluuid "go_framework/internal/uuid"
+)
+
+// OrganizationHeader selects the organization a customer request acts for.
+const OrganizationHeader = "X-Organization-ID"
+
+// Organization roles
+const (
+	RoleOwner     = "owner"
+	RoleBilling   = "billing"
+	RoleDeveloper = "developer"
+	RoleViewer    = "viewer"
+)
+
+// Permission is an action on an account.
+type Permission string
+
+const (
+	ViewBilling      Permission = "billing:view"      // balance, transactions, invoices, usage
+	ManageBilling    Permission = "billing:manage"    // topups
+	ViewContainers   Permission = "containers:view"   // list and inspect containers
+	ManageContainers Permission = "containers:manage" // create, change, deploy and delete containers
+	ManageMembers    Permission = "members:manage"    // invitations, roles, organization settings
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleOwner:     {ViewBilling, ManageBilling, ViewContainers, ManageContainers, ManageMembers},
+	RoleBilling:   {ViewBilling, ManageBilling, ViewContainers},
+	RoleDeveloper: {ViewContainers, ManageContainers},
+	RoleViewer:    {ViewBilling, ViewContainers},
+}
+
+var (
+	ErrForbidden            = errors.New("access denied")
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrAccountUnavailable   = errors.New("account is suspended or closed")
+)
+
+// blockedStatuses are customers.status values of accounts nobody may act
+// on. The auth middleware enforces them for the caller's own account; an
+// organization account never signs in, so they are checked here.
+var blockedStatuses = map[string]bool{
+	"SUSPENDED": true,
+	"BANNED":    true,
+	"CLOSING":   true,
+	"CLOSED":    true,
+}
+
+// AccountUsable reports whether an account with the given customers.status
+// may be acted on.
+func AccountUsable(status string) bool {
+	return !blockedStatuses[status]
+}
+
+// ValidRole reports whether role is an organization role.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleAllows reports whether members with role may perform p.
+func RoleAllows(role string, p Permission) bool {
+	for _, allowed := range rolePermissions[role] {
+		if allowed == p {
+			return true
+		}
+	}
+	return false
+}
+
+type Checker struct {
+	db *gorm.DB
+}
+
+func NewChecker(gdb *gorm.DB) (*Checker, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &Checker{db: gdb}, nil
+}
+
+func NewCheckerFromDefault() (*Checker, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewChecker(gdb)
+}
+
+// Authorize checks customerID may perform p on accountID: their own
+// account, or an organization account where their role allows it and that
+// is not suspended or closed.
+func (c *Checker) Authorize(customerID, accountID string, p Permission) error {
+	if customerID == "" || accountID == "" {
+		return ErrForbidden
+	}
+	if customerID == accountID {
+		return nil
+	}
+	var rows []struct {
+		Role   string
+		Status string
+	}
+	if err := c.db.Raw(`
+		SELECT m.role, a.status FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		JOIN customers a ON a.id = o.account_id
+		WHERE o.account_id = ? AND m.customer_id = ?`, accountID, customerID).Scan(&rows).Error; err != nil {
+		return err
+	}
+	if len(rows) == 0 || !RoleAllows(rows[0].Role, p) {
+		return ErrForbidden
+	}
+	if !AccountUsable(rows[0].Status) {
+		return ErrAccountUnavailable
+	}
+	return nil
+}
+
+// ResolveAccount returns the account a request acts on: customerID itself
+// when organizationID is empty, else the organization's billing account.
+// Non-members get ErrOrganizationNotFound so organization IDs do not leak;
+// members of a suspended or closed organization get ErrAccountUnavailable.
+func (c *Checker) ResolveAccount(customerID, organizationID string, p Permission) (string, error) {
+	if customerID == "" {
+		return "", ErrForbidden
+	}
+	if organizationID == "" {
+		return customerID, nil
+	}
+	if !internaluuid.Valid(organizationID) {
+		return "", ErrOrganizationNotFound
+	}
+	var rows []struct {
+		AccountID string
+		Role      string
+		Status    string
+	}
+	if err := c.db.Raw(`
+		SELECT o.account_id, m.role, a.status FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		JOIN customers a ON a.id = o.account_id
+		WHERE o.id = ? AND m.customer_id = ?`, organizationID, customerID).Scan(&rows).Error; err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", ErrOrganizationNotFound
+	}
+	if !RoleAllows(rows[0].Role, p) {
+		return "", ErrForbidden
+	}
+	if !AccountUsable(rows[0].Status) {
+		return "", ErrAccountUnavailable
+	}
+	return rows[0].AccountID, nil
+}
+
+// Account resolves the account of a customer request (see ResolveAccount)
+// and writes the error response when it fails.
+func Account(c *gin.Context, p Permission) (string, bool) {
+	customerID := c.GetString("customer_id")
+	if customerID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return "", false
+	}
+	organizationID := strings.TrimSpace(c.GetHeader(OrganizationHeader))
+	if organizationID == "" {
+		return customerID, true
+	}
+	checker, err := NewCheckerFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return "", false
+	}
+	accountID, err := checker.ResolveAccount(customerID, organizationID, p)
+	if err != nil {
+		writeError(c, err)
+		return "", false
+	}
+	return accountID, true
+}
+
+// Require checks the signed-in customer may perform p on accountID, the
+// owner of the resource being accessed, and writes the error response when
+// not.
+func Require(c *gin.Context, accountID string, p Permission) bool {
+	customerID := c.GetString("customer_id")
+	if customerID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return false
+	}
+	if customerID == accountID {
+		return true
+	}
+	checker, err := NewCheckerFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return false
+	}
+	if err := checker.Authorize(customerID, accountID, p); err != nil {
+		writeError(c, err)
+		return false
+	}
+	return true
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrOrganizationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrForbidden), errors.Is(err, ErrAccountUnavailable):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,47 @@
+package access
+
+import "testing"
+
+func TestRoleAllows(t *testing.T) {
+	cases := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{RoleOwner, ManageMembers, true},
+		{RoleOwner, ManageBilling, true},
+		{RoleBilling, ManageBilling, true},
+		{RoleBilling, ViewContainers, true},
+		{RoleBilling, ManageContainers, false},
+		{RoleDeveloper, ManageContainers, true},
+		{RoleDeveloper, ViewBilling, false},
+		{RoleDeveloper, ManageMembers, false},
+		{RoleViewer, ViewBilling, true},
+		{RoleViewer, ViewContainers, true},
+		{RoleViewer, ManageBilling, false},
+		{RoleViewer, ManageContainers, false},
+		{"admin", ViewBilling, false},
+	}
+	for _, c := range cases {
+		if got := RoleAllows(c.role, c.perm); got != c.want {
+			t.Errorf("RoleAllows(%s, %s) = %v, want %v", c.role, c.perm, got, c.want)
+		}
+	}
+	if !ValidRole(RoleDeveloper) || ValidRole("admin") {
+		t.Errorf("ValidRole")
+	}
+}
+
+func TestAccountUsable(t *testing.T) {
+	for status, want := range map[string]bool{
+		"ACTIVE":    true,
+		"SUSPENDED": false,
+		"BANNED":    false,
+		"CLOSING":   false,
+		"CLOSED":    false,
+	} {
+		if got := AccountUsable(status); got != want {
+			t.Errorf("AccountUsable(%s) = %v, want %v", status, got, want)
+		}
+	}
+}
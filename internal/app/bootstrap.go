@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,10 +18,13 @@ import (
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
+	"go_framework/internal/access"
 	"go_framework/internal/db"
+	"go_framework/internal/idempotency"
 	"go_framework/internal/keydb"
 	"go_framework/internal/pluginloader"
 	"go_framework/internal/plugins"
+	"go_framework/internal/scheduler"
 	"go_framework/internal/storage"
 
 	"gorm.io/gorm"
@@ -88,6 +92,9 @@ func New(opts Options) (*App, error) {
 		log.Println("[INFO] KeyDB not configured (KEYDB_HOST/PORT missing), flash messages disabled")
 	}
 
+	// Expired Idempotency-Key records are purged by a background job.
+	idempotency.RegisterPurgeJob()
+
 	r := gin.Default()
 
 	// Configure CORS from environment variable `CORS_ALLOWED_ORIGINS`.
@@ -111,8 +118,8 @@ func New(opts Options) (*App, error) {
 
 		corsCfg := cors.Config{
 			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-			AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "Accept", "x-artywiz_service-access-token"},
-			ExposeHeaders:    []string{"Content-Length"},
+			AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "Accept", "x-artywiz_service-access-token", "X-CSRF-Token", idempotency.Header, access.OrganizationHeader},
+			ExposeHeaders:    []string{"Content-Length", idempotency.ReplayedHeader},
 			AllowCredentials: true,
 			MaxAge:           12 * time.Hour,
 		}
@@ -215,11 +222,12 @@ func New(opts Options) (*App, error) {
 	return app, nil
 }
 
-// Run starts the HTTP server.
+// Run starts the background scheduler and the HTTP server.
 func (a *App) Run() error {
 	if a == nil || a.router == nil {
 		return errors.New("app not initialized")
 	}
+	scheduler.Start(context.Background())
 	return a.router.Run()
 }
 
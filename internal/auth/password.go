@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported password hashing algorithms.
+const (
+	AlgoBcrypt   = "bcrypt"
+	AlgoArgon2id = "argon2id"
+)
+
+// BcryptMaxBytes is the longest password bcrypt accepts.
+const BcryptMaxBytes = 72
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Default argon2id parameters and the ranges accepted from the environment.
+const (
+	defaultArgon2Memory      = 64 * 1024 // KiB
+	defaultArgon2Iterations  = 3
+	defaultArgon2Parallelism = 2
+
+	minArgon2Memory     = 8 * 1024        // KiB
+	maxArgon2Memory     = 4 * 1024 * 1024 // KiB
+	maxArgon2Iterations = 64
+	maxArgon2Threads    = 255
+)
+
+// Argon2Params are the argon2id cost parameters encoded in every PHC string.
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// PasswordHasher hashes new passwords with the configured algorithm and
+// verifies hashes produced by any supported algorithm.
+type PasswordHasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// NewPasswordHasherFromEnv builds a hasher from AUTH_PASSWORD_* variables.
+// bcrypt stays the default so existing deployments keep their behaviour.
+func NewPasswordHasherFromEnv() *PasswordHasher {
+	algo := passwordAlgoFromEnv()
+	cost := getEnvInt("AUTH_BCRYPT_COST", bcrypt.DefaultCost)
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	// Out-of-range argon2 values fall back to the defaults: a zero or
+	// wrapped parameter would make every hash, and so every login, fail.
+	memory := getEnvInt("AUTH_ARGON2_MEMORY_KB", defaultArgon2Memory)
+	if memory < minArgon2Memory || memory > maxArgon2Memory {
+		memory = defaultArgon2Memory
+	}
+	iterations := getEnvInt("AUTH_ARGON2_ITERATIONS", defaultArgon2Iterations)
+	if iterations < 1 || iterations > maxArgon2Iterations {
+		iterations = defaultArgon2Iterations
+	}
+	parallelism := getEnvInt("AUTH_ARGON2_PARALLELISM", defaultArgon2Parallelism)
+	if parallelism < 1 || parallelism > maxArgon2Threads {
+		parallelism = defaultArgon2Parallelism
+	}
+	return &PasswordHasher{
+		Algorithm:  algo,
+		BcryptCost: cost,
+		Argon2: Argon2Params{
+			Memory:      uint32(memory),
+			Iterations:  uint32(iterations),
+			Parallelism: uint8(parallelism),
+			SaltLength:  16,
+			KeyLength:   32,
+		},
+	}
+}
+
+// passwordAlgoFromEnv returns AUTH_PASSWORD_ALGO, defaulting to bcrypt.
+func passwordAlgoFromEnv() string {
+	if strings.ToLower(getEnv("AUTH_PASSWORD_ALGO", AlgoBcrypt)) == AlgoArgon2id {
+		return AlgoArgon2id
+	}
+	return AlgoBcrypt
+}
+
+var (
+	defaultHasher     *PasswordHasher
+	defaultHasherOnce sync.Once
+)
+
+// DefaultPasswordHasher returns the process-wide hasher. It is built lazily so
+// that .env has been loaded by the time the configuration is read.
+func DefaultPasswordHasher() *PasswordHasher {
+	defaultHasherOnce.Do(func() { defaultHasher = NewPasswordHasherFromEnv() })
+	return defaultHasher
+}
+
+// Hash returns a PHC-style encoded hash of pw using the configured algorithm.
+// A password too long for bcrypt is a policy violation.
+func (h *PasswordHasher) Hash(pw string) (string, error) {
+	if h.Algorithm == AlgoArgon2id {
+		return h.hashArgon2id(pw)
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(pw), h.BcryptCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", fmt.Errorf("%w: must be at most %d bytes", ErrPasswordPolicy, BcryptMaxBytes)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Verify reports whether pw matches encoded. needsRehash is true when the
+// password matched but the hash was made with another algorithm or with
+// parameters different from the current configuration.
+func (h *PasswordHasher) Verify(encoded, pw string) (ok bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		got := argon2.IDKey([]byte(pw), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(got, key) != 1 {
+			return false, false, nil
+		}
+		stale := h.Algorithm != AlgoArgon2id ||
+			params.Memory != h.Argon2.Memory ||
+			params.Iterations != h.Argon2.Iterations ||
+			params.Parallelism != h.Argon2.Parallelism ||
+			uint32(len(key)) != h.Argon2.KeyLength
+		return true, stale, nil
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(pw)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrPasswordTooLong) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return true, true, nil
+		}
+		return true, h.Algorithm != AlgoBcrypt || cost != h.BcryptCost, nil
+	default:
+		return false, false, ErrUnknownHashFormat
+	}
+}
+
+func (h *PasswordHasher) hashArgon2id(pw string) (string, error) {
+	p := h.Argon2
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(pw), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// decodeArgon2id parses $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	var p Argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	for _, kv := range strings.Split(parts[3], ",") {
+		k, v, found := strings.Cut(kv, "=")
+		if !found {
+			return p, nil, nil, ErrUnknownHashFormat
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return p, nil, nil, ErrUnknownHashFormat
+		}
+		switch k {
+		case "m":
+			p.Memory = uint32(n)
+		case "t":
+			p.Iterations = uint32(n)
+		case "p":
+			p.Parallelism = uint8(n)
+		}
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	key, err := enc.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 || len(key) == 0 {
+		return p, nil, nil, ErrUnknownHashFormat
+	}
+	return p, salt, key, nil
+}
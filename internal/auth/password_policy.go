@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ErrPasswordPolicy is wrapped by every policy violation so handlers can map
+// it to a 400 response.
+var ErrPasswordPolicy = errors.New("password does not meet policy")
+
+// PasswordPolicy describes the rules enforced whenever a password is set.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// MaxBytes caps the UTF-8 length on top of MaxLength; bcrypt accepts at
+	// most BcryptMaxBytes.
+	MaxBytes int
+	// MinClasses is how many of lower, upper, digit and symbol must appear.
+	MinClasses int
+	// BlocklistFile is an optional local file with one entry per line. An
+	// entry is either a plain password (compared case-insensitively) or a
+	// SHA-1 hex digest, optionally followed by ":count" as in breach dumps.
+	BlocklistFile string
+
+	once      sync.Once
+	plain     map[string]struct{}
+	sha1Set   map[string]struct{}
+	loadError error
+}
+
+// builtinCommonPasswords is always checked, even without a blocklist file.
+var builtinCommonPasswords = []string{
+	"password", "password1", "password123", "12345678", "123456789", "1234567890",
+	"qwerty123", "qwertyuiop", "iloveyou", "admin123", "letmein1", "welcome1",
+	"11111111", "00000000", "abc12345", "passw0rd", "p@ssw0rd", "changeme",
+}
+
+// NewPasswordPolicyFromEnv builds a policy from AUTH_PASSWORD_* variables.
+// Under bcrypt, passwords are also capped at BcryptMaxBytes.
+func NewPasswordPolicyFromEnv() *PasswordPolicy {
+	p := &PasswordPolicy{
+		MinLength:     getEnvInt("AUTH_PASSWORD_MIN_LENGTH", 8),
+		MaxLength:     getEnvInt("AUTH_PASSWORD_MAX_LENGTH", 128),
+		MinClasses:    getEnvInt("AUTH_PASSWORD_MIN_CLASSES", 2),
+		BlocklistFile: os.Getenv("AUTH_PASSWORD_BLOCKLIST_FILE"),
+	}
+	if passwordAlgoFromEnv() == AlgoBcrypt {
+		p.MaxBytes = BcryptMaxBytes
+	}
+	return p
+}
+
+var (
+	defaultPolicy     *PasswordPolicy
+	defaultPolicyOnce sync.Once
+)
+
+// DefaultPasswordPolicy returns the process-wide password policy.
+func DefaultPasswordPolicy() *PasswordPolicy {
+	defaultPolicyOnce.Do(func() { defaultPolicy = NewPasswordPolicyFromEnv() })
+	return defaultPolicy
+}
+
+// ValidatePassword checks pw against the default policy.
+func ValidatePassword(pw string) error {
+	return DefaultPasswordPolicy().Validate(pw)
+}
+
+// Validate returns an error wrapping ErrPasswordPolicy when pw is rejected.
+func (p *PasswordPolicy) Validate(pw string) error {
+	n := len([]rune(pw))
+	if n < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrPasswordPolicy, p.MinLength)
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		return fmt.Errorf("%w: must be at most %d characters", ErrPasswordPolicy, p.MaxLength)
+	}
+	if p.MaxBytes > 0 && len(pw) > p.MaxBytes {
+		return fmt.Errorf("%w: must be at most %d bytes", ErrPasswordPolicy, p.MaxBytes)
+	}
+	if c := characterClasses(pw); c < p.MinClasses {
+		return fmt.Errorf("%w: must mix at least %d of lowercase, uppercase, digits and symbols", ErrPasswordPolicy, p.MinClasses)
+	}
+	if p.blocked(pw) {
+		return fmt.Errorf("%w: password is too common or has appeared in a data breach", ErrPasswordPolicy)
+	}
+	return nil
+}
+
+func characterClasses(pw string) int {
+	var lower, upper, digit, other bool
+	for _, r := range pw {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, b := range []bool{lower, upper, digit, other} {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+func (p *PasswordPolicy) blocked(pw string) bool {
+	p.once.Do(p.loadBlocklist)
+	if _, ok := p.plain[strings.ToLower(pw)]; ok {
+		return true
+	}
+	if len(p.sha1Set) > 0 {
+		sum := sha1.Sum([]byte(pw))
+		if _, ok := p.sha1Set[strings.ToUpper(hex.EncodeToString(sum[:]))]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PasswordPolicy) loadBlocklist() {
+	p.plain = make(map[string]struct{}, len(builtinCommonPasswords))
+	p.sha1Set = make(map[string]struct{})
+	for _, pw := range builtinCommonPasswords {
+		p.plain[pw] = struct{}{}
+	}
+	if p.BlocklistFile == "" {
+		return
+	}
+	f, err := os.Open(p.BlocklistFile)
+	if err != nil {
+		// A missing list must not lock everyone out; log and continue with
+		// the built-in entries.
+		p.loadError = err
+		log.Printf("auth: password blocklist %s not loaded: %v", p.BlocklistFile, err)
+		return
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if digest, _, _ := strings.Cut(line, ":"); isSHA1Hex(digest) {
+			p.sha1Set[strings.ToUpper(digest)] = struct{}{}
+			continue
+		}
+		p.plain[strings.ToLower(line)] = struct{}{}
+	}
+	if err := sc.Err(); err != nil {
+		p.loadError = err
+		log.Printf("auth: password blocklist %s read error: %v", p.BlocklistFile, err)
+	}
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testArgon2Hasher() *PasswordHasher {
+	return &PasswordHasher{
+		Algorithm:  AlgoArgon2id,
+		BcryptCost: 4,
+		Argon2:     Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	}
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	h := testArgon2Hasher()
+	enc, err := h.Hash("Correct-Horse-9")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.HasPrefix(enc, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected PHC string: %s", enc)
+	}
+	ok, rehash, err := h.Verify(enc, "Correct-Horse-9")
+	if err != nil || !ok || rehash {
+		t.Fatalf("verify = %v, %v, %v; want true, false, nil", ok, rehash, err)
+	}
+	if ok, _, _ := h.Verify(enc, "wrong"); ok {
+		t.Fatal("wrong password verified")
+	}
+
+	h.Argon2.Iterations = 2
+	if _, rehash, _ := h.Verify(enc, "Correct-Horse-9"); !rehash {
+		t.Fatal("expected rehash after parameter change")
+	}
+}
+
+func TestBcryptHashNeedsRehashUnderArgon2(t *testing.T) {
+	b := &PasswordHasher{Algorithm: AlgoBcrypt, BcryptCost: 4}
+	enc, err := b.Hash("Correct-Horse-9")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if ok, rehash, err := b.Verify(enc, "Correct-Horse-9"); err != nil || !ok || rehash {
+		t.Fatalf("bcrypt verify = %v, %v, %v", ok, rehash, err)
+	}
+	if ok, rehash, _ := testArgon2Hasher().Verify(enc, "Correct-Horse-9"); !ok || !rehash {
+		t.Fatalf("argon2 hasher on bcrypt hash = %v, %v; want true, true", ok, rehash)
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "blocklist.txt")
+	// "Summer2024!" as plain text and SHA-1("Winter2024!") as a breach entry.
+	content := "# comment\nSummer2024!\nFCB8F40140297C7D1E3464C53E1F9A8BC4DDBEDF:3\n"
+	if err := os.WriteFile(list, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := &PasswordPolicy{MinLength: 8, MaxLength: 64, MinClasses: 3, BlocklistFile: list}
+
+	cases := map[string]bool{
+		"short1A":          false,
+		"alllowercase":     false,
+		"Password123":      false, // built-in list is case-insensitive
+		"Winter2024!":      false,
+		"password123":      false,
+		"summer2024!":      false,
+		"Tr1cky-but-fine!": true,
+	}
+	for pw, want := range cases {
+		err := p.Validate(pw)
+		if (err == nil) != want {
+			t.Errorf("Validate(%q) = %v, want ok=%v", pw, err, want)
+		}
+		if err != nil && !errors.Is(err, ErrPasswordPolicy) {
+			t.Errorf("Validate(%q) error does not wrap ErrPasswordPolicy", pw)
+		}
+	}
+}
+
+func TestHasherFromEnvRejectsOutOfRangeArgon2Params(t *testing.T) {
+	t.Setenv("AUTH_ARGON2_MEMORY_KB", "0")
+	t.Setenv("AUTH_ARGON2_ITERATIONS", "-1")
+	t.Setenv("AUTH_ARGON2_PARALLELISM", "256") // would wrap to 0 as uint8
+	h := NewPasswordHasherFromEnv()
+	want := Argon2Params{Memory: defaultArgon2Memory, Iterations: defaultArgon2Iterations, Parallelism: defaultArgon2Parallelism, SaltLength: 16, KeyLength: 32}
+	if h.Argon2 != want {
+		t.Fatalf("argon2 params = %+v, want %+v", h.Argon2, want)
+	}
+
+	t.Setenv("AUTH_ARGON2_MEMORY_KB", "19456")
+	t.Setenv("AUTH_ARGON2_ITERATIONS", "2")
+	t.Setenv("AUTH_ARGON2_PARALLELISM", "1")
+	h = NewPasswordHasherFromEnv()
+	if h.Argon2.Memory != 19456 || h.Argon2.Iterations != 2 || h.Argon2.Parallelism != 1 {
+		t.Fatalf("argon2 params = %+v", h.Argon2)
+	}
+}
+
+func TestBcryptPolicyCountsBytes(t *testing.T) {
+	t.Setenv("AUTH_PASSWORD_ALGO", "")
+	p := NewPasswordPolicyFromEnv()
+	if p.MaxBytes != BcryptMaxBytes {
+		t.Fatalf("MaxBytes = %d, want %d", p.MaxBytes, BcryptMaxBytes)
+	}
+	// 40 characters, 80 bytes: within MaxLength but too long for bcrypt.
+	long := strings.Repeat("é", 38) + "A1"
+	if err := p.Validate(long); !errors.Is(err, ErrPasswordPolicy) {
+		t.Fatalf("Validate = %v, want ErrPasswordPolicy", err)
+	}
+	if err := p.Validate(strings.Repeat("é", 30) + "A1"); err != nil {
+		t.Fatalf("Validate 62 bytes = %v", err)
+	}
+
+	b := &PasswordHasher{Algorithm: AlgoBcrypt, BcryptCost: 4}
+	if _, err := b.Hash(long); !errors.Is(err, ErrPasswordPolicy) {
+		t.Fatalf("Hash = %v, want ErrPasswordPolicy", err)
+	}
+
+	t.Setenv("AUTH_PASSWORD_ALGO", AlgoArgon2id)
+	if p := NewPasswordPolicyFromEnv(); p.MaxBytes != 0 {
+		t.Fatalf("argon2id MaxBytes = %d, want 0", p.MaxBytes)
+	}
+}
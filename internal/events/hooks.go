@@ -0,0 +1,44 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// HookFunc is a synchronous handler that can veto or fail the operation that
+// triggered it by returning an error.
+type HookFunc func(ctx context.Context, payload interface{}) error
+
+type hookRegistry struct {
+	mu    sync.RWMutex
+	hooks map[string][]HookFunc
+}
+
+var defaultHooks = &hookRegistry{hooks: make(map[string][]HookFunc)}
+
+// RegisterHook registers a synchronous hook for an event. Hooks run in
+// registration order when RunHooks is called.
+func RegisterHook(event string, h HookFunc) {
+	defaultHooks.mu.Lock()
+	defaultHooks.hooks[event] = append(defaultHooks.hooks[event], h)
+	defaultHooks.mu.Unlock()
+}
+
+// RunHooks runs all hooks registered for event in the caller's goroutine and
+// stops at the first error. Use it when the caller must know that every
+// subscriber finished (e.g. coordinated teardown); use Publish otherwise.
+func RunHooks(ctx context.Context, event string, payload interface{}) error {
+	defaultHooks.mu.RLock()
+	hs := append([]HookFunc{}, defaultHooks.hooks[event]...)
+	defaultHooks.mu.RUnlock()
+
+	log.Printf("events.RunHooks: event=%s hooks=%d", event, len(hs))
+	for i, h := range hs {
+		if err := h(ctx, payload); err != nil {
+			return fmt.Errorf("hook %d for %s: %w", i, event, err)
+		}
+	}
+	return nil
+}
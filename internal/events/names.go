@@ -0,0 +1,67 @@
+package events
+
+import "time"
+
+// Customer lifecycle events shared between plugins. Plugins subscribe by
+// name so they do not need to import each other.
+const (
+	// CustomerCloseCheck runs (as hooks) before an account is closed; any
+	// error aborts the closure without side effects.
+	CustomerCloseCheck = "customer.close_check"
+	// CustomerClosing runs (as hooks) to tear down resources owned by the
+	// customer before PII is anonymised.
+	CustomerClosing = "customer.closing"
+	// CustomerClosed is published once the account has been anonymised.
+	CustomerClosed = "customer.closed"
+	// CustomerSuspended is published when a customer is suspended or banned;
+	// plugins stop billable resources and freeze payments.
+	CustomerSuspended = "customer.suspended"
+	// CustomerReinstated is published when a suspension or ban is lifted.
+	CustomerReinstated = "customer.reinstated"
+
+	// UsageOverdue runs (as hooks) when a customer's usage charges stay
+	// unpaid past the grace period; plugins stop billable resources. Billing
+	// marks the customer stopped only once every hook succeeded, so a failed
+	// stop is retried on the next meter run.
+	UsageOverdue = "billing.usage_overdue"
+	// UsageSettled is published once overdue usage has been paid; plugins
+	// resume what they stopped for UsageOverdue.
+	UsageSettled = "billing.usage_settled"
+)
+
+// CustomerEvent is the payload for customer lifecycle events.
+type CustomerEvent struct {
+	CustomerID string
+	Reason     string
+	// Status is the customer status after the transition, when relevant.
+	Status string
+}
+
+// Container lifecycle events raised by the node plugin.
+const (
+	// ContainerDeploying runs (as hooks) after a node is reserved and before
+	// the container is deployed; any error aborts the deploy.
+	ContainerDeploying = "container.deploying"
+	// ContainerDeployFailed is published when a deploy failed after the
+	// ContainerDeploying hooks ran: a hook vetoed it or the node failed.
+	ContainerDeployFailed = "container.deploy_failed"
+	// ContainerDeployed runs (as hooks) once a deploy succeeded and the
+	// container is RUNNING; failing hooks are retried, then the container is
+	// stopped and the deploy reports the error.
+	ContainerDeployed = "container.deployed"
+	// ContainerDeleted is published once a container has been deleted;
+	// billing refunds the unused part of what was paid for it.
+	ContainerDeleted = "container.deleted"
+)
+
+// ContainerEvent is the payload for container lifecycle events.
+type ContainerEvent struct {
+	ContainerID string
+	CustomerID  string
+	TemplateID  string
+	RegionCode  string
+	RamMB       int
+	CPUPercent  int
+	// At is when the event happened; set for ContainerDeleted.
+	At time.Time
+}
@@ -0,0 +1,170 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go_framework/internal/db"
+	"go_framework/internal/scheduler"
+
+	"gorm.io/gorm"
+)
+
+// PurgeJob is the scheduler name of the expired-key cleanup.
+const PurgeJob = "idempotency.purge"
+
+const defaultPurgeInterval = time.Hour
+
+// keyRow maps idempotency_keys.
+type keyRow struct {
+	Key            string     `gorm:"column:key;primaryKey"`
+	Fingerprint    string     `gorm:"column:fingerprint"`
+	Status         string     `gorm:"column:status"`
+	ResponseStatus *int       `gorm:"column:response_status"`
+	ContentType    *string    `gorm:"column:content_type"`
+	ResponseBody   []byte     `gorm:"column:response_body"`
+	LockedUntil    *time.Time `gorm:"column:locked_until"`
+	ExpiresAt      time.Time  `gorm:"column:expires_at"`
+	CreatedAt      time.Time  `gorm:"column:created_at"`
+}
+
+func (keyRow) TableName() string { return "idempotency_keys" }
+
+// DBStore keeps records in the idempotency_keys table.
+type DBStore struct {
+	db *gorm.DB
+}
+
+func NewDBStore(gdb *gorm.DB) (*DBStore, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &DBStore{db: gdb}, nil
+}
+
+func NewDBStoreFromDefault() (*DBStore, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewDBStore(gdb)
+}
+
+// Acquire inserts a PROCESSING row, or takes over one that expired or whose
+// lock went stale. The upsert is a single statement, so concurrent callers
+// cannot both win.
+func (s *DBStore) Acquire(ctx context.Context, key, fingerprint string, lockUntil, expiresAt time.Time) (*Record, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		now := time.Now()
+		res := s.db.WithContext(ctx).Exec(`
+			INSERT INTO idempotency_keys (key, fingerprint, status, locked_until, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET
+				fingerprint = EXCLUDED.fingerprint,
+				status = EXCLUDED.status,
+				response_status = NULL,
+				content_type = NULL,
+				response_body = NULL,
+				locked_until = EXCLUDED.locked_until,
+				expires_at = EXCLUDED.expires_at,
+				created_at = EXCLUDED.created_at
+			WHERE idempotency_keys.expires_at <= ?
+				OR (idempotency_keys.status = ? AND idempotency_keys.locked_until <= ?)`,
+			key, fingerprint, StatusProcessing, lockUntil, expiresAt, now,
+			now, StatusProcessing, now)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 1 {
+			return nil, nil
+		}
+
+		var row keyRow
+		if err := s.db.WithContext(ctx).Where("key = ?", key).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue // released since the insert; try again
+			}
+			return nil, err
+		}
+		return row.record(), nil
+	}
+	return &Record{Fingerprint: fingerprint, Status: StatusProcessing}, nil
+}
+
+func (s *DBStore) Extend(ctx context.Context, key string, lockUntil time.Time) error {
+	return s.db.WithContext(ctx).Model(&keyRow{}).
+		Where("key = ? AND status = ?", key, StatusProcessing).
+		Update("locked_until", lockUntil).Error
+}
+
+func (s *DBStore) Complete(ctx context.Context, key string, resp Response, expiresAt time.Time) error {
+	return s.db.WithContext(ctx).Model(&keyRow{}).
+		Where("key = ? AND status = ?", key, StatusProcessing).
+		Updates(map[string]interface{}{
+			"status":          StatusCompleted,
+			"response_status": resp.Status,
+			"content_type":    resp.ContentType,
+			"response_body":   resp.Body,
+			"locked_until":    nil,
+			"expires_at":      expiresAt,
+		}).Error
+}
+
+func (s *DBStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).
+		Where("key = ? AND status = ?", key, StatusProcessing).
+		Delete(&keyRow{}).Error
+}
+
+// Purge deletes expired records.
+func (s *DBStore) Purge(ctx context.Context, now time.Time) (int64, error) {
+	res := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&keyRow{})
+	return res.RowsAffected, res.Error
+}
+
+func (r keyRow) record() *Record {
+	rec := &Record{Fingerprint: r.Fingerprint, Status: r.Status}
+	if r.Status == StatusCompleted && r.ResponseStatus != nil {
+		rec.Response = &Response{Status: *r.ResponseStatus, Body: r.ResponseBody}
+		if r.ContentType != nil {
+			rec.Response.ContentType = *r.ContentType
+		}
+	}
+	return rec
+}
+
+// RegisterPurgeJob schedules Purge every IDEMPOTENCY_PURGE_INTERVAL
+// (default 1h) when the DB store is in use; KeyDB expires keys itself.
+func RegisterPurgeJob() {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("IDEMPOTENCY_STORE")), "keydb") {
+		return
+	}
+	interval := defaultPurgeInterval
+	if v := strings.TrimSpace(os.Getenv("IDEMPOTENCY_PURGE_INTERVAL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		}
+	}
+	scheduler.Register(scheduler.Job{
+		Name:     PurgeJob,
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			store, err := NewDBStoreFromDefault()
+			if err != nil {
+				return err
+			}
+			n, err := store.Purge(ctx, time.Now())
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("idempotency: purged expired=%d", n)
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,343 @@
+// Package idempotency makes retried mutating requests safe.
+//
+// Routes wrapped with Middleware honour an Idempotency-Key header: the first
+// request with a key runs and its response is stored for IDEMPOTENCY_TTL
+// (default 24h). A retry with the same key and body gets the stored response
+// back instead of running the handler again; the same key with a different
+// body is rejected with 422. A duplicate that arrives while the first request
+// is still running waits for it and replays its response, so concurrent
+// duplicates are serialised. Requests without the header are not affected.
+//
+// Keys are scoped to the caller (admin or customer), the account the request
+// acts for (X-Organization-ID) and the route, so two customers, or one
+// customer's personal and organization accounts, can never replay each
+// other's responses. 5xx responses and
+// panics are not stored; the key is freed and the client may retry. While a
+// request runs its lock is renewed, so a slow handler (e.g. a deploy) is
+// never taken over by a retry. Bodies over 1 MiB are refused with 413.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/access"
+)
+
+// Header is the request header carrying the client's key.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set on responses served from a stored result.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// Record statuses
+const (
+	StatusProcessing = "PROCESSING"
+	StatusCompleted  = "COMPLETED"
+)
+
+const (
+	defaultTTL = 24 * time.Hour
+	// waitTimeout is how long a concurrent duplicate waits for the first
+	// request before giving up with 409.
+	waitTimeout  = 30 * time.Second
+	pollInterval = 100 * time.Millisecond
+	maxKeyLength = 255
+	maxBodyBytes = 1 << 20
+)
+
+// lockTimeout bounds how long a PROCESSING record blocks its key once its
+// request stops renewing it, so a request that died with the process does
+// not lock it until the TTL. Running requests renew it every third of it.
+var lockTimeout = 5 * time.Minute
+
+var (
+	ErrStoreUnavailable = errors.New("idempotency store unavailable")
+	ErrKeyTooLong       = errors.New("Idempotency-Key must be at most 255 characters")
+	ErrKeyReused        = errors.New("Idempotency-Key was already used with a different request")
+	ErrInProgress       = errors.New("a request with this Idempotency-Key is still in progress")
+	ErrBodyTooLarge     = errors.New("request body too large for an idempotent request")
+)
+
+// Response is a stored handler result.
+type Response struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// Record is the state of a key owned by an earlier request.
+type Record struct {
+	Fingerprint string    `json:"fingerprint"`
+	Status      string    `json:"status"`
+	Response    *Response `json:"response,omitempty"`
+}
+
+// Store persists idempotency records. Acquire must be atomic: exactly one
+// caller gets a nil record for a free (absent, expired or stale) key.
+type Store interface {
+	// Acquire claims key for fingerprint until lockUntil, or returns the
+	// record that currently owns it.
+	Acquire(ctx context.Context, key, fingerprint string, lockUntil, expiresAt time.Time) (*Record, error)
+	// Extend renews the lock of a key that is still PROCESSING.
+	Extend(ctx context.Context, key string, lockUntil time.Time) error
+	// Complete stores the response of the request that acquired key.
+	Complete(ctx context.Context, key string, resp Response, expiresAt time.Time) error
+	// Release frees a key whose request failed, so a retry runs again.
+	Release(ctx context.Context, key string) error
+}
+
+// TTL is how long responses are kept for replay: IDEMPOTENCY_TTL, default
+// 24h.
+func TTL() time.Duration {
+	if v := strings.TrimSpace(os.Getenv("IDEMPOTENCY_TTL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultTTL
+}
+
+var (
+	storeMu       sync.Mutex
+	overrideStore Store
+)
+
+// SetStore replaces the store chosen from IDEMPOTENCY_STORE; nil restores
+// the default. Intended for tests.
+func SetStore(s Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	overrideStore = s
+}
+
+// DefaultStore returns the store selected by IDEMPOTENCY_STORE: "db"
+// (default) or "keydb".
+func DefaultStore() (Store, error) {
+	storeMu.Lock()
+	s := overrideStore
+	storeMu.Unlock()
+	if s != nil {
+		return s, nil
+	}
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("IDEMPOTENCY_STORE")), "keydb") {
+		return NewKeyDBStoreFromDefault()
+	}
+	return NewDBStoreFromDefault()
+}
+
+// Middleware enforces Idempotency-Key semantics on the route it wraps. Put
+// it after the auth middleware so the key is scoped to the caller.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(Header))
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrKeyTooLong.Error()})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBodyBytes+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(body) > maxBodyBytes {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": ErrBodyTooLarge.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		store, err := DefaultStore()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": ErrStoreUnavailable.Error()})
+			return
+		}
+
+		scoped := scopedKey(c, key)
+		fp := fingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		rec, err := acquire(c.Request.Context(), store, scoped, fp)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, ErrInProgress):
+				status = http.StatusConflict
+			case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+				status = http.StatusRequestTimeout
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if rec != nil {
+			if rec.Fingerprint != fp {
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": ErrKeyReused.Error()})
+				return
+			}
+			replay(c, rec.Response)
+			return
+		}
+
+		rw := &recorder{ResponseWriter: c.Writer}
+		c.Writer = rw
+		stopRenew := renewLock(store, scoped)
+		returned := false
+		defer func() {
+			stopRenew()
+			// Handler panicked: free the key for a retry.
+			if !returned {
+				release(store, scoped)
+			}
+		}()
+
+		c.Next()
+		returned = true
+		stopRenew()
+
+		status := rw.Status()
+		if status >= http.StatusInternalServerError {
+			release(store, scoped)
+			return
+		}
+		resp := Response{
+			Status:      status,
+			ContentType: rw.Header().Get("Content-Type"),
+			Body:        rw.body.Bytes(),
+		}
+		// The handler's work is committed; if the response cannot be stored
+		// the key stays PROCESSING until its lock goes stale rather than
+		// being freed for a retry that would run it again.
+		if err := store.Complete(context.Background(), scoped, resp, time.Now().Add(TTL())); err != nil {
+			log.Printf("idempotency: complete key=%s: %v", scoped, err)
+		}
+	}
+}
+
+func release(store Store, key string) {
+	if err := store.Release(context.Background(), key); err != nil {
+		log.Printf("idempotency: release key=%s: %v", key, err)
+	}
+}
+
+// renewLock extends key's lock every third of lockTimeout until the returned
+// stop function is called; stop may be called more than once.
+func renewLock(store Store, key string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := store.Extend(context.Background(), key, time.Now().Add(lockTimeout)); err != nil {
+					log.Printf("idempotency: extend key=%s: %v", key, err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// acquire claims key, or waits while another request holds it and returns
+// its completed record.
+func acquire(ctx context.Context, store Store, key, fp string) (*Record, error) {
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		now := time.Now()
+		rec, err := store.Acquire(ctx, key, fp, now.Add(lockTimeout), now.Add(TTL()))
+		if err != nil {
+			return nil, err
+		}
+		if rec == nil || rec.Fingerprint != fp || rec.Status == StatusCompleted {
+			return rec, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrInProgress
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func replay(c *gin.Context, resp *Response) {
+	if resp == nil {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrInProgress.Error()})
+		return
+	}
+	c.Header(ReplayedHeader, "true")
+	contentType := resp.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Data(resp.Status, contentType, resp.Body)
+	c.Abort()
+}
+
+// scopedKey namespaces the client key by caller, account and route.
+func scopedKey(c *gin.Context, key string) string {
+	actor := "anon"
+	if id := c.GetString("admin_id"); id != "" {
+		actor = "admin:" + id
+	} else if id := c.GetString("customer_id"); id != "" {
+		actor = "customer:" + id
+	}
+	if org := strings.TrimSpace(c.GetHeader(access.OrganizationHeader)); org != "" {
+		actor += "/org:" + org
+	}
+	return actor + "|" + c.Request.Method + " " + c.FullPath() + "|" + key
+}
+
+// fingerprint identifies a request by method, path and body.
+func fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(path))
+	h.Write([]byte{'\n'})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder copies the response body while writing it through.
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
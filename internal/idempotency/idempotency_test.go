@@ -0,0 +1,321 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/access"
+)
+
+// memStore is an in-process Store with the same claim semantics as DBStore.
+type memStore struct {
+	mu   sync.Mutex
+	recs map[string]*memRecord
+}
+
+type memRecord struct {
+	Record
+	lockUntil, expiresAt time.Time
+}
+
+func newMemStore() *memStore { return &memStore{recs: map[string]*memRecord{}} }
+
+func (s *memStore) Acquire(_ context.Context, key, fp string, lockUntil, expiresAt time.Time) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	r, ok := s.recs[key]
+	if !ok || !r.expiresAt.After(now) || (r.Status == StatusProcessing && !r.lockUntil.After(now)) {
+		s.recs[key] = &memRecord{Record: Record{Fingerprint: fp, Status: StatusProcessing}, lockUntil: lockUntil, expiresAt: expiresAt}
+		return nil, nil
+	}
+	out := r.Record
+	return &out, nil
+}
+
+func (s *memStore) Extend(_ context.Context, key string, lockUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.recs[key]; ok && r.Status == StatusProcessing {
+		r.lockUntil = lockUntil
+	}
+	return nil
+}
+
+func (s *memStore) Complete(_ context.Context, key string, resp Response, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.recs[key]; ok && r.Status == StatusProcessing {
+		r.Status, r.Response, r.expiresAt = StatusCompleted, &resp, expiresAt
+	}
+	return nil
+}
+
+func (s *memStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.recs[key]; ok && r.Status == StatusProcessing {
+		delete(s.recs, key)
+	}
+	return nil
+}
+
+// failingCompleteStore cannot store responses.
+type failingCompleteStore struct{ *memStore }
+
+func (s failingCompleteStore) Complete(context.Context, string, Response, time.Time) error {
+	return errors.New("store down")
+}
+
+func newTestRouter(t *testing.T, handler gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+	return newTestRouterWithStore(t, newMemStore(), handler)
+}
+
+func newTestRouterWithStore(t *testing.T, store Store, handler gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	SetStore(store)
+	t.Cleanup(func() { SetStore(nil) })
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-Test-Customer"); id != "" {
+			c.Set("customer_id", id)
+		}
+		c.Next()
+	})
+	r.POST("/topup", Middleware(), handler)
+	return r
+}
+
+func send(r http.Handler, key, customer, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/topup", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	if customer != "" {
+		req.Header.Set("X-Test-Customer", customer)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddlewareReplaysSameRequest(t *testing.T) {
+	var calls int32
+	r := newTestRouter(t, func(c *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		c.JSON(http.StatusCreated, gin.H{"call": n})
+	})
+
+	first := send(r, "k1", "c1", `{"amount":100}`)
+	second := send(r, "k1", "c1", `{"amount":100}`)
+	if calls != 1 {
+		t.Fatalf("handler ran %d times", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %q, want %d %q", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(ReplayedHeader) != "true" || first.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("replayed header first=%q second=%q", first.Header().Get(ReplayedHeader), second.Header().Get(ReplayedHeader))
+	}
+}
+
+func TestMiddlewareRejectsDifferentBody(t *testing.T) {
+	r := newTestRouter(t, func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+
+	send(r, "k1", "c1", `{"amount":100}`)
+	if w := send(r, "k1", "c1", `{"amount":500}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key status = %d", w.Code)
+	}
+}
+
+func TestMiddlewareScopesKeysByCaller(t *testing.T) {
+	var calls int32
+	r := newTestRouter(t, func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	send(r, "k1", "c1", `{}`)
+	send(r, "k1", "c2", `{}`)
+	send(r, "", "c1", `{}`)
+	send(r, "", "c1", `{}`)
+	if calls != 4 {
+		t.Errorf("handler ran %d times, want 4", calls)
+	}
+}
+
+func TestMiddlewareScopesKeysByOrganization(t *testing.T) {
+	var calls int32
+	r := newTestRouter(t, func(c *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		c.JSON(http.StatusCreated, gin.H{"call": n})
+	})
+
+	sendFor := func(org string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/topup", strings.NewReader(`{"amount":100}`))
+		req.Header.Set(Header, "k1")
+		req.Header.Set("X-Test-Customer", "c1")
+		if org != "" {
+			req.Header.Set(access.OrganizationHeader, org)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	sendFor("")
+	if w := sendFor("org-1"); w.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("organization request replayed the personal one: %s", w.Body)
+	}
+	if w := sendFor("org-1"); w.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry for the organization was not replayed")
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
+
+func TestMiddlewareFreesKeyAfterServerError(t *testing.T) {
+	var calls int32
+	r := newTestRouter(t, func(c *gin.Context) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	send(r, "k1", "c1", `{}`)
+	if w := send(r, "k1", "c1", `{}`); w.Code != http.StatusOK || calls != 2 {
+		t.Errorf("retry after 500: status=%d calls=%d", w.Code, calls)
+	}
+}
+
+func TestMiddlewareSerialisesConcurrentDuplicates(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	r := newTestRouter(t, func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"ok": true})
+	})
+
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = send(r, "k1", "c1", `{}`).Code
+		}(i)
+	}
+	time.Sleep(3 * pollInterval)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times", calls)
+	}
+	for i, code := range codes {
+		if code != http.StatusCreated {
+			t.Errorf("request %d status = %d", i, code)
+		}
+	}
+}
+
+func TestMiddlewareRejectsLongKey(t *testing.T) {
+	r := newTestRouter(t, func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+	if w := send(r, strings.Repeat("k", maxKeyLength+1), "c1", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d", w.Code)
+	}
+}
+
+func TestMiddlewareKeepsKeyWhenCompleteFails(t *testing.T) {
+	mem := newMemStore()
+	r := newTestRouterWithStore(t, failingCompleteStore{mem}, func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	if w := send(r, "k1", "c1", `{}`); w.Code != http.StatusCreated {
+		t.Fatalf("first status = %d", w.Code)
+	}
+	// The handler's work is done; the key must stay claimed so a retry
+	// waits for the lock to go stale instead of running it again.
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+	if len(mem.recs) != 1 {
+		t.Fatalf("key was released after a failed complete")
+	}
+	for _, rec := range mem.recs {
+		if rec.Status != StatusProcessing {
+			t.Errorf("status = %s", rec.Status)
+		}
+	}
+}
+
+func TestMiddlewareFreesKeyAfterPanic(t *testing.T) {
+	var calls int32
+	r := newTestRouter(t, func(c *gin.Context) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	func() {
+		defer func() { _ = recover() }()
+		send(r, "k1", "c1", `{}`)
+	}()
+	if w := send(r, "k1", "c1", `{}`); w.Code != http.StatusOK || calls != 2 {
+		t.Errorf("retry after panic: status=%d calls=%d", w.Code, calls)
+	}
+}
+
+func TestMiddlewareRenewsLockOfSlowRequest(t *testing.T) {
+	saved := lockTimeout
+	lockTimeout = 60 * time.Millisecond
+	t.Cleanup(func() { lockTimeout = saved })
+
+	var calls int32
+	r := newTestRouter(t, func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(5 * lockTimeout)
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	first := make(chan int)
+	go func() { first <- send(r, "k1", "c1", `{}`).Code }()
+	time.Sleep(2 * lockTimeout)
+	if w := send(r, "k1", "c1", `{}`); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("duplicate during slow request: status=%d replayed=%q", w.Code, w.Header().Get(ReplayedHeader))
+	}
+	if code := <-first; code != http.StatusCreated || calls != 1 {
+		t.Errorf("first status=%d calls=%d", code, calls)
+	}
+}
+
+func TestMiddlewareRejectsOversizedBody(t *testing.T) {
+	var calls int32
+	r := newTestRouter(t, func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	if w := send(r, "k1", "c1", strings.Repeat("x", maxBodyBytes+1)); w.Code != http.StatusRequestEntityTooLarge || calls != 0 {
+		t.Errorf("oversized body: status=%d calls=%d", w.Code, calls)
+	}
+	if w := send(r, "k2", "c1", strings.Repeat("x", maxBodyBytes)); w.Code != http.StatusOK {
+		t.Errorf("body at the limit: status=%d", w.Code)
+	}
+}
@@ -0,0 +1,106 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go_framework/internal/keydb"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyDBStore keeps records in KeyDB, letting key expiry replace the purge
+// job. Key pattern: idem:<scoped key>
+type KeyDBStore struct {
+	client *redis.Client
+}
+
+func NewKeyDBStore(client *redis.Client) (*KeyDBStore, error) {
+	if client == nil {
+		return nil, errors.New("KeyDB client not initialized")
+	}
+	return &KeyDBStore{client: client}, nil
+}
+
+func NewKeyDBStoreFromDefault() (*KeyDBStore, error) {
+	return NewKeyDBStore(keydb.Client)
+}
+
+// Acquire sets the key only if absent; a PROCESSING entry expires at
+// lockUntil, so a request lost with its process frees the key.
+func (s *KeyDBStore) Acquire(ctx context.Context, key, fingerprint string, lockUntil, expiresAt time.Time) (*Record, error) {
+	data, err := json.Marshal(Record{Fingerprint: fingerprint, Status: StatusProcessing})
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		ok, err := s.client.SetNX(ctx, redisKey(key), data, time.Until(lockUntil)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return nil, nil
+		}
+		val, err := s.client.Get(ctx, redisKey(key)).Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				continue // expired or released since SETNX; try again
+			}
+			return nil, err
+		}
+		var rec Record
+		if err := json.Unmarshal(val, &rec); err != nil {
+			return nil, err
+		}
+		return &rec, nil
+	}
+	return &Record{Fingerprint: fingerprint, Status: StatusProcessing}, nil
+}
+
+// Extend pushes back the expiry of a PROCESSING entry; completed entries
+// keep their TTL.
+func (s *KeyDBStore) Extend(ctx context.Context, key string, lockUntil time.Time) error {
+	val, err := s.client.Get(ctx, redisKey(key)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		return err
+	}
+	var rec Record
+	if err := json.Unmarshal(val, &rec); err != nil {
+		return err
+	}
+	if rec.Status != StatusProcessing {
+		return nil
+	}
+	return s.client.Expire(ctx, redisKey(key), time.Until(lockUntil)).Err()
+}
+
+func (s *KeyDBStore) Complete(ctx context.Context, key string, resp Response, expiresAt time.Time) error {
+	val, err := s.client.Get(ctx, redisKey(key)).Bytes()
+	if err != nil {
+		return err
+	}
+	var rec Record
+	if err := json.Unmarshal(val, &rec); err != nil {
+		return err
+	}
+	rec.Status = StatusCompleted
+	rec.Response = &resp
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisKey(key), data, time.Until(expiresAt)).Err()
+}
+
+func (s *KeyDBStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, redisKey(key)).Err()
+}
+
+func redisKey(key string) string {
+	return "idem:" + key
+}
@@ -80,3 +80,48 @@ func (c *ConfirmMailable) Data() map[string]interface{} {
 func (c *ConfirmMailable) From() (string, string) {
 	return "", ""
 }
+
+// TemplateMailable is a generic Mailable for plugins that render their own
+// template under templates/email.
+type TemplateMailable struct {
+	subject      string
+	templateBase string
+	data         map[string]interface{}
+	attachments  []Attachment
+}
+
+// NewTemplateMailable builds a mailable for templateBase (without extension).
+func NewTemplateMailable(subject, templateBase string, data map[string]interface{}) *TemplateMailable {
+	return &TemplateMailable{subject: subject, templateBase: templateBase, data: data}
+}
+
+func (t *TemplateMailable) Subject() string {
+	return t.subject
+}
+
+func (t *TemplateMailable) TemplateBase() string {
+	return t.templateBase
+}
+
+func (t *TemplateMailable) Data() map[string]interface{} {
+	return t.data
+}
+
+func (t *TemplateMailable) From() (string, string) {
+	return "", ""
+}
+
+// WithAttachments adds files to the mail.
+func (t *TemplateMailable) WithAttachments(files ...Attachment) *TemplateMailable {
+	t.attachments = append(t.attachments, files...)
+	return t
+}
+
+func (t *TemplateMailable) Attachments() []Attachment {
+	return t.attachments
+}
+
+// QueueTemplate renders templateBase with data and sends it asynchronously.
+func QueueTemplate(toEmail, subject, templateBase string, data map[string]interface{}) {
+	NewMailer().Queue(toEmail, NewTemplateMailable(subject, templateBase, data))
+}
@@ -3,11 +3,13 @@ package mail
 import (
 	"bytes"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"html/template"
 	"net/smtp"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,6 +23,19 @@ type Mailable interface {
 	From() (email string, name string)
 }
 
+// Attachment is a file sent along with a mail.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// AttachmentMailable is implemented by mailables that carry files.
+type AttachmentMailable interface {
+	Mailable
+	Attachments() []Attachment
+}
+
 type Mailer struct {
 	FromEmail string
 	FromName  string
@@ -115,6 +130,49 @@ func (m *Mailer) renderParts(base string, data map[string]interface{}) (htmlPart
 	return htmlBuf.Bytes(), textBuf.Bytes(), nil
 }
 
+// buildMessage assembles the MIME message: text and HTML alternatives, wrapped
+// in multipart/mixed when there are attachments.
+func buildMessage(fromName, fromEmail, toEmail, subject string, textPart, htmlPart []byte, attachments []Attachment) []byte {
+	msg := bytes.Buffer{}
+	msg.WriteString(fmt.Sprintf("From: %s <%s>\r\n", fromName, fromEmail))
+	msg.WriteString(fmt.Sprintf("To: %s\r\n", toEmail))
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	if len(attachments) > 0 {
+		msg.WriteString("Content-Type: multipart/mixed; boundary=mixed42\r\n")
+		msg.WriteString("\r\n--mixed42\r\n")
+	}
+	msg.WriteString("Content-Type: multipart/alternative; boundary=boundary42\r\n")
+	msg.WriteString("\r\n--boundary42\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.Write(textPart)
+	msg.WriteString("\r\n--boundary42\r\n")
+	msg.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+	msg.Write(htmlPart)
+	msg.WriteString("\r\n--boundary42--\r\n")
+	for _, a := range attachments {
+		ct := a.ContentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		name := strings.NewReplacer(`"`, "", "\r", "", "\n", "").Replace(a.Filename)
+		msg.WriteString("\r\n--mixed42\r\n")
+		msg.WriteString(fmt.Sprintf("Content-Type: %s; name=\"%s\"\r\n", ct, name))
+		msg.WriteString("Content-Transfer-Encoding: base64\r\n")
+		msg.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s\"\r\n\r\n", name))
+		enc := base64.StdEncoding.EncodeToString(a.Data)
+		for len(enc) > 76 {
+			msg.WriteString(enc[:76] + "\r\n")
+			enc = enc[76:]
+		}
+		msg.WriteString(enc + "\r\n")
+	}
+	if len(attachments) > 0 {
+		msg.WriteString("\r\n--mixed42--\r\n")
+	}
+	return msg.Bytes()
+}
+
 func (m *Mailer) Send(toEmail string, mail Mailable) error {
 	htmlPart, textPart, err := m.renderParts(mail.TemplateBase(), mail.Data())
 	if err != nil {
@@ -136,20 +194,11 @@ func (m *Mailer) Send(toEmail string, mail Mailable) error {
 
 	addr, auth, tlsCfg, useTLS, useStartTLS := smtpAuth()
 
-	// Build multipart message
-	msg := bytes.Buffer{}
-	msg.WriteString(fmt.Sprintf("From: %s <%s>\r\n", fromName, fromEmail))
-	msg.WriteString(fmt.Sprintf("To: %s\r\n", toEmail))
-	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
-	msg.WriteString("MIME-Version: 1.0\r\n")
-	msg.WriteString("Content-Type: multipart/alternative; boundary=boundary42\r\n")
-	msg.WriteString("\r\n--boundary42\r\n")
-	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
-	msg.Write(textPart)
-	msg.WriteString("\r\n--boundary42\r\n")
-	msg.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
-	msg.Write(htmlPart)
-	msg.WriteString("\r\n--boundary42--\r\n")
+	var attachments []Attachment
+	if am, ok := mail.(AttachmentMailable); ok {
+		attachments = am.Attachments()
+	}
+	msg := buildMessage(fromName, fromEmail, toEmail, subject, textPart, htmlPart, attachments)
 
 	// send
 	var client *smtp.Client
@@ -198,7 +247,7 @@ func (m *Mailer) Send(toEmail string, mail Mailable) error {
 		return err
 	}
 	defer w.Close()
-	if _, err := w.Write(msg.Bytes()); err != nil {
+	if _, err := w.Write(msg); err != nil {
 		return err
 	}
 
@@ -127,3 +127,33 @@ func TestMailerSendWithFakeSMTP(t *testing.T) {
 		t.Fatalf("Send failed: %v", err)
 	}
 }
+
+func TestBuildMessageWithAttachment(t *testing.T) {
+	pdf := []byte(strings.Repeat("%PDF-1.4 ", 20))
+	msg := string(buildMessage("Billing", "billing@example.com", "to@example.com", "Your receipt",
+		[]byte("text body"), []byte("<p>html body</p>"),
+		[]Attachment{{Filename: "INV-2026-000001.pdf", ContentType: "application/pdf", Data: pdf}}))
+
+	for _, want := range []string{
+		"Content-Type: multipart/mixed; boundary=mixed42",
+		"Content-Type: multipart/alternative; boundary=boundary42",
+		"text body",
+		"<p>html body</p>",
+		`Content-Disposition: attachment; filename="INV-2026-000001.pdf"`,
+		"--mixed42--",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message missing %q", want)
+		}
+	}
+	for _, line := range strings.Split(msg, "\r\n") {
+		if len(line) > 998 {
+			t.Fatalf("line exceeds SMTP limit: %d", len(line))
+		}
+	}
+
+	plain := string(buildMessage("A", "a@example.com", "b@example.com", "s", []byte("t"), []byte("h"), nil))
+	if strings.Contains(plain, "mixed42") {
+		t.Error("message without attachments should not be multipart/mixed")
+	}
+}
@@ -0,0 +1,351 @@
+// Package money provides exact fixed-point amounts for billing.
+//
+// Amounts are stored as integer minor units (1/100 of the currency unit),
+// matching the DECIMAL(15,2) columns used by the billing tables. Parsing never
+// goes through binary floating point, and every operation that can produce a
+// fraction of a minor unit takes an explicit RoundingMode.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal places kept by Amount.
+const Scale = 2
+
+const unit = 100 // 10^Scale
+
+var (
+	ErrInvalidAmount = errors.New("money: invalid amount")
+	ErrTooPrecise    = errors.New("money: more than 2 decimal places")
+	ErrOverflow      = errors.New("money: amount out of range")
+)
+
+// RoundingMode selects how fractions of a minor unit are resolved.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds halves away from zero ("commercial" rounding). It is
+	// the default for fees, prices and taxes.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds halves to the nearest even minor unit.
+	RoundHalfEven
+	// RoundDown truncates toward zero; use it when the platform must never
+	// give away more than owed (e.g. pro-rated refunds).
+	RoundDown
+	// RoundUp rounds away from zero.
+	RoundUp
+)
+
+// Amount is a signed money amount in minor units.
+type Amount int64
+
+// Zero is the zero amount.
+const Zero Amount = 0
+
+// FromMinor returns an Amount of n minor units.
+func FromMinor(n int64) Amount { return Amount(n) }
+
+// FromMajor returns an Amount of n whole currency units.
+func FromMajor(n int64) Amount { return Amount(n * unit) }
+
+// FromFloat converts a float with the given rounding. It exists for legacy
+// inputs only; prefer Parse for anything user-supplied.
+func FromFloat(f float64, mode RoundingMode) (Amount, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrInvalidAmount
+	}
+	// strconv gives the shortest decimal that round-trips, so 0.1 becomes
+	// "0.1" rather than 0.1000000000000000055...
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	if !ok {
+		return 0, ErrInvalidAmount
+	}
+	return fromRat(r.Mul(r, big.NewRat(unit, 1)), mode)
+}
+
+// Parse reads a decimal string such as "1500", "-12.5" or "0.05". More than
+// Scale decimal places is an error rather than a silent rounding.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+	neg := false
+	switch s[0] {
+	case '-':
+		neg = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+	intPart, frac, hasDot := strings.Cut(s, ".")
+	if intPart == "" && (!hasDot || frac == "") {
+		return 0, ErrInvalidAmount
+	}
+	if !digitsOnly(intPart) || !digitsOnly(frac) {
+		return 0, ErrInvalidAmount
+	}
+	// Trailing zeros beyond the scale carry no value ("1.500").
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > Scale {
+		return 0, ErrTooPrecise
+	}
+	frac += strings.Repeat("0", Scale-len(frac))
+	if intPart == "" {
+		intPart = "0"
+	}
+	whole, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, ErrOverflow
+	}
+	minor, _ := strconv.ParseInt(frac, 10, 64)
+	if whole > (math.MaxInt64-minor)/unit {
+		return 0, ErrOverflow
+	}
+	v := whole*unit + minor
+	if neg {
+		v = -v
+	}
+	return Amount(v), nil
+}
+
+// MustParse is Parse for constants; it panics on error.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func digitsOnly(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Minor returns the amount in minor units.
+func (a Amount) Minor() int64 { return int64(a) }
+
+// Float64 returns an approximate float, for display and logging only.
+func (a Amount) Float64() float64 { return float64(a) / unit }
+
+// String formats the amount with exactly Scale decimals, e.g. "-12.50".
+func (a Amount) String() string {
+	v := int64(a)
+	sign := ""
+	// Work in uint64 so MinInt64 does not overflow on negation.
+	u := uint64(v)
+	if v < 0 {
+		sign = "-"
+		u = uint64(-(v + 1)) + 1
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, u/unit, Scale, u%unit)
+}
+
+func (a Amount) Add(b Amount) Amount { return a + b }
+func (a Amount) Sub(b Amount) Amount { return a - b }
+func (a Amount) Neg() Amount         { return -a }
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+func (a Amount) IsZero() bool     { return a == 0 }
+func (a Amount) IsPositive() bool { return a > 0 }
+func (a Amount) IsNegative() bool { return a < 0 }
+
+// Cmp returns -1, 0 or 1.
+func (a Amount) Cmp(b Amount) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Mul multiplies by an integer quantity.
+func (a Amount) Mul(n int64) Amount { return a * Amount(n) }
+
+// MulRat multiplies by num/den and rounds the result.
+func (a Amount) MulRat(num, den int64, mode RoundingMode) Amount {
+	if den == 0 {
+		panic("money: zero denominator")
+	}
+	r := new(big.Rat).SetFrac(new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(num)), big.NewInt(den))
+	out, err := fromRat(r, mode)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Percent applies a percentage rate, e.g. a fee of 2.5%.
+func (a Amount) Percent(r Rate, mode RoundingMode) Amount {
+	return a.MulRat(int64(r), 100*rateUnit, mode)
+}
+
+// RoundMajor rounds to whole currency units, for providers that cannot
+// charge fractions.
+func (a Amount) RoundMajor(mode RoundingMode) Amount {
+	return a.MulRat(1, unit, mode).Mul(unit)
+}
+
+// Allocate splits a into n parts that differ by at most one minor unit and
+// always sum back to a.
+func (a Amount) Allocate(n int) []Amount {
+	if n <= 0 {
+		return nil
+	}
+	out := make([]Amount, n)
+	q, r := int64(a)/int64(n), int64(a)%int64(n)
+	for i := range out {
+		out[i] = Amount(q)
+		if r > 0 {
+			out[i]++
+			r--
+		} else if r < 0 {
+			out[i]--
+			r++
+		}
+	}
+	return out
+}
+
+// Sum adds up amounts.
+func Sum(list ...Amount) Amount {
+	var t Amount
+	for _, a := range list {
+		t += a
+	}
+	return t
+}
+
+// Min returns the smaller amount.
+func Min(a, b Amount) Amount {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// fromRat rounds an exact rational number of minor units.
+func fromRat(r *big.Rat, mode RoundingMode) (Amount, error) {
+	num, den := r.Num(), r.Denom()
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if m.Sign() != 0 {
+		twice := new(big.Int).Abs(m)
+		twice.Lsh(twice, 1)
+		cmpHalf := twice.Cmp(den)
+		away := false
+		switch mode {
+		case RoundHalfUp:
+			away = cmpHalf >= 0
+		case RoundHalfEven:
+			away = cmpHalf > 0 || (cmpHalf == 0 && q.Bit(0) == 1)
+		case RoundUp:
+			away = true
+		case RoundDown:
+			away = false
+		}
+		if away {
+			if num.Sign() < 0 {
+				q.Sub(q, big.NewInt(1))
+			} else {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+	}
+	if !q.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return Amount(q.Int64()), nil
+}
+
+// MarshalJSON emits a JSON number with exactly Scale decimals so existing
+// clients that expect numbers keep working.
+func (a Amount) MarshalJSON() ([]byte, error) { return []byte(a.String()), nil }
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string. The literal
+// text is parsed directly, never through float64.
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	s := strings.TrimSpace(string(b))
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		var str string
+		if err := json.Unmarshal(b, &str); err != nil {
+			return err
+		}
+		s = str
+	}
+	if strings.ContainsAny(s, "eE") {
+		return fmt.Errorf("%w: exponent notation not allowed", ErrInvalidAmount)
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Value stores the amount as an exact decimal string for NUMERIC columns.
+func (a Amount) Value() (driver.Value, error) { return a.String(), nil }
+
+// Scan reads NUMERIC (delivered as text), integer and, as a fallback, float
+// values. Postgres NUMERIC with a larger scale is rounded half-up.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case int64:
+		*a = FromMajor(v)
+		return nil
+	case float64:
+		out, err := FromFloat(v, RoundHalfUp)
+		if err != nil {
+			return err
+		}
+		*a = out
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	}
+	return fmt.Errorf("money: cannot scan %T", src)
+}
+
+func (a *Amount) scanString(s string) error {
+	v, err := Parse(s)
+	if errors.Is(err, ErrTooPrecise) {
+		// Aggregates such as AVG() can return more decimals than the column.
+		r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+		if !ok {
+			return ErrInvalidAmount
+		}
+		v, err = fromRat(r.Mul(r, big.NewRat(unit, 1)), RoundHalfUp)
+	}
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
@@ -0,0 +1,163 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+func TestParseAndString(t *testing.T) {
+	cases := map[string]string{
+		"0":       "0.00",
+		"1500":    "1500.00",
+		"-12.5":   "-12.50",
+		"0.05":    "0.05",
+		".5":      "0.50",
+		"+3.10":   "3.10",
+		"1.500":   "1.50",
+		"-0.01":   "-0.01",
+		"9999.99": "9999.99",
+	}
+	for in, want := range cases {
+		a, err := Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", in, err)
+		}
+		if a.String() != want {
+			t.Errorf("Parse(%q) = %s, want %s", in, a, want)
+		}
+	}
+	for _, bad := range []string{"", "-", "1.2.3", "abc", "1e5", "1.234"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+	if _, err := Parse("0.001"); !errors.Is(err, ErrTooPrecise) {
+		t.Errorf("Parse(0.001) err = %v, want ErrTooPrecise", err)
+	}
+}
+
+func TestStringParseRoundTrip(t *testing.T) {
+	prop := func(n int64) bool {
+		a := Amount(n)
+		b, err := Parse(a.String())
+		return err == nil && a == b
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	prop := func(n int64) bool {
+		in := struct{ A Amount }{Amount(n % 1e15)}
+		b, err := json.Marshal(in)
+		if err != nil {
+			return false
+		}
+		var out struct{ A Amount }
+		return json.Unmarshal(b, &out) == nil && out == in
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var a Amount
+	if err := json.Unmarshal([]byte(`"12.34"`), &a); err != nil || a != 1234 {
+		t.Fatalf("quoted json = %v, %v", a, err)
+	}
+	if err := json.Unmarshal([]byte(`1e3`), &a); err == nil {
+		t.Fatal("exponent accepted")
+	}
+}
+
+// Summing decimal strings through Amount must agree with exact rational
+// arithmetic, which float64 does not (0.1 + 0.2 != 0.3).
+func TestSumMatchesExactDecimal(t *testing.T) {
+	prop := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		exact := new(big.Rat)
+		var sum Amount
+		for i := 0; i < 100; i++ {
+			a := Amount(r.Int63n(1e9) - 5e8)
+			rat, _ := new(big.Rat).SetString(a.String())
+			exact.Add(exact, rat)
+			sum = sum.Add(a)
+		}
+		return exact.FloatString(2) == sum.String()
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRounding(t *testing.T) {
+	cases := []struct {
+		amount string
+		num    int64
+		den    int64
+		mode   RoundingMode
+		want   string
+	}{
+		{"0.05", 1, 2, RoundHalfUp, "0.03"},
+		{"0.05", 1, 2, RoundHalfEven, "0.02"},
+		{"0.07", 1, 2, RoundHalfEven, "0.04"},
+		{"-0.05", 1, 2, RoundHalfUp, "-0.03"},
+		{"0.05", 1, 2, RoundDown, "0.02"},
+		{"0.01", 1, 3, RoundUp, "0.01"},
+		{"10.00", 1, 3, RoundDown, "3.33"},
+	}
+	for _, c := range cases {
+		got := MustParse(c.amount).MulRat(c.num, c.den, c.mode)
+		if got.String() != c.want {
+			t.Errorf("%s * %d/%d mode %d = %s, want %s", c.amount, c.num, c.den, c.mode, got, c.want)
+		}
+	}
+
+	fee := MustParse("100000").Percent(Rate(250), RoundHalfUp) // 2.5%
+	if fee.String() != "2500.00" {
+		t.Errorf("2.5%% of 100000 = %s", fee)
+	}
+	fee = MustParse("333.33").Percent(Rate(150), RoundHalfUp) // 1.5% = 4.99995
+	if fee.String() != "5.00" {
+		t.Errorf("1.5%% of 333.33 = %s", fee)
+	}
+
+	if got := MustParse("250.50").RoundMajor(RoundHalfUp); got.String() != "251.00" {
+		t.Errorf("250.50 to whole units = %s", got)
+	}
+	if got := MustParse("-250.03").RoundMajor(RoundHalfUp); got.String() != "-250.00" {
+		t.Errorf("-250.03 to whole units = %s", got)
+	}
+}
+
+func TestAllocateSumsBack(t *testing.T) {
+	prop := func(n int64, parts uint8) bool {
+		k := int(parts%20) + 1
+		a := Amount(n % 1e15)
+		return Sum(a.Allocate(k)...) == a
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	var a Amount
+	for src, want := range map[interface{}]string{
+		"15.20":      "15.20",
+		"15.205":     "15.21", // AVG() style extra precision rounds half-up
+		int64(7):     "7.00",
+		float64(0.1): "0.10",
+	} {
+		if err := a.Scan(src); err != nil || a.String() != want {
+			t.Errorf("Scan(%v) = %s, %v; want %s", src, a, err, want)
+		}
+	}
+	if err := a.Scan([]byte("-3.5")); err != nil || a.String() != "-3.50" {
+		t.Errorf("Scan([]byte) = %s, %v", a, err)
+	}
+}
@@ -0,0 +1,49 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+const rateUnit = 100
+
+// Rate is a percentage with two decimals stored as hundredths of a percent
+// (basis points), e.g. 2.5% is Rate(250). It matches DECIMAL(5,2) columns.
+type Rate int64
+
+// ParseRate reads a percentage such as "2.5" or "11".
+func ParseRate(s string) (Rate, error) {
+	a, err := Parse(s)
+	if err != nil {
+		return 0, err
+	}
+	return Rate(a), nil
+}
+
+// String formats the rate as a percentage number, e.g. "2.50".
+func (r Rate) String() string { return Amount(r).String() }
+
+// Float64 returns an approximate float, for display only.
+func (r Rate) Float64() float64 { return float64(r) / rateUnit }
+
+func (r Rate) MarshalJSON() ([]byte, error) { return Amount(r).MarshalJSON() }
+
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	var a Amount
+	if err := a.UnmarshalJSON(b); err != nil {
+		return fmt.Errorf("rate: %w", err)
+	}
+	*r = Rate(a)
+	return nil
+}
+
+func (r Rate) Value() (driver.Value, error) { return Amount(r).Value() }
+
+func (r *Rate) Scan(src interface{}) error {
+	var a Amount
+	if err := a.Scan(src); err != nil {
+		return err
+	}
+	*r = Rate(a)
+	return nil
+}
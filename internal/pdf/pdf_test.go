@@ -0,0 +1,66 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestBytesProducesValidXref(t *testing.T) {
+	doc := New()
+	doc.Title = "Receipt (test)"
+	p := doc.AddPage()
+	p.Text(56, 780, 20, true, "RECEIPT")
+	p.TextRight(539, 780, 10, false, "Café (paid) \\ 100%")
+	p.Line(56, 700, 539, 700, 0.5)
+	p.FillRect(56, 650, 483, 20, 0.9)
+	doc.AddPage().Text(56, 780, 12, false, "page two ✓")
+
+	out := doc.Bytes()
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatalf("missing header or trailer")
+	}
+	if !bytes.Contains(out, []byte(`(Caf\351 \(paid\) \\ 100%) Tj`)) {
+		t.Errorf("text not escaped/encoded:\n%s", out)
+	}
+	if !bytes.Contains(out, []byte("(page two ?) Tj")) {
+		t.Errorf("non-Latin-1 rune not replaced")
+	}
+	if !bytes.Contains(out, []byte("/Count 2")) {
+		t.Errorf("expected two pages")
+	}
+
+	// Every xref entry must point at the start of its object.
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[xref:], -1)
+	if len(entries) != 9 {
+		t.Fatalf("expected 9 objects, got %d", len(entries))
+	}
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		want := fmt.Sprintf("%d 0 obj", i+1)
+		if !bytes.HasPrefix(out[off:], []byte(want)) {
+			t.Errorf("object %d offset %d points at %q", i+1, off, out[off:off+10])
+		}
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	// "Hi" in Helvetica: H=722, i=222.
+	if got := StringWidth("Hi", 10, false); got != 9.44 {
+		t.Errorf("regular width = %v", got)
+	}
+	// Bold i is 278.
+	if got := StringWidth("Hi", 10, true); got != 10 {
+		t.Errorf("bold width = %v", got)
+	}
+}
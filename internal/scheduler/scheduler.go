@@ -0,0 +1,105 @@
+// Package scheduler runs periodic background jobs inside the server process.
+//
+// Plugins register jobs from RegisterServices; the app starts them once the
+// HTTP server boots. Each job runs on its own ticker and never overlaps with
+// itself. Jobs must be safe to run on several replicas at once (row locks,
+// idempotent updates); set SCHEDULER_ENABLED=false on replicas that should
+// not run them at all.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job is a named periodic task.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+var (
+	mu      sync.Mutex
+	jobs    []Job
+	started bool
+)
+
+// Register adds a job. Registering a name twice replaces the earlier job.
+func Register(j Job) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range jobs {
+		if jobs[i].Name == j.Name {
+			jobs[i] = j
+			return
+		}
+	}
+	jobs = append(jobs, j)
+}
+
+// Jobs returns the registered jobs.
+func Jobs() []Job {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Job(nil), jobs...)
+}
+
+// Enabled reports whether SCHEDULER_ENABLED allows jobs in this process
+// (default true).
+func Enabled() bool {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv("SCHEDULER_ENABLED")))
+	return v != "false" && v != "0" && v != "no"
+}
+
+// Start launches every registered job until ctx is cancelled. It is a no-op
+// when disabled or already started.
+func Start(ctx context.Context) {
+	mu.Lock()
+	if started || !Enabled() {
+		mu.Unlock()
+		return
+	}
+	started = true
+	list := append([]Job(nil), jobs...)
+	mu.Unlock()
+
+	for _, j := range list {
+		if j.Interval <= 0 || j.Run == nil {
+			continue
+		}
+		log.Printf("scheduler: starting job=%s interval=%s", j.Name, j.Interval)
+		go loop(ctx, j)
+	}
+}
+
+func loop(ctx context.Context, j Job) {
+	t := time.NewTicker(j.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := runJob(ctx, j); err != nil {
+				log.Printf("scheduler: job=%s error: %v", j.Name, err)
+			}
+		}
+	}
+}
+
+// runJob shields the loop from panics in a single run.
+func runJob(ctx context.Context, j Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("scheduler: job=%s panic: %v", j.Name, r)
+			err = errors.New("scheduler: job panicked")
+		}
+	}()
+	return j.Run(ctx)
+}
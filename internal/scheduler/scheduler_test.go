@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStartRunsJobsAndSurvivesPanics(t *testing.T) {
+	var runs, panics int32
+	Register(Job{Name: "tick", Interval: time.Millisecond, Run: func(context.Context) error {
+		t.Error("replaced job must not run")
+		return nil
+	}})
+	Register(Job{Name: "tick", Interval: 5 * time.Millisecond, Run: func(context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}})
+	Register(Job{Name: "boom", Interval: 5 * time.Millisecond, Run: func(context.Context) error {
+		atomic.AddInt32(&panics, 1)
+		panic("boom")
+	}})
+	if n := len(Jobs()); n != 2 {
+		t.Fatalf("jobs = %d, want 2", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	Start(ctx)
+	Start(ctx) // second call is a no-op
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if atomic.LoadInt32(&runs) >= 3 && atomic.LoadInt32(&panics) >= 3 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("runs=%d panics=%d", atomic.LoadInt32(&runs), atomic.LoadInt32(&panics))
+}
+
+func TestEnabled(t *testing.T) {
+	t.Setenv("SCHEDULER_ENABLED", "")
+	if !Enabled() {
+		t.Error("default should be enabled")
+	}
+	t.Setenv("SCHEDULER_ENABLED", "false")
+	if Enabled() {
+		t.Error("false should disable")
+	}
+}
@@ -36,3 +36,25 @@ func New() (string, error) {
 	return fmt.Sprintf("%s-%s-%s-%s-%s",
 		string(hexs[0:8]), string(hexs[8:12]), string(hexs[12:16]), string(hexs[16:20]), string(hexs[20:32])), nil
 }
+
+// Valid reports whether s is a hyphenated UUID string of any version, so
+// malformed IDs can be rejected before they reach a uuid column.
+func Valid(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
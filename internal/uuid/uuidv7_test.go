@@ -0,0 +1,22 @@
+package uuid
+
+import "testing"
+
+func TestValid(t *testing.T) {
+	id, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for s, want := range map[string]bool{
+		id:                                     true,
+		"0190C7B2-8F3A-7C1E-9A4B-1234567890AB": true,
+		"0190c7b2-8f3a-7c1e-9a4b-1234567890a":  false,
+		"0190c7b28f3a-7c1e-9a4b-1234567890abc": false,
+		"zzzzzzzz-8f3a-7c1e-9a4b-1234567890ab": false,
+		"":                                     false,
+	} {
+		if got := Valid(s); got != want {
+			t.Errorf("Valid(%q) = %v", s, got)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Package xlsx writes simple single-table spreadsheets (report exports) in
+// the Office Open XML format without third-party dependencies.
+//
+// Each sheet is a grid of inline strings and numbers; the only styling is a
+// bold header row. Formulas, shared strings and column widths are not
+// supported.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Number is a decimal written as a numeric cell without going through
+// float64, e.g. Number("1234.50") for a money amount.
+type Number string
+
+// Workbook is a spreadsheet being built in memory.
+type Workbook struct {
+	sheets []*Sheet
+}
+
+// Sheet is one worksheet.
+type Sheet struct {
+	name string
+	rows []row
+}
+
+type row struct {
+	header bool
+	cells  []interface{}
+}
+
+// New returns an empty workbook.
+func New() *Workbook {
+	return &Workbook{}
+}
+
+// AddSheet appends a worksheet. Characters Excel forbids in sheet names are
+// replaced and the name is cut to 31 characters.
+func (w *Workbook) AddSheet(name string) *Sheet {
+	s := &Sheet{name: sheetName(name, len(w.sheets)+1)}
+	w.sheets = append(w.sheets, s)
+	return s
+}
+
+// AddHeader appends a bold row of column titles.
+func (s *Sheet) AddHeader(titles ...string) {
+	cells := make([]interface{}, len(titles))
+	for i, t := range titles {
+		cells[i] = t
+	}
+	s.rows = append(s.rows, row{header: true, cells: cells})
+}
+
+// AddRow appends a row. Strings are written as text; integers, floats and
+// Number as numbers; nil as an empty cell; anything else with fmt's %v.
+func (s *Sheet) AddRow(values ...interface{}) {
+	s.rows = append(s.rows, row{cells: values})
+}
+
+// Bytes serialises the workbook.
+func (w *Workbook) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := w.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write serialises the workbook to out.
+func (w *Workbook) Write(out io.Writer) error {
+	sheets := w.sheets
+	if len(sheets) == 0 {
+		sheets = []*Sheet{{name: "Sheet1"}}
+	}
+
+	zw := zip.NewWriter(out)
+	add := func(name, body string) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, body)
+		return err
+	}
+
+	var types, sheetList, rels strings.Builder
+	for i, s := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&sheetList, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(s.name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheetList.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+		{"xl/styles.xml", stylesXML},
+	}
+	for _, p := range parts {
+		if err := add(p.name, p.body); err != nil {
+			return err
+		}
+	}
+	for i, s := range sheets {
+		if err := add(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), s.xml()); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// stylesXML defines cell format 0 (default) and 1 (bold).
+const stylesXML = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+func (s *Sheet) xml() string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, rw := range s.rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		style := ""
+		if rw.header {
+			style = ` s="1"`
+		}
+		for c, v := range rw.cells {
+			ref := ColumnName(c) + strconv.Itoa(r+1)
+			switch v := v.(type) {
+			case nil:
+				continue
+			case Number:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, style, escape(string(v)))
+			case int:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+			case int64:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+			case float64:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'f', -1, 64))
+			case string:
+				fmt.Fprintf(&b, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, escape(v))
+			default:
+				fmt.Fprintf(&b, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, escape(fmt.Sprintf("%v", v)))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// ColumnName returns the letters of a zero-based column index: 0 is A, 26
+// is AA.
+func ColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func sheetName(name string, n int) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", n)
+	}
+	return name
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
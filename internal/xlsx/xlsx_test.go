@@ -0,0 +1,79 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBytesProducesReadableWorkbook(t *testing.T) {
+	wb := New()
+	s := wb.AddSheet("Topups: by [gateway]")
+	s.AddHeader("Period", "Gateway", "Count", "Amount")
+	s.AddRow("2026-03-01", "Bank <BCA> & co", 3, Number("150000.00"))
+	s.AddRow("2026-03-02", nil, int64(1), 2.5)
+
+	out, err := wb.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+
+		// Every part must be well-formed XML.
+		dec := xml.NewDecoder(bytes.NewReader(b))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+
+	if !strings.Contains(files["xl/workbook.xml"], `name="Topups_ by _gateway_"`) {
+		t.Errorf("sheet name not sanitised: %s", files["xl/workbook.xml"])
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">Period</t></is></c>`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">Bank &lt;BCA&gt; &amp; co</t></is></c>`,
+		`<c r="C2"><v>3</v></c>`,
+		`<c r="D2"><v>150000.00</v></c>`,
+		`<c r="D3"><v>2.5</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet missing %s\n%s", want, sheet)
+		}
+	}
+	if strings.Contains(sheet, `r="B3"`) {
+		t.Errorf("nil cell written")
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := ColumnName(i); got != want {
+			t.Errorf("ColumnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
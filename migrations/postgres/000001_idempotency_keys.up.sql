@@ -0,0 +1,19 @@
+-- ============================================================
+-- TABLE: idempotency_keys
+-- Responses of mutating requests sent with an Idempotency-Key header,
+-- replayed when the client retries. key is scoped to the caller and route.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(512) PRIMARY KEY,
+    fingerprint CHAR(64) NOT NULL,                  -- sha256 of method, path and body
+    status VARCHAR(20) NOT NULL,                    -- PROCESSING, COMPLETED
+    response_status INTEGER,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    locked_until TIMESTAMPTZ,                       -- PROCESSING rows past this may be taken over
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (status IN ('PROCESSING', 'COMPLETED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expiry ON idempotency_keys(expires_at);
@@ -5,8 +5,6 @@ import (
 	"log"
 	"strconv"
 
-	"golang.org/x/crypto/bcrypt"
-
 	"go_framework/internal/db"
 	authmodels "go_framework/plugins/auth/models"
 	authservices "go_framework/plugins/auth/services"
@@ -42,14 +40,14 @@ func (p *Plugin) ConsoleCommands() []*cobra.Command {
 				fmt.Scanln(&password)
 			}
 			svc := newAdminService()
-			hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			hashed, err := svc.HashPassword(password)
 			if err != nil {
 				log.Fatalf("failed to hash password: %v", err)
 			}
 			admin := &authmodels.Admin{
 				Username:     username,
 				Email:        email,
-				PasswordHash: string(hashed),
+				PasswordHash: hashed,
 				Level:        level,
 				IsActive:     true,
 			}
@@ -114,11 +112,11 @@ func (p *Plugin) ConsoleCommands() []*cobra.Command {
 				admin.IsActive = active
 			}
 			if updPassword != "" {
-				hashed, err := bcrypt.GenerateFromPassword([]byte(updPassword), bcrypt.DefaultCost)
+				hashed, err := svc.HashPassword(updPassword)
 				if err != nil {
 					log.Fatalf("failed to hash password: %v", err)
 				}
-				admin.PasswordHash = string(hashed)
+				admin.PasswordHash = hashed
 			}
 			if err := svc.UpdateAdmin(admin); err != nil {
 				log.Fatalf("failed to update admin: %v", err)
@@ -167,5 +165,5 @@ func (p *Plugin) ConsoleCommands() []*cobra.Command {
 
 	adminCmd.AddCommand(createCmd, getCmd, updateCmd, deleteCmd)
 
-	return []*cobra.Command{adminCmd}
+	return []*cobra.Command{adminCmd, customerConsoleCommand()}
 }
@@ -149,7 +149,7 @@ func UpdateAdminHandler(c *gin.Context) {
 	if req.Password != "" {
 		h, err := core.HashPassword(req.Password)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+			writePasswordHashError(c, err)
 			return
 		}
 		admin.PasswordHash = h
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/mail"
+	"go_framework/plugins/auth/models"
+	"go_framework/plugins/auth/services"
+
+	"gorm.io/gorm"
+)
+
+type customerStatusReq struct {
+	Reason string     `json:"reason" binding:"required,max=1000"`
+	Until  *time.Time `json:"until"`
+}
+
+// POST /admin/customers/:id/suspend  (STAFF or SUPERADMIN)
+// Body: {"reason": "...", "until": "2026-01-01T00:00:00Z"}; until is optional.
+func SuspendCustomerHandler(c *gin.Context) {
+	changeCustomerStatus(c, false, func(svc *services.MemberService, adminID string, req customerStatusReq) (*models.Customer, error) {
+		return svc.SuspendCustomer(c.Request.Context(), c.Param("id"), adminID, req.Reason, req.Until)
+	})
+}
+
+// POST /admin/customers/:id/ban  (SUPERADMIN only)
+func BanCustomerHandler(c *gin.Context) {
+	changeCustomerStatus(c, true, func(svc *services.MemberService, adminID string, req customerStatusReq) (*models.Customer, error) {
+		return svc.BanCustomer(c.Request.Context(), c.Param("id"), adminID, req.Reason)
+	})
+}
+
+// POST /admin/customers/:id/reinstate  (STAFF or SUPERADMIN)
+func ReinstateCustomerHandler(c *gin.Context) {
+	changeCustomerStatus(c, false, func(svc *services.MemberService, adminID string, req customerStatusReq) (*models.Customer, error) {
+		return svc.ReinstateCustomer(c.Request.Context(), c.Param("id"), adminID, req.Reason)
+	})
+}
+
+// POST /admin/customers/:id/finish-closure  (SUPERADMIN only)
+// Retries the teardown of an account stuck in CLOSING after a failed hook.
+func FinishCustomerClosureHandler(c *gin.Context) {
+	if level, _ := c.Get("admin_level"); level != "SUPERADMIN" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient privileges"})
+		return
+	}
+	svc, ok := memberService(c)
+	if !ok {
+		return
+	}
+	cust, err := svc.FinishCustomerClosure(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
+		case errors.Is(err, services.ErrInvalidStatusTransition), errors.Is(err, services.ErrAccountClosed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	mail.QueueTemplate(cust.Email, "Your account has been closed", "templates/email/account_closed", map[string]interface{}{
+		"Name": cust.FullName,
+	})
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func changeCustomerStatus(c *gin.Context, superadminOnly bool, apply func(*services.MemberService, string, customerStatusReq) (*models.Customer, error)) {
+	lvlv, ok := c.Get("admin_level")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing admin auth"})
+		return
+	}
+	levelStr, _ := lvlv.(string)
+	if levelStr != "SUPERADMIN" && (superadminOnly || levelStr != "STAFF") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient privileges"})
+		return
+	}
+	adminID := c.GetString("admin_id")
+
+	var req customerStatusReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svc, ok := memberService(c)
+	if !ok {
+		return
+	}
+	cust, err := apply(svc, adminID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
+		case errors.Is(err, services.ErrInvalidStatusTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"customer": cust})
+}
@@ -87,7 +87,12 @@ func GetCustomerHandler(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"customer": cust})
+	history, err := svc.ListCustomerStatusHistory(cust.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"customer": cust, "status_history": history})
 }
 
 // POST /admin/customers  (SUPERADMIN only)
@@ -121,7 +126,7 @@ func CreateCustomerHandler(c *gin.Context) {
 	}
 	ph, err := authCore.HashPassword(req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		writePasswordHashError(c, err)
 		return
 	}
 	cust := &models.Customer{
@@ -183,7 +188,7 @@ func UpdateCustomerHandler(c *gin.Context) {
 	if req.Password != "" {
 		ph, err := authCore.HashPassword(req.Password)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+			writePasswordHashError(c, err)
 			return
 		}
 		cust.PasswordHash = ph
@@ -47,7 +47,7 @@ func RegisterAdminHandler(c *gin.Context) {
 	coreSvc := services.New(gdb)
 	pwHash, err := coreSvc.HashPassword(req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		writePasswordHashError(c, err)
 		return
 	}
 
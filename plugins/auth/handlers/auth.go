@@ -4,7 +4,6 @@ import (
 	"context"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -19,10 +18,6 @@ type loginReq struct {
 	Password string `json:"password" binding:"required"`
 }
 
-type refreshReq struct {
-	RefreshToken string `json:"refresh_token" binding:"required"`
-}
-
 // POST /admin/login
 func LoginHandler(c *gin.Context) {
 	var req loginReq
@@ -62,20 +57,15 @@ func LoginHandler(c *gin.Context) {
 		)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"access_token":       at,
-		"access_expires_at":  aexp.Format(time.RFC3339),
-		"refresh_token":      refreshPlain,
-		"refresh_expires_at": rexp.Format(time.RFC3339),
-		"session_id":         sid,
-	})
+	writeTokenResponse(c, AdminRefreshCookie, AdminRefreshCookiePath, at, aexp, refreshPlain, rexp, sid)
 }
 
 // POST /admin/refresh
+// Reads refresh_token from the body, or from the refresh cookie in cookie mode.
 func RefreshHandler(c *gin.Context) {
-	var req refreshReq
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	refreshToken := refreshTokenFromRequest(c, AdminRefreshCookie)
+	if refreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
 		return
 	}
 	gdb, err := db.GetGormDB()
@@ -89,26 +79,20 @@ func RefreshHandler(c *gin.Context) {
 		return
 	}
 
-	at, aexp, newRefresh, rexp, sid, err := svc.RefreshTokens(req.RefreshToken)
+	at, aexp, newRefresh, rexp, sid, err := svc.RefreshTokens(refreshToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"access_token":       at,
-		"access_expires_at":  aexp.Format(time.RFC3339),
-		"refresh_token":      newRefresh,
-		"refresh_expires_at": rexp.Format(time.RFC3339),
-		"session_id":         sid,
-	})
+	writeTokenResponse(c, AdminRefreshCookie, AdminRefreshCookiePath, at, aexp, newRefresh, rexp, sid)
 }
 
 // POST /admin/logout
 func LogoutHandler(c *gin.Context) {
-	var req refreshReq
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	refreshToken := refreshTokenFromRequest(c, AdminRefreshCookie)
+	if refreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
 		return
 	}
 	gdb, err := db.GetGormDB()
@@ -121,11 +105,14 @@ func LogoutHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": serr.Error()})
 		return
 	}
-	hash := authpkg.HashOpaqueToken(req.RefreshToken)
+	hash := authpkg.HashOpaqueToken(refreshToken)
 	if err := svc.RevokeByRefreshHash(hash); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if CookieModeEnabled() {
+		clearBrowserSession(c, AdminRefreshCookie, AdminRefreshCookiePath)
+	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
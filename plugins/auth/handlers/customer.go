@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -39,7 +38,7 @@ func MemberRegisterHandler(c *gin.Context) {
 
 	pwHash, err := svc.HashPassword(req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		writePasswordHashError(c, err)
 		return
 	}
 	cust := &models.Customer{}
@@ -77,22 +76,14 @@ func MemberLoginHandler(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"access_token":       at,
-		"access_expires_at":  aexp.Format(time.RFC3339),
-		"refresh_token":      refreshPlain,
-		"refresh_expires_at": rexp.Format(time.RFC3339),
-		"session_id":         sid,
-	})
+	writeTokenResponse(c, MemberRefreshCookie, MemberRefreshCookiePath, at, aexp, refreshPlain, rexp, sid)
 }
 
 // POST /member/refresh
 func MemberRefreshHandler(c *gin.Context) {
-	var req struct {
-		RefreshToken string `json:"refresh_token" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	refreshToken := refreshTokenFromRequest(c, MemberRefreshCookie)
+	if refreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
 		return
 	}
 	gdb, err := db.GetGormDB()
@@ -105,21 +96,19 @@ func MemberRefreshHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": serr.Error()})
 		return
 	}
-	at, aexp, newRefresh, rexp, sid, err := svc.CustomerRefreshTokens(req.RefreshToken)
+	at, aexp, newRefresh, rexp, sid, err := svc.CustomerRefreshTokens(refreshToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"access_token": at, "access_expires_at": aexp.Format(time.RFC3339), "refresh_token": newRefresh, "refresh_expires_at": rexp.Format(time.RFC3339), "session_id": sid})
+	writeTokenResponse(c, MemberRefreshCookie, MemberRefreshCookiePath, at, aexp, newRefresh, rexp, sid)
 }
 
 // POST /member/logout
 func MemberLogoutHandler(c *gin.Context) {
-	var req struct {
-		RefreshToken string `json:"refresh_token" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	refreshToken := refreshTokenFromRequest(c, MemberRefreshCookie)
+	if refreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
 		return
 	}
 	gdb, err := db.GetGormDB()
@@ -132,11 +121,14 @@ func MemberLogoutHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": serr.Error()})
 		return
 	}
-	hash := authpkg.HashOpaqueToken(req.RefreshToken)
+	hash := authpkg.HashOpaqueToken(refreshToken)
 	if err := svc.RevokeCustomerByRefreshHash(hash); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if CookieModeEnabled() {
+		clearBrowserSession(c, MemberRefreshCookie, MemberRefreshCookiePath)
+	}
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
@@ -164,5 +156,5 @@ func MemberMeHandler(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"member": gin.H{"id": cust.ID, "email": cust.Email, "full_name": cust.FullName}})
+	c.JSON(http.StatusOK, gin.H{"member": memberView(cust)})
 }
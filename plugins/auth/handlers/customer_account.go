@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/db"
+	"go_framework/internal/mail"
+	"go_framework/plugins/auth/models"
+	"go_framework/plugins/auth/services"
+
+	"gorm.io/gorm"
+)
+
+type memberUpdateProfileReq struct {
+	FullName       string  `json:"full_name" binding:"required,max=255"`
+	TaxID          *string `json:"tax_id" binding:"omitempty,max=32"`            // omitted: unchanged, "": cleared
+	BillingAddress *string `json:"billing_address" binding:"omitempty,max=1000"` // omitted: unchanged, "": cleared
+}
+
+type memberEmailChangeReq struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type memberEmailConfirmReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type memberCloseAccountReq struct {
+	Password string `json:"password" binding:"required"`
+	Reason   string `json:"reason"`
+}
+
+// PUT /api/auth/me
+func MemberUpdateProfileHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req memberUpdateProfileReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svc, ok := memberService(c)
+	if !ok {
+		return
+	}
+	cust, err := svc.UpdateCustomerProfile(id, services.ProfileUpdate{
+		FullName:       strings.TrimSpace(req.FullName),
+		TaxID:          req.TaxID,
+		BillingAddress: req.BillingAddress,
+	})
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"member": memberView(cust)})
+}
+
+// POST /api/auth/me/email
+// Stores a pending change and mails a confirmation link to the new address.
+func MemberRequestEmailChangeHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req memberEmailChangeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svc, ok := memberService(c)
+	if !ok {
+		return
+	}
+	cust, token, expires, err := svc.RequestEmailChange(id, req.Password, req.NewEmail)
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+
+	link := strings.TrimRight(os.Getenv("FRONT_URL"), "/") + "/account/email/confirm?token=" + url.QueryEscape(token)
+	mail.QueueTemplate(req.NewEmail, "Confirm your new email address", "templates/email/email_change", map[string]interface{}{
+		"Name":          cust.FullName,
+		"NewEmail":      req.NewEmail,
+		"ConfirmLink":   link,
+		"ExpiryMinutes": fmt.Sprintf("%d", int(services.EmailChangeTTL().Minutes())),
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{"ok": true, "pending_email": req.NewEmail, "expires_at": expires})
+}
+
+// POST /api/auth/email/confirm
+// Public: the token itself proves ownership of the new address.
+func MemberConfirmEmailChangeHandler(c *gin.Context) {
+	var req memberEmailConfirmReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svc, ok := memberService(c)
+	if !ok {
+		return
+	}
+	cust, err := svc.ConfirmEmailChange(req.Token)
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"member": memberView(cust)})
+}
+
+// DELETE /api/auth/me
+// Closes the account: containers are torn down, the wallet is settled per
+// billing policy, sessions are revoked and PII is anonymised.
+func MemberCloseAccountHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req memberCloseAccountReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svc, ok := memberService(c)
+	if !ok {
+		return
+	}
+	if err := svc.VerifyCustomerPassword(id, req.Password); err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	reason := req.Reason
+	if reason == "" {
+		reason = "closed by customer"
+	}
+	cust, err := svc.CloseCustomerAccount(c.Request.Context(), id, reason)
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+
+	mail.QueueTemplate(cust.Email, "Your account has been closed", "templates/email/account_closed", map[string]interface{}{
+		"Name": cust.FullName,
+	})
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func memberIDFromContext(c *gin.Context) (string, bool) {
+	idv, exists := c.Get("customer_id")
+	if !exists {
+		return "", false
+	}
+	id, _ := idv.(string)
+	return id, id != ""
+}
+
+func memberService(c *gin.Context) (*services.MemberService, bool) {
+	gdb, err := db.GetGormDB()
+	if err != nil || gdb == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return nil, false
+	}
+	svc, serr := services.NewMemberService(gdb)
+	if serr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": serr.Error()})
+		return nil, false
+	}
+	return svc, true
+}
+
+// memberView is the member profile returned by the self-service endpoints.
+func memberView(cust *models.Customer) gin.H {
+	return gin.H{
+		"id":              cust.ID,
+		"email":           cust.Email,
+		"full_name":       cust.FullName,
+		"tax_id":          cust.TaxID,
+		"billing_address": cust.BillingAddress,
+	}
+}
+
+func writeAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPassword):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailTaken), errors.Is(err, services.ErrAccountClosed),
+		errors.Is(err, services.ErrSoleOwner):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailUnchanged), errors.Is(err, services.ErrEmailChangeInvalid),
+		errors.Is(err, services.ErrInvalidTaxID):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+	default:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/mail"
+	"go_framework/plugins/auth/services"
+
+	"gorm.io/gorm"
+)
+
+type organizationCreateReq struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+type organizationUpdateReq struct {
+	Name           string  `json:"name" binding:"required,max=255"`
+	TaxID          *string `json:"tax_id" binding:"omitempty,max=32"`            // omitted: unchanged, "": cleared
+	BillingAddress *string `json:"billing_address" binding:"omitempty,max=1000"` // omitted: unchanged, "": cleared
+}
+
+type organizationRoleReq struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type organizationInviteReq struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type organizationAcceptReq struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func organizationService(c *gin.Context) (*services.OrganizationService, bool) {
+	svc, err := services.NewOrganizationServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return nil, false
+	}
+	return svc, true
+}
+
+// POST /api/orgs
+// Creates an organization with its own wallet; the caller becomes owner.
+func MemberCreateOrganizationHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req organizationCreateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	org, err := svc.CreateOrganization(id, req.Name)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"organization": org})
+}
+
+// GET /api/orgs
+func MemberListOrganizationsHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	list, err := svc.ListCustomerOrganizations(id)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organizations": list})
+}
+
+// GET /api/orgs/:id
+func MemberGetOrganizationHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	org, account, err := svc.GetOrganizationAccount(id, c.Param("id"))
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"organization":    org,
+		"tax_id":          account.TaxID,
+		"billing_address": account.BillingAddress,
+	})
+}
+
+// PUT /api/orgs/:id
+// Owner only: name and the tax details printed on the organization's invoices.
+func MemberUpdateOrganizationHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req organizationUpdateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	org, account, err := svc.UpdateOrganization(id, c.Param("id"), services.ProfileUpdate{
+		FullName:       req.Name,
+		TaxID:          req.TaxID,
+		BillingAddress: req.BillingAddress,
+	})
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"organization":    org,
+		"tax_id":          account.TaxID,
+		"billing_address": account.BillingAddress,
+	})
+}
+
+// GET /api/orgs/:id/members
+func MemberListOrganizationMembersHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	members, err := svc.ListMembers(id, c.Param("id"))
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// PUT /api/orgs/:id/members/:customer_id
+// Owner only: change a member's role.
+func MemberUpdateOrganizationMemberHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req organizationRoleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	if err := svc.UpdateMemberRole(id, c.Param("id"), c.Param("customer_id"), req.Role); err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// DELETE /api/orgs/:id/members/:customer_id
+// Owners remove members; any member can remove themselves to leave.
+func MemberRemoveOrganizationMemberHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	if err := svc.RemoveMember(id, c.Param("id"), c.Param("customer_id")); err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// POST /api/orgs/:id/invitations
+// Owner only: mails an invitation link to the address.
+func MemberInviteToOrganizationHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req organizationInviteReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	inv, org, token, err := svc.CreateInvitation(id, c.Param("id"), req.Email, req.Role)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+
+	inviter := ""
+	if msvc, ok := memberService(c); ok {
+		if cust, err := msvc.GetCustomerByID(id); err == nil {
+			inviter = cust.FullName
+			if inviter == "" {
+				inviter = cust.Email
+			}
+		}
+	}
+	link := strings.TrimRight(os.Getenv("FRONT_URL"), "/") + "/orgs/invitations/accept?token=" + url.QueryEscape(token)
+	mail.QueueTemplate(inv.Email, fmt.Sprintf("You have been invited to join %s", org.Name), "templates/email/org_invitation", map[string]interface{}{
+		"Organization": org.Name,
+		"Inviter":      inviter,
+		"Role":         inv.Role,
+		"AcceptLink":   link,
+		"ExpiryDays":   strconv.Itoa(int(services.InvitationTTL().Hours() / 24)),
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"invitation": inv})
+}
+
+// GET /api/orgs/:id/invitations
+// Owner only: pending invitations.
+func MemberListOrganizationInvitationsHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	list, err := svc.ListInvitations(id, c.Param("id"))
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invitations": list})
+}
+
+// DELETE /api/orgs/:id/invitations/:invitation_id
+func MemberRevokeOrganizationInvitationHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	if err := svc.RevokeInvitation(id, c.Param("id"), c.Param("invitation_id")); err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// POST /api/orgs/invitations/accept
+// The signed-in customer must own the invited email address.
+func MemberAcceptOrganizationInvitationHandler(c *gin.Context) {
+	id, ok := memberIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req organizationAcceptReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	org, err := svc.AcceptInvitation(id, req.Token)
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organization": org})
+}
+
+// GET /admin/organizations
+// Query: q, limit, offset
+func AdminListOrganizationsHandler(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	list, total, err := svc.ListOrganizations(c.Query("q"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"organizations": list,
+		"total":         total,
+		"limit":         limit,
+		"offset":        offset,
+	})
+}
+
+// GET /admin/organizations/:id
+func AdminGetOrganizationHandler(c *gin.Context) {
+	svc, ok := organizationService(c)
+	if !ok {
+		return
+	}
+	org, members, err := svc.AdminGetOrganization(c.Param("id"))
+	if err != nil {
+		writeOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"organization": org, "members": members})
+}
+
+func writeOrganizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOrganizationNotFound), errors.Is(err, services.ErrMemberNotFound),
+		errors.Is(err, services.ErrInvitationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOrganizationDenied), errors.Is(err, services.ErrInvitationEmail):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAlreadyMember), errors.Is(err, services.ErrLastOwner),
+		errors.Is(err, services.ErrAccountClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidOrganization), errors.Is(err, services.ErrInvitationInvalid),
+		errors.Is(err, services.ErrInvalidTaxID):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	authpkg "go_framework/internal/auth"
+)
+
+// writePasswordHashError answers a failed HashPassword call: policy
+// violations are client errors, anything else is a server error.
+func writePasswordHashError(c *gin.Context, err error) {
+	if errors.Is(err, authpkg.ErrPasswordPolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Browser session mode (AUTH_COOKIE_MODE=true) keeps the refresh token out of
+// JavaScript: it is sent as an HttpOnly cookie scoped to the auth path, and
+// every mutating request from a browser holding that cookie must carry a
+// double-submit CSRF token in the X-CSRF-Token header. Each realm has its own
+// CSRF cookie so an admin and a member session in one browser do not
+// overwrite each other's token.
+const (
+	AdminRefreshCookie  = "admin_refresh_token"
+	MemberRefreshCookie = "member_refresh_token"
+	AdminCSRFCookie     = "admin_csrf_token"
+	MemberCSRFCookie    = "member_csrf_token"
+	CSRFHeader          = "X-CSRF-Token"
+
+	// Cookie paths cover both the refresh and logout endpoints.
+	AdminRefreshCookiePath  = "/admin/auth"
+	MemberRefreshCookiePath = "/api/auth"
+)
+
+type cookieConfig struct {
+	enabled  bool
+	secure   bool
+	sameSite http.SameSite
+	domain   string
+}
+
+var (
+	cookieCfg     cookieConfig
+	cookieCfgOnce sync.Once
+)
+
+func loadCookieConfig() cookieConfig {
+	cookieCfgOnce.Do(func() {
+		cookieCfg.enabled, _ = strconv.ParseBool(os.Getenv("AUTH_COOKIE_MODE"))
+		cookieCfg.secure = true
+		if v := os.Getenv("AUTH_COOKIE_SECURE"); v != "" {
+			cookieCfg.secure, _ = strconv.ParseBool(v)
+		}
+		switch strings.ToLower(os.Getenv("AUTH_COOKIE_SAMESITE")) {
+		case "strict":
+			cookieCfg.sameSite = http.SameSiteStrictMode
+		case "none":
+			// Browsers reject SameSite=None without Secure.
+			cookieCfg.sameSite = http.SameSiteNoneMode
+			cookieCfg.secure = true
+		default:
+			cookieCfg.sameSite = http.SameSiteLaxMode
+		}
+		cookieCfg.domain = os.Getenv("AUTH_COOKIE_DOMAIN")
+	})
+	return cookieCfg
+}
+
+// CookieModeEnabled reports whether browser session mode is on.
+func CookieModeEnabled() bool { return loadCookieConfig().enabled }
+
+func setSessionCookie(c *gin.Context, name, value, path string, expires time.Time, httpOnly bool) {
+	cfg := loadCookieConfig()
+	maxAge := int(time.Until(expires).Seconds())
+	if value == "" {
+		maxAge = -1
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cfg.domain,
+		Expires:  expires,
+		MaxAge:   maxAge,
+		Secure:   cfg.secure,
+		HttpOnly: httpOnly,
+		SameSite: cfg.sameSite,
+	})
+}
+
+// csrfCookieFor is the CSRF cookie of the realm a refresh cookie belongs to.
+func csrfCookieFor(refreshCookie string) string {
+	if refreshCookie == AdminRefreshCookie {
+		return AdminCSRFCookie
+	}
+	return MemberCSRFCookie
+}
+
+// issueBrowserSession sets the refresh cookie and a fresh CSRF token, and
+// returns the CSRF token so cross-origin SPAs (which cannot read the API's
+// cookies) can keep it in memory.
+func issueBrowserSession(c *gin.Context, cookieName, cookiePath, refreshPlain string, refreshExp time.Time) (string, error) {
+	setSessionCookie(c, cookieName, refreshPlain, cookiePath, refreshExp, true)
+	return issueCSRFToken(c, csrfCookieFor(cookieName), refreshExp)
+}
+
+func clearBrowserSession(c *gin.Context, cookieName, cookiePath string) {
+	setSessionCookie(c, cookieName, "", cookiePath, time.Unix(0, 0), true)
+	setSessionCookie(c, csrfCookieFor(cookieName), "", "/", time.Unix(0, 0), false)
+}
+
+func issueCSRFToken(c *gin.Context, cookieName string, expires time.Time) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	setSessionCookie(c, cookieName, token, "/", expires, false)
+	return token, nil
+}
+
+// ValidCSRFRequest implements the double-submit check: the header must
+// match the realm's CSRF cookie.
+func ValidCSRFRequest(c *gin.Context, cookieName string) bool {
+	cookie, err := c.Cookie(cookieName)
+	if err != nil || cookie == "" {
+		return false
+	}
+	header := c.GetHeader(CSRFHeader)
+	return header != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+// refreshTokenFromRequest reads refresh_token from the JSON body and, in
+// cookie mode, falls back to the refresh cookie.
+func refreshTokenFromRequest(c *gin.Context, cookieName string) string {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if c.Request.ContentLength != 0 {
+		_ = c.ShouldBindJSON(&req)
+	}
+	if req.RefreshToken != "" {
+		return req.RefreshToken
+	}
+	if CookieModeEnabled() {
+		if v, err := c.Cookie(cookieName); err == nil {
+			return v
+		}
+	}
+	return ""
+}
+
+// writeTokenResponse answers login and refresh. In cookie mode the refresh
+// token goes into the HttpOnly cookie instead of the body.
+func writeTokenResponse(c *gin.Context, cookieName, cookiePath, at string, aexp time.Time, refreshPlain string, rexp time.Time, sid string) {
+	resp := gin.H{
+		"access_token":       at,
+		"access_expires_at":  aexp.Format(time.RFC3339),
+		"refresh_expires_at": rexp.Format(time.RFC3339),
+		"session_id":         sid,
+	}
+	if CookieModeEnabled() {
+		csrf, err := issueBrowserSession(c, cookieName, cookiePath, refreshPlain, rexp)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue csrf token"})
+			return
+		}
+		resp["csrf_token"] = csrf
+	} else {
+		resp["refresh_token"] = refreshPlain
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GET /admin/auth/csrf
+// Rotates the admin CSRF token, e.g. after a page reload lost the in-memory copy.
+func AdminCSRFTokenHandler(c *gin.Context) { rotateCSRFToken(c, AdminCSRFCookie) }
+
+// GET /api/auth/csrf
+// Rotates the member CSRF token.
+func MemberCSRFTokenHandler(c *gin.Context) { rotateCSRFToken(c, MemberCSRFCookie) }
+
+func rotateCSRFToken(c *gin.Context, cookieName string) {
+	if !CookieModeEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cookie session mode disabled"})
+		return
+	}
+	token, err := issueCSRFToken(c, cookieName, time.Now().Add(24*time.Hour))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue csrf token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"csrf_token": token})
+}
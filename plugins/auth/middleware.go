@@ -2,8 +2,11 @@ package auth
 
 import (
 	"fmt"
+	"net/http"
 
 	authjwt "go_framework/internal/auth"
+	pluginhandlers "go_framework/plugins/auth/handlers"
+	authservices "go_framework/plugins/auth/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -45,6 +48,16 @@ func MemberClaimsMiddleware() gin.HandlerFunc {
 			var token string
 			if n, _ := fmt.Sscanf(auth, "Bearer %s", &token); n == 1 {
 				if claims, err := authjwt.ParseAccessTokenClaims(token); err == nil {
+					// Suspended, banned or deactivated customers keep a valid
+					// access token until it expires; reject it here.
+					if claims.Level == "customer" {
+						if svc, serr := authservices.NewFromDefault(); serr == nil {
+							if aerr := svc.CheckCustomerAccess(c.Request.Context(), claims.AdminID); aerr != nil {
+								c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": aerr.Error()})
+								return
+							}
+						}
+					}
 					// claims.Level may indicate "customer" or roles
 					c.Set("customer_id", claims.AdminID)
 					c.Set("user_id", claims.AdminID)
@@ -59,3 +72,31 @@ func MemberClaimsMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// CSRFMiddleware enforces double-submit CSRF tokens in cookie session mode.
+// Only browsers that hold a session cookie are checked, so bearer-only API
+// clients are unaffected. Safe methods always pass.
+func CSRFMiddleware(refreshCookie, csrfCookie string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !pluginhandlers.CookieModeEnabled() {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		_, errRefresh := c.Cookie(refreshCookie)
+		_, errCSRF := c.Cookie(csrfCookie)
+		if errRefresh != nil && errCSRF != nil {
+			c.Next()
+			return
+		}
+		if !pluginhandlers.ValidCSRFRequest(c, csrfCookie) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid or missing csrf token"})
+			return
+		}
+		c.Next()
+	}
+}
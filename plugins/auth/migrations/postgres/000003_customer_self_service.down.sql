@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS customer_email_changes;
+ALTER TABLE customers DROP COLUMN IF EXISTS closed_at;
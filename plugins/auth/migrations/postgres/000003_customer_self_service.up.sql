@@ -0,0 +1,15 @@
+-- Customer self-service: account closure and email change confirmation
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS customer_email_changes (
+    id UUID PRIMARY KEY,
+    customer_id UUID REFERENCES customers(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    token_hash TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    confirmed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_email_changes_customer ON customer_email_changes(customer_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_customer_email_changes_token ON customer_email_changes(token_hash);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_customers_status;
+DROP TABLE IF EXISTS customer_status_history;
+ALTER TABLE customers DROP COLUMN IF EXISTS suspended_until;
+ALTER TABLE customers DROP COLUMN IF EXISTS status_reason;
@@ -0,0 +1,17 @@
+-- Customer suspension lifecycle: current status metadata and an audit trail
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS status_reason TEXT;
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS customer_status_history (
+    id UUID PRIMARY KEY,
+    customer_id UUID REFERENCES customers(id) ON DELETE CASCADE,
+    from_status VARCHAR(50) NOT NULL,
+    to_status VARCHAR(50) NOT NULL,
+    reason TEXT,
+    expires_at TIMESTAMPTZ,
+    admin_id UUID REFERENCES admins(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_status_history_customer ON customer_status_history(customer_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_customers_status ON customers(status);
@@ -0,0 +1,2 @@
+ALTER TABLE customers DROP COLUMN IF EXISTS billing_address;
+ALTER TABLE customers DROP COLUMN IF EXISTS tax_id;
@@ -0,0 +1,3 @@
+-- Tax ID (NPWP) and billing address printed on the customer's invoices
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS tax_id VARCHAR(32);
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS billing_address TEXT;
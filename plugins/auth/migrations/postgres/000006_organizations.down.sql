@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
+ALTER TABLE customers DROP COLUMN IF EXISTS account_type;
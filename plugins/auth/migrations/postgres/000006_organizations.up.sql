@@ -0,0 +1,40 @@
+-- Organizations: team accounts sharing one wallet and one set of containers.
+-- Each organization owns a customers row (account_type ORGANIZATION) that
+-- holds the wallet and owns containers; it cannot log in.
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS account_type VARCHAR(20) NOT NULL DEFAULT 'PERSONAL';
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    account_id UUID NOT NULL UNIQUE REFERENCES customers(id) ON DELETE RESTRICT,
+    created_by UUID REFERENCES customers(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    id UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'billing', 'developer', 'viewer')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (organization_id, customer_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_customer ON organization_members(customer_id);
+
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'billing', 'developer', 'viewer')),
+    token_hash TEXT NOT NULL,
+    invited_by UUID REFERENCES customers(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_org ON organization_invitations(organization_id, created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_invitations_token ON organization_invitations(token_hash);
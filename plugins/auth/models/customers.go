@@ -16,8 +16,17 @@ type Customer struct {
 	IsActive        bool       `gorm:"default:true" json:"is_active"`
 	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 	Status          string     `gorm:"size:50;default:'ACTIVE'" json:"status"`
-	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt       time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	StatusReason    string     `gorm:"type:text" json:"status_reason,omitempty"`
+	SuspendedUntil  *time.Time `json:"suspended_until,omitempty"`
+	ClosedAt        *time.Time `json:"closed_at,omitempty"`
+	// TaxID (NPWP) and BillingAddress are printed on invoices.
+	TaxID          *string `gorm:"size:32" json:"tax_id,omitempty"`
+	BillingAddress *string `gorm:"type:text" json:"billing_address,omitempty"`
+	// AccountType is PERSONAL, or ORGANIZATION for an organization's wallet
+	// account, which cannot log in.
+	AccountType string    `gorm:"size:20;default:'PERSONAL'" json:"account_type"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (Customer) TableName() string { return "customers" }
@@ -56,3 +65,53 @@ func (c *CustomerSession) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// CustomerEmailChange is a pending email change awaiting confirmation from
+// the new address.
+type CustomerEmailChange struct {
+	ID          string     `gorm:"type:uuid;primaryKey" json:"id"`
+	CustomerID  string     `gorm:"type:uuid;index" json:"customer_id"`
+	NewEmail    string     `gorm:"size:255;not null" json:"new_email"`
+	TokenHash   string     `gorm:"type:text;not null" json:"-"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (CustomerEmailChange) TableName() string { return "customer_email_changes" }
+
+func (c *CustomerEmailChange) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		id, err := internaluuid.New()
+		if err != nil {
+			return err
+		}
+		c.ID = id
+	}
+	return nil
+}
+
+// CustomerStatusHistory records every status transition of a customer.
+type CustomerStatusHistory struct {
+	ID         string     `gorm:"type:uuid;primaryKey" json:"id"`
+	CustomerID string     `gorm:"type:uuid;index" json:"customer_id"`
+	FromStatus string     `gorm:"size:50;not null" json:"from_status"`
+	ToStatus   string     `gorm:"size:50;not null" json:"to_status"`
+	Reason     string     `gorm:"type:text" json:"reason"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	AdminID    *string    `gorm:"type:uuid" json:"admin_id,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (CustomerStatusHistory) TableName() string { return "customer_status_history" }
+
+func (h *CustomerStatusHistory) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == "" {
+		id, err := internaluuid.New()
+		if err != nil {
+			return err
+		}
+		h.ID = id
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	internaluuid "go_framework/internal/uuid"
+
+	"gorm.io/gorm"
+)
+
+// Organization is a team sharing one billing account. AccountID is the
+// customers row that holds the wallet and owns the organization's
+// containers.
+type Organization struct {
+	ID        string    `gorm:"type:uuid;primaryKey" json:"id"`
+	Name      string    `gorm:"size:255;not null" json:"name"`
+	AccountID string    `gorm:"type:uuid;unique;not null" json:"account_id"`
+	CreatedBy *string   `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (Organization) TableName() string { return "organizations" }
+
+func (o *Organization) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		id, err := internaluuid.New()
+		if err != nil {
+			return err
+		}
+		o.ID = id
+	}
+	return nil
+}
+
+type OrganizationMember struct {
+	ID             string    `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID string    `gorm:"type:uuid;not null" json:"organization_id"`
+	CustomerID     string    `gorm:"type:uuid;not null" json:"customer_id"`
+	Role           string    `gorm:"size:20;not null" json:"role"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (OrganizationMember) TableName() string { return "organization_members" }
+
+func (m *OrganizationMember) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		id, err := internaluuid.New()
+		if err != nil {
+			return err
+		}
+		m.ID = id
+	}
+	return nil
+}
+
+// OrganizationInvitation is a pending invitation mailed to Email. It is
+// accepted by a signed-in customer with that email address.
+type OrganizationInvitation struct {
+	ID             string     `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID string     `gorm:"type:uuid;not null" json:"organization_id"`
+	Email          string     `gorm:"size:255;not null" json:"email"`
+	Role           string     `gorm:"size:20;not null" json:"role"`
+	TokenHash      string     `gorm:"type:text;not null" json:"-"`
+	InvitedBy      *string    `gorm:"type:uuid" json:"invited_by,omitempty"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (OrganizationInvitation) TableName() string { return "organization_invitations" }
+
+func (i *OrganizationInvitation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		id, err := internaluuid.New()
+		if err != nil {
+			return err
+		}
+		i.ID = id
+	}
+	return nil
+}
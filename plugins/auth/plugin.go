@@ -3,6 +3,7 @@ package auth
 import (
 	"go_framework/internal/plugins"
 	pluginhandlers "go_framework/plugins/auth/handlers"
+	authservices "go_framework/plugins/auth/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,10 +18,26 @@ func New() plugins.Plugin { return &Plugin{} }
 
 func (p *Plugin) ID() string { return "auth" }
 
-func (p *Plugin) RegisterServices(deps plugins.ServiceDeps) error { p.deps = deps; return nil }
+func (p *Plugin) RegisterServices(deps plugins.ServiceDeps) error {
+	p.deps = deps
+	authservices.RegisterSuspensionExpiryJob()
+	return nil
+}
 
 func (p *Plugin) RegisterMiddleware() []plugins.MiddlewareDescriptor {
 	return []plugins.MiddlewareDescriptor{
+		{
+			Name:     "plugins.auth.csrf",
+			Target:   "admin",
+			Priority: 50,
+			Handler:  CSRFMiddleware(pluginhandlers.AdminRefreshCookie, pluginhandlers.AdminCSRFCookie),
+		},
+		{
+			Name:     "plugins.auth.member_csrf",
+			Target:   "api",
+			Priority: 50,
+			Handler:  CSRFMiddleware(pluginhandlers.MemberRefreshCookie, pluginhandlers.MemberCSRFCookie),
+		},
 		{
 			Name:     "plugins.auth.claims",
 			Target:   "admin",
@@ -44,6 +61,7 @@ func (p *Plugin) RegisterRoutes(router *gin.Engine, admin *gin.RouterGroup, api
 	authAdmin.POST("/login", pluginhandlers.LoginHandler)
 	authAdmin.POST("/refresh", pluginhandlers.RefreshHandler)
 	authAdmin.POST("/logout", pluginhandlers.LogoutHandler)
+	authAdmin.GET("/csrf", pluginhandlers.AdminCSRFTokenHandler)
 	authAdmin.GET("/me", pluginhandlers.MeHandler)
 	authAdmin.POST("/register", pluginhandlers.RegisterAdminHandler)
 	authAdmin.GET("/list", pluginhandlers.ListAdminsHandler)
@@ -58,6 +76,15 @@ func (p *Plugin) RegisterRoutes(router *gin.Engine, admin *gin.RouterGroup, api
 	adminCustomers.GET("/:id", pluginhandlers.GetCustomerHandler)
 	adminCustomers.PUT("/:id", pluginhandlers.UpdateCustomerHandler)
 	adminCustomers.DELETE("/:id", pluginhandlers.DeleteCustomerHandler)
+	adminCustomers.POST("/:id/suspend", pluginhandlers.SuspendCustomerHandler)
+	adminCustomers.POST("/:id/ban", pluginhandlers.BanCustomerHandler)
+	adminCustomers.POST("/:id/reinstate", pluginhandlers.ReinstateCustomerHandler)
+	adminCustomers.POST("/:id/finish-closure", pluginhandlers.FinishCustomerClosureHandler)
+
+	// Admin organization overview at /admin/organizations
+	adminOrgs := admin.Group("/organizations")
+	adminOrgs.GET("", pluginhandlers.AdminListOrganizationsHandler)
+	adminOrgs.GET("/:id", pluginhandlers.AdminGetOrganizationHandler)
 
 	// Customer (member) auth routes on /api/auth
 	if api != nil {
@@ -65,7 +92,26 @@ func (p *Plugin) RegisterRoutes(router *gin.Engine, admin *gin.RouterGroup, api
 		api.POST("/auth/login", pluginhandlers.MemberLoginHandler)
 		api.POST("/auth/refresh", pluginhandlers.MemberRefreshHandler)
 		api.POST("/auth/logout", pluginhandlers.MemberLogoutHandler)
+		api.GET("/auth/csrf", pluginhandlers.MemberCSRFTokenHandler)
 		api.GET("/auth/me", pluginhandlers.MemberMeHandler)
+		api.PUT("/auth/me", pluginhandlers.MemberUpdateProfileHandler)
+		api.DELETE("/auth/me", pluginhandlers.MemberCloseAccountHandler)
+		api.POST("/auth/me/email", pluginhandlers.MemberRequestEmailChangeHandler)
+		api.POST("/auth/email/confirm", pluginhandlers.MemberConfirmEmailChangeHandler)
+
+		// Organizations: shared wallets and containers; select one on other
+		// customer endpoints with the X-Organization-ID header.
+		api.POST("/orgs", pluginhandlers.MemberCreateOrganizationHandler)
+		api.GET("/orgs", pluginhandlers.MemberListOrganizationsHandler)
+		api.POST("/orgs/invitations/accept", pluginhandlers.MemberAcceptOrganizationInvitationHandler)
+		api.GET("/orgs/:id", pluginhandlers.MemberGetOrganizationHandler)
+		api.PUT("/orgs/:id", pluginhandlers.MemberUpdateOrganizationHandler)
+		api.GET("/orgs/:id/members", pluginhandlers.MemberListOrganizationMembersHandler)
+		api.PUT("/orgs/:id/members/:customer_id", pluginhandlers.MemberUpdateOrganizationMemberHandler)
+		api.DELETE("/orgs/:id/members/:customer_id", pluginhandlers.MemberRemoveOrganizationMemberHandler)
+		api.POST("/orgs/:id/invitations", pluginhandlers.MemberInviteToOrganizationHandler)
+		api.GET("/orgs/:id/invitations", pluginhandlers.MemberListOrganizationInvitationsHandler)
+		api.DELETE("/orgs/:id/invitations/:invitation_id", pluginhandlers.MemberRevokeOrganizationInvitationHandler)
 	}
 	return nil
 }
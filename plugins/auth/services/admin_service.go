@@ -42,3 +42,6 @@ func (s *AdminService) ListAdminsWithPagination(limit, offset int) ([]models.Adm
 }
 func (s *AdminService) UpdateAdmin(a *models.Admin) error { return s.core.UpdateAdmin(a) }
 func (s *AdminService) DeleteAdmin(id string) error       { return s.core.DeleteAdmin(id) }
+func (s *AdminService) HashPassword(pw string) (string, error) {
+	return s.core.HashPassword(pw)
+}
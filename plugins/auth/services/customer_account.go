@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	authpkg "go_framework/internal/auth"
+	"go_framework/internal/events"
+	"go_framework/plugins/auth/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPassword    = errors.New("invalid password")
+	ErrEmailTaken         = errors.New("email already in use")
+	ErrEmailUnchanged     = errors.New("new email is the same as the current email")
+	ErrEmailChangeInvalid = errors.New("invalid or expired email change token")
+	ErrAccountClosed      = errors.New("account is closed")
+	ErrInvalidTaxID       = errors.New("tax ID must be a 15 or 16 digit NPWP")
+)
+
+var (
+	defaultEmailChangeTTL   = 60 * time.Minute
+	closedCustomerEmailHost = "closed.invalid"
+)
+
+// EmailChangeTTL returns how long an email change confirmation stays valid.
+func EmailChangeTTL() time.Duration { return defaultEmailChangeTTL }
+
+// VerifyCustomerPassword checks password against the stored customer hash.
+func (s *AuthService) VerifyCustomerPassword(id, password string) error {
+	cust, err := s.GetCustomerByID(id)
+	if err != nil {
+		return err
+	}
+	if !s.CheckPassword(cust.PasswordHash, password) {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// ProfileUpdate is the self-service profile. A nil TaxID or BillingAddress
+// is left unchanged; an empty one is cleared.
+type ProfileUpdate struct {
+	FullName       string
+	TaxID          *string
+	BillingAddress *string
+}
+
+// NormalizeTaxID strips the dots, dashes and spaces of a formatted NPWP
+// (01.234.567.8-901.000) and checks 15 or 16 digits remain.
+func NormalizeTaxID(raw string) (string, error) {
+	id := strings.NewReplacer(".", "", "-", "", " ", "").Replace(strings.TrimSpace(raw))
+	if len(id) != 15 && len(id) != 16 {
+		return "", ErrInvalidTaxID
+	}
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return "", ErrInvalidTaxID
+		}
+	}
+	return id, nil
+}
+
+// UpdateCustomerProfile updates the self-service profile fields of a customer.
+func (s *AuthService) UpdateCustomerProfile(id string, input ProfileUpdate) (*models.Customer, error) {
+	cust, err := s.GetCustomerByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if cust.ClosedAt != nil {
+		return nil, ErrAccountClosed
+	}
+
+	updates := map[string]interface{}{"full_name": input.FullName}
+	cust.FullName = input.FullName
+	if input.TaxID != nil {
+		cust.TaxID = nil
+		if raw := strings.TrimSpace(*input.TaxID); raw != "" {
+			taxID, err := NormalizeTaxID(raw)
+			if err != nil {
+				return nil, err
+			}
+			cust.TaxID = &taxID
+		}
+		updates["tax_id"] = cust.TaxID
+	}
+	if input.BillingAddress != nil {
+		cust.BillingAddress = nil
+		if address := strings.TrimSpace(*input.BillingAddress); address != "" {
+			cust.BillingAddress = &address
+		}
+		updates["billing_address"] = cust.BillingAddress
+	}
+	if err := s.db.Model(cust).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return cust, nil
+}
+
+// RequestEmailChange verifies the password and stores a pending email change.
+// It returns the plain confirmation token to be mailed to the new address;
+// the current email stays active until ConfirmEmailChange is called.
+func (s *AuthService) RequestEmailChange(id, password, newEmail string) (*models.Customer, string, time.Time, error) {
+	cust, err := s.GetCustomerByID(id)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	if cust.ClosedAt != nil {
+		return nil, "", time.Time{}, ErrAccountClosed
+	}
+	if !s.CheckPassword(cust.PasswordHash, password) {
+		return nil, "", time.Time{}, ErrInvalidPassword
+	}
+	newEmail = strings.TrimSpace(newEmail)
+	if strings.EqualFold(newEmail, cust.Email) {
+		return nil, "", time.Time{}, ErrEmailUnchanged
+	}
+	if taken, err := s.customerEmailTaken(s.db, newEmail, cust.ID); err != nil {
+		return nil, "", time.Time{}, err
+	} else if taken {
+		return nil, "", time.Time{}, ErrEmailTaken
+	}
+
+	plain, hash, err := authpkg.GenerateOpaqueRefreshToken()
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	expires := time.Now().Add(defaultEmailChangeTTL)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Only the latest request stays valid.
+		if err := tx.Where("customer_id = ? AND confirmed_at IS NULL", cust.ID).Delete(&models.CustomerEmailChange{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.CustomerEmailChange{
+			CustomerID: cust.ID,
+			NewEmail:   newEmail,
+			TokenHash:  hash,
+			ExpiresAt:  &expires,
+		}).Error
+	})
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	return cust, plain, expires, nil
+}
+
+// ConfirmEmailChange consumes a confirmation token and switches the email.
+// All sessions are revoked so other devices must log in with the new address.
+func (s *AuthService) ConfirmEmailChange(token string) (*models.Customer, error) {
+	hash := authpkg.HashOpaqueToken(token)
+	var cust models.Customer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var change models.CustomerEmailChange
+		if err := tx.Where("token_hash = ? AND confirmed_at IS NULL", hash).First(&change).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEmailChangeInvalid
+			}
+			return err
+		}
+		if change.ExpiresAt != nil && change.ExpiresAt.Before(time.Now()) {
+			return ErrEmailChangeInvalid
+		}
+		if err := tx.Where("id = ?", change.CustomerID).First(&cust).Error; err != nil {
+			return err
+		}
+		if cust.ClosedAt != nil {
+			return ErrAccountClosed
+		}
+		if taken, err := s.customerEmailTaken(tx, change.NewEmail, cust.ID); err != nil {
+			return err
+		} else if taken {
+			return ErrEmailTaken
+		}
+
+		now := time.Now()
+		if err := tx.Model(&change).Update("confirmed_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&cust).Updates(map[string]interface{}{
+			"email":             change.NewEmail,
+			"email_verified_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		cust.Email = change.NewEmail
+		cust.EmailVerifiedAt = &now
+		return tx.Model(&models.CustomerSession{}).Where("customer_id = ?", cust.ID).Update("revoked", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cust, nil
+}
+
+// RevokeAllCustomerSessions revokes every refresh session of a customer.
+func (s *AuthService) RevokeAllCustomerSessions(customerID string) error {
+	return s.db.Model(&models.CustomerSession{}).Where("customer_id = ? AND revoked = ?", customerID, false).Update("revoked", true).Error
+}
+
+// CloseCustomerAccount runs the coordinated account teardown:
+//  1. the sole owner of an organization with other members must hand over
+//     ownership first, and CustomerCloseCheck hooks may veto the closure
+//     (e.g. billing policy);
+//  2. the account is deactivated and all sessions are revoked;
+//  3. CustomerClosing hooks tear down plugin-owned resources;
+//  4. PII is anonymised instead of deleting the row, because
+//     wallet_transactions and other ledgers keep referencing it.
+//
+// If a teardown hook fails the account stays in CLOSING state; the customer
+// can no longer log in, so an admin finishes it with FinishCustomerClosure.
+func (s *AuthService) CloseCustomerAccount(ctx context.Context, id, reason string) (*models.Customer, error) {
+	cust, err := s.GetCustomerByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if cust.ClosedAt != nil {
+		return nil, ErrAccountClosed
+	}
+	original := *cust
+
+	if owned, err := soleOwnedOrganizations(s.db, cust.ID); err != nil {
+		return nil, err
+	} else if owned > 0 {
+		return nil, ErrSoleOwner
+	}
+
+	ev := events.CustomerEvent{CustomerID: cust.ID, Reason: reason}
+	if err := events.RunHooks(ctx, events.CustomerCloseCheck, ev); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Customer{}).Where("id = ?", cust.ID).Updates(map[string]interface{}{
+			"status":        CustomerStatusClosing,
+			"status_reason": reason,
+			"is_active":     false,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CustomerSession{}).Where("customer_id = ?", cust.ID).Update("revoked", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	forgetCustomerAccess(cust.ID)
+
+	if err := s.teardownCustomer(ctx, ev); err != nil {
+		return nil, err
+	}
+	return &original, nil
+}
+
+// FinishCustomerClosure retries the teardown of an account left in CLOSING
+// state by a failed CustomerClosing hook. It returns the customer as it was
+// before anonymisation, so the caller can still notify them.
+func (s *AuthService) FinishCustomerClosure(ctx context.Context, id string) (*models.Customer, error) {
+	cust, err := s.GetCustomerByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if cust.ClosedAt != nil {
+		return nil, ErrAccountClosed
+	}
+	if cust.Status != CustomerStatusClosing {
+		return nil, ErrInvalidStatusTransition
+	}
+	original := *cust
+
+	ev := events.CustomerEvent{CustomerID: cust.ID, Reason: cust.StatusReason}
+	if err := s.teardownCustomer(ctx, ev); err != nil {
+		return nil, err
+	}
+	return &original, nil
+}
+
+// teardownCustomer runs the CustomerClosing hooks and anonymises the
+// account once they all succeed.
+func (s *AuthService) teardownCustomer(ctx context.Context, ev events.CustomerEvent) error {
+	if err := events.RunHooks(ctx, events.CustomerClosing, ev); err != nil {
+		return fmt.Errorf("account teardown incomplete: %w", err)
+	}
+	if err := s.anonymiseCustomer(ev.CustomerID); err != nil {
+		return err
+	}
+	events.Publish(events.CustomerClosed, ev)
+	return nil
+}
+
+// anonymiseCustomer strips PII from a customer row and its dependent records.
+func (s *AuthService) anonymiseCustomer(id string) error {
+	// A random hash that no password can match.
+	_, unusable, err := authpkg.GenerateOpaqueRefreshToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Customer{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"email":             fmt.Sprintf("closed+%s@%s", id, closedCustomerEmailHost),
+			"full_name":         "",
+			"password_hash":     "!" + unusable,
+			"email_verified_at": nil,
+			"status":            CustomerStatusClosed,
+			"is_active":         false,
+			"closed_at":         now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("customer_id = ?", id).Delete(&models.CustomerEmailChange{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("customer_id = ?", id).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CustomerSession{}).Where("customer_id = ?", id).Updates(map[string]interface{}{
+			"user_agent": "",
+			"ip_address": nil,
+			"revoked":    true,
+		}).Error
+	})
+}
+
+func (s *AuthService) customerEmailTaken(tx *gorm.DB, email, exceptID string) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.Customer{}).Where("LOWER(email) = LOWER(?) AND id <> ?", email, exceptID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
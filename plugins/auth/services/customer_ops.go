@@ -0,0 +1,117 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/auth/models"
+)
+
+// CustomerListFilter narrows ListCustomersFiltered. Zero values mean "any".
+type CustomerListFilter struct {
+	// Query matches email or full name (case-insensitive substring).
+	Query    string
+	Status   string
+	Active   *bool
+	Verified *bool
+}
+
+// ListCustomersFiltered returns customers matching f with pagination.
+func (s *AuthService) ListCustomersFiltered(f CustomerListFilter, limit, offset int) ([]models.Customer, int64, error) {
+	q := s.db.Model(&models.Customer{})
+	if f.Query != "" {
+		like := "%" + strings.ToLower(f.Query) + "%"
+		q = q.Where("LOWER(email) LIKE ? OR LOWER(full_name) LIKE ?", like, like)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", strings.ToUpper(f.Status))
+	}
+	if f.Active != nil {
+		q = q.Where("is_active = ?", *f.Active)
+	}
+	if f.Verified != nil {
+		if *f.Verified {
+			q = q.Where("email_verified_at IS NOT NULL")
+		} else {
+			q = q.Where("email_verified_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []models.Customer
+	if err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// SetCustomerActive activates or deactivates a customer. Deactivation also
+// revokes every session so the customer is logged out immediately.
+func (s *AuthService) SetCustomerActive(id string, active bool) (*models.Customer, error) {
+	cust, err := s.GetCustomerByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if cust.ClosedAt != nil {
+		return nil, ErrAccountClosed
+	}
+	if err := s.db.Model(cust).Update("is_active", active).Error; err != nil {
+		return nil, err
+	}
+	cust.IsActive = active
+	if !active {
+		if err := s.RevokeAllCustomerSessions(cust.ID); err != nil {
+			return nil, err
+		}
+	}
+	return cust, nil
+}
+
+// ResetCustomerPassword sets a new password (subject to the password policy)
+// and revokes all sessions.
+func (s *AuthService) ResetCustomerPassword(id, password string) (*models.Customer, error) {
+	cust, err := s.GetCustomerByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if cust.ClosedAt != nil {
+		return nil, ErrAccountClosed
+	}
+	hash, err := s.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(cust).Update("password_hash", hash).Error; err != nil {
+		return nil, err
+	}
+	return cust, s.RevokeAllCustomerSessions(cust.ID)
+}
+
+// MarkCustomerEmailVerified sets email_verified_at if it is not set yet.
+func (s *AuthService) MarkCustomerEmailVerified(id string) (*models.Customer, error) {
+	cust, err := s.GetCustomerByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if cust.EmailVerifiedAt != nil {
+		return cust, nil
+	}
+	now := time.Now()
+	if err := s.db.Model(cust).Update("email_verified_at", now).Error; err != nil {
+		return nil, err
+	}
+	cust.EmailVerifiedAt = &now
+	return cust, nil
+}
+
+// GetCustomerWalletBalance reads the wallet balance column maintained by the
+// billing plugin on the shared customers table.
+func (s *AuthService) GetCustomerWalletBalance(id string) (money.Amount, error) {
+	var balance money.Amount
+	err := s.db.Table(models.Customer{}.TableName()).Select("wallet_balance").Where("id = ?", id).Scan(&balance).Error
+	return balance, err
+}
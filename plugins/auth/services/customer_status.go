@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go_framework/internal/events"
+	"go_framework/internal/scheduler"
+	"go_framework/plugins/auth/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Customer statuses. ACTIVE is the column default.
+const (
+	CustomerStatusActive    = "ACTIVE"
+	CustomerStatusSuspended = "SUSPENDED"
+	CustomerStatusBanned    = "BANNED"
+	CustomerStatusClosing   = "CLOSING"
+	CustomerStatusClosed    = "CLOSED"
+)
+
+// SuspensionExpiryJob is the scheduler name of the sweep that reinstates
+// customers whose suspension has expired.
+const SuspensionExpiryJob = "auth.suspension-expiry"
+
+const defaultSuspensionExpiryInterval = time.Minute
+
+var (
+	ErrCustomerSuspended       = errors.New("account suspended")
+	ErrCustomerBanned          = errors.New("account banned")
+	ErrInvalidStatusTransition = errors.New("invalid customer status transition")
+)
+
+// accessCacheTTL bounds how long a status change may take to reach API
+// requests served by other instances.
+var accessCacheTTL = 15 * time.Second
+
+// accessCacheMax caps the cache; when full, expired entries are swept and,
+// if that is not enough, the cache starts over.
+const accessCacheMax = 10000
+
+type accessEntry struct {
+	err     error
+	checked time.Time
+}
+
+var (
+	accessCacheMu sync.Mutex
+	accessCache   = map[string]accessEntry{}
+)
+
+func rememberCustomerAccess(id string, err error, now time.Time) {
+	accessCacheMu.Lock()
+	defer accessCacheMu.Unlock()
+	if len(accessCache) >= accessCacheMax {
+		for k, e := range accessCache {
+			if now.Sub(e.checked) >= accessCacheTTL {
+				delete(accessCache, k)
+			}
+		}
+		if len(accessCache) >= accessCacheMax {
+			accessCache = map[string]accessEntry{}
+		}
+	}
+	accessCache[id] = accessEntry{err: err, checked: now}
+}
+
+func forgetCustomerAccess(id string) {
+	accessCacheMu.Lock()
+	delete(accessCache, id)
+	accessCacheMu.Unlock()
+}
+
+// SuspendCustomer blocks login and API use until reinstated or until the
+// optional expiry passes. Re-suspending an already suspended customer
+// updates the reason and expiry.
+func (s *AuthService) SuspendCustomer(ctx context.Context, id, adminID, reason string, until *time.Time) (*models.Customer, error) {
+	if until != nil && !until.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expiry must be in the future", ErrInvalidStatusTransition)
+	}
+	return s.changeCustomerStatus(ctx, id, adminID, CustomerStatusSuspended, reason, until)
+}
+
+// BanCustomer blocks the customer indefinitely.
+func (s *AuthService) BanCustomer(ctx context.Context, id, adminID, reason string) (*models.Customer, error) {
+	return s.changeCustomerStatus(ctx, id, adminID, CustomerStatusBanned, reason, nil)
+}
+
+// ReinstateCustomer lifts a suspension or ban.
+func (s *AuthService) ReinstateCustomer(ctx context.Context, id, adminID, reason string) (*models.Customer, error) {
+	return s.changeCustomerStatus(ctx, id, adminID, CustomerStatusActive, reason, nil)
+}
+
+// ListCustomerStatusHistory returns status transitions, newest first.
+func (s *AuthService) ListCustomerStatusHistory(customerID string) ([]models.CustomerStatusHistory, error) {
+	var list []models.CustomerStatusHistory
+	if err := s.db.Where("customer_id = ?", customerID).Order("created_at DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func suspensionExpired(cust *models.Customer, now time.Time) bool {
+	return cust.Status == CustomerStatusSuspended && cust.SuspendedUntil != nil && !cust.SuspendedUntil.After(now)
+}
+
+// ReinstateExpiredSuspensions reinstates every customer whose suspension
+// expired, so their containers and topups resume without waiting for them
+// to sign in. It returns how many were reinstated.
+func (s *AuthService) ReinstateExpiredSuspensions(ctx context.Context) (int, error) {
+	var ids []string
+	if err := s.db.Model(&models.Customer{}).
+		Where("status = ? AND suspended_until <= ?", CustomerStatusSuspended, time.Now()).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	reinstated := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := s.ReinstateCustomer(ctx, id, "", "suspension expired"); err != nil {
+			if !errors.Is(err, ErrInvalidStatusTransition) {
+				log.Printf("auth: reinstate expired suspension customer=%s failed: %v", id, err)
+			}
+			continue
+		}
+		reinstated++
+	}
+	return reinstated, nil
+}
+
+// RegisterSuspensionExpiryJob schedules ReinstateExpiredSuspensions every
+// AUTH_SUSPENSION_EXPIRY_INTERVAL (default 1m).
+func RegisterSuspensionExpiryJob() {
+	interval := defaultSuspensionExpiryInterval
+	if v := strings.TrimSpace(os.Getenv("AUTH_SUSPENSION_EXPIRY_INTERVAL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		}
+	}
+	scheduler.Register(scheduler.Job{
+		Name:     SuspensionExpiryJob,
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			svc, err := NewFromDefault()
+			if err != nil {
+				return err
+			}
+			n, err := svc.ReinstateExpiredSuspensions(ctx)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("auth: reinstated %d customers with expired suspensions", n)
+			}
+			return nil
+		},
+	})
+}
+
+func allowedStatusTransition(from, to string) bool {
+	switch to {
+	case CustomerStatusSuspended:
+		return from == CustomerStatusActive || from == CustomerStatusSuspended
+	case CustomerStatusBanned:
+		return from == CustomerStatusActive || from == CustomerStatusSuspended
+	case CustomerStatusActive:
+		return from == CustomerStatusSuspended || from == CustomerStatusBanned
+	}
+	return false
+}
+
+func (s *AuthService) changeCustomerStatus(ctx context.Context, id, adminID, to, reason string, until *time.Time) (*models.Customer, error) {
+	var cust models.Customer
+	var from string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&cust).Error; err != nil {
+			return err
+		}
+		from = cust.Status
+		if from == "" {
+			from = CustomerStatusActive
+		}
+		if !allowedStatusTransition(from, to) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, from, to)
+		}
+		// Without an admin only an expired suspension is lifted; checked
+		// under the lock as an admin may have extended it meanwhile.
+		if to == CustomerStatusActive && adminID == "" && !suspensionExpired(&cust, time.Now()) {
+			return fmt.Errorf("%w: suspension has not expired", ErrInvalidStatusTransition)
+		}
+
+		updates := map[string]interface{}{
+			"status":          to,
+			"status_reason":   reason,
+			"suspended_until": until,
+		}
+		if err := tx.Model(&models.Customer{}).Where("id = ?", cust.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		cust.Status = to
+		cust.StatusReason = reason
+		cust.SuspendedUntil = until
+
+		h := &models.CustomerStatusHistory{
+			CustomerID: cust.ID,
+			FromStatus: from,
+			ToStatus:   to,
+			Reason:     reason,
+			ExpiresAt:  until,
+		}
+		if adminID != "" {
+			h.AdminID = &adminID
+		}
+		if err := tx.Create(h).Error; err != nil {
+			return err
+		}
+		if to != CustomerStatusActive {
+			return tx.Model(&models.CustomerSession{}).Where("customer_id = ? AND revoked = ?", cust.ID, false).Update("revoked", true).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	forgetCustomerAccess(cust.ID)
+
+	ev := events.CustomerEvent{CustomerID: cust.ID, Reason: reason, Status: to}
+	switch {
+	case to == CustomerStatusActive:
+		events.Publish(events.CustomerReinstated, ev)
+	case from == CustomerStatusActive:
+		// Suspended -> banned (or re-suspended) already had its effects applied.
+		events.Publish(events.CustomerSuspended, ev)
+	}
+	return &cust, nil
+}
+
+// customerAccessError reports why a customer may not log in or use the API.
+// A suspension whose expiry has passed is lifted on the spot, in case the
+// expiry job has not reached it yet.
+func (s *AuthService) customerAccessError(ctx context.Context, cust *models.Customer) error {
+	switch cust.Status {
+	case CustomerStatusBanned:
+		return ErrCustomerBanned
+	case CustomerStatusClosing, CustomerStatusClosed:
+		return ErrAccountClosed
+	case CustomerStatusSuspended:
+		if suspensionExpired(cust, time.Now()) {
+			if _, err := s.ReinstateCustomer(ctx, cust.ID, "", "suspension expired"); err != nil && !errors.Is(err, ErrInvalidStatusTransition) {
+				log.Printf("auth: auto-reinstate customer=%s failed: %v", cust.ID, err)
+				return ErrCustomerSuspended
+			}
+			return nil
+		}
+		return ErrCustomerSuspended
+	}
+	return nil
+}
+
+// CheckCustomerAccess is used on every authenticated API request. Results are
+// cached briefly to avoid a database round trip per request.
+func (s *AuthService) CheckCustomerAccess(ctx context.Context, id string) error {
+	accessCacheMu.Lock()
+	e, ok := accessCache[id]
+	accessCacheMu.Unlock()
+	if ok && time.Since(e.checked) < accessCacheTTL {
+		return e.err
+	}
+
+	cust, err := s.GetCustomerByID(id)
+	if err != nil {
+		return err
+	}
+	aerr := s.customerAccessError(ctx, cust)
+	if aerr == nil && !cust.IsActive {
+		aerr = errors.New("account inactive")
+	}
+	rememberCustomerAccess(id, aerr, time.Now())
+	return aerr
+}
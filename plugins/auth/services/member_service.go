@@ -1,9 +1,11 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"time"
 
+	"go_framework/internal/money"
 	"go_framework/plugins/auth/models"
 
 	"gorm.io/gorm"
@@ -52,3 +54,58 @@ func (s *MemberService) CustomerRefreshTokens(refreshToken string) (string, time
 func (s *MemberService) RevokeCustomerByRefreshHash(hash string) error {
 	return s.core.RevokeCustomerByRefreshHash(hash)
 }
+
+func (s *MemberService) UpdateCustomerProfile(id string, input ProfileUpdate) (*models.Customer, error) {
+	return s.core.UpdateCustomerProfile(id, input)
+}
+func (s *MemberService) VerifyCustomerPassword(id, password string) error {
+	return s.core.VerifyCustomerPassword(id, password)
+}
+func (s *MemberService) RequestEmailChange(id, password, newEmail string) (*models.Customer, string, time.Time, error) {
+	return s.core.RequestEmailChange(id, password, newEmail)
+}
+func (s *MemberService) ConfirmEmailChange(token string) (*models.Customer, error) {
+	return s.core.ConfirmEmailChange(token)
+}
+func (s *MemberService) RevokeAllCustomerSessions(customerID string) error {
+	return s.core.RevokeAllCustomerSessions(customerID)
+}
+func (s *MemberService) CloseCustomerAccount(ctx context.Context, id, reason string) (*models.Customer, error) {
+	return s.core.CloseCustomerAccount(ctx, id, reason)
+}
+func (s *MemberService) FinishCustomerClosure(ctx context.Context, id string) (*models.Customer, error) {
+	return s.core.FinishCustomerClosure(ctx, id)
+}
+func (s *MemberService) HashPassword(pw string) (string, error) {
+	return s.core.HashPassword(pw)
+}
+func (s *MemberService) ListCustomersFiltered(f CustomerListFilter, limit, offset int) ([]models.Customer, int64, error) {
+	return s.core.ListCustomersFiltered(f, limit, offset)
+}
+func (s *MemberService) SetCustomerActive(id string, active bool) (*models.Customer, error) {
+	return s.core.SetCustomerActive(id, active)
+}
+func (s *MemberService) ResetCustomerPassword(id, password string) (*models.Customer, error) {
+	return s.core.ResetCustomerPassword(id, password)
+}
+func (s *MemberService) MarkCustomerEmailVerified(id string) (*models.Customer, error) {
+	return s.core.MarkCustomerEmailVerified(id)
+}
+func (s *MemberService) GetCustomerWalletBalance(id string) (money.Amount, error) {
+	return s.core.GetCustomerWalletBalance(id)
+}
+func (s *MemberService) SuspendCustomer(ctx context.Context, id, adminID, reason string, until *time.Time) (*models.Customer, error) {
+	return s.core.SuspendCustomer(ctx, id, adminID, reason, until)
+}
+func (s *MemberService) BanCustomer(ctx context.Context, id, adminID, reason string) (*models.Customer, error) {
+	return s.core.BanCustomer(ctx, id, adminID, reason)
+}
+func (s *MemberService) ReinstateCustomer(ctx context.Context, id, adminID, reason string) (*models.Customer, error) {
+	return s.core.ReinstateCustomer(ctx, id, adminID, reason)
+}
+func (s *MemberService) ListCustomerStatusHistory(customerID string) ([]models.CustomerStatusHistory, error) {
+	return s.core.ListCustomerStatusHistory(customerID)
+}
+func (s *MemberService) CheckCustomerAccess(ctx context.Context, id string) error {
+	return s.core.CheckCustomerAccess(ctx, id)
+}
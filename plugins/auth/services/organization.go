@@ -0,0 +1,572 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go_framework/internal/access"
+	authpkg "go_framework/internal/auth"
+	"go_framework/internal/db"
+	internaluuid "go_framework/internal/uuid"
+	"go_framework/plugins/auth/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Customer account types. An organization's wallet and containers belong to
+// an ORGANIZATION account, which has no password and cannot log in.
+const (
+	AccountTypePersonal     = "PERSONAL"
+	AccountTypeOrganization = "ORGANIZATION"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrganizationDenied   = errors.New("only organization owners can do this")
+	ErrInvalidOrganization  = errors.New("invalid organization")
+	ErrMemberNotFound       = errors.New("member not found")
+	ErrAlreadyMember        = errors.New("already a member of this organization")
+	ErrLastOwner            = errors.New("an organization needs at least one owner")
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrInvitationInvalid    = errors.New("invalid or expired invitation")
+	ErrInvitationEmail      = errors.New("invitation was sent to a different email address")
+	ErrSoleOwner            = errors.New("transfer ownership of your organizations before closing the account")
+)
+
+var (
+	defaultInvitationTTL   = 7 * 24 * time.Hour
+	organizationEmailHost  = "organizations.invalid"
+	maxOrganizationNameLen = 255
+)
+
+// InvitationTTL returns how long an organization invitation stays valid.
+func InvitationTTL() time.Duration { return defaultInvitationTTL }
+
+// MemberOrganization is an organization as seen by one of its members.
+type MemberOrganization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	AccountID string    `json:"account_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrganizationMemberView is a member with their profile.
+type OrganizationMemberView struct {
+	CustomerID string    `json:"customer_id"`
+	Email      string    `json:"email"`
+	FullName   string    `json:"full_name"`
+	Role       string    `json:"role"`
+	JoinedAt   time.Time `json:"joined_at"`
+}
+
+type OrganizationService struct {
+	db   *gorm.DB
+	core *AuthService
+}
+
+func NewOrganizationService(gdb *gorm.DB) (*OrganizationService, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &OrganizationService{db: gdb, core: New(gdb)}, nil
+}
+
+func NewOrganizationServiceFromDefault() (*OrganizationService, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewOrganizationService(gdb)
+}
+
+func validOrganizationName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxOrganizationNameLen {
+		return "", fmt.Errorf("%w: name is required (max %d characters)", ErrInvalidOrganization, maxOrganizationNameLen)
+	}
+	return name, nil
+}
+
+func validRole(role string) error {
+	if !access.ValidRole(role) {
+		return fmt.Errorf("%w: role must be owner, billing, developer or viewer", ErrInvalidOrganization)
+	}
+	return nil
+}
+
+// CreateOrganization creates an organization with its billing account and
+// makes customerID its owner.
+// Usage: Customer
+func (s *OrganizationService) CreateOrganization(customerID, name string) (*MemberOrganization, error) {
+	name, err := validOrganizationName(name)
+	if err != nil {
+		return nil, err
+	}
+	creator, err := s.core.GetCustomerByID(customerID)
+	if err != nil {
+		return nil, err
+	}
+	if creator.AccountType == AccountTypeOrganization {
+		return nil, ErrOrganizationDenied
+	}
+	if creator.ClosedAt != nil {
+		return nil, ErrAccountClosed
+	}
+
+	accountID, err := internaluuid.New()
+	if err != nil {
+		return nil, err
+	}
+	// A random hash that no password can match.
+	_, unusable, err := authpkg.GenerateOpaqueRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	org := &models.Organization{Name: name, AccountID: accountID, CreatedBy: &creator.ID}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.Customer{
+			ID:           accountID,
+			Email:        fmt.Sprintf("org+%s@%s", accountID, organizationEmailHost),
+			PasswordHash: "!" + unusable,
+			FullName:     name,
+			IsActive:     true,
+			Status:       CustomerStatusActive,
+			AccountType:  AccountTypeOrganization,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationMember{
+			OrganizationID: org.ID,
+			CustomerID:     creator.ID,
+			Role:           access.RoleOwner,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MemberOrganization{ID: org.ID, Name: org.Name, AccountID: org.AccountID, Role: access.RoleOwner, CreatedAt: org.CreatedAt}, nil
+}
+
+// ListCustomerOrganizations lists the organizations customerID belongs to.
+// Usage: Customer
+func (s *OrganizationService) ListCustomerOrganizations(customerID string) ([]MemberOrganization, error) {
+	list := []MemberOrganization{}
+	err := s.db.Raw(`
+		SELECT o.id, o.name, o.account_id, m.role, o.created_at
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.customer_id = ?
+		ORDER BY o.name ASC`, customerID).Scan(&list).Error
+	return list, err
+}
+
+// GetOrganization returns an organization customerID belongs to. Other
+// customers get ErrOrganizationNotFound.
+// Usage: Customer
+func (s *OrganizationService) GetOrganization(customerID, orgID string) (*MemberOrganization, error) {
+	return s.membership(s.db, customerID, orgID)
+}
+
+func (s *OrganizationService) membership(tx *gorm.DB, customerID, orgID string) (*MemberOrganization, error) {
+	if !internaluuid.Valid(orgID) {
+		return nil, ErrOrganizationNotFound
+	}
+	var list []MemberOrganization
+	if err := tx.Raw(`
+		SELECT o.id, o.name, o.account_id, m.role, o.created_at
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE o.id = ? AND m.customer_id = ?`, orgID, customerID).Scan(&list).Error; err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrOrganizationNotFound
+	}
+	return &list[0], nil
+}
+
+// ownerOf locks the organization and checks customerID owns it. The lock
+// serialises membership changes so the last owner cannot be removed by two
+// concurrent requests.
+func (s *OrganizationService) ownerOf(tx *gorm.DB, customerID, orgID string) (*MemberOrganization, error) {
+	org, err := s.membership(tx, customerID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.RoleAllows(org.Role, access.ManageMembers) {
+		return nil, ErrOrganizationDenied
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", org.ID).Take(&models.Organization{}).Error; err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// UpdateOrganization renames an organization and updates the tax details
+// printed on its invoices. The billing account carries the same name.
+// Usage: Customer (owner)
+func (s *OrganizationService) UpdateOrganization(customerID, orgID string, input ProfileUpdate) (*MemberOrganization, *models.Customer, error) {
+	name, err := validOrganizationName(input.FullName)
+	if err != nil {
+		return nil, nil, err
+	}
+	input.FullName = name
+	org, err := s.GetOrganization(customerID, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !access.RoleAllows(org.Role, access.ManageMembers) {
+		return nil, nil, ErrOrganizationDenied
+	}
+	account, err := s.core.UpdateCustomerProfile(org.AccountID, input)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.db.Model(&models.Organization{}).Where("id = ?", org.ID).Update("name", name).Error; err != nil {
+		return nil, nil, err
+	}
+	org.Name = name
+	return org, account, nil
+}
+
+// GetOrganizationAccount returns the billing account of an organization
+// customerID belongs to.
+// Usage: Customer
+func (s *OrganizationService) GetOrganizationAccount(customerID, orgID string) (*MemberOrganization, *models.Customer, error) {
+	org, err := s.GetOrganization(customerID, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	account, err := s.core.GetCustomerByID(org.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return org, account, nil
+}
+
+// ListMembers lists the members of an organization customerID belongs to.
+// Usage: Customer
+func (s *OrganizationService) ListMembers(customerID, orgID string) ([]OrganizationMemberView, error) {
+	org, err := s.GetOrganization(customerID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return s.listMembers(org.ID)
+}
+
+func (s *OrganizationService) listMembers(orgID string) ([]OrganizationMemberView, error) {
+	list := []OrganizationMemberView{}
+	err := s.db.Raw(`
+		SELECT m.customer_id, c.email, c.full_name, m.role, m.created_at AS joined_at
+		FROM organization_members m
+		JOIN customers c ON c.id = m.customer_id
+		WHERE m.organization_id = ?
+		ORDER BY m.created_at ASC`, orgID).Scan(&list).Error
+	return list, err
+}
+
+// UpdateMemberRole changes a member's role. The last owner cannot be
+// demoted.
+// Usage: Customer (owner)
+func (s *OrganizationService) UpdateMemberRole(customerID, orgID, memberID, role string) error {
+	if err := validRole(role); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		org, err := s.ownerOf(tx, customerID, orgID)
+		if err != nil {
+			return err
+		}
+		member, err := s.member(tx, org.ID, memberID)
+		if err != nil {
+			return err
+		}
+		if member.Role == access.RoleOwner && role != access.RoleOwner {
+			if err := s.keepAnOwner(tx, org.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(member).Update("role", role).Error
+	})
+}
+
+// RemoveMember removes a member. Owners can remove anyone; other members can
+// only leave. The last owner cannot leave.
+// Usage: Customer
+func (s *OrganizationService) RemoveMember(customerID, orgID, memberID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var org *MemberOrganization
+		var err error
+		if memberID == customerID {
+			if org, err = s.membership(tx, customerID, orgID); err == nil {
+				err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", org.ID).Take(&models.Organization{}).Error
+			}
+		} else {
+			org, err = s.ownerOf(tx, customerID, orgID)
+		}
+		if err != nil {
+			return err
+		}
+		member, err := s.member(tx, org.ID, memberID)
+		if err != nil {
+			return err
+		}
+		if member.Role == access.RoleOwner {
+			if err := s.keepAnOwner(tx, org.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(member).Error
+	})
+}
+
+func (s *OrganizationService) member(tx *gorm.DB, orgID, customerID string) (*models.OrganizationMember, error) {
+	if !internaluuid.Valid(customerID) {
+		return nil, ErrMemberNotFound
+	}
+	var m models.OrganizationMember
+	if err := tx.Where("organization_id = ? AND customer_id = ?", orgID, customerID).Take(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMemberNotFound
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// keepAnOwner fails unless the organization has another owner besides the
+// one about to be demoted or removed.
+func (s *OrganizationService) keepAnOwner(tx *gorm.DB, orgID string) error {
+	var owners int64
+	if err := tx.Model(&models.OrganizationMember{}).Where("organization_id = ? AND role = ?", orgID, access.RoleOwner).Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// CreateInvitation invites email to the organization with role. It returns
+// the plain token to be mailed; an earlier pending invitation for the same
+// email is revoked.
+// Usage: Customer (owner)
+func (s *OrganizationService) CreateInvitation(customerID, orgID, email, role string) (*models.OrganizationInvitation, *MemberOrganization, string, error) {
+	if err := validRole(role); err != nil {
+		return nil, nil, "", err
+	}
+	email = strings.TrimSpace(email)
+	plain, hash, err := authpkg.GenerateOpaqueRefreshToken()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	var inv *models.OrganizationInvitation
+	var org *MemberOrganization
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if org, err = s.ownerOf(tx, customerID, orgID); err != nil {
+			return err
+		}
+		var members int64
+		if err := tx.Table("organization_members m").
+			Joins("JOIN customers c ON c.id = m.customer_id").
+			Where("m.organization_id = ? AND LOWER(c.email) = LOWER(?)", org.ID, email).
+			Count(&members).Error; err != nil {
+			return err
+		}
+		if members > 0 {
+			return ErrAlreadyMember
+		}
+		now := time.Now()
+		if err := tx.Model(&models.OrganizationInvitation{}).
+			Where("organization_id = ? AND LOWER(email) = LOWER(?) AND accepted_at IS NULL AND revoked_at IS NULL", org.ID, email).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		inv = &models.OrganizationInvitation{
+			OrganizationID: org.ID,
+			Email:          email,
+			Role:           role,
+			TokenHash:      hash,
+			InvitedBy:      &customerID,
+			ExpiresAt:      now.Add(defaultInvitationTTL),
+		}
+		return tx.Create(inv).Error
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return inv, org, plain, nil
+}
+
+// ListInvitations lists the pending invitations of an organization.
+// Usage: Customer (owner)
+func (s *OrganizationService) ListInvitations(customerID, orgID string) ([]models.OrganizationInvitation, error) {
+	org, err := s.GetOrganization(customerID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.RoleAllows(org.Role, access.ManageMembers) {
+		return nil, ErrOrganizationDenied
+	}
+	list := []models.OrganizationInvitation{}
+	err = s.db.Where("organization_id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", org.ID, time.Now()).
+		Order("created_at DESC").Find(&list).Error
+	return list, err
+}
+
+// RevokeInvitation cancels a pending invitation.
+// Usage: Customer (owner)
+func (s *OrganizationService) RevokeInvitation(customerID, orgID, invitationID string) error {
+	org, err := s.GetOrganization(customerID, orgID)
+	if err != nil {
+		return err
+	}
+	if !access.RoleAllows(org.Role, access.ManageMembers) {
+		return ErrOrganizationDenied
+	}
+	if !internaluuid.Valid(invitationID) {
+		return ErrInvitationNotFound
+	}
+	res := s.db.Model(&models.OrganizationInvitation{}).
+		Where("id = ? AND organization_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitationID, org.ID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation adds customerID to the organization of an invitation
+// token. The invitation must have been sent to the customer's email.
+// Usage: Customer
+func (s *OrganizationService) AcceptInvitation(customerID, token string) (*MemberOrganization, error) {
+	cust, err := s.core.GetCustomerByID(customerID)
+	if err != nil {
+		return nil, err
+	}
+	if cust.AccountType == AccountTypeOrganization {
+		return nil, ErrOrganizationDenied
+	}
+	hash := authpkg.HashOpaqueToken(token)
+	var org *MemberOrganization
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var inv models.OrganizationInvitation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND accepted_at IS NULL AND revoked_at IS NULL", hash).
+			Take(&inv).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationInvalid
+			}
+			return err
+		}
+		if !inv.ExpiresAt.After(time.Now()) {
+			return ErrInvitationInvalid
+		}
+		if !strings.EqualFold(inv.Email, cust.Email) {
+			return ErrInvitationEmail
+		}
+		if _, err := s.member(tx, inv.OrganizationID, cust.ID); err == nil {
+			return ErrAlreadyMember
+		} else if !errors.Is(err, ErrMemberNotFound) {
+			return err
+		}
+		if err := tx.Model(&inv).Update("accepted_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.OrganizationMember{
+			OrganizationID: inv.OrganizationID,
+			CustomerID:     cust.ID,
+			Role:           inv.Role,
+		}).Error; err != nil {
+			return err
+		}
+		org, err = s.membership(tx, cust.ID, inv.OrganizationID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// ListOrganizations lists all organizations with their member count.
+// Usage: Admin
+func (s *OrganizationService) ListOrganizations(query string, limit, offset int) ([]AdminOrganizationView, int64, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	q := s.db.Table("organizations o")
+	if query = strings.TrimSpace(query); query != "" {
+		q = q.Where("LOWER(o.name) LIKE ?", "%"+strings.ToLower(query)+"%")
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	list := []AdminOrganizationView{}
+	err := q.Select(`o.id, o.name, o.account_id, o.created_by, o.created_at,
+			(SELECT COUNT(*) FROM organization_members m WHERE m.organization_id = o.id) AS members`).
+		Order("o.created_at DESC").Limit(limit).Offset(offset).Scan(&list).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// AdminOrganizationView is an organization in the admin list.
+type AdminOrganizationView struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	AccountID string    `json:"account_id"`
+	CreatedBy *string   `json:"created_by,omitempty"`
+	Members   int64     `json:"members"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AdminGetOrganization returns an organization with its members.
+// Usage: Admin
+func (s *OrganizationService) AdminGetOrganization(orgID string) (*models.Organization, []OrganizationMemberView, error) {
+	if !internaluuid.Valid(orgID) {
+		return nil, nil, ErrOrganizationNotFound
+	}
+	var org models.Organization
+	if err := s.db.Where("id = ?", orgID).Take(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrOrganizationNotFound
+		}
+		return nil, nil, err
+	}
+	members, err := s.listMembers(org.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &org, members, nil
+}
+
+// soleOwnedOrganizations counts organizations where customerID is the only
+// owner and other members remain; closing the account would orphan them.
+func soleOwnedOrganizations(tx *gorm.DB, customerID string) (int64, error) {
+	var count int64
+	err := tx.Raw(`
+		SELECT COUNT(*) FROM organization_members m
+		WHERE m.customer_id = ? AND m.role = ?
+		  AND NOT EXISTS (SELECT 1 FROM organization_members o
+		                  WHERE o.organization_id = m.organization_id AND o.role = ? AND o.customer_id <> m.customer_id)
+		  AND EXISTS (SELECT 1 FROM organization_members o
+		              WHERE o.organization_id = m.organization_id AND o.customer_id <> m.customer_id)`,
+		customerID, access.RoleOwner, access.RoleOwner).Scan(&count).Error
+	return count, err
+}
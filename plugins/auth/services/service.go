@@ -1,7 +1,9 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"log"
 	"os"
 	"time"
 
@@ -9,7 +11,6 @@ import (
 	"go_framework/internal/db"
 	"go_framework/plugins/auth/models"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -97,19 +98,37 @@ func (s *AuthService) RevokeSession(id string) error {
 	return s.db.Model(&models.AdminSession{}).Where("id = ?", id).Update("revoked", true).Error
 }
 
-// HashPassword returns bcrypt hash
+// HashPassword validates pw against the password policy and returns a hash
+// made with the configured algorithm. Policy errors wrap
+// authpkg.ErrPasswordPolicy.
 func (s *AuthService) HashPassword(pw string) (string, error) {
-	b, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
-	if err != nil {
+	if err := authpkg.ValidatePassword(pw); err != nil {
 		return "", err
 	}
-	return string(b), nil
+	return authpkg.DefaultPasswordHasher().Hash(pw)
 }
 
 func (s *AuthService) CheckPassword(hash, pw string) bool {
-	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pw)); err != nil {
+	ok, _, err := authpkg.DefaultPasswordHasher().Verify(hash, pw)
+	return err == nil && ok
+}
+
+// checkPasswordAndUpgrade verifies pw and, when the stored hash uses an
+// outdated algorithm or parameters, saves a fresh hash into table/id.
+// Rehash failures are logged only; they must not fail the login.
+func (s *AuthService) checkPasswordAndUpgrade(table, id, hash, pw string) bool {
+	hasher := authpkg.DefaultPasswordHasher()
+	ok, needsRehash, err := hasher.Verify(hash, pw)
+	if err != nil || !ok {
 		return false
 	}
+	if needsRehash {
+		if newHash, herr := hasher.Hash(pw); herr == nil {
+			if uerr := s.db.Table(table).Where("id = ?", id).Update("password_hash", newHash).Error; uerr != nil {
+				log.Printf("auth: rehash %s %s failed: %v", table, id, uerr)
+			}
+		}
+	}
 	return true
 }
 
@@ -124,7 +143,7 @@ func (s *AuthService) AuthenticateAndCreateSession(email, password string) (acce
 	if err != nil {
 		return "", time.Time{}, "", time.Time{}, "", err
 	}
-	if !s.CheckPassword(admin.PasswordHash, password) {
+	if !s.checkPasswordAndUpgrade(admin.TableName(), admin.ID, admin.PasswordHash, password) {
 		return "", time.Time{}, "", time.Time{}, "", errors.New("invalid credentials")
 	}
 	if !admin.IsActive {
@@ -246,9 +265,16 @@ func (s *AuthService) CustomerAuthenticateAndCreateSession(email, password strin
 	if err != nil {
 		return "", time.Time{}, "", time.Time{}, "", err
 	}
-	if !s.CheckPassword(cust.PasswordHash, password) {
+	// Organization accounts only hold a wallet; members sign in as themselves.
+	if cust.AccountType == AccountTypeOrganization {
 		return "", time.Time{}, "", time.Time{}, "", errors.New("invalid credentials")
 	}
+	if !s.checkPasswordAndUpgrade(cust.TableName(), cust.ID, cust.PasswordHash, password) {
+		return "", time.Time{}, "", time.Time{}, "", errors.New("invalid credentials")
+	}
+	if err := s.customerAccessError(context.Background(), cust); err != nil {
+		return "", time.Time{}, "", time.Time{}, "", err
+	}
 	if !cust.IsActive {
 		return "", time.Time{}, "", time.Time{}, "", errors.New("account inactive")
 	}
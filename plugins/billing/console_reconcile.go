@@ -0,0 +1,68 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"go_framework/internal/db"
+	billingservices "go_framework/plugins/billing/services"
+
+	"github.com/spf13/cobra"
+)
+
+// reconcileConsoleCommand builds `billing:reconcile`, which checks every
+// wallet against its transaction history and prints the report as JSON.
+// It exits 1 while issues remain unfixed, so it can gate a cron job.
+func reconcileConsoleCommand() *cobra.Command {
+	var fix bool
+	var customerID, adminID string
+
+	cmd := &cobra.Command{
+		Use:   "billing:reconcile",
+		Short: "Check wallet balances against their transaction history",
+		Long: "Check every customer's wallet: cached balance against the sum of its transactions, " +
+			"the balance_before/balance_after chain, SUCCESS topups against their TOPUP credits, " +
+			"and the ledger wallet account. Prints a JSON report and records the run. " +
+			"With --fix, balance, ledger and topup credit issues are corrected with ADMIN_ADJUSTMENT " +
+			"entries that reference the run; chain breaks and orphan credits are left for review.",
+		Run: func(cmd *cobra.Command, args []string) {
+			gdb, err := db.GetGormDB()
+			if err != nil || gdb == nil {
+				log.Fatalf("db unavailable: %v", err)
+			}
+			svc, err := billingservices.NewWalletService(gdb)
+			if err != nil {
+				log.Fatalf("service init: %v", err)
+			}
+
+			opts := billingservices.ReconcileOptions{Fix: fix, TriggeredBy: billingservices.ReconcileByConsole}
+			if customerID != "" {
+				opts.CustomerID = &customerID
+			}
+			if adminID != "" {
+				opts.AdminID = &adminID
+			}
+			report, err := svc.Reconcile(context.Background(), opts)
+			if err != nil {
+				log.Fatalf("reconcile: %v", err)
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				log.Fatalf("encode json: %v", err)
+			}
+			if report.IssuesFound > report.IssuesFixed {
+				fmt.Fprintf(os.Stderr, "%d of %d issue(s) unresolved\n", report.IssuesFound-report.IssuesFixed, report.IssuesFound)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&fix, "fix", false, "post correcting ADMIN_ADJUSTMENT entries for fixable issues")
+	cmd.Flags().StringVar(&customerID, "customer", "", "only reconcile this customer ID")
+	cmd.Flags().StringVar(&adminID, "admin-id", "", "admin recorded as creator of correcting entries")
+	return cmd
+}
@@ -0,0 +1,132 @@
+package billing
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go_framework/internal/db"
+	billingmodels "go_framework/plugins/billing/models"
+	billingservices "go_framework/plugins/billing/services"
+
+	"github.com/spf13/cobra"
+)
+
+// webhookConsoleCommand builds `billing:webhook` for working with the
+// webhook inbox from the shell.
+func webhookConsoleCommand() *cobra.Command {
+	var asJSON bool
+
+	newTopupService := func() *billingservices.TopupService {
+		gdb, err := db.GetGormDB()
+		if err != nil || gdb == nil {
+			log.Fatalf("db unavailable: %v", err)
+		}
+		svc, serr := billingservices.NewTopupService(gdb)
+		if serr != nil {
+			log.Fatalf("service init: %v", serr)
+		}
+		return svc
+	}
+	output := func(v interface{}, text string) {
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(v); err != nil {
+				log.Fatalf("encode json: %v", err)
+			}
+			return
+		}
+		fmt.Println(text)
+	}
+	describe := func(e *billingmodels.WebhookInboxEntry) string {
+		deref := func(p *string) string {
+			if p == nil {
+				return "-"
+			}
+			return *p
+		}
+		return fmt.Sprintf("id=%s provider=%s status=%s external_id=%s event_id=%s attempts=%d received=%s error=%q",
+			e.ID, e.Provider, e.Status, deref(e.ExternalID), deref(e.EventID), e.Attempts,
+			e.CreatedAt.Format(time.RFC3339), deref(e.Error))
+	}
+
+	webhookCmd := &cobra.Command{
+		Use:   "billing:webhook",
+		Short: "Webhook inbox commands for billing plugin",
+	}
+	webhookCmd.PersistentFlags().BoolVar(&asJSON, "json", false, "print machine-readable JSON")
+
+	var lsStatus, lsProvider string
+	var lsLimit int
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List webhook inbox entries, newest first",
+		Run: func(cmd *cobra.Command, args []string) {
+			var statusPtr, providerPtr *string
+			if lsStatus != "" {
+				statusPtr = &lsStatus
+			}
+			if lsProvider != "" {
+				providerPtr = &lsProvider
+			}
+			entries, total, err := newTopupService().ListWebhookInbox(struct {
+				Provider   *string
+				Status     *string
+				ExternalID *string
+				Limit      int
+				Offset     int
+			}{Provider: providerPtr, Status: statusPtr, Limit: lsLimit})
+			if err != nil {
+				log.Fatalf("list webhooks: %v", err)
+			}
+			if asJSON {
+				output(map[string]interface{}{"webhooks": entries, "total": total}, "")
+				return
+			}
+			for i := range entries {
+				fmt.Println(describe(&entries[i]))
+			}
+			fmt.Printf("%d of %d entries\n", len(entries), total)
+		},
+	}
+	listCmd.Flags().StringVar(&lsStatus, "status", "", "RECEIVED, PROCESSING, PROCESSED, FAILED, REJECTED, DUPLICATE or UNKNOWN")
+	listCmd.Flags().StringVar(&lsProvider, "provider", "", "gateway slug, or payment for the generic endpoint")
+	listCmd.Flags().IntVar(&lsLimit, "limit", 50, "maximum entries to show")
+
+	replayCmd := &cobra.Command{
+		Use:   "replay <id>",
+		Short: "Process one inbox entry again",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			entry, err := newTopupService().ReplayWebhook(args[0])
+			if entry == nil {
+				log.Fatalf("replay: %v", err)
+			}
+			output(entry, describe(entry))
+		},
+	}
+
+	var rpSince time.Duration
+	var rpLimit int
+	reprocessCmd := &cobra.Command{
+		Use:   "reprocess",
+		Short: "Replay FAILED inbox entries",
+		Long:  "Replay FAILED inbox entries received within --since, oldest first. Entries that fail again stay FAILED with the new error.",
+		Run: func(cmd *cobra.Command, args []string) {
+			processed, failed, err := newTopupService().ReprocessFailedWebhooks(time.Now().Add(-rpSince), rpLimit)
+			if err != nil {
+				log.Fatalf("reprocess: %v", err)
+			}
+			output(map[string]int{"processed": processed, "failed": failed},
+				fmt.Sprintf("processed=%d failed=%d", processed, failed))
+		},
+	}
+	reprocessCmd.Flags().DurationVar(&rpSince, "since", 7*24*time.Hour, "only entries received within this window")
+	reprocessCmd.Flags().IntVar(&rpLimit, "limit", 100, "maximum entries to replay")
+
+	webhookCmd.AddCommand(listCmd, replayCmd, reprocessCmd)
+	return webhookCmd
+}
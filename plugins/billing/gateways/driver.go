@@ -0,0 +1,269 @@
+// Package gateways holds the payment provider drivers used by billing.
+//
+// A driver is selected by PaymentGateway.Slug (or by a "driver" key in the
+// gateway's config, so several gateways can share one provider). It knows how
+// to open a charge, authenticate and decode the provider's notifications, and
+// ask the provider for the current state of a charge.
+package gateways
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+// Topup statuses a driver maps provider states onto.
+const (
+	StatusPending = "PENDING"
+	StatusSuccess = "SUCCESS"
+	StatusFailed  = "FAILED"
+	StatusExpired = "EXPIRED"
+)
+
+var (
+	ErrDriverNotFound       = errors.New("payment gateway driver not found")
+	ErrInvalidSignature     = errors.New("invalid webhook signature")
+	ErrGatewayMisconfigured = errors.New("payment gateway is missing credentials")
+	ErrInvalidPayload       = errors.New("invalid webhook payload")
+	ErrChargeNotFound       = errors.New("charge not found at provider")
+	ErrUnsupportedAmount    = errors.New("amount not supported by provider")
+)
+
+// ChargeRequest describes a payment to open at the provider.
+type ChargeRequest struct {
+	ExternalID    string // our reference, echoed back in notifications
+	Amount        money.Amount
+	Description   string
+	CustomerName  string
+	CustomerEmail string
+	SuccessURL    string
+	Expiry        time.Duration
+}
+
+// Charge is what the provider returned for a new payment.
+type Charge struct {
+	ExternalID string
+	ProviderID string
+	PaymentURL string
+	ExpiresAt  *time.Time
+}
+
+// WebhookEvent is a decoded provider notification.
+type WebhookEvent struct {
+	// EventID identifies the notification at the provider; redeliveries of
+	// the same notification share it.
+	EventID        string
+	ExternalID     string
+	Status         string
+	PaidAmount     *money.Amount
+	PaymentMethod  string
+	PaymentChannel string
+	Data           map[string]interface{}
+}
+
+// StatusResult is the provider's current view of a charge.
+type StatusResult struct {
+	Status     string
+	PaidAmount *money.Amount
+	Data       map[string]interface{}
+}
+
+// Driver talks to one payment provider.
+type Driver interface {
+	Name() string
+	CreateCharge(ctx context.Context, g *models.PaymentGateway, req ChargeRequest) (*Charge, error)
+	// ParseWebhook decodes a notification body. It does not authenticate it;
+	// call VerifyWebhook with the gateway the event belongs to.
+	ParseWebhook(body []byte) (*WebhookEvent, error)
+	VerifyWebhook(g *models.PaymentGateway, header http.Header, body []byte, ev *WebhookEvent) error
+	QueryStatus(ctx context.Context, g *models.PaymentGateway, externalID string) (*StatusResult, error)
+	Cancel(ctx context.Context, g *models.PaymentGateway, externalID string) error
+}
+
+var (
+	mu      sync.RWMutex
+	drivers = map[string]Driver{}
+)
+
+// Register adds a driver under its Name; a later registration replaces it.
+func Register(d Driver) {
+	mu.Lock()
+	defer mu.Unlock()
+	drivers[d.Name()] = d
+}
+
+// Get returns the driver registered under name.
+func Get(name string) (Driver, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	d, ok := drivers[name]
+	if !ok {
+		return nil, ErrDriverNotFound
+	}
+	return d, nil
+}
+
+// ForGateway resolves the driver of a gateway: config "driver" first, then
+// the gateway's slug.
+func ForGateway(g *models.PaymentGateway) (Driver, error) {
+	if g == nil {
+		return nil, ErrDriverNotFound
+	}
+	return Get(DriverName(g))
+}
+
+// DriverName is the driver key of a gateway.
+func DriverName(g *models.PaymentGateway) string {
+	if name := ConfigString(g, "driver"); name != "" {
+		return name
+	}
+	return g.Slug
+}
+
+// WholeUnitDriver is implemented by drivers whose provider only charges
+// whole currency units.
+type WholeUnitDriver interface {
+	WholeUnitsOnly() bool
+}
+
+// RequiresWholeUnits reports whether g's driver refuses fractional amounts.
+// Gateways without a driver (manual transfers) take any amount.
+func RequiresWholeUnits(g *models.PaymentGateway) bool {
+	d, err := ForGateway(g)
+	if err != nil {
+		return false
+	}
+	w, ok := d.(WholeUnitDriver)
+	return ok && w.WholeUnitsOnly()
+}
+
+// RegisterDefaults registers the built-in providers.
+func RegisterDefaults() {
+	client := &http.Client{Timeout: 15 * time.Second}
+	Register(NewMidtrans(client))
+	Register(NewXendit(client))
+}
+
+// ConfigString reads a string key from PaymentGateway.Config (jsonb).
+func ConfigString(g *models.PaymentGateway, key string) string {
+	v, _ := configMap(g)[key].(string)
+	return v
+}
+
+// ConfigBool reads a boolean key, accepting JSON booleans and "true"/"1".
+func ConfigBool(g *models.PaymentGateway, key string) bool {
+	switch v := configMap(g)[key].(type) {
+	case bool:
+		return v
+	case string:
+		v = strings.ToLower(v)
+		return v == "true" || v == "1"
+	}
+	return false
+}
+
+func configMap(g *models.PaymentGateway) map[string]interface{} {
+	if g == nil || g.Config == "" {
+		return nil
+	}
+	var cfg map[string]interface{}
+	if err := json.Unmarshal([]byte(g.Config), &cfg); err != nil {
+		return nil
+	}
+	return cfg
+}
+
+// decodeJSON decodes with numbers kept as json.Number so amounts never pass
+// through float64.
+func decodeJSON(body []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var out map[string]interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, ErrInvalidPayload
+	}
+	return out, nil
+}
+
+// jsonString renders a decoded JSON scalar as its literal text.
+func jsonString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	}
+	return ""
+}
+
+// parseAmount reads an amount field; empty fields give nil.
+func parseAmount(v interface{}) (*money.Amount, error) {
+	s := jsonString(v)
+	if s == "" {
+		return nil, nil
+	}
+	a, err := money.Parse(s)
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	return &a, nil
+}
+
+// wholeUnits returns the amount in whole currency units, for providers that
+// only take integer IDR.
+func wholeUnits(a money.Amount) (int64, error) {
+	if a.Minor()%100 != 0 {
+		return 0, ErrUnsupportedAmount
+	}
+	return a.Minor() / 100, nil
+}
+
+// doJSON sends an optional JSON body with HTTP basic auth (key as username,
+// empty password, as both providers expect) and decodes the JSON response
+// into out. Non-2xx statuses are returned for the caller to interpret.
+func doJSON(ctx context.Context, client *http.Client, provider, method, endpoint, key string, in, out interface{}) (int, error) {
+	var reader io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.SetBasicAuth(key, "")
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if len(bytes.TrimSpace(raw)) > 0 && out != nil {
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("%s: invalid response (%d)", provider, resp.StatusCode)
+		}
+	}
+	return resp.StatusCode, nil
+}
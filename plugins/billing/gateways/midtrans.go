@@ -0,0 +1,238 @@
+package gateways
+
+import (
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go_framework/plugins/billing/models"
+)
+
+// Midtrans drives Snap checkout (charges) and the Core API (status, cancel).
+//
+// Gateway config: server_key, is_production, and optional snap_url / api_url
+// overrides of the sandbox or production hosts.
+type Midtrans struct {
+	client *http.Client
+}
+
+func NewMidtrans(client *http.Client) *Midtrans {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Midtrans{client: client}
+}
+
+func (m *Midtrans) Name() string { return "midtrans" }
+
+// WholeUnitsOnly reports that Snap only takes integer IDR.
+func (m *Midtrans) WholeUnitsOnly() bool { return true }
+
+func (m *Midtrans) snapURL(g *models.PaymentGateway) string {
+	if u := ConfigString(g, "snap_url"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	if ConfigBool(g, "is_production") {
+		return "https://app.midtrans.com"
+	}
+	return "https://app.sandbox.midtrans.com"
+}
+
+func (m *Midtrans) apiURL(g *models.PaymentGateway) string {
+	if u := ConfigString(g, "api_url"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	if ConfigBool(g, "is_production") {
+		return "https://api.midtrans.com"
+	}
+	return "https://api.sandbox.midtrans.com"
+}
+
+// CreateCharge opens a Snap transaction: POST /snap/v1/transactions.
+func (m *Midtrans) CreateCharge(ctx context.Context, g *models.PaymentGateway, req ChargeRequest) (*Charge, error) {
+	gross, err := wholeUnits(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{
+		"transaction_details": map[string]interface{}{
+			"order_id":     req.ExternalID,
+			"gross_amount": gross,
+		},
+		"customer_details": map[string]interface{}{
+			"first_name": req.CustomerName,
+			"email":      req.CustomerEmail,
+		},
+	}
+	if req.SuccessURL != "" {
+		body["callbacks"] = map[string]interface{}{"finish": req.SuccessURL}
+	}
+	var expiresAt *time.Time
+	if req.Expiry > 0 {
+		minutes := int(req.Expiry / time.Minute)
+		if minutes < 1 {
+			minutes = 1
+		}
+		body["expiry"] = map[string]interface{}{"unit": "minutes", "duration": minutes}
+		t := time.Now().Add(time.Duration(minutes) * time.Minute)
+		expiresAt = &t
+	}
+
+	var resp struct {
+		Token         string   `json:"token"`
+		RedirectURL   string   `json:"redirect_url"`
+		ErrorMessages []string `json:"error_messages"`
+	}
+	status, err := m.do(ctx, g, http.MethodPost, m.snapURL(g)+"/snap/v1/transactions", body, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if status/100 != 2 || resp.RedirectURL == "" {
+		return nil, fmt.Errorf("midtrans: create charge failed (%d): %s", status, strings.Join(resp.ErrorMessages, "; "))
+	}
+	return &Charge{
+		ExternalID: req.ExternalID,
+		ProviderID: resp.Token,
+		PaymentURL: resp.RedirectURL,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+func (m *Midtrans) ParseWebhook(body []byte) (*WebhookEvent, error) {
+	payload, err := decodeJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	orderID := jsonString(payload["order_id"])
+	if orderID == "" {
+		return nil, fmt.Errorf("%w: missing order_id", ErrInvalidPayload)
+	}
+	paid, err := parseAmount(payload["gross_amount"])
+	if err != nil {
+		return nil, err
+	}
+	txStatus := jsonString(payload["transaction_status"])
+	status := midtransStatus(txStatus, jsonString(payload["fraud_status"]))
+	payload["status"] = status
+	var eventID string
+	if id := jsonString(payload["transaction_id"]); id != "" {
+		// Midtrans notifies once per state change of a transaction.
+		eventID = id + ":" + txStatus
+	}
+	return &WebhookEvent{
+		EventID:        eventID,
+		ExternalID:     orderID,
+		Status:         status,
+		PaidAmount:     paid,
+		PaymentMethod:  jsonString(payload["payment_type"]),
+		PaymentChannel: midtransChannel(payload),
+		Data:           payload,
+	}, nil
+}
+
+// VerifyWebhook checks signature_key = SHA512(order_id + status_code +
+// gross_amount + server_key).
+func (m *Midtrans) VerifyWebhook(g *models.PaymentGateway, _ http.Header, _ []byte, ev *WebhookEvent) error {
+	serverKey := ConfigString(g, "server_key")
+	if serverKey == "" {
+		return ErrGatewayMisconfigured
+	}
+	want := MidtransSignature(ev.ExternalID, jsonString(ev.Data["status_code"]), jsonString(ev.Data["gross_amount"]), serverKey)
+	got := strings.ToLower(jsonString(ev.Data["signature_key"]))
+	if subtle.ConstantTimeCompare([]byte(want), []byte(got)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// QueryStatus calls GET /v2/{order_id}/status.
+func (m *Midtrans) QueryStatus(ctx context.Context, g *models.PaymentGateway, externalID string) (*StatusResult, error) {
+	var resp map[string]interface{}
+	status, err := m.do(ctx, g, http.MethodGet, m.apiURL(g)+"/v2/"+url.PathEscape(externalID)+"/status", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	// The Core API reports missing orders in the body with HTTP 200.
+	if status == http.StatusNotFound || jsonString(resp["status_code"]) == "404" {
+		return nil, ErrChargeNotFound
+	}
+	if status/100 != 2 {
+		return nil, fmt.Errorf("midtrans: status query failed (%d)", status)
+	}
+	paid, err := parseAmount(resp["gross_amount"])
+	if err != nil {
+		return nil, err
+	}
+	return &StatusResult{
+		Status:     midtransStatus(jsonString(resp["transaction_status"]), jsonString(resp["fraud_status"])),
+		PaidAmount: paid,
+		Data:       resp,
+	}, nil
+}
+
+// Cancel calls POST /v2/{order_id}/cancel. An order the customer never opened
+// in Snap does not exist at Midtrans yet and is treated as cancelled.
+func (m *Midtrans) Cancel(ctx context.Context, g *models.PaymentGateway, externalID string) error {
+	var resp map[string]interface{}
+	status, err := m.do(ctx, g, http.MethodPost, m.apiURL(g)+"/v2/"+url.PathEscape(externalID)+"/cancel", nil, &resp)
+	if err != nil {
+		return err
+	}
+	code := jsonString(resp["status_code"])
+	if status == http.StatusNotFound || code == "404" {
+		return nil
+	}
+	if status/100 != 2 || (code != "" && code[0] != '2') {
+		return fmt.Errorf("midtrans: cancel failed (%d %s): %s", status, code, jsonString(resp["status_message"]))
+	}
+	return nil
+}
+
+func (m *Midtrans) do(ctx context.Context, g *models.PaymentGateway, method, endpoint string, in, out interface{}) (int, error) {
+	serverKey := ConfigString(g, "server_key")
+	if serverKey == "" {
+		return 0, ErrGatewayMisconfigured
+	}
+	return doJSON(ctx, m.client, "midtrans", method, endpoint, serverKey, in, out)
+}
+
+// MidtransSignature computes SHA512(order_id + status_code + gross_amount + server_key).
+func MidtransSignature(orderID, statusCode, grossAmount, serverKey string) string {
+	sum := sha512.Sum512([]byte(orderID + statusCode + grossAmount + serverKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func midtransStatus(transactionStatus, fraudStatus string) string {
+	switch transactionStatus {
+	case "capture":
+		if fraudStatus == "accept" {
+			return StatusSuccess
+		}
+		return StatusPending
+	case "settlement":
+		return StatusSuccess
+	case "deny", "cancel", "failure":
+		return StatusFailed
+	case "expire":
+		return StatusExpired
+	}
+	return StatusPending
+}
+
+func midtransChannel(payload map[string]interface{}) string {
+	if vas, ok := payload["va_numbers"].([]interface{}); ok && len(vas) > 0 {
+		if va, ok := vas[0].(map[string]interface{}); ok {
+			return jsonString(va["bank"])
+		}
+	}
+	if s := jsonString(payload["issuer"]); s != "" {
+		return s
+	}
+	return jsonString(payload["store"])
+}
@@ -0,0 +1,197 @@
+package gateways
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+// fakeMidtrans serves the Snap and Core API endpoints the driver uses.
+func fakeMidtrans(t *testing.T, serverKey string, orders map[string]map[string]interface{}) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	auth := func(w http.ResponseWriter, r *http.Request) bool {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != serverKey || pass != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error_messages":["Access denied"]}`)
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("/snap/v1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		if !auth(w, r) {
+			return
+		}
+		var body struct {
+			TransactionDetails struct {
+				OrderID     string      `json:"order_id"`
+				GrossAmount json.Number `json:"gross_amount"`
+			} `json:"transaction_details"`
+			Expiry struct {
+				Duration int `json:"duration"`
+			} `json:"expiry"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("snap body: %v", err)
+		}
+		if body.TransactionDetails.GrossAmount.String() != "150000" {
+			t.Errorf("gross_amount = %s, want 150000", body.TransactionDetails.GrossAmount)
+		}
+		if body.Expiry.Duration != 60 {
+			t.Errorf("expiry duration = %d, want 60", body.Expiry.Duration)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":"tok-1","redirect_url":"https://snap.test/v2/vtweb/%s"}`, body.TransactionDetails.OrderID)
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if !auth(w, r) {
+			return
+		}
+		rest := strings.TrimPrefix(r.URL.Path, "/v2/")
+		i := strings.LastIndex(rest, "/")
+		orderID, action := rest[:i], rest[i+1:]
+		order, ok := orders[orderID]
+		if !ok {
+			fmt.Fprint(w, `{"status_code":"404","status_message":"Transaction doesn't exist."}`)
+			return
+		}
+		if action == "cancel" {
+			order["transaction_status"] = "cancel"
+		}
+		json.NewEncoder(w).Encode(order)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func midtransGateway(url string) *models.PaymentGateway {
+	return &models.PaymentGateway{
+		Slug:   "midtrans",
+		Config: fmt.Sprintf(`{"server_key":"SB-srv","snap_url":%q,"api_url":%q}`, url, url),
+	}
+}
+
+func TestMidtransCreateCharge(t *testing.T) {
+	srv := fakeMidtrans(t, "SB-srv", nil)
+	d := NewMidtrans(srv.Client())
+
+	charge, err := d.CreateCharge(context.Background(), midtransGateway(srv.URL), ChargeRequest{
+		ExternalID: "order-1",
+		Amount:     money.MustParse("150000"),
+		Expiry:     time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("CreateCharge: %v", err)
+	}
+	if charge.PaymentURL != "https://snap.test/v2/vtweb/order-1" || charge.ProviderID != "tok-1" {
+		t.Fatalf("unexpected charge %+v", charge)
+	}
+	if charge.ExpiresAt == nil {
+		t.Fatal("expected an expiry")
+	}
+
+	if _, err := d.CreateCharge(context.Background(), midtransGateway(srv.URL), ChargeRequest{
+		ExternalID: "order-2",
+		Amount:     money.MustParse("150000.50"),
+	}); !errors.Is(err, ErrUnsupportedAmount) {
+		t.Fatalf("fractional IDR: got %v", err)
+	}
+
+	bad := &models.PaymentGateway{Config: fmt.Sprintf(`{"server_key":"wrong","snap_url":%q}`, srv.URL)}
+	if _, err := d.CreateCharge(context.Background(), bad, ChargeRequest{ExternalID: "x", Amount: money.FromMajor(1)}); err == nil {
+		t.Fatal("expected an error for a rejected key")
+	}
+}
+
+func TestMidtransQueryStatusAndCancel(t *testing.T) {
+	orders := map[string]map[string]interface{}{
+		"paid":    {"status_code": "200", "transaction_status": "settlement", "gross_amount": "150000.00"},
+		"pending": {"status_code": "201", "transaction_status": "pending", "gross_amount": "150000.00"},
+	}
+	srv := fakeMidtrans(t, "SB-srv", orders)
+	d := NewMidtrans(srv.Client())
+	g := midtransGateway(srv.URL)
+	ctx := context.Background()
+
+	res, err := d.QueryStatus(ctx, g, "paid")
+	if err != nil {
+		t.Fatalf("QueryStatus: %v", err)
+	}
+	if res.Status != StatusSuccess || res.PaidAmount == nil || *res.PaidAmount != money.MustParse("150000") {
+		t.Fatalf("unexpected status %+v", res)
+	}
+	if _, err := d.QueryStatus(ctx, g, "missing"); !errors.Is(err, ErrChargeNotFound) {
+		t.Fatalf("missing order: got %v", err)
+	}
+
+	if err := d.Cancel(ctx, g, "pending"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	res, _ = d.QueryStatus(ctx, g, "pending")
+	if res.Status != StatusFailed {
+		t.Fatalf("status after cancel = %s", res.Status)
+	}
+	// Orders never opened in Snap are unknown to Midtrans.
+	if err := d.Cancel(ctx, g, "missing"); err != nil {
+		t.Fatalf("Cancel unknown order: %v", err)
+	}
+}
+
+func TestMidtransWebhook(t *testing.T) {
+	d := NewMidtrans(nil)
+	g := midtransGateway("http://unused")
+	sig := MidtransSignature("order-1", "200", "150000.00", "SB-srv")
+	body := []byte(`{"order_id":"order-1","transaction_id":"tx-9","status_code":"200","gross_amount":"150000.00",` +
+		`"transaction_status":"settlement","payment_type":"bank_transfer",` +
+		`"va_numbers":[{"bank":"bca","va_number":"123"}],"signature_key":"` + sig + `"}`)
+
+	ev, err := d.ParseWebhook(body)
+	if err != nil {
+		t.Fatalf("ParseWebhook: %v", err)
+	}
+	if ev.ExternalID != "order-1" || ev.EventID != "tx-9:settlement" || ev.Status != StatusSuccess || ev.PaymentChannel != "bca" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if ev.PaidAmount == nil || *ev.PaidAmount != money.MustParse("150000") {
+		t.Fatalf("paid amount = %v", ev.PaidAmount)
+	}
+	if err := d.VerifyWebhook(g, nil, body, ev); err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+
+	ev.Data["gross_amount"] = "1.00"
+	if err := d.VerifyWebhook(g, nil, body, ev); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("tampered amount: got %v", err)
+	}
+	if err := d.VerifyWebhook(&models.PaymentGateway{}, nil, body, ev); !errors.Is(err, ErrGatewayMisconfigured) {
+		t.Fatalf("no server key: got %v", err)
+	}
+}
+
+func TestMidtransStatusMapping(t *testing.T) {
+	cases := []struct{ tx, fraud, want string }{
+		{"capture", "accept", StatusSuccess},
+		{"capture", "challenge", StatusPending},
+		{"settlement", "", StatusSuccess},
+		{"pending", "", StatusPending},
+		{"deny", "", StatusFailed},
+		{"cancel", "", StatusFailed},
+		{"expire", "", StatusExpired},
+	}
+	for _, c := range cases {
+		if got := midtransStatus(c.tx, c.fraud); got != c.want {
+			t.Errorf("midtransStatus(%q, %q) = %s, want %s", c.tx, c.fraud, got, c.want)
+		}
+	}
+}
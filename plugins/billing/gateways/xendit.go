@@ -0,0 +1,226 @@
+package gateways
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go_framework/plugins/billing/models"
+)
+
+// Xendit drives the Invoice API.
+//
+// Gateway config: api_key (secret key), callback_token, and an optional
+// base_url override. Test and live mode are chosen by the key itself.
+type Xendit struct {
+	client *http.Client
+}
+
+func NewXendit(client *http.Client) *Xendit {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Xendit{client: client}
+}
+
+func (x *Xendit) Name() string { return "xendit" }
+
+// WholeUnitsOnly reports that invoices only take integer IDR.
+func (x *Xendit) WholeUnitsOnly() bool { return true }
+
+func (x *Xendit) baseURL(g *models.PaymentGateway) string {
+	if u := ConfigString(g, "base_url"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "https://api.xendit.co"
+}
+
+type xenditInvoice struct {
+	ID             string `json:"id"`
+	ExternalID     string `json:"external_id"`
+	Status         string `json:"status"`
+	InvoiceURL     string `json:"invoice_url"`
+	ExpiryDate     string `json:"expiry_date"`
+	ErrorCode      string `json:"error_code"`
+	Message        string `json:"message"`
+	PaymentMethod  string `json:"payment_method"`
+	PaymentChannel string `json:"payment_channel"`
+}
+
+// CreateCharge creates an invoice: POST /v2/invoices.
+func (x *Xendit) CreateCharge(ctx context.Context, g *models.PaymentGateway, req ChargeRequest) (*Charge, error) {
+	amount, err := wholeUnits(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{
+		"external_id": req.ExternalID,
+		"amount":      amount,
+		"description": req.Description,
+		"currency":    "IDR",
+	}
+	if req.CustomerEmail != "" {
+		body["payer_email"] = req.CustomerEmail
+		body["customer"] = map[string]interface{}{"given_names": req.CustomerName, "email": req.CustomerEmail}
+	}
+	if req.SuccessURL != "" {
+		body["success_redirect_url"] = req.SuccessURL
+	}
+	if req.Expiry > 0 {
+		body["invoice_duration"] = int(req.Expiry / time.Second)
+	}
+
+	var inv xenditInvoice
+	status, err := x.do(ctx, g, http.MethodPost, x.baseURL(g)+"/v2/invoices", body, &inv)
+	if err != nil {
+		return nil, err
+	}
+	if status/100 != 2 || inv.InvoiceURL == "" {
+		return nil, fmt.Errorf("xendit: create invoice failed (%d %s): %s", status, inv.ErrorCode, inv.Message)
+	}
+	charge := &Charge{ExternalID: req.ExternalID, ProviderID: inv.ID, PaymentURL: inv.InvoiceURL}
+	if t, err := time.Parse(time.RFC3339, inv.ExpiryDate); err == nil {
+		charge.ExpiresAt = &t
+	}
+	return charge, nil
+}
+
+func (x *Xendit) ParseWebhook(body []byte) (*WebhookEvent, error) {
+	payload, err := decodeJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	externalID := jsonString(payload["external_id"])
+	if externalID == "" {
+		return nil, fmt.Errorf("%w: missing external_id", ErrInvalidPayload)
+	}
+	// Invoice callbacks carry paid_amount once paid; fall back to amount.
+	raw := payload["paid_amount"]
+	if jsonString(raw) == "" {
+		raw = payload["amount"]
+	}
+	paid, err := parseAmount(raw)
+	if err != nil {
+		return nil, err
+	}
+	rawStatus := jsonString(payload["status"])
+	status := xenditStatus(rawStatus)
+	if status == StatusSuccess && paid == nil {
+		return nil, fmt.Errorf("%w: missing amount", ErrInvalidPayload)
+	}
+	payload["status"] = status
+	var eventID string
+	if id := jsonString(payload["id"]); id != "" {
+		// Invoice callbacks are sent once per invoice status.
+		eventID = id + ":" + rawStatus
+	}
+	return &WebhookEvent{
+		EventID:        eventID,
+		ExternalID:     externalID,
+		Status:         status,
+		PaidAmount:     paid,
+		PaymentMethod:  jsonString(payload["payment_method"]),
+		PaymentChannel: jsonString(payload["payment_channel"]),
+		Data:           payload,
+	}, nil
+}
+
+// VerifyWebhook compares X-Callback-Token with the gateway's callback_token.
+func (x *Xendit) VerifyWebhook(g *models.PaymentGateway, header http.Header, _ []byte, _ *WebhookEvent) error {
+	expected := ConfigString(g, "callback_token")
+	if expected == "" {
+		return ErrGatewayMisconfigured
+	}
+	token := header.Get("X-Callback-Token")
+	if token == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(token)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// QueryStatus looks the invoice up by external_id: GET /v2/invoices.
+func (x *Xendit) QueryStatus(ctx context.Context, g *models.PaymentGateway, externalID string) (*StatusResult, error) {
+	inv, raw, err := x.findInvoice(ctx, g, externalID)
+	if err != nil {
+		return nil, err
+	}
+	amountKey := "paid_amount"
+	if jsonString(raw[amountKey]) == "" {
+		amountKey = "amount"
+	}
+	paid, err := parseAmount(raw[amountKey])
+	if err != nil {
+		return nil, err
+	}
+	return &StatusResult{Status: xenditStatus(inv.Status), PaidAmount: paid, Data: raw}, nil
+}
+
+// Cancel expires the invoice: POST /invoices/{id}/expire!. Invoices that are
+// already expired are left alone.
+func (x *Xendit) Cancel(ctx context.Context, g *models.PaymentGateway, externalID string) error {
+	inv, _, err := x.findInvoice(ctx, g, externalID)
+	if err != nil {
+		return err
+	}
+	if inv.Status == "EXPIRED" {
+		return nil
+	}
+	var out xenditInvoice
+	status, err := x.do(ctx, g, http.MethodPost, x.baseURL(g)+"/invoices/"+url.PathEscape(inv.ID)+"/expire!", nil, &out)
+	if err != nil {
+		return err
+	}
+	if status/100 != 2 {
+		return fmt.Errorf("xendit: expire invoice failed (%d %s): %s", status, out.ErrorCode, out.Message)
+	}
+	return nil
+}
+
+// findInvoice returns the newest invoice created for externalID.
+func (x *Xendit) findInvoice(ctx context.Context, g *models.PaymentGateway, externalID string) (*xenditInvoice, map[string]interface{}, error) {
+	endpoint := x.baseURL(g) + "/v2/invoices?external_id=" + url.QueryEscape(externalID)
+	var list []map[string]interface{}
+	status, err := x.do(ctx, g, http.MethodGet, endpoint, nil, &list)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, nil, ErrChargeNotFound
+	}
+	if status/100 != 2 {
+		return nil, nil, fmt.Errorf("xendit: invoice lookup failed (%d)", status)
+	}
+	if len(list) == 0 {
+		return nil, nil, ErrChargeNotFound
+	}
+	raw := list[0]
+	return &xenditInvoice{
+		ID:         jsonString(raw["id"]),
+		ExternalID: jsonString(raw["external_id"]),
+		Status:     jsonString(raw["status"]),
+	}, raw, nil
+}
+
+func (x *Xendit) do(ctx context.Context, g *models.PaymentGateway, method, endpoint string, in, out interface{}) (int, error) {
+	apiKey := ConfigString(g, "api_key")
+	if apiKey == "" {
+		return 0, ErrGatewayMisconfigured
+	}
+	return doJSON(ctx, x.client, "xendit", method, endpoint, apiKey, in, out)
+}
+
+func xenditStatus(s string) string {
+	switch strings.ToUpper(s) {
+	case "PAID", "SETTLED":
+		return StatusSuccess
+	case "EXPIRED":
+		return StatusExpired
+	case "FAILED":
+		return StatusFailed
+	}
+	return StatusPending
+}
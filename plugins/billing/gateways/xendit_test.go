@@ -0,0 +1,197 @@
+package gateways
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+// fakeXendit keeps invoices in memory and serves the Invoice API.
+func fakeXendit(t *testing.T, apiKey string) (*httptest.Server, map[string]map[string]interface{}) {
+	t.Helper()
+	invoices := map[string]map[string]interface{}{} // by invoice id
+	mux := http.NewServeMux()
+	auth := func(w http.ResponseWriter, r *http.Request) bool {
+		user, _, ok := r.BasicAuth()
+		if !ok || user != apiKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error_code":"INVALID_API_KEY","message":"API key is not authorized"}`)
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("/v2/invoices", func(w http.ResponseWriter, r *http.Request) {
+		if !auth(w, r) {
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			var body map[string]interface{}
+			dec := json.NewDecoder(r.Body)
+			dec.UseNumber()
+			if err := dec.Decode(&body); err != nil {
+				t.Errorf("invoice body: %v", err)
+			}
+			id := fmt.Sprintf("inv_%d", len(invoices)+1)
+			inv := map[string]interface{}{
+				"id":          id,
+				"external_id": body["external_id"],
+				"amount":      body["amount"],
+				"status":      "PENDING",
+				"invoice_url": "https://checkout.xendit.test/web/" + id,
+				"expiry_date": time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC).Format(time.RFC3339),
+			}
+			invoices[id] = inv
+			json.NewEncoder(w).Encode(inv)
+		case http.MethodGet:
+			out := []map[string]interface{}{}
+			for _, inv := range invoices {
+				if inv["external_id"] == r.URL.Query().Get("external_id") {
+					out = append(out, inv)
+				}
+			}
+			json.NewEncoder(w).Encode(out)
+		}
+	})
+	mux.HandleFunc("/invoices/", func(w http.ResponseWriter, r *http.Request) {
+		if !auth(w, r) {
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/invoices/"), "/expire!")
+		inv, ok := invoices[id]
+		if !ok || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error_code":"INVOICE_NOT_FOUND_ERROR","message":"not found"}`)
+			return
+		}
+		inv["status"] = "EXPIRED"
+		json.NewEncoder(w).Encode(inv)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, invoices
+}
+
+func xenditGateway(url string) *models.PaymentGateway {
+	return &models.PaymentGateway{
+		Slug:   "xendit",
+		Config: fmt.Sprintf(`{"api_key":"xnd_test","callback_token":"cb-secret","base_url":%q}`, url),
+	}
+}
+
+func TestXenditChargeLifecycle(t *testing.T) {
+	srv, invoices := fakeXendit(t, "xnd_test")
+	d := NewXendit(srv.Client())
+	g := xenditGateway(srv.URL)
+	ctx := context.Background()
+
+	charge, err := d.CreateCharge(ctx, g, ChargeRequest{
+		ExternalID:    "topup-1",
+		Amount:        money.MustParse("50000"),
+		CustomerEmail: "a@example.com",
+		Expiry:        time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("CreateCharge: %v", err)
+	}
+	if charge.ProviderID != "inv_1" || charge.PaymentURL != "https://checkout.xendit.test/web/inv_1" {
+		t.Fatalf("unexpected charge %+v", charge)
+	}
+	if charge.ExpiresAt == nil || charge.ExpiresAt.Year() != 2030 {
+		t.Fatalf("expiry = %v", charge.ExpiresAt)
+	}
+
+	res, err := d.QueryStatus(ctx, g, "topup-1")
+	if err != nil {
+		t.Fatalf("QueryStatus: %v", err)
+	}
+	if res.Status != StatusPending {
+		t.Fatalf("status = %s", res.Status)
+	}
+
+	invoices["inv_1"]["status"] = "PAID"
+	invoices["inv_1"]["paid_amount"] = json.Number("50000")
+	res, _ = d.QueryStatus(ctx, g, "topup-1")
+	if res.Status != StatusSuccess || res.PaidAmount == nil || *res.PaidAmount != money.MustParse("50000") {
+		t.Fatalf("paid status %+v", res)
+	}
+
+	invoices["inv_1"]["status"] = "PENDING"
+	if err := d.Cancel(ctx, g, "topup-1"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if invoices["inv_1"]["status"] != "EXPIRED" {
+		t.Fatalf("invoice not expired: %v", invoices["inv_1"]["status"])
+	}
+
+	if _, err := d.QueryStatus(ctx, g, "unknown"); !errors.Is(err, ErrChargeNotFound) {
+		t.Fatalf("unknown invoice: got %v", err)
+	}
+	if err := d.Cancel(ctx, g, "unknown"); !errors.Is(err, ErrChargeNotFound) {
+		t.Fatalf("cancel unknown invoice: got %v", err)
+	}
+
+	bad := &models.PaymentGateway{Config: fmt.Sprintf(`{"api_key":"nope","base_url":%q}`, srv.URL)}
+	if _, err := d.CreateCharge(ctx, bad, ChargeRequest{ExternalID: "x", Amount: money.FromMajor(1)}); err == nil {
+		t.Fatal("expected an error for a rejected key")
+	}
+}
+
+func TestXenditWebhook(t *testing.T) {
+	d := NewXendit(nil)
+	g := xenditGateway("http://unused")
+	body := []byte(`{"id":"inv_1","external_id":"topup-1","status":"PAID","amount":50000,` +
+		`"paid_amount":50000,"payment_method":"BANK_TRANSFER","payment_channel":"BCA"}`)
+
+	ev, err := d.ParseWebhook(body)
+	if err != nil {
+		t.Fatalf("ParseWebhook: %v", err)
+	}
+	if ev.ExternalID != "topup-1" || ev.EventID != "inv_1:PAID" || ev.Status != StatusSuccess || ev.PaymentChannel != "BCA" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if ev.PaidAmount == nil || *ev.PaidAmount != money.MustParse("50000") {
+		t.Fatalf("paid amount = %v", ev.PaidAmount)
+	}
+
+	h := http.Header{}
+	if err := d.VerifyWebhook(g, h, body, ev); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("missing token: got %v", err)
+	}
+	h.Set("X-Callback-Token", "wrong")
+	if err := d.VerifyWebhook(g, h, body, ev); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("wrong token: got %v", err)
+	}
+	h.Set("X-Callback-Token", "cb-secret")
+	if err := d.VerifyWebhook(g, h, body, ev); err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+
+	if _, err := d.ParseWebhook([]byte(`{"external_id":"topup-1","status":"PAID"}`)); !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("paid without amount: got %v", err)
+	}
+}
+
+func TestRegistryResolvesBySlugOrDriver(t *testing.T) {
+	RegisterDefaults()
+	d, err := ForGateway(&models.PaymentGateway{Slug: "xendit"})
+	if err != nil || d.Name() != "xendit" {
+		t.Fatalf("by slug: %v %v", d, err)
+	}
+	d, err = ForGateway(&models.PaymentGateway{Slug: "midtrans-qris", Config: `{"driver":"midtrans"}`})
+	if err != nil || d.Name() != "midtrans" {
+		t.Fatalf("by config driver: %v %v", d, err)
+	}
+	if _, err := ForGateway(&models.PaymentGateway{Slug: "manual"}); !errors.Is(err, ErrDriverNotFound) {
+		t.Fatalf("manual: got %v", err)
+	}
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"go_framework/internal/money"
 	"go_framework/plugins/billing/models"
 	"go_framework/plugins/billing/services"
 )
@@ -13,15 +15,16 @@ import (
 // Admin handlers for /admin/billing routes
 
 type adminCreateTopupReq struct {
-	CustomerID string  `json:"customer_id" binding:"required,uuid"`
-	GatewayID  string  `json:"gateway_id" binding:"required,uuid"`
-	Amount     float64 `json:"amount" binding:"required,gt=0"`
+	CustomerID string       `json:"customer_id" binding:"required,uuid"`
+	GatewayID  string       `json:"gateway_id" binding:"required,uuid"`
+	Amount     money.Amount `json:"amount" binding:"required,gt=0"`
+	PromoCode  string       `json:"promo_code" binding:"omitempty,max=50"`
 }
 
 type adminAdjustBalanceReq struct {
-	CustomerID string  `json:"customer_id" binding:"required,uuid"`
-	Amount     float64 `json:"amount" binding:"required"`
-	Reason     string  `json:"reason" binding:"required"`
+	CustomerID string       `json:"customer_id" binding:"required,uuid"`
+	Amount     money.Amount `json:"amount" binding:"required"`
+	Reason     string       `json:"reason" binding:"required"`
 }
 
 type adminConfirmTopupReq struct {
@@ -29,42 +32,40 @@ type adminConfirmTopupReq struct {
 }
 
 type adminRefundReq struct {
-	CustomerID    string  `json:"customer_id" binding:"required,uuid"`
-	Amount        float64 `json:"amount" binding:"required,gt=0"`
-	ReferenceID   string  `json:"reference_id" binding:"required,uuid"`
-	ReferenceType string  `json:"reference_type" binding:"required"`
-	Reason        string  `json:"reason" binding:"required"`
+	TransactionID string       `json:"transaction_id" binding:"required,uuid"` // PURCHASE or RENEWAL
+	Amount        money.Amount `json:"amount" binding:"gte=0"`                 // 0 or omitted: the remaining amount
+	Reason        string       `json:"reason" binding:"required"`
 }
 
 type createGatewayReq struct {
-	Name          string  `json:"name" binding:"required"`
-	Slug          string  `json:"slug" binding:"required"`
-	GatewayType   string  `json:"gateway_type" binding:"required,oneof=AUTOMATIC MANUAL"`
-	IsActive      *bool   `json:"is_active"`
-	Config        any     `json:"config"`
-	FeePercentage float64 `json:"fee_percentage" binding:"gte=0"`
-	FeeFixed      float64 `json:"fee_fixed" binding:"gte=0"`
-	MinAmount     float64 `json:"min_amount" binding:"gt=0"`
-	MaxAmount     float64 `json:"max_amount" binding:"gt=0"`
-	DisplayOrder  int     `json:"display_order"`
+	Name          string       `json:"name" binding:"required"`
+	Slug          string       `json:"slug" binding:"required"`
+	GatewayType   string       `json:"gateway_type" binding:"required,oneof=AUTOMATIC MANUAL"`
+	IsActive      *bool        `json:"is_active"`
+	Config        any          `json:"config"`
+	FeePercentage money.Rate   `json:"fee_percentage" binding:"gte=0"`
+	FeeFixed      money.Amount `json:"fee_fixed" binding:"gte=0"`
+	MinAmount     money.Amount `json:"min_amount" binding:"gt=0"`
+	MaxAmount     money.Amount `json:"max_amount" binding:"gt=0"`
+	DisplayOrder  int          `json:"display_order"`
 }
 
 type updateGatewayReq struct {
-	Name          string   `json:"name" binding:"omitempty"`
-	Slug          string   `json:"slug" binding:"omitempty"`
-	GatewayType   string   `json:"gateway_type" binding:"omitempty,oneof=AUTOMATIC MANUAL"`
-	IsActive      *bool    `json:"is_active"`
-	Config        any      `json:"config"`
-	FeePercentage *float64 `json:"fee_percentage" binding:"omitempty,gte=0"`
-	FeeFixed      *float64 `json:"fee_fixed" binding:"omitempty,gte=0"`
-	MinAmount     *float64 `json:"min_amount" binding:"omitempty,gt=0"`
-	MaxAmount     *float64 `json:"max_amount" binding:"omitempty,gt=0"`
-	DisplayOrder  *int     `json:"display_order"`
+	Name          string        `json:"name" binding:"omitempty"`
+	Slug          string        `json:"slug" binding:"omitempty"`
+	GatewayType   string        `json:"gateway_type" binding:"omitempty,oneof=AUTOMATIC MANUAL"`
+	IsActive      *bool         `json:"is_active"`
+	Config        any           `json:"config"`
+	FeePercentage *money.Rate   `json:"fee_percentage" binding:"omitempty,gte=0"`
+	FeeFixed      *money.Amount `json:"fee_fixed" binding:"omitempty,gte=0"`
+	MinAmount     *money.Amount `json:"min_amount" binding:"omitempty,gt=0"`
+	MaxAmount     *money.Amount `json:"max_amount" binding:"omitempty,gt=0"`
+	DisplayOrder  *int          `json:"display_order"`
 }
 
 // ========== WALLET & TRANSACTIONS ==========
 
-// GET /admin/billing/balance/:customer_id - Get customer balance
+// GET /admin/billing/balance/:customer_id - Get customer balance, held and available amounts
 func AdminGetCustomerBalance(c *gin.Context) {
 	customerID := c.Param("customer_id")
 
@@ -74,15 +75,21 @@ func AdminGetCustomerBalance(c *gin.Context) {
 		return
 	}
 
-	balance, err := svc.GetBalance(customerID)
+	balance, err := svc.GetBalanceSummary(customerID)
 	if err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"customer_id": customerID,
-		"balance":     balance,
+		"balance":     balance.Balance,
+		"held":        balance.Held,
+		"available":   balance.Available,
 	})
 }
 
@@ -146,6 +153,8 @@ func AdminGetAllTransactions(c *gin.Context) {
 }
 
 // POST /admin/billing/adjust - Manual balance adjustment
+// Above BILLING_APPROVAL_ADJUST_THRESHOLD it is queued for a second admin
+// and 202 is returned with the approval request.
 func AdminAdjustBalance(c *gin.Context) {
 	adminIDVal, exists := c.Get("admin_id")
 	if !exists {
@@ -160,17 +169,21 @@ func AdminAdjustBalance(c *gin.Context) {
 		return
 	}
 
-	svc, err := services.NewWalletServiceFromDefault()
+	svc, err := services.NewApprovalServiceFromDefault()
 	if err != nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
 		return
 	}
 
-	transaction, err := svc.AdjustBalance(adminID, req.CustomerID, req.Amount, req.Reason)
+	transaction, approval, err := svc.SubmitAdjustment(adminID, req.CustomerID, req.Amount, req.Reason)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if approval != nil {
+		c.JSON(http.StatusAccepted, gin.H{"message": "adjustment awaits approval", "approval": approval})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"transaction": transaction})
 }
@@ -266,14 +279,20 @@ func AdminCreateTopup(c *gin.Context) {
 	topup, err := svc.CreateTopupRequest(struct {
 		CustomerID string
 		GatewayID  string
-		Amount     float64
+		Amount     money.Amount
+		PromoCode  string
 	}{
 		CustomerID: req.CustomerID,
 		GatewayID:  req.GatewayID,
 		Amount:     req.Amount,
+		PromoCode:  req.PromoCode,
 	})
 
 	if err != nil {
+		if errors.Is(err, services.ErrGatewayUnavailable) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -323,6 +342,10 @@ func AdminCancelTopup(c *gin.Context) {
 	}
 
 	if err := svc.CancelTopup(topupID); err != nil {
+		if errors.Is(err, services.ErrGatewayUnavailable) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -332,7 +355,9 @@ func AdminCancelTopup(c *gin.Context) {
 
 // ========== REFUND ==========
 
-// POST /admin/billing/refund - Manual refund
+// POST /admin/billing/refund - Refund a PURCHASE or RENEWAL, in full or in part
+// Above BILLING_APPROVAL_REFUND_THRESHOLD it is queued for a second admin
+// and 202 is returned with the approval request.
 func AdminRefund(c *gin.Context) {
 	adminIDVal, exists := c.Get("admin_id")
 	if !exists {
@@ -347,32 +372,54 @@ func AdminRefund(c *gin.Context) {
 		return
 	}
 
+	svc, err := services.NewApprovalServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	refund, approval, err := svc.SubmitRefund(adminID, req.TransactionID, req.Amount, req.Reason)
+	if err != nil {
+		writeRefundError(c, err)
+		return
+	}
+	if approval != nil {
+		c.JSON(http.StatusAccepted, gin.H{"message": "refund awaits approval", "approval": approval})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "refund processed", "refund": refund})
+}
+
+// GET /admin/billing/transactions/:id/refunds - Refunds of a charge and the
+// remaining refundable amount
+func AdminGetTransactionRefunds(c *gin.Context) {
 	svc, err := services.NewPurchaseServiceFromDefault()
 	if err != nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
 		return
 	}
 
-	if err := svc.RefundBalance(struct {
-		CustomerID    string
-		Amount        float64
-		ReferenceID   string
-		ReferenceType string
-		Reason        string
-		AdminID       *string
-	}{
-		CustomerID:    req.CustomerID,
-		Amount:        req.Amount,
-		ReferenceID:   req.ReferenceID,
-		ReferenceType: req.ReferenceType,
-		Reason:        req.Reason,
-		AdminID:       &adminID,
-	}); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	summary, err := svc.GetRefundSummary(c.Param("id"))
+	if err != nil {
+		writeRefundError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "refund processed"})
+	c.JSON(http.StatusOK, summary)
+}
+
+func writeRefundError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTransactionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAlreadyRefunded), errors.Is(err, services.ErrRefundExceedsRemaining):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotRefundable), errors.Is(err, services.ErrNegativeAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
 
 // ========== PAYMENT GATEWAY MANAGEMENT ==========
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/plugins/billing/services"
+)
+
+// ========== WEBHOOK INBOX ==========
+
+// GET /admin/billing/webhooks - Browse the webhook inbox
+// Query: provider, status, external_id, limit, offset
+func AdminListWebhooks(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	provider := c.Query("provider")
+	status := c.Query("status")
+	externalID := c.Query("external_id")
+
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	var providerPtr, statusPtr, externalIDPtr *string
+	if provider != "" {
+		providerPtr = &provider
+	}
+	if status != "" {
+		statusPtr = &status
+	}
+	if externalID != "" {
+		externalIDPtr = &externalID
+	}
+
+	entries, total, err := svc.ListWebhookInbox(struct {
+		Provider   *string
+		Status     *string
+		ExternalID *string
+		Limit      int
+		Offset     int
+	}{
+		Provider:   providerPtr,
+		Status:     statusPtr,
+		ExternalID: externalIDPtr,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": entries,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// GET /admin/billing/webhooks/:id - Get one inbox entry
+func AdminGetWebhook(c *gin.Context) {
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	entry, err := svc.GetWebhookInboxEntry(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrInboxEntryNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhook": entry})
+}
+
+// POST /admin/billing/webhooks/:id/replay - Process a stored webhook again
+// The entry is returned with its new status; a processing failure is
+// reported in the entry's error rather than as an HTTP error.
+func AdminReplayWebhook(c *gin.Context) {
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	entry, err := svc.ReplayWebhook(c.Param("id"))
+	if entry == nil {
+		if errors.Is(err, services.ErrInboxEntryNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhook": entry})
+}
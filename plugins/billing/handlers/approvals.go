@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/plugins/billing/services"
+)
+
+type approvalDecisionReq struct {
+	Note string `json:"note" binding:"max=1000"`
+}
+
+// ========== BALANCE APPROVALS ==========
+
+// GET /admin/billing/approvals - Adjustments and refunds awaiting a second admin
+// Query: status (PENDING|APPROVED|REJECTED|CANCELLED|EXPIRED, default PENDING),
+// kind (ADJUSTMENT|REFUND), customer_id, limit, offset
+func AdminListApprovals(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	var statusPtr, kindPtr, customerIDPtr *string
+	if status := c.Query("status"); status != "" {
+		statusPtr = &status
+	}
+	if kind := c.Query("kind"); kind != "" {
+		kindPtr = &kind
+	}
+	if customerID := c.Query("customer_id"); customerID != "" {
+		customerIDPtr = &customerID
+	}
+
+	svc, err := services.NewApprovalServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	approvals, total, err := svc.ListApprovals(struct {
+		Status     *string
+		Kind       *string
+		CustomerID *string
+		Limit      int
+		Offset     int
+	}{
+		Status:     statusPtr,
+		Kind:       kindPtr,
+		CustomerID: customerIDPtr,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"approvals": approvals,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// GET /admin/billing/approvals/:id - Get an approval request
+func AdminGetApproval(c *gin.Context) {
+	svc, err := services.NewApprovalServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+	approval, err := svc.GetApproval(c.Param("id"))
+	if err != nil {
+		writeApprovalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"approval": approval})
+}
+
+// POST /admin/billing/approvals/:id/approve - Post the request (SUPERADMIN, not the requester)
+func AdminApproveApproval(c *gin.Context) {
+	decideApprovalRequest(c, func(svc *services.ApprovalService, adminID string, req approvalDecisionReq) (interface{}, error) {
+		return svc.Approve(adminID, c.Param("id"), req.Note)
+	}, "approval request approved")
+}
+
+// POST /admin/billing/approvals/:id/reject - Reject with a note (SUPERADMIN, not the requester)
+func AdminRejectApproval(c *gin.Context) {
+	decideApprovalRequest(c, func(svc *services.ApprovalService, adminID string, req approvalDecisionReq) (interface{}, error) {
+		return svc.Reject(adminID, c.Param("id"), req.Note)
+	}, "approval request rejected")
+}
+
+// POST /admin/billing/approvals/:id/cancel - Withdraw own request
+func AdminCancelApproval(c *gin.Context) {
+	decideApprovalRequest(c, func(svc *services.ApprovalService, adminID string, _ approvalDecisionReq) (interface{}, error) {
+		return svc.Cancel(adminID, c.Param("id"))
+	}, "approval request cancelled")
+}
+
+func decideApprovalRequest(c *gin.Context, decide func(*services.ApprovalService, string, approvalDecisionReq) (interface{}, error), message string) {
+	adminID := c.GetString("admin_id")
+	if adminID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req approvalDecisionReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	svc, err := services.NewApprovalServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+	approval, err := decide(svc, adminID, req)
+	if err != nil {
+		writeApprovalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "approval": approval})
+}
+
+func writeApprovalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrApprovalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrApprovalSelf), errors.Is(err, services.ErrApprovalForbidden),
+		errors.Is(err, services.ErrApprovalNotRequester):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrApprovalNotPending), errors.Is(err, services.ErrApprovalExpired),
+		errors.Is(err, services.ErrInsufficientBalance), errors.Is(err, services.ErrAlreadyRefunded),
+		errors.Is(err, services.ErrRefundExceedsRemaining):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrApprovalNoteRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,31 +1,29 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"go_framework/internal/access"
+	"go_framework/internal/money"
 	"go_framework/plugins/billing/services"
 )
 
 // Customer handlers for /api routes
 
 type createTopupReq struct {
-	GatewayID string  `json:"gateway_id" binding:"required,uuid"`
-	Amount    float64 `json:"amount" binding:"required,gt=0"`
+	GatewayID string       `json:"gateway_id" binding:"required,uuid"`
+	Amount    money.Amount `json:"amount" binding:"required,gt=0"`
+	PromoCode string       `json:"promo_code" binding:"omitempty,max=50"`
 }
 
-// GET /api/billing/balance - Get customer wallet balance
+// GET /api/billing/balance - Get customer wallet balance, held and available amounts
 func CustomerGetBalance(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-	if customerID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid customer_id"})
+	customerID, ok := access.Account(c, access.ViewBilling)
+	if !ok {
 		return
 	}
 
@@ -35,28 +33,28 @@ func CustomerGetBalance(c *gin.Context) {
 		return
 	}
 
-	balance, err := svc.GetBalance(customerID)
+	balance, err := svc.GetBalanceSummary(customerID)
 	if err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"customer_id": customerID,
-		"balance":     balance,
+		"balance":     balance.Balance,
+		"held":        balance.Held,
+		"available":   balance.Available,
 	})
 }
 
 // GET /api/billing/transactions - Get customer transaction history
 func CustomerGetTransactions(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-	if customerID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid customer_id"})
+	customerID, ok := access.Account(c, access.ViewBilling)
+	if !ok {
 		return
 	}
 
@@ -102,14 +100,8 @@ func CustomerListGateways(c *gin.Context) {
 
 // POST /api/billing/topup - Create topup request
 func CustomerCreateTopup(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-	if customerID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid customer_id"})
+	customerID, ok := access.Account(c, access.ManageBilling)
+	if !ok {
 		return
 	}
 
@@ -128,14 +120,28 @@ func CustomerCreateTopup(c *gin.Context) {
 	topup, err := svc.CreateTopupRequest(struct {
 		CustomerID string
 		GatewayID  string
-		Amount     float64
+		Amount     money.Amount
+		PromoCode  string
 	}{
 		CustomerID: customerID,
 		GatewayID:  req.GatewayID,
 		Amount:     req.Amount,
+		PromoCode:  req.PromoCode,
 	})
 
 	if err != nil {
+		if errors.Is(err, services.ErrTopupsFrozen) || errors.Is(err, services.ErrTopupLimited) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrPromoLimitReached) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrGatewayUnavailable) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -145,14 +151,8 @@ func CustomerCreateTopup(c *gin.Context) {
 
 // GET /api/billing/topup - List customer's topup requests
 func CustomerListTopups(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-	if customerID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid customer_id"})
+	customerID, ok := access.Account(c, access.ViewBilling)
+	if !ok {
 		return
 	}
 
@@ -200,13 +200,6 @@ func CustomerListTopups(c *gin.Context) {
 
 // GET /api/billing/topup/:id - Get topup detail
 func CustomerGetTopup(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-
 	topupID := c.Param("id")
 
 	svc, err := services.NewTopupServiceFromDefault()
@@ -222,8 +215,7 @@ func CustomerGetTopup(c *gin.Context) {
 	}
 
 	// Ownership check
-	if topup.CustomerID != customerID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	if !access.Require(c, topup.CustomerID, access.ViewBilling) {
 		return
 	}
 
@@ -232,13 +224,6 @@ func CustomerGetTopup(c *gin.Context) {
 
 // DELETE /api/billing/topup/:id - Cancel pending topup
 func CustomerCancelTopup(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-
 	topupID := c.Param("id")
 
 	svc, err := services.NewTopupServiceFromDefault()
@@ -254,12 +239,15 @@ func CustomerCancelTopup(c *gin.Context) {
 		return
 	}
 
-	if topup.CustomerID != customerID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	if !access.Require(c, topup.CustomerID, access.ManageBilling) {
 		return
 	}
 
 	if err := svc.CancelTopup(topupID); err != nil {
+		if errors.Is(err, services.ErrGatewayUnavailable) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
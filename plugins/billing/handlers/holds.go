@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/access"
+	"go_framework/plugins/billing/services"
+)
+
+// ========== WALLET HOLDS ==========
+
+// GET /admin/billing/holds - List wallet holds
+// Query: customer_id, status (ACTIVE|CAPTURED|RELEASED|EXPIRED), limit, offset
+func AdminListHolds(c *gin.Context) {
+	var customerIDPtr *string
+	if customerID := c.Query("customer_id"); customerID != "" {
+		customerIDPtr = &customerID
+	}
+	listHolds(c, customerIDPtr)
+}
+
+// POST /admin/billing/holds/:id/release - Release an active hold without
+// charging it
+func AdminReleaseHold(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewWalletServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	if err := svc.CancelHold(c.Param("id"), req.Reason); err != nil {
+		switch {
+		case errors.Is(err, services.ErrHoldNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrHoldNotActive):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "hold released"})
+}
+
+// GET /api/billing/holds - List own wallet holds
+// Query: status, limit, offset
+func CustomerListHolds(c *gin.Context) {
+	customerID, ok := access.Account(c, access.ViewBilling)
+	if !ok {
+		return
+	}
+	listHolds(c, &customerID)
+}
+
+func listHolds(c *gin.Context, customerID *string) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	var statusPtr *string
+	if status := c.Query("status"); status != "" {
+		statusPtr = &status
+	}
+
+	svc, err := services.NewWalletServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	holds, total, err := svc.ListHolds(struct {
+		CustomerID *string
+		Status     *string
+		Limit      int
+		Offset     int
+	}{
+		CustomerID: customerID,
+		Status:     statusPtr,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"holds":  holds,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/access"
+	"go_framework/plugins/billing/models"
+	"go_framework/plugins/billing/services"
+)
+
+// ========== INVOICES ==========
+
+// GET /api/billing/invoices - List my invoices
+// Query: year, limit, offset
+func CustomerListInvoices(c *gin.Context) {
+	customerID, ok := access.Account(c, access.ViewBilling)
+	if !ok {
+		return
+	}
+
+	listInvoices(c, &customerID)
+}
+
+// GET /api/billing/invoices/:id - Get my invoice
+func CustomerGetInvoice(c *gin.Context) {
+	inv, _, ok := customerInvoice(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invoice": inv})
+}
+
+// GET /api/billing/invoices/:id/html - Download my receipt as HTML
+func CustomerDownloadInvoiceHTML(c *gin.Context) {
+	inv, svc, ok := customerInvoice(c)
+	if !ok {
+		return
+	}
+	serveInvoice(c, svc, inv, "html")
+}
+
+// GET /api/billing/invoices/:id/pdf - Download my receipt as PDF
+func CustomerDownloadInvoicePDF(c *gin.Context) {
+	inv, svc, ok := customerInvoice(c)
+	if !ok {
+		return
+	}
+	serveInvoice(c, svc, inv, "pdf")
+}
+
+// GET /admin/billing/invoices - List invoices
+// Query: customer_id, year, limit, offset
+func AdminListInvoices(c *gin.Context) {
+	var customerIDPtr *string
+	if customerID := c.Query("customer_id"); customerID != "" {
+		customerIDPtr = &customerID
+	}
+	listInvoices(c, customerIDPtr)
+}
+
+// GET /admin/billing/invoices/:id - Get invoice
+func AdminGetInvoice(c *gin.Context) {
+	inv, _, ok := adminInvoice(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invoice": inv})
+}
+
+// GET /admin/billing/invoices/:id/html - Download receipt as HTML
+func AdminDownloadInvoiceHTML(c *gin.Context) {
+	inv, svc, ok := adminInvoice(c)
+	if !ok {
+		return
+	}
+	serveInvoice(c, svc, inv, "html")
+}
+
+// GET /admin/billing/invoices/:id/pdf - Download receipt as PDF
+func AdminDownloadInvoicePDF(c *gin.Context) {
+	inv, svc, ok := adminInvoice(c)
+	if !ok {
+		return
+	}
+	serveInvoice(c, svc, inv, "pdf")
+}
+
+// POST /admin/billing/invoices/:id/resend - Email the receipt again
+func AdminResendInvoice(c *gin.Context) {
+	inv, svc, ok := adminInvoice(c)
+	if !ok {
+		return
+	}
+	if err := svc.SendInvoice(c.Request.Context(), inv); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "invoice queued for email", "invoice": inv})
+}
+
+func listInvoices(c *gin.Context, customerID *string) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	var yearPtr *int
+	if v := c.Query("year"); v != "" {
+		year, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+			return
+		}
+		yearPtr = &year
+	}
+
+	svc, err := services.NewInvoiceServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	invoices, total, err := svc.ListInvoices(struct {
+		CustomerID *string
+		Year       *int
+		Limit      int
+		Offset     int
+	}{
+		CustomerID: customerID,
+		Year:       yearPtr,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invoices": invoices,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+func customerInvoice(c *gin.Context) (*models.Invoice, *services.InvoiceService, bool) {
+	customerID, ok := access.Account(c, access.ViewBilling)
+	if !ok {
+		return nil, nil, false
+	}
+
+	svc, err := services.NewInvoiceServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return nil, nil, false
+	}
+	inv, err := svc.GetCustomerInvoice(customerID, c.Param("id"))
+	if err != nil {
+		writeInvoiceError(c, err)
+		return nil, nil, false
+	}
+	return inv, svc, true
+}
+
+func adminInvoice(c *gin.Context) (*models.Invoice, *services.InvoiceService, bool) {
+	svc, err := services.NewInvoiceServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return nil, nil, false
+	}
+	inv, err := svc.GetInvoice(c.Param("id"))
+	if err != nil {
+		writeInvoiceError(c, err)
+		return nil, nil, false
+	}
+	return inv, svc, true
+}
+
+func serveInvoice(c *gin.Context, svc *services.InvoiceService, inv *models.Invoice, format string) {
+	data, err := svc.Document(c.Request.Context(), inv, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	contentType := "text/html; charset=utf-8"
+	disposition := "inline"
+	if format == "pdf" {
+		contentType = "application/pdf"
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", disposition+`; filename="`+services.InvoiceFilename(inv, format)+`"`)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+func writeInvoiceError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvoiceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/plugins/billing/services"
+)
+
+// ========== LEDGER ==========
+
+// GET /admin/billing/ledger/accounts - Chart of accounts with balances
+// Query: type, include_customers (true to list customer wallets too)
+func AdminListLedgerAccounts(c *gin.Context) {
+	var typePtr *string
+	if accountType := c.Query("type"); accountType != "" {
+		typePtr = &accountType
+	}
+
+	svc, err := services.NewLedgerServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	accounts, err := svc.ListAccounts(struct {
+		Type             *string
+		IncludeCustomers bool
+	}{
+		Type:             typePtr,
+		IncludeCustomers: c.Query("include_customers") == "true",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+// GET /admin/billing/ledger/accounts/:id - Account with balance
+func AdminGetLedgerAccount(c *gin.Context) {
+	svc, err := services.NewLedgerServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	account, err := svc.GetAccount(c.Param("id"))
+	if err != nil {
+		writeLedgerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"account": account})
+}
+
+// GET /admin/billing/ledger/accounts/:id/postings - Account postings
+// Query: limit, offset
+func AdminListLedgerPostings(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	svc, err := services.NewLedgerServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	postings, total, err := svc.ListPostings(c.Param("id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"postings": postings,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// GET /admin/billing/ledger/entries - Journal entries with postings
+// Query: reference_id, start_date, end_date, limit, offset
+func AdminListJournalEntries(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	referenceID := c.Query("reference_id")
+	startDate := c.Query("start_date")
+	endDate := c.Query("end_date")
+
+	var referenceIDPtr, startPtr, endPtr *string
+	if referenceID != "" {
+		referenceIDPtr = &referenceID
+	}
+	if startDate != "" {
+		startPtr = &startDate
+	}
+	if endDate != "" {
+		endPtr = &endDate
+	}
+
+	svc, err := services.NewLedgerServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	entries, total, err := svc.ListEntries(struct {
+		ReferenceID *string
+		StartDate   *string
+		EndDate     *string
+		Limit       int
+		Offset      int
+	}{
+		ReferenceID: referenceIDPtr,
+		StartDate:   startPtr,
+		EndDate:     endPtr,
+		Limit:       limit,
+		Offset:      offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GET /admin/billing/ledger/entries/:id - Journal entry with postings
+func AdminGetJournalEntry(c *gin.Context) {
+	svc, err := services.NewLedgerServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	entry, err := svc.GetEntry(c.Param("id"))
+	if err != nil {
+		writeLedgerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entry": entry})
+}
+
+// GET /admin/billing/ledger/trial-balance - All balances and the debit and
+// credit totals, which must be equal
+func AdminTrialBalance(c *gin.Context) {
+	svc, err := services.NewLedgerServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	accounts, debits, credits, err := svc.TrialBalance()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"accounts":      accounts,
+		"total_debits":  debits,
+		"total_credits": credits,
+		"balanced":      debits == credits,
+	})
+}
+
+func writeLedgerError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrLedgerAccountNotFound) || errors.Is(err, services.ErrJournalEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/access"
+	"go_framework/plugins/billing/models"
+	"go_framework/plugins/billing/services"
+)
+
+// multipartOverhead is room for the multipart envelope around an upload.
+const multipartOverhead = 1 << 20
+
+// ========== PAYMENT PROOFS ==========
+
+// POST /api/billing/topup/:id/proof - Upload proof of transfer
+// Multipart form field "file": JPEG, PNG, WebP or PDF
+func CustomerUploadPaymentProof(c *gin.Context) {
+	svc, topup, ok := customerTopup(c, access.ManageBilling)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.PaymentProofMaxBytes()+multipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrProofTooLarge.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	proof, err := svc.UploadPaymentProof(c.Request.Context(), topup.ID, c.GetString("customer_id"), header.Filename, file)
+	if err != nil {
+		writePaymentProofError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "payment proof uploaded", "proof": proof})
+}
+
+// GET /api/billing/topup/:id/proofs - List proofs attached to a topup
+func CustomerListPaymentProofs(c *gin.Context) {
+	svc, topup, ok := customerTopup(c, access.ViewBilling)
+	if !ok {
+		return
+	}
+	proofs, err := svc.ListTopupPaymentProofs(topup.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"proofs": proofs})
+}
+
+// GET /api/billing/topup/:id/proofs/:proof_id/file - Download an uploaded proof
+func CustomerDownloadPaymentProof(c *gin.Context) {
+	svc, topup, ok := customerTopup(c, access.ViewBilling)
+	if !ok {
+		return
+	}
+	proof, err := svc.GetPaymentProof(c.Param("proof_id"))
+	if err != nil || proof.TopupID != topup.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrProofNotFound.Error()})
+		return
+	}
+	servePaymentProof(c, svc, proof)
+}
+
+// GET /admin/billing/payment-proofs - Review queue
+// Query: status (PENDING|APPROVED|REJECTED|REPLACED, default PENDING),
+// customer_id, limit, offset
+func AdminListPaymentProofs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	var statusPtr, customerIDPtr *string
+	if status := c.Query("status"); status != "" {
+		statusPtr = &status
+	}
+	if customerID := c.Query("customer_id"); customerID != "" {
+		customerIDPtr = &customerID
+	}
+
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	proofs, total, err := svc.ListPaymentProofs(struct {
+		Status     *string
+		CustomerID *string
+		Limit      int
+		Offset     int
+	}{
+		Status:     statusPtr,
+		CustomerID: customerIDPtr,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"proofs": proofs,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GET /admin/billing/payment-proofs/:id - Get proof with its topup
+func AdminGetPaymentProof(c *gin.Context) {
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+	proof, err := svc.GetPaymentProof(c.Param("id"))
+	if err != nil {
+		writePaymentProofError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"proof": proof})
+}
+
+// GET /admin/billing/payment-proofs/:id/file - Download the uploaded file
+func AdminDownloadPaymentProof(c *gin.Context) {
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+	proof, err := svc.GetPaymentProof(c.Param("id"))
+	if err != nil {
+		writePaymentProofError(c, err)
+		return
+	}
+	servePaymentProof(c, svc, proof)
+}
+
+// POST /admin/billing/payment-proofs/:id/approve - Approve and confirm the topup
+func AdminApprovePaymentProof(c *gin.Context) {
+	adminID := c.GetString("admin_id")
+	if adminID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req struct {
+		Notes string `json:"notes"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+	proof, err := svc.ApprovePaymentProof(adminID, c.Param("id"), req.Notes)
+	if err != nil {
+		writePaymentProofError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "payment proof approved", "proof": proof})
+}
+
+// POST /admin/billing/payment-proofs/:id/reject - Reject with a reason
+func AdminRejectPaymentProof(c *gin.Context) {
+	adminID := c.GetString("admin_id")
+	if adminID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req struct {
+		Reason string `json:"reason" binding:"required,max=1000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+	proof, err := svc.RejectPaymentProof(adminID, c.Param("id"), req.Reason)
+	if err != nil {
+		writePaymentProofError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "payment proof rejected", "proof": proof})
+}
+
+// customerTopup loads the topup of a customer route and checks the caller
+// may perform p on its account.
+func customerTopup(c *gin.Context, p access.Permission) (*services.TopupService, *models.TopupRequest, bool) {
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return nil, nil, false
+	}
+	topup, err := svc.GetTopupDetail(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	if !access.Require(c, topup.CustomerID, p) {
+		return nil, nil, false
+	}
+	return svc, topup, true
+}
+
+func servePaymentProof(c *gin.Context, svc *services.TopupService, proof *models.PaymentProof) {
+	rc, err := svc.OpenPaymentProof(c.Request.Context(), proof)
+	if err != nil {
+		writePaymentProofError(c, err)
+		return
+	}
+	defer rc.Close()
+	c.DataFromReader(http.StatusOK, proof.SizeBytes, proof.ContentType, rc, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("inline", map[string]string{"filename": proof.Filename}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func writePaymentProofError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProofNotFound), errors.Is(err, services.ErrTopupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProofNotPending), errors.Is(err, services.ErrProofNotAccepted),
+		errors.Is(err, services.ErrInvalidTopupStatus), errors.Is(err, services.ErrTooManyProofs),
+		errors.Is(err, services.ErrTopupUnderReview):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProofTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProofType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProofReasonRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProofStoreMissing):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+	"go_framework/plugins/billing/services"
+)
+
+type PricePlanTierRequest struct {
+	Resource     string       `json:"resource" binding:"required,oneof=RAM CPU"`
+	MinUnits     int          `json:"min_units" binding:"gte=0"`
+	MaxUnits     *int         `json:"max_units" binding:"omitempty,gt=0"`
+	PricePerHour money.Amount `json:"price_per_hour" binding:"gte=0"`
+}
+
+type PriceOverrideRequest struct {
+	TemplateID      *string       `json:"template_id"`
+	RegionCode      *string       `json:"region_code"`
+	PricePerGBHour  *money.Amount `json:"price_per_gb_hour" binding:"omitempty,gte=0"`
+	PricePerCPUHour *money.Amount `json:"price_per_cpu_hour" binding:"omitempty,gte=0"`
+	SetupFee        *money.Amount `json:"setup_fee" binding:"omitempty,gte=0"`
+}
+
+type PricePlanRequest struct {
+	Name            string                 `json:"name" binding:"required"`
+	Description     *string                `json:"description"`
+	EffectiveFrom   *time.Time             `json:"effective_from"` // default: now
+	IsActive        *bool                  `json:"is_active"`      // default: true
+	PricePerGBHour  money.Amount           `json:"price_per_gb_hour" binding:"gt=0"`
+	PricePerCPUHour money.Amount           `json:"price_per_cpu_hour" binding:"gt=0"`
+	SetupFee        money.Amount           `json:"setup_fee" binding:"gte=0"`
+	Tiers           []PricePlanTierRequest `json:"tiers" binding:"dive"`
+	Overrides       []PriceOverrideRequest `json:"overrides" binding:"dive"`
+}
+
+func (r *PricePlanRequest) toModel() *models.PricePlan {
+	plan := &models.PricePlan{
+		Name:            r.Name,
+		Description:     r.Description,
+		IsActive:        true,
+		PricePerGBHour:  r.PricePerGBHour,
+		PricePerCPUHour: r.PricePerCPUHour,
+		SetupFee:        r.SetupFee,
+	}
+	if r.EffectiveFrom != nil {
+		plan.EffectiveFrom = *r.EffectiveFrom
+	}
+	if r.IsActive != nil {
+		plan.IsActive = *r.IsActive
+	}
+	for _, t := range r.Tiers {
+		plan.Tiers = append(plan.Tiers, models.PricePlanTier{
+			Resource:     t.Resource,
+			MinUnits:     t.MinUnits,
+			MaxUnits:     t.MaxUnits,
+			PricePerHour: t.PricePerHour,
+		})
+	}
+	for _, o := range r.Overrides {
+		plan.Overrides = append(plan.Overrides, models.PriceOverride{
+			TemplateID:      o.TemplateID,
+			RegionCode:      o.RegionCode,
+			PricePerGBHour:  o.PricePerGBHour,
+			PricePerCPUHour: o.PricePerCPUHour,
+			SetupFee:        o.SetupFee,
+		})
+	}
+	return plan
+}
+
+// ========== PRICING ==========
+
+// GET /admin/billing/price-plans - List price plans
+func AdminListPricePlans(c *gin.Context) {
+	svc, err := services.NewPricingServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	plans, err := svc.ListPricePlans()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"price_plans": plans})
+}
+
+// GET /admin/billing/price-plans/:id - Get price plan
+func AdminGetPricePlan(c *gin.Context) {
+	svc, err := services.NewPricingServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	plan, err := svc.GetPricePlan(c.Param("id"))
+	if err != nil {
+		writePricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"price_plan": plan})
+}
+
+// POST /admin/billing/price-plans - Create price plan
+func AdminCreatePricePlan(c *gin.Context) {
+	var req PricePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewPricingServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	plan := req.toModel()
+	if err := svc.CreatePricePlan(plan); err != nil {
+		writePricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "price plan created", "price_plan": plan})
+}
+
+// PUT /admin/billing/price-plans/:id - Replace a price plan that is not yet
+// in effect
+func AdminUpdatePricePlan(c *gin.Context) {
+	var req PricePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewPricingServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	plan := req.toModel()
+	plan.ID = c.Param("id")
+	if err := svc.UpdatePricePlan(plan); err != nil {
+		writePricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "price plan updated", "price_plan": plan})
+}
+
+// DELETE /admin/billing/price-plans/:id - Delete an unused price plan
+func AdminDeletePricePlan(c *gin.Context) {
+	svc, err := services.NewPricingServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	if err := svc.DeletePricePlan(c.Param("id")); err != nil {
+		writePricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "price plan deleted"})
+}
+
+// PATCH /admin/billing/price-plans/:id/toggle - Enable/disable price plan
+func AdminTogglePricePlan(c *gin.Context) {
+	var req struct {
+		IsActive bool `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewPricingServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	if err := svc.SetPricePlanActive(c.Param("id"), req.IsActive); err != nil {
+		writePricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "price plan status updated"})
+}
+
+// GET /admin/billing/price-preview - Quote a container under the current plan
+// Query: template_id, region_code, ram_mb, cpu_percent, at (RFC3339)
+func AdminPricePreview(c *gin.Context) {
+	at := time.Now()
+	if v := c.Query("at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be RFC3339"})
+			return
+		}
+		at = t
+	}
+	pricePreview(c, at)
+}
+
+// GET /api/billing/price-preview - Quote a container before deploying it
+// Query: template_id, region_code, ram_mb, cpu_percent
+func CustomerPricePreview(c *gin.Context) {
+	pricePreview(c, time.Now())
+}
+
+func pricePreview(c *gin.Context, at time.Time) {
+	templateID := c.Query("template_id")
+	regionCode := c.Query("region_code")
+
+	ramMB, cpuPercent := 0, 0
+	if v := c.Query("ram_mb"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ram_mb"})
+			return
+		}
+		ramMB = n
+	}
+	if v := c.Query("cpu_percent"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cpu_percent"})
+			return
+		}
+		cpuPercent = n
+	}
+
+	svc, err := services.NewPricingServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	// Missing sizes fall back to the template's defaults.
+	if ramMB == 0 || cpuPercent == 0 {
+		if templateID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ram_mb and cpu_percent are required without template_id"})
+			return
+		}
+		defRAM, defCPU, err := svc.TemplateDefaults(templateID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if ramMB == 0 {
+			ramMB = defRAM
+		}
+		if cpuPercent == 0 {
+			cpuPercent = defCPU
+		}
+	}
+
+	quote, err := svc.Quote(at, templateID, regionCode, ramMB, cpuPercent)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quote": quote})
+}
+
+func writePricingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPricePlanNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPricePlanLocked), errors.Is(err, services.ErrPricePlanInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPricePlan):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
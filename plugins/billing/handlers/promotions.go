@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+	"go_framework/plugins/billing/services"
+)
+
+type PromotionRequest struct {
+	Code                      string        `json:"code" binding:"required,max=50"`
+	Name                      string        `json:"name" binding:"required"`
+	Description               *string       `json:"description"`
+	BonusType                 string        `json:"bonus_type" binding:"required,oneof=PERCENT FIXED"`
+	BonusPercent              money.Rate    `json:"bonus_percent" binding:"gte=0"`
+	BonusAmount               money.Amount  `json:"bonus_amount" binding:"gte=0"`
+	MaxBonus                  *money.Amount `json:"max_bonus" binding:"omitempty,gt=0"`
+	MinTopupAmount            money.Amount  `json:"min_topup_amount" binding:"gte=0"`
+	FirstTopupOnly            bool          `json:"first_topup_only"`
+	MaxRedemptions            *int          `json:"max_redemptions" binding:"omitempty,gt=0"`
+	MaxRedemptionsPerCustomer *int          `json:"max_redemptions_per_customer" binding:"omitempty,gt=0"`
+	StartsAt                  *time.Time    `json:"starts_at"`
+	EndsAt                    *time.Time    `json:"ends_at"`
+	IsActive                  *bool         `json:"is_active"`   // default: true
+	GatewayIDs                []string      `json:"gateway_ids"` // empty: every gateway
+}
+
+func (r *PromotionRequest) toModel() *models.Promotion {
+	promo := &models.Promotion{
+		Code:                      r.Code,
+		Name:                      r.Name,
+		Description:               r.Description,
+		BonusType:                 r.BonusType,
+		BonusPercent:              r.BonusPercent,
+		BonusAmount:               r.BonusAmount,
+		MaxBonus:                  r.MaxBonus,
+		MinTopupAmount:            r.MinTopupAmount,
+		FirstTopupOnly:            r.FirstTopupOnly,
+		MaxRedemptions:            r.MaxRedemptions,
+		MaxRedemptionsPerCustomer: r.MaxRedemptionsPerCustomer,
+		StartsAt:                  r.StartsAt,
+		EndsAt:                    r.EndsAt,
+		IsActive:                  true,
+		Gateways:                  []models.PromotionGateway{},
+	}
+	if r.IsActive != nil {
+		promo.IsActive = *r.IsActive
+	}
+	for _, id := range r.GatewayIDs {
+		promo.Gateways = append(promo.Gateways, models.PromotionGateway{GatewayID: id})
+	}
+	return promo
+}
+
+// ========== PROMOTIONS ==========
+
+// GET /admin/billing/promotions - List promotions
+// Query: is_active, limit, offset
+func AdminListPromotions(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	var isActivePtr *bool
+	if v := c.Query("is_active"); v != "" {
+		isActive := v == "true"
+		isActivePtr = &isActive
+	}
+
+	svc, err := services.NewPromotionServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	promos, total, err := svc.ListPromotions(struct {
+		IsActive *bool
+		Limit    int
+		Offset   int
+	}{
+		IsActive: isActivePtr,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"promotions": promos,
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// GET /admin/billing/promotions/:id - Get promotion
+func AdminGetPromotion(c *gin.Context) {
+	svc, err := services.NewPromotionServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	promo, err := svc.GetPromotion(c.Param("id"))
+	if err != nil {
+		writePromotionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"promotion": promo})
+}
+
+// POST /admin/billing/promotions - Create promotion
+func AdminCreatePromotion(c *gin.Context) {
+	var req PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewPromotionServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	promo := req.toModel()
+	if err := svc.CreatePromotion(promo); err != nil {
+		writePromotionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "promotion created", "promotion": promo})
+}
+
+// PUT /admin/billing/promotions/:id - Update promotion
+func AdminUpdatePromotion(c *gin.Context) {
+	var req PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewPromotionServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	promo := req.toModel()
+	promo.ID = c.Param("id")
+	if err := svc.UpdatePromotion(promo); err != nil {
+		writePromotionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "promotion updated", "promotion": promo})
+}
+
+// DELETE /admin/billing/promotions/:id - Delete a promotion never redeemed
+func AdminDeletePromotion(c *gin.Context) {
+	svc, err := services.NewPromotionServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	if err := svc.DeletePromotion(c.Param("id")); err != nil {
+		writePromotionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "promotion deleted"})
+}
+
+// PATCH /admin/billing/promotions/:id/toggle - Enable/disable promotion
+func AdminTogglePromotion(c *gin.Context) {
+	var req struct {
+		IsActive bool `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewPromotionServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	if err := svc.SetPromotionActive(c.Param("id"), req.IsActive); err != nil {
+		writePromotionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "promotion status updated"})
+}
+
+// GET /admin/billing/promotions/:id/redemptions - List redemptions
+// Query: status (RESERVED|APPLIED|RELEASED), limit, offset
+func AdminListPromotionRedemptions(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	var statusPtr *string
+	if status := c.Query("status"); status != "" {
+		statusPtr = &status
+	}
+
+	svc, err := services.NewPromotionServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	redemptions, total, err := svc.ListRedemptions(struct {
+		PromotionID string
+		Status      *string
+		Limit       int
+		Offset      int
+	}{
+		PromotionID: c.Param("id"),
+		Status:      statusPtr,
+		Limit:       limit,
+		Offset:      offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"redemptions": redemptions,
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+func writePromotionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPromotionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPromotionInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPromotion):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
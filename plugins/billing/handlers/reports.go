@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/money"
+	"go_framework/internal/xlsx"
+	"go_framework/plugins/billing/services"
+)
+
+// reportTable is a report flattened for CSV and XLSX export. Cells are
+// strings, int64 or money.Amount.
+type reportTable struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+// ========== REPORTS ==========
+//
+// Every report takes start_date and end_date (YYYY-MM-DD business days in
+// BILLING_TIMEZONE, both inclusive; default the last 30 days), interval
+// (day|week|month, default day) and format (json|csv|xlsx, default json).
+
+// GET /admin/billing/reports/topups - Topup volume by period, gateway and status
+// Query: gateway_id, status, plus the common report parameters
+func AdminTopupVolumeReport(c *gin.Context) {
+	rng, svc, ok := reportRequest(c)
+	if !ok {
+		return
+	}
+
+	var gatewayIDPtr, statusPtr *string
+	if gatewayID := c.Query("gateway_id"); gatewayID != "" {
+		gatewayIDPtr = &gatewayID
+	}
+	if status := c.Query("status"); status != "" {
+		statusPtr = &status
+	}
+
+	rows, err := svc.TopupVolume(rng, struct {
+		GatewayID *string
+		Status    *string
+	}{
+		GatewayID: gatewayIDPtr,
+		Status:    statusPtr,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	table := reportTable{columns: []string{"Period", "Gateway ID", "Gateway", "Status", "Count", "Amount", "Fee", "Tax", "Total Paid"}}
+	for _, r := range rows {
+		table.rows = append(table.rows, []interface{}{r.Period, r.GatewayID, r.GatewayName, r.Status, r.Count, r.Amount, r.Fee, r.Tax, r.TotalPaid})
+	}
+	writeReport(c, "topups", rng, rows, table)
+}
+
+// GET /admin/billing/reports/revenue - Fee, purchase and usage revenue,
+// refunds and bonuses by period
+func AdminRevenueReport(c *gin.Context) {
+	rng, svc, ok := reportRequest(c)
+	if !ok {
+		return
+	}
+
+	rows, err := svc.Revenue(rng)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	table := reportTable{columns: []string{"Period", "Topup Fees", "Topup Tax", "Purchases", "Usage", "Refunds", "Bonuses", "Net"}}
+	for _, r := range rows {
+		table.rows = append(table.rows, []interface{}{r.Period, r.TopupFees, r.TopupTax, r.Purchases, r.Usage, r.Refunds, r.Bonuses, r.Net})
+	}
+	writeReport(c, "revenue", rng, rows, table)
+}
+
+// GET /admin/billing/reports/purchases - Container revenue by period,
+// template and region
+func AdminPurchaseReport(c *gin.Context) {
+	rng, svc, ok := reportRequest(c)
+	if !ok {
+		return
+	}
+
+	rows, err := svc.PurchaseRevenue(rng)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	table := reportTable{columns: []string{"Period", "Template ID", "Template", "Region", "Transactions", "Purchases", "Usage", "Total"}}
+	for _, r := range rows {
+		templateID := ""
+		if r.TemplateID != nil {
+			templateID = *r.TemplateID
+		}
+		table.rows = append(table.rows, []interface{}{r.Period, templateID, r.TemplateName, r.RegionCode, r.Transactions, r.Purchases, r.Usage, r.Total})
+	}
+	writeReport(c, "purchases", rng, rows, table)
+}
+
+// GET /admin/billing/reports/refunds - Refunds by period and kind
+func AdminRefundReport(c *gin.Context) {
+	rng, svc, ok := reportRequest(c)
+	if !ok {
+		return
+	}
+
+	rows, err := svc.Refunds(rng)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	table := reportTable{columns: []string{"Period", "Kind", "Count", "Amount"}}
+	for _, r := range rows {
+		table.rows = append(table.rows, []interface{}{r.Period, r.Kind, r.Count, r.Amount})
+	}
+	writeReport(c, "refunds", rng, rows, table)
+}
+
+// GET /admin/billing/reports/liability - Wallet balances owed to customers
+// at the end of a business day
+// Query: as_of (YYYY-MM-DD, default now), format
+func AdminLiabilityReport(c *gin.Context) {
+	if !checkReportFormat(c) {
+		return
+	}
+	asOf := time.Now()
+	rng, err := services.NewReportRange("", "", "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if day := c.Query("as_of"); day != "" {
+		rng, err = services.NewReportRange(day, day, "")
+		if err != nil {
+			writeReportError(c, err)
+			return
+		}
+		asOf = rng.To
+	}
+
+	svc, err := services.NewReportServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	report, err := svc.Liability(asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	table := reportTable{
+		columns: []string{"As Of", "Customers", "Liability"},
+		rows:    [][]interface{}{{report.AsOf.In(rng.Location).Format(time.RFC3339), report.Customers, report.Liability}},
+	}
+	writeReport(c, "liability", rng, report, table)
+}
+
+// GET /admin/billing/reports/top-customers - Customers ranked by spend or topups
+// Query: by (spend|topups, default spend), limit (default 20, max 100),
+// plus the common report parameters
+func AdminTopCustomersReport(c *gin.Context) {
+	rng, svc, ok := reportRequest(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	rows, err := svc.TopCustomers(rng, c.DefaultQuery("by", "spend"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	table := reportTable{columns: []string{"Customer ID", "Email", "Name", "Topups", "Spend", "Refunds", "Balance"}}
+	for _, r := range rows {
+		table.rows = append(table.rows, []interface{}{r.CustomerID, r.Email, r.FullName, r.Topups, r.Spend, r.Refunds, r.Balance})
+	}
+	writeReport(c, "top_customers", rng, rows, table)
+}
+
+// reportRequest parses the common report parameters.
+func reportRequest(c *gin.Context) (services.ReportRange, *services.ReportService, bool) {
+	if !checkReportFormat(c) {
+		return services.ReportRange{}, nil, false
+	}
+	rng, err := services.NewReportRange(c.Query("start_date"), c.Query("end_date"), c.Query("interval"))
+	if err != nil {
+		writeReportError(c, err)
+		return services.ReportRange{}, nil, false
+	}
+	svc, err := services.NewReportServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return services.ReportRange{}, nil, false
+	}
+	return rng, svc, true
+}
+
+func checkReportFormat(c *gin.Context) bool {
+	if format := c.DefaultQuery("format", "json"); format != "json" && format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or xlsx"})
+		return false
+	}
+	return true
+}
+
+// csvCell defuses values a spreadsheet would evaluate as a formula, such as a
+// customer name starting with "=", by prefixing them with a quote.
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// writeReport answers with JSON or, for format=csv|xlsx, the table as a
+// download named after the report and range.
+func writeReport(c *gin.Context, name string, rng services.ReportRange, data interface{}, table reportTable) {
+	filename := name + "_" + rng.StartDate + "_" + rng.EndDate
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		_ = w.Write(table.columns)
+		for _, row := range table.rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				switch v := v.(type) {
+				case string:
+					record[i] = csvCell(v)
+				case int64:
+					record[i] = strconv.FormatInt(v, 10)
+				case money.Amount:
+					record[i] = v.String()
+				}
+			}
+			_ = w.Write(record)
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	case "xlsx":
+		wb := xlsx.New()
+		sheet := wb.AddSheet(name)
+		sheet.AddHeader(table.columns...)
+		for _, row := range table.rows {
+			cells := make([]interface{}, len(row))
+			for i, v := range row {
+				if a, ok := v.(money.Amount); ok {
+					cells[i] = xlsx.Number(a.String())
+				} else {
+					cells[i] = v
+				}
+			}
+			sheet.AddRow(cells...)
+		}
+		data, err := wb.Bytes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.xlsx"`)
+		c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", data)
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"report": name,
+			"range":  rng,
+			"data":   data,
+		})
+	}
+}
+
+func writeReportError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidReportRange) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
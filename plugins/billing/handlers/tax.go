@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+	"go_framework/plugins/billing/services"
+)
+
+type TaxRuleRequest struct {
+	Name            string     `json:"name" binding:"required,max=100"`
+	Rate            money.Rate `json:"rate" binding:"gte=0"`
+	Inclusive       bool       `json:"inclusive"`
+	ExemptWithTaxID bool       `json:"exempt_with_tax_id"`
+	EffectiveFrom   time.Time  `json:"effective_from" binding:"required"`
+	EffectiveUntil  *time.Time `json:"effective_until"`
+	IsActive        *bool      `json:"is_active"` // default: true
+}
+
+func (r *TaxRuleRequest) toModel() *models.TaxRule {
+	rule := &models.TaxRule{
+		Name:            r.Name,
+		Rate:            r.Rate,
+		Inclusive:       r.Inclusive,
+		ExemptWithTaxID: r.ExemptWithTaxID,
+		EffectiveFrom:   r.EffectiveFrom,
+		EffectiveUntil:  r.EffectiveUntil,
+		IsActive:        true,
+	}
+	if r.IsActive != nil {
+		rule.IsActive = *r.IsActive
+	}
+	return rule
+}
+
+// ========== TAX RULES ==========
+
+// GET /admin/billing/tax-rules - List tax rules
+// Query: is_active, limit, offset
+func AdminListTaxRules(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	var isActivePtr *bool
+	if v := c.Query("is_active"); v != "" {
+		isActive := v == "true"
+		isActivePtr = &isActive
+	}
+
+	svc, err := services.NewTaxServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	rules, total, err := svc.ListTaxRules(struct {
+		IsActive *bool
+		Limit    int
+		Offset   int
+	}{
+		IsActive: isActivePtr,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tax_rules": rules,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// GET /admin/billing/tax-rules/:id - Get tax rule
+func AdminGetTaxRule(c *gin.Context) {
+	svc, err := services.NewTaxServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	rule, err := svc.GetTaxRule(c.Param("id"))
+	if err != nil {
+		writeTaxRuleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tax_rule": rule})
+}
+
+// POST /admin/billing/tax-rules - Create tax rule
+func AdminCreateTaxRule(c *gin.Context) {
+	var req TaxRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewTaxServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	rule := req.toModel()
+	if err := svc.CreateTaxRule(rule); err != nil {
+		writeTaxRuleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "tax rule created", "tax_rule": rule})
+}
+
+// PUT /admin/billing/tax-rules/:id - Update tax rule
+func AdminUpdateTaxRule(c *gin.Context) {
+	var req TaxRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewTaxServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	rule := req.toModel()
+	rule.ID = c.Param("id")
+	if err := svc.UpdateTaxRule(rule); err != nil {
+		writeTaxRuleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tax rule updated", "tax_rule": rule})
+}
+
+// DELETE /admin/billing/tax-rules/:id - Delete a tax rule never charged
+func AdminDeleteTaxRule(c *gin.Context) {
+	svc, err := services.NewTaxServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	if err := svc.DeleteTaxRule(c.Param("id")); err != nil {
+		writeTaxRuleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tax rule deleted"})
+}
+
+func writeTaxRuleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTaxRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTaxRuleInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTaxRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+	"go_framework/plugins/billing/services"
+)
+
+type RiskRuleRequest struct {
+	Name        string        `json:"name" binding:"required,max=100"`
+	Kind        string        `json:"kind" binding:"required"`
+	Action      string        `json:"action" binding:"required,oneof=BLOCK REVIEW"`
+	MaxCount    *int          `json:"max_count"`
+	MaxAmount   *money.Amount `json:"max_amount"`
+	WindowHours *int          `json:"window_hours"`
+	IsActive    *bool         `json:"is_active"` // default: true
+}
+
+func (r *RiskRuleRequest) toModel() *models.TopupRiskRule {
+	rule := &models.TopupRiskRule{
+		Name:        r.Name,
+		Kind:        r.Kind,
+		Action:      r.Action,
+		MaxCount:    r.MaxCount,
+		MaxAmount:   r.MaxAmount,
+		WindowHours: r.WindowHours,
+		IsActive:    true,
+	}
+	if r.IsActive != nil {
+		rule.IsActive = *r.IsActive
+	}
+	return rule
+}
+
+type riskReviewReq struct {
+	Notes  string `json:"notes" binding:"max=1000"`
+	Reason string `json:"reason" binding:"max=1000"`
+}
+
+// ========== TOPUP RISK RULES ==========
+
+// GET /admin/billing/risk-rules - List risk rules
+// Query: is_active, kind
+func AdminListRiskRules(c *gin.Context) {
+	var isActivePtr *bool
+	if v := c.Query("is_active"); v != "" {
+		isActive := v == "true"
+		isActivePtr = &isActive
+	}
+	var kindPtr *string
+	if kind := c.Query("kind"); kind != "" {
+		kindPtr = &kind
+	}
+
+	svc, err := services.NewRiskServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	rules, err := svc.ListRiskRules(struct {
+		IsActive *bool
+		Kind     *string
+	}{
+		IsActive: isActivePtr,
+		Kind:     kindPtr,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"risk_rules": rules})
+}
+
+// GET /admin/billing/risk-rules/:id - Get risk rule
+func AdminGetRiskRule(c *gin.Context) {
+	svc, err := services.NewRiskServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	rule, err := svc.GetRiskRule(c.Param("id"))
+	if err != nil {
+		writeRiskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"risk_rule": rule})
+}
+
+// POST /admin/billing/risk-rules - Create risk rule
+func AdminCreateRiskRule(c *gin.Context) {
+	var req RiskRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewRiskServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	rule := req.toModel()
+	if err := svc.CreateRiskRule(rule); err != nil {
+		writeRiskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "risk rule created", "risk_rule": rule})
+}
+
+// PUT /admin/billing/risk-rules/:id - Update risk rule
+func AdminUpdateRiskRule(c *gin.Context) {
+	var req RiskRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	svc, err := services.NewRiskServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	rule := req.toModel()
+	rule.ID = c.Param("id")
+	if err := svc.UpdateRiskRule(rule); err != nil {
+		writeRiskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "risk rule updated", "risk_rule": rule})
+}
+
+// DELETE /admin/billing/risk-rules/:id - Delete risk rule (hits are kept)
+func AdminDeleteRiskRule(c *gin.Context) {
+	svc, err := services.NewRiskServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	if err := svc.DeleteRiskRule(c.Param("id")); err != nil {
+		writeRiskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "risk rule deleted"})
+}
+
+// GET /admin/billing/risk-hits - Recorded rule hits
+// Query: customer_id, rule_id, topup_id, kind, action, start_date, end_date,
+// limit, offset
+func AdminListRiskHits(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	optional := func(key string) *string {
+		if v := c.Query(key); v != "" {
+			return &v
+		}
+		return nil
+	}
+
+	svc, err := services.NewRiskServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	hits, total, err := svc.ListRiskHits(struct {
+		CustomerID *string
+		RuleID     *string
+		TopupID    *string
+		Kind       *string
+		Action     *string
+		StartDate  *string
+		EndDate    *string
+		Limit      int
+		Offset     int
+	}{
+		CustomerID: optional("customer_id"),
+		RuleID:     optional("rule_id"),
+		TopupID:    optional("topup_id"),
+		Kind:       optional("kind"),
+		Action:     optional("action"),
+		StartDate:  optional("start_date"),
+		EndDate:    optional("end_date"),
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hits":   hits,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// ========== TOPUP RISK REVIEW ==========
+
+// GET /admin/billing/topup-reviews - Topups flagged by risk rules
+// Query: risk_status (FLAGGED|CLEARED|REJECTED, default FLAGGED), customer_id,
+// limit, offset
+func AdminListTopupReviews(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	var statusPtr, customerIDPtr *string
+	if status := c.Query("risk_status"); status != "" {
+		statusPtr = &status
+	}
+	if customerID := c.Query("customer_id"); customerID != "" {
+		customerIDPtr = &customerID
+	}
+
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	topups, total, err := svc.ListFlaggedTopups(struct {
+		RiskStatus *string
+		CustomerID *string
+		Limit      int
+		Offset     int
+	}{
+		RiskStatus: statusPtr,
+		CustomerID: customerIDPtr,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"topups": topups,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// POST /admin/billing/topup-reviews/:id/clear - Let a flagged topup proceed
+func AdminClearTopupReview(c *gin.Context) {
+	reviewTopup(c, func(svc *services.TopupService, adminID string, req riskReviewReq) (*models.TopupRequest, error) {
+		return svc.ClearFlaggedTopup(adminID, c.Param("id"), req.Notes)
+	}, "topup cleared")
+}
+
+// POST /admin/billing/topup-reviews/:id/reject - Cancel a flagged topup
+// Body: {"reason": "..."}
+func AdminRejectTopupReview(c *gin.Context) {
+	reviewTopup(c, func(svc *services.TopupService, adminID string, req riskReviewReq) (*models.TopupRequest, error) {
+		return svc.RejectFlaggedTopup(adminID, c.Param("id"), req.Reason)
+	}, "topup rejected")
+}
+
+func reviewTopup(c *gin.Context, review func(*services.TopupService, string, riskReviewReq) (*models.TopupRequest, error), message string) {
+	adminID := c.GetString("admin_id")
+	if adminID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return
+	}
+	var req riskReviewReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	svc, err := services.NewTopupServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+	topup, err := review(svc, adminID, req)
+	if err != nil {
+		writeRiskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "topup": topup})
+}
+
+func writeRiskError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRiskRuleNotFound), errors.Is(err, services.ErrTopupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTopupNotFlagged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRiskRule), errors.Is(err, services.ErrRiskReasonRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGatewayUnavailable):
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go_framework/internal/access"
+	"go_framework/plugins/billing/services"
+)
+
+// ========== CONTAINER USAGE ==========
+
+// GET /api/billing/usage - My usage line items
+// Query: container_id, status (BILLED|UNPAID), limit, offset
+func CustomerListUsage(c *gin.Context) {
+	customerID, ok := access.Account(c, access.ViewBilling)
+	if !ok {
+		return
+	}
+
+	listUsage(c, &customerID)
+}
+
+// GET /api/billing/usage/summary - My usage totals per container
+// Query: since (YYYY-MM-DD)
+func CustomerUsageSummary(c *gin.Context) {
+	customerID, ok := access.Account(c, access.ViewBilling)
+	if !ok {
+		return
+	}
+
+	usageSummary(c, customerID)
+}
+
+// GET /admin/billing/usage - Usage line items
+// Query: customer_id, container_id, status, limit, offset
+func AdminListUsage(c *gin.Context) {
+	var customerIDPtr *string
+	if customerID := c.Query("customer_id"); customerID != "" {
+		customerIDPtr = &customerID
+	}
+	listUsage(c, customerIDPtr)
+}
+
+// GET /admin/billing/usage/summary/:customer_id - Usage totals per container
+// Query: since (YYYY-MM-DD)
+func AdminUsageSummary(c *gin.Context) {
+	usageSummary(c, c.Param("customer_id"))
+}
+
+// POST /admin/billing/usage/settle/:customer_id - Pay unpaid usage from the
+// wallet now instead of waiting for the next meter run
+func AdminSettleUsage(c *gin.Context) {
+	svc, err := services.NewUsageServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	customerID := c.Param("customer_id")
+	settled, wasStopped, err := svc.SettleUsageArrears(customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if settled && wasStopped {
+		services.PublishUsageSettled(customerID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settled": settled, "resumed": settled && wasStopped})
+}
+
+func listUsage(c *gin.Context, customerID *string) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	var containerIDPtr, statusPtr *string
+	if containerID := c.Query("container_id"); containerID != "" {
+		containerIDPtr = &containerID
+	}
+	if status := c.Query("status"); status != "" {
+		statusPtr = &status
+	}
+
+	svc, err := services.NewUsageServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	items, total, err := svc.ListUsage(struct {
+		CustomerID  *string
+		ContainerID *string
+		Status      *string
+		Limit       int
+		Offset      int
+	}{
+		CustomerID:  customerID,
+		ContainerID: containerIDPtr,
+		Status:      statusPtr,
+		Limit:       limit,
+		Offset:      offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"usage":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func usageSummary(c *gin.Context, customerID string) {
+	var sincePtr *time.Time
+	if v := c.Query("since"); v != "" {
+		since, err := time.ParseInLocation("2006-01-02", v, services.InvoiceLocation())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be YYYY-MM-DD"})
+			return
+		}
+		sincePtr = &since
+	}
+
+	svc, err := services.NewUsageServiceFromDefault()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+
+	containers, err := svc.UsageSummary(customerID, sincePtr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"customer_id": customerID, "containers": containers})
+}
@@ -0,0 +1,2 @@
+-- PostgreSQL cannot drop a single enum value; ACCOUNT_CLOSURE is left in place.
+SELECT 1;
@@ -0,0 +1,2 @@
+-- Wallet balance forfeited when a customer closes their account
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'ACCOUNT_CLOSURE';
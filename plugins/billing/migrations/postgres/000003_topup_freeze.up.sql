@@ -0,0 +1,2 @@
+-- Topups are frozen while a customer is suspended or banned
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS topups_frozen_at TIMESTAMPTZ;
@@ -0,0 +1,25 @@
+-- ============================================================
+-- TABLE: webhook_inbox
+-- Every inbound payment webhook, stored before it is processed so it can be
+-- inspected and replayed
+-- ============================================================
+CREATE TABLE IF NOT EXISTS webhook_inbox (
+    id UUID PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,                  -- gateway slug, or 'payment' for the generic endpoint
+    gateway_id UUID REFERENCES payment_gateways(id) ON DELETE SET NULL,
+    event_id VARCHAR(255),                          -- provider event ID, used for de-duplication
+    external_id VARCHAR(255),                       -- topup_requests.external_id
+    headers JSONB,                                  -- request headers (secrets redacted)
+    body TEXT NOT NULL,                             -- raw request body
+    signature_valid BOOLEAN,                        -- NULL until verified
+    status VARCHAR(20) NOT NULL DEFAULT 'RECEIVED', -- RECEIVED, PROCESSED, FAILED, REJECTED, DUPLICATE
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    processed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_event ON webhook_inbox(provider, event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_external ON webhook_inbox(external_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_status ON webhook_inbox(status, created_at DESC);
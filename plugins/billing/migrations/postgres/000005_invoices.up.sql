@@ -0,0 +1,37 @@
+-- ============================================================
+-- TABLE: invoice_sequences
+-- Last issued invoice number per year. Numbers are taken inside the
+-- transaction that settles the topup, so a rollback never leaves a gap.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+    year INTEGER PRIMARY KEY,
+    last_number INTEGER NOT NULL DEFAULT 0
+);
+
+-- ============================================================
+-- TABLE: invoices
+-- Receipt for a successful topup
+-- ============================================================
+CREATE TABLE IF NOT EXISTS invoices (
+    id UUID PRIMARY KEY,
+    invoice_number VARCHAR(50) NOT NULL UNIQUE,     -- e.g. INV/2026/000042
+    year INTEGER NOT NULL,
+    sequence INTEGER NOT NULL,
+    customer_id UUID REFERENCES customers(id) ON DELETE SET NULL,      -- kept after the customer is deleted
+    topup_id UUID UNIQUE REFERENCES topup_requests(id) ON DELETE SET NULL,
+    amount DECIMAL(15,2) NOT NULL,                  -- credited to the wallet
+    fee DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    total DECIMAL(15,2) NOT NULL,                   -- amount + fee, as paid
+    currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+    customer_name VARCHAR(255),                     -- snapshot at issue time
+    customer_email VARCHAR(255),
+    gateway_name VARCHAR(100),
+    html_key VARCHAR(500),                          -- storage keys, set once rendered
+    pdf_key VARCHAR(500),
+    emailed_at TIMESTAMPTZ,
+    issued_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (year, sequence)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_customer ON invoices(customer_id, issued_at DESC);
@@ -0,0 +1,4 @@
+ALTER TABLE customers DROP COLUMN IF EXISTS usage_stopped_at;
+ALTER TABLE customers DROP COLUMN IF EXISTS usage_overdue_since;
+DROP TABLE IF EXISTS container_usage;
+DROP TABLE IF EXISTS container_meters;
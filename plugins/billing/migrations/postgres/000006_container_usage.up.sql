@@ -0,0 +1,45 @@
+-- ============================================================
+-- TABLE: container_meters
+-- Running containers being metered: the span since metered_since has not
+-- been billed yet
+-- ============================================================
+CREATE TABLE IF NOT EXISTS container_meters (
+    container_id UUID PRIMARY KEY,                 -- containers(id), owned by the node plugin
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    ram_mb INTEGER NOT NULL,
+    cpu_percent INTEGER NOT NULL,
+    metered_since TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL,             -- last sweep that saw the container RUNNING
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- ============================================================
+-- TABLE: container_usage
+-- One billed (or owed) usage period per row
+-- ============================================================
+CREATE TABLE IF NOT EXISTS container_usage (
+    id UUID PRIMARY KEY,
+    container_id UUID NOT NULL,                    -- no FK: usage outlives the container
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    hours INTEGER NOT NULL,
+    ram_mb INTEGER NOT NULL,
+    cpu_percent INTEGER NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'BILLED',  -- BILLED, UNPAID
+    wallet_transaction_id UUID REFERENCES wallet_transactions(id) ON DELETE SET NULL,
+    billed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (container_id, period_start)            -- a period is charged once, even with several sweepers
+);
+
+CREATE INDEX IF NOT EXISTS idx_container_usage_customer ON container_usage(customer_id, period_start DESC);
+CREATE INDEX IF NOT EXISTS idx_container_usage_container ON container_usage(container_id, period_start DESC);
+CREATE INDEX IF NOT EXISTS idx_container_usage_unpaid ON container_usage(customer_id, period_start) WHERE status = 'UNPAID';
+
+-- Usage arrears: set when a usage charge could not be paid, cleared once all
+-- UNPAID periods are settled. usage_stopped_at is set when the grace period
+-- ran out and the customer's containers were stopped.
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS usage_overdue_since TIMESTAMPTZ;
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS usage_stopped_at TIMESTAMPTZ;
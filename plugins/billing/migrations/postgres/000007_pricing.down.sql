@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS container_prices;
+DROP TABLE IF EXISTS price_overrides;
+DROP TABLE IF EXISTS price_plan_tiers;
+DROP TABLE IF EXISTS price_plans;
@@ -0,0 +1,72 @@
+-- ============================================================
+-- TABLE: price_plans
+-- Rate cards. The active plan with the latest effective_from <= now prices
+-- new deployments; a plan cannot be edited once it is in effect, so a price
+-- change is a new plan with a future effective_from.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS price_plans (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    effective_from TIMESTAMPTZ NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    price_per_gb_hour DECIMAL(15,2) NOT NULL,       -- per GB RAM per hour
+    price_per_cpu_hour DECIMAL(15,2) NOT NULL,      -- per CPU % per hour
+    setup_fee DECIMAL(15,2) NOT NULL DEFAULT 0.00,  -- charged once per deployment
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_plans_effective ON price_plans(effective_from DESC) WHERE is_active;
+
+-- ============================================================
+-- TABLE: price_plan_tiers
+-- Per-unit rate for containers whose RAM (MB) or CPU (%) falls in
+-- [min_units, max_units]; replaces the plan's base rate for that resource
+-- ============================================================
+CREATE TABLE IF NOT EXISTS price_plan_tiers (
+    id UUID PRIMARY KEY,
+    plan_id UUID NOT NULL REFERENCES price_plans(id) ON DELETE CASCADE,
+    resource VARCHAR(10) NOT NULL,                  -- RAM, CPU
+    min_units INTEGER NOT NULL DEFAULT 0,
+    max_units INTEGER,                              -- NULL = no upper bound
+    price_per_hour DECIMAL(15,2) NOT NULL,          -- per GB (RAM) or per % (CPU)
+    CHECK (resource IN ('RAM', 'CPU'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_plan_tiers_plan ON price_plan_tiers(plan_id);
+
+-- ============================================================
+-- TABLE: price_overrides
+-- Rates for one app template and/or region; NULL fields keep the plan rate
+-- ============================================================
+CREATE TABLE IF NOT EXISTS price_overrides (
+    id UUID PRIMARY KEY,
+    plan_id UUID NOT NULL REFERENCES price_plans(id) ON DELETE CASCADE,
+    template_id UUID,                               -- app_templates(id), owned by the node plugin
+    region_code VARCHAR(10),
+    price_per_gb_hour DECIMAL(15,2),
+    price_per_cpu_hour DECIMAL(15,2),
+    setup_fee DECIMAL(15,2),
+    CHECK (template_id IS NOT NULL OR region_code IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_overrides_plan ON price_overrides(plan_id);
+
+-- ============================================================
+-- TABLE: container_prices
+-- The plan a container was bought under; its usage is always metered with
+-- this plan
+-- ============================================================
+CREATE TABLE IF NOT EXISTS container_prices (
+    container_id UUID PRIMARY KEY,                  -- containers(id), owned by the node plugin
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    plan_id UUID REFERENCES price_plans(id) ON DELETE RESTRICT, -- NULL = built-in default rates
+    template_id UUID,
+    region_code VARCHAR(10),
+    setup_fee DECIMAL(15,2) NOT NULL DEFAULT 0.00,
+    setup_transaction_id UUID REFERENCES wallet_transactions(id) ON DELETE SET NULL,
+    priced_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_container_prices_plan ON container_prices(plan_id);
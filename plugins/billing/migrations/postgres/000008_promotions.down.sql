@@ -0,0 +1,6 @@
+ALTER TABLE topup_requests DROP COLUMN IF EXISTS bonus_amount;
+ALTER TABLE topup_requests DROP COLUMN IF EXISTS promotion_id;
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS promotion_gateways;
+DROP TABLE IF EXISTS promotions;
+-- Postgres cannot drop an enum value; BONUS stays in transaction_type.
@@ -0,0 +1,64 @@
+-- Bonus credit from promotions is its own wallet transaction type, so
+-- reports can tell it apart from cash topups.
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'BONUS';
+
+-- ============================================================
+-- TABLE: promotions
+-- Promo codes that add bonus credit to a topup
+-- ============================================================
+CREATE TABLE IF NOT EXISTS promotions (
+    id UUID PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE,               -- stored upper-case
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    bonus_type VARCHAR(20) NOT NULL,                -- PERCENT, FIXED
+    bonus_percent DECIMAL(5,2) DEFAULT 0.00,        -- PERCENT: share of the topup amount
+    bonus_amount DECIMAL(15,2) DEFAULT 0.00,        -- FIXED: credit granted
+    max_bonus DECIMAL(15,2),                        -- PERCENT: cap, NULL = uncapped
+    min_topup_amount DECIMAL(15,2) DEFAULT 0.00,
+    first_topup_only BOOLEAN NOT NULL DEFAULT FALSE,
+    max_redemptions INTEGER,                        -- global limit, NULL = unlimited
+    max_redemptions_per_customer INTEGER DEFAULT 1, -- NULL = unlimited
+    starts_at TIMESTAMPTZ,
+    ends_at TIMESTAMPTZ,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (bonus_type IN ('PERCENT', 'FIXED'))
+);
+
+-- ============================================================
+-- TABLE: promotion_gateways
+-- Gateways a promotion is restricted to; none = every gateway
+-- ============================================================
+CREATE TABLE IF NOT EXISTS promotion_gateways (
+    promotion_id UUID NOT NULL REFERENCES promotions(id) ON DELETE CASCADE,
+    gateway_id UUID NOT NULL REFERENCES payment_gateways(id) ON DELETE CASCADE,
+    PRIMARY KEY (promotion_id, gateway_id)
+);
+
+-- ============================================================
+-- TABLE: promotion_redemptions
+-- A code applied to a topup. RESERVED while the topup is unpaid and
+-- counted against the limits; APPLIED once the bonus is credited;
+-- RELEASED when the topup fails, expires or is cancelled.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    id UUID PRIMARY KEY,
+    promotion_id UUID NOT NULL REFERENCES promotions(id) ON DELETE RESTRICT,
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    topup_id UUID NOT NULL UNIQUE REFERENCES topup_requests(id) ON DELETE CASCADE,
+    bonus_amount DECIMAL(15,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'RESERVED', -- RESERVED, APPLIED, RELEASED
+    wallet_transaction_id UUID REFERENCES wallet_transactions(id) ON DELETE SET NULL,
+    applied_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_promotion ON promotion_redemptions(promotion_id, status);
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_customer ON promotion_redemptions(customer_id, promotion_id);
+
+-- The code applied to a topup and the bonus it will earn
+ALTER TABLE topup_requests ADD COLUMN IF NOT EXISTS promotion_id UUID REFERENCES promotions(id) ON DELETE SET NULL;
+ALTER TABLE topup_requests ADD COLUMN IF NOT EXISTS bonus_amount DECIMAL(15,2) DEFAULT 0.00;
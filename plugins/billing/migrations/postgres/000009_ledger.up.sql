@@ -0,0 +1,70 @@
+-- ============================================================
+-- DOUBLE-ENTRY LEDGER
+-- Every wallet mutation posts a balanced journal entry. Customer wallets
+-- are liability accounts; customers.wallet_balance is a cached view of
+-- their balance, updated in the same transaction.
+-- ============================================================
+
+-- ============================================================
+-- TABLE: ledger_accounts
+-- Chart of accounts. Platform accounts are created on first use.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id UUID PRIMARY KEY,
+    code VARCHAR(100) NOT NULL UNIQUE,              -- 'customer_wallet:<id>', 'gateway_clearing:midtrans', 'revenue:fees', ...
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL,                      -- ASSET, LIABILITY, EQUITY, REVENUE, EXPENSE
+    customer_id UUID UNIQUE REFERENCES customers(id) ON DELETE SET NULL,
+    gateway_id UUID REFERENCES payment_gateways(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (type IN ('ASSET', 'LIABILITY', 'EQUITY', 'REVENUE', 'EXPENSE'))
+);
+
+-- ============================================================
+-- TABLE: journal_entries
+-- One business event (topup, purchase, refund, ...). APPEND-ONLY.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id UUID PRIMARY KEY,
+    description TEXT NOT NULL,
+    reference_id UUID,
+    reference_type VARCHAR(100),
+    wallet_transaction_id UUID UNIQUE REFERENCES wallet_transactions(id) ON DELETE SET NULL,
+    created_by_admin_id UUID,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_entries_reference ON journal_entries(reference_id);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_created_at ON journal_entries(created_at DESC);
+
+-- ============================================================
+-- TABLE: journal_postings
+-- Debits are positive, credits negative; an entry's postings sum to zero.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS journal_postings (
+    id UUID PRIMARY KEY,
+    entry_id UUID NOT NULL REFERENCES journal_entries(id) ON DELETE CASCADE,
+    account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+    amount DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (amount <> 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_journal_postings_entry ON journal_postings(entry_id);
+CREATE INDEX IF NOT EXISTS idx_journal_postings_account ON journal_postings(account_id, created_at DESC);
+
+-- Checked at commit, once all postings of the entry are in
+CREATE OR REPLACE FUNCTION check_journal_entry_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT COALESCE(SUM(amount), 0) FROM journal_postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_journal_entry_balanced ON journal_postings;
+CREATE CONSTRAINT TRIGGER trg_journal_entry_balanced
+    AFTER INSERT OR UPDATE ON journal_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_entry_balanced();
@@ -0,0 +1,20 @@
+-- ============================================================
+-- TABLE: reconciliation_runs
+-- Audit trail of wallet reconciliations (billing:reconcile and the
+-- scheduled job). Correcting ADMIN_ADJUSTMENT transactions reference
+-- their run with reference_type 'reconciliation_run'.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS reconciliation_runs (
+    id UUID PRIMARY KEY,
+    triggered_by VARCHAR(20) NOT NULL,             -- CONSOLE, SCHEDULER
+    fix BOOLEAN NOT NULL DEFAULT FALSE,
+    admin_id UUID,                                 -- recorded on correcting transactions
+    customers_checked INTEGER NOT NULL DEFAULT 0,
+    issues_found INTEGER NOT NULL DEFAULT 0,
+    issues_fixed INTEGER NOT NULL DEFAULT 0,
+    report JSONB,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconciliation_runs_started_at ON reconciliation_runs(started_at DESC);
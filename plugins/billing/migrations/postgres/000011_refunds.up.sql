@@ -0,0 +1,23 @@
+-- ============================================================
+-- TABLE: wallet_refunds
+-- Links every REFUND wallet transaction to the PURCHASE or RENEWAL it
+-- gives back. The refundable remainder of a charge is its amount minus
+-- the refunds recorded here.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS wallet_refunds (
+    id UUID PRIMARY KEY,
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    original_transaction_id UUID NOT NULL REFERENCES wallet_transactions(id),
+    refund_transaction_id UUID NOT NULL UNIQUE REFERENCES wallet_transactions(id),
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    kind VARCHAR(20) NOT NULL,                      -- MANUAL, PRORATED, DEPLOY_FAILED
+    reason TEXT,
+    created_by_admin_id UUID,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (kind IN ('MANUAL', 'PRORATED', 'DEPLOY_FAILED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_refunds_original ON wallet_refunds(original_transaction_id);
+CREATE INDEX IF NOT EXISTS idx_wallet_refunds_customer ON wallet_refunds(customer_id, created_at DESC);
+-- A charge is pro-rated once, however often the container delete is seen.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallet_refunds_prorated ON wallet_refunds(original_transaction_id) WHERE kind = 'PRORATED';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_topup_requests_paid_at;
+DROP INDEX IF EXISTS idx_wallet_transactions_type_created_at;
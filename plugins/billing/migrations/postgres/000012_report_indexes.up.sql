@@ -0,0 +1,5 @@
+-- Indexes for /admin/billing/reports: revenue and refund reports scan
+-- wallet transactions by type over a time range, fee revenue scans paid
+-- topups by payment time.
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_type_created_at ON wallet_transactions(type, created_at);
+CREATE INDEX IF NOT EXISTS idx_topup_requests_paid_at ON topup_requests(paid_at) WHERE status = 'SUCCESS';
@@ -0,0 +1,3 @@
+ALTER TABLE container_prices DROP COLUMN IF EXISTS setup_hold_id;
+ALTER TABLE customers DROP COLUMN IF EXISTS held_balance;
+DROP TABLE IF EXISTS wallet_holds;
@@ -0,0 +1,32 @@
+-- ============================================================
+-- TABLE: wallet_holds
+-- Funds reserved for a pending charge (a container deploy's setup fee).
+-- A hold lowers the available balance but not the wallet balance or the
+-- ledger; it is captured as a wallet transaction or released.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS wallet_holds (
+    id UUID PRIMARY KEY,
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',   -- ACTIVE, CAPTURED, RELEASED, EXPIRED
+    reference_id UUID,                              -- containers(id), etc
+    reference_type VARCHAR(100),
+    description TEXT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    transaction_id UUID REFERENCES wallet_transactions(id), -- set when captured
+    release_reason TEXT,
+    settled_at TIMESTAMPTZ,                         -- captured, released or expired
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (status IN ('ACTIVE', 'CAPTURED', 'RELEASED', 'EXPIRED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_holds_customer ON wallet_holds(customer_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_wallet_holds_reference ON wallet_holds(reference_id);
+CREATE INDEX IF NOT EXISTS idx_wallet_holds_expiry ON wallet_holds(expires_at) WHERE status = 'ACTIVE';
+
+-- Sum of the customer's ACTIVE holds; available = wallet_balance - held_balance.
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS held_balance DECIMAL(15,2) NOT NULL DEFAULT 0.00;
+
+-- The deploy's setup fee is held until the container is running.
+ALTER TABLE container_prices ADD COLUMN IF NOT EXISTS setup_hold_id UUID REFERENCES wallet_holds(id) ON DELETE SET NULL;
@@ -0,0 +1,13 @@
+ALTER TABLE invoices DROP COLUMN IF EXISTS customer_address;
+ALTER TABLE invoices DROP COLUMN IF EXISTS customer_tax_id;
+ALTER TABLE invoices DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE invoices DROP COLUMN IF EXISTS tax_inclusive;
+ALTER TABLE invoices DROP COLUMN IF EXISTS tax_rate;
+ALTER TABLE invoices DROP COLUMN IF EXISTS tax_name;
+
+ALTER TABLE topup_requests DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE topup_requests DROP COLUMN IF EXISTS tax_inclusive;
+ALTER TABLE topup_requests DROP COLUMN IF EXISTS tax_rate;
+ALTER TABLE topup_requests DROP COLUMN IF EXISTS tax_rule_id;
+
+DROP TABLE IF EXISTS tax_rules;
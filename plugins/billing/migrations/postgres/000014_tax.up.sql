@@ -0,0 +1,36 @@
+-- ============================================================
+-- TABLE: tax_rules
+-- VAT (PPN) charged on topups. The active rule with the latest
+-- effective_from that covers the topup's creation time applies.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS tax_rules (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,                     -- printed on invoices, e.g. PPN
+    rate DECIMAL(5,2) NOT NULL CHECK (rate >= 0 AND rate <= 100),
+    inclusive BOOLEAN NOT NULL DEFAULT FALSE,       -- TRUE: prices already include the tax
+    exempt_with_tax_id BOOLEAN NOT NULL DEFAULT FALSE, -- customers with a tax ID pay no tax
+    effective_from TIMESTAMPTZ NOT NULL,
+    effective_until TIMESTAMPTZ,                    -- NULL: open-ended
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (effective_until IS NULL OR effective_until > effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tax_rules_effective ON tax_rules(effective_from DESC) WHERE is_active;
+
+-- Tax quoted when the topup was created. total_paid = amount + fee + tax_amount
+-- for exclusive rules; for inclusive rules tax_amount is part of amount + fee.
+ALTER TABLE topup_requests ADD COLUMN IF NOT EXISTS tax_rule_id UUID REFERENCES tax_rules(id) ON DELETE SET NULL;
+ALTER TABLE topup_requests ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0.00;
+ALTER TABLE topup_requests ADD COLUMN IF NOT EXISTS tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE topup_requests ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00;
+
+-- Invoices keep their own copy so later rule or profile edits do not change
+-- an issued document.
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS tax_name VARCHAR(100);
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0.00;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(15,2) NOT NULL DEFAULT 0.00;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS customer_tax_id VARCHAR(32);
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS customer_address TEXT;
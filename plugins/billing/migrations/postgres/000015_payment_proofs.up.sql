@@ -0,0 +1,26 @@
+-- ============================================================
+-- TABLE: payment_proofs
+-- Proof of transfer (image or PDF) a customer attaches to a manual topup.
+-- The file lives in object storage under storage_key; admins review PENDING
+-- proofs and approve (confirming the topup) or reject them with a reason.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS payment_proofs (
+    id UUID PRIMARY KEY,
+    topup_id UUID NOT NULL REFERENCES topup_requests(id) ON DELETE CASCADE,
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    uploaded_by UUID REFERENCES customers(id) ON DELETE SET NULL,
+    storage_key TEXT NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',  -- PENDING, APPROVED, REJECTED, REPLACED
+    reviewed_by UUID REFERENCES admins(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    rejection_reason TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (status IN ('PENDING', 'APPROVED', 'REJECTED', 'REPLACED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_proofs_topup ON payment_proofs(topup_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payment_proofs_review ON payment_proofs(created_at) WHERE status = 'PENDING';
@@ -0,0 +1,31 @@
+-- ============================================================
+-- TABLE: balance_approvals
+-- Maker-checker requests for balance adjustments and refunds above the
+-- configured thresholds. One admin requests, a different SUPERADMIN approves
+-- (posting the wallet transaction) or rejects; PENDING requests expire at
+-- expires_at.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS balance_approvals (
+    id UUID PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,                      -- ADJUSTMENT, REFUND
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL CHECK (amount <> 0),
+    original_transaction_id UUID REFERENCES wallet_transactions(id) ON DELETE CASCADE, -- REFUND: the charge
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',  -- PENDING, APPROVED, REJECTED, CANCELLED, EXPIRED
+    requested_by UUID REFERENCES admins(id) ON DELETE SET NULL,
+    reviewed_by UUID REFERENCES admins(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    review_note TEXT,
+    wallet_transaction_id UUID REFERENCES wallet_transactions(id) ON DELETE SET NULL, -- posted on approval
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (kind IN ('ADJUSTMENT', 'REFUND')),
+    CHECK (kind <> 'REFUND' OR original_transaction_id IS NOT NULL),
+    CHECK (status IN ('PENDING', 'APPROVED', 'REJECTED', 'CANCELLED', 'EXPIRED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_approvals_customer ON balance_approvals(customer_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_balance_approvals_pending ON balance_approvals(expires_at) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_balance_approvals_charge ON balance_approvals(original_transaction_id) WHERE status = 'PENDING';
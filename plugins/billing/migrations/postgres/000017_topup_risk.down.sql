@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_topup_requests_risk_review;
+ALTER TABLE topup_requests DROP COLUMN IF EXISTS risk_reviewed_at;
+ALTER TABLE topup_requests DROP COLUMN IF EXISTS risk_reviewed_by;
+ALTER TABLE topup_requests DROP COLUMN IF EXISTS risk_status;
+DROP TABLE IF EXISTS topup_risk_hits;
+DROP TABLE IF EXISTS topup_risk_rules;
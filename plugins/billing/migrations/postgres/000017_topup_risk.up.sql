@@ -0,0 +1,70 @@
+-- ============================================================
+-- TABLE: topup_risk_rules
+-- Fraud and velocity rules evaluated when a topup is created. A BLOCK hit
+-- refuses the topup; a REVIEW hit creates it FLAGGED for an admin to clear
+-- or reject. Parameters by kind:
+--   MAX_PENDING       max_count PENDING topups already open
+--   DAILY_AMOUNT      max_amount created today (business time zone)
+--   MONTHLY_AMOUNT    max_amount created this calendar month
+--   ACCOUNT_AGE       max_amount per topup for accounts younger than window_hours
+--   UNVERIFIED_EMAIL  max_amount per topup until the email is verified
+--   FAILED_TOPUPS     max_count FAILED or EXPIRED topups within window_hours
+-- ============================================================
+CREATE TABLE IF NOT EXISTS topup_risk_rules (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(30) NOT NULL,
+    action VARCHAR(10) NOT NULL DEFAULT 'REVIEW',   -- BLOCK, REVIEW
+    max_count INT CHECK (max_count IS NULL OR max_count > 0),
+    max_amount DECIMAL(15,2) CHECK (max_amount IS NULL OR max_amount >= 0),
+    window_hours INT CHECK (window_hours IS NULL OR window_hours > 0),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (kind IN ('MAX_PENDING', 'DAILY_AMOUNT', 'MONTHLY_AMOUNT', 'ACCOUNT_AGE', 'UNVERIFIED_EMAIL', 'FAILED_TOPUPS')),
+    CHECK (action IN ('BLOCK', 'REVIEW'))
+);
+
+-- ============================================================
+-- TABLE: topup_risk_hits
+-- Every rule a topup attempt tripped, kept for analysis. topup_id is NULL
+-- when the attempt was blocked.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS topup_risk_hits (
+    id UUID PRIMARY KEY,
+    rule_id UUID REFERENCES topup_risk_rules(id) ON DELETE SET NULL,
+    rule_name VARCHAR(100) NOT NULL,
+    kind VARCHAR(30) NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    topup_id UUID REFERENCES topup_requests(id) ON DELETE SET NULL,
+    amount DECIMAL(15,2) NOT NULL,                  -- amount requested
+    detail TEXT NOT NULL,                           -- what was observed against the limit
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_topup_risk_hits_customer ON topup_risk_hits(customer_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_topup_risk_hits_rule ON topup_risk_hits(rule_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_topup_risk_hits_created ON topup_risk_hits(created_at DESC);
+
+-- FLAGGED topups wait for review: no charge is opened and the expiry sweep
+-- skips them. CLEARED ones proceed; REJECTED ones are cancelled.
+ALTER TABLE topup_requests ADD COLUMN IF NOT EXISTS risk_status VARCHAR(20)
+    CHECK (risk_status IN ('FLAGGED', 'CLEARED', 'REJECTED'));
+ALTER TABLE topup_requests ADD COLUMN IF NOT EXISTS risk_reviewed_by UUID REFERENCES admins(id) ON DELETE SET NULL;
+ALTER TABLE topup_requests ADD COLUMN IF NOT EXISTS risk_reviewed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_topup_requests_risk_review ON topup_requests(created_at) WHERE risk_status = 'FLAGGED';
+
+-- ============================================================
+-- SEED: Default rules
+-- ============================================================
+INSERT INTO topup_risk_rules (id, name, kind, action, max_count, max_amount, window_hours)
+VALUES
+    ('018e0000-0000-7000-8000-000000000101'::UUID, 'Open pending topups', 'MAX_PENDING', 'BLOCK', 5, NULL, NULL),
+    ('018e0000-0000-7000-8000-000000000102'::UUID, 'Daily topup amount', 'DAILY_AMOUNT', 'REVIEW', NULL, 50000000.00, NULL),
+    ('018e0000-0000-7000-8000-000000000103'::UUID, 'Monthly topup amount', 'MONTHLY_AMOUNT', 'REVIEW', NULL, 200000000.00, NULL),
+    ('018e0000-0000-7000-8000-000000000104'::UUID, 'New account topup', 'ACCOUNT_AGE', 'REVIEW', NULL, 5000000.00, 72),
+    ('018e0000-0000-7000-8000-000000000105'::UUID, 'Unverified email topup', 'UNVERIFIED_EMAIL', 'BLOCK', NULL, 1000000.00, NULL),
+    ('018e0000-0000-7000-8000-000000000106'::UUID, 'Repeated failed topups', 'FAILED_TOPUPS', 'REVIEW', 5, NULL, 24)
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,21 @@
+-- ============================================================
+-- webhook_inbox: one claim per provider event
+-- An entry claims its provider event (PROCESSING) before applying it; the
+-- partial unique index turns a concurrent redelivery into a DUPLICATE.
+-- Redeliveries themselves are still stored.
+-- ============================================================
+UPDATE webhook_inbox w
+SET status = 'DUPLICATE', updated_at = NOW()
+WHERE w.status IN ('PROCESSING', 'PROCESSED')
+  AND w.event_id IS NOT NULL
+  AND EXISTS (
+      SELECT 1 FROM webhook_inbox o
+      WHERE o.provider = w.provider
+        AND o.event_id = w.event_id
+        AND o.status IN ('PROCESSING', 'PROCESSED')
+        AND (o.created_at, o.id) < (w.created_at, w.id)
+  );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_inbox_event_claim
+    ON webhook_inbox(provider, event_id)
+    WHERE event_id IS NOT NULL AND status IN ('PROCESSING', 'PROCESSED');
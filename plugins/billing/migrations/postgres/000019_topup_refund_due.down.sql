@@ -0,0 +1,2 @@
+-- PostgreSQL cannot drop a single enum value; REFUND_DUE is left in place.
+SELECT 1;
@@ -0,0 +1,3 @@
+-- A payment that arrives after its account was closed is not credited; the
+-- topup is marked REFUND_DUE so finance refunds it by hand.
+ALTER TYPE topup_status ADD VALUE IF NOT EXISTS 'REFUND_DUE';
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"go_framework/internal/money"
 	appuuid "go_framework/internal/uuid"
 
 	"gorm.io/gorm"
@@ -10,19 +11,19 @@ import (
 
 // PaymentGateway represents payment provider configuration
 type PaymentGateway struct {
-	ID            string    `gorm:"type:uuid;primaryKey" json:"id"`
-	Name          string    `gorm:"size:100;not null;uniqueIndex" json:"name"`
-	Slug          string    `gorm:"size:50;not null;uniqueIndex" json:"slug"`
-	GatewayType   string    `gorm:"size:50;not null" json:"gateway_type"` // AUTOMATIC, MANUAL
-	IsActive      bool      `gorm:"default:true" json:"is_active"`
-	Config        string    `gorm:"type:jsonb" json:"config"`
-	FeePercentage float64   `gorm:"type:decimal(5,2);default:0.00" json:"fee_percentage"`
-	FeeFixed      float64   `gorm:"type:decimal(15,2);default:0.00" json:"fee_fixed"`
-	MinAmount     float64   `gorm:"type:decimal(15,2);default:10000.00" json:"min_amount"`
-	MaxAmount     float64   `gorm:"type:decimal(15,2);default:10000000.00" json:"max_amount"`
-	DisplayOrder  int       `gorm:"default:0" json:"display_order"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            string       `gorm:"type:uuid;primaryKey" json:"id"`
+	Name          string       `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Slug          string       `gorm:"size:50;not null;uniqueIndex" json:"slug"`
+	GatewayType   string       `gorm:"size:50;not null" json:"gateway_type"` // AUTOMATIC, MANUAL
+	IsActive      bool         `gorm:"default:true" json:"is_active"`
+	Config        string       `gorm:"type:jsonb" json:"config"`
+	FeePercentage money.Rate   `gorm:"type:decimal(5,2);default:0.00" json:"fee_percentage"`
+	FeeFixed      money.Amount `gorm:"type:decimal(15,2);default:0.00" json:"fee_fixed"`
+	MinAmount     money.Amount `gorm:"type:decimal(15,2);default:10000.00" json:"min_amount"`
+	MaxAmount     money.Amount `gorm:"type:decimal(15,2);default:10000000.00" json:"max_amount"`
+	DisplayOrder  int          `gorm:"default:0" json:"display_order"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
 }
 
 func (PaymentGateway) TableName() string { return "payment_gateways" }
@@ -40,21 +41,32 @@ func (g *PaymentGateway) BeforeCreate(tx *gorm.DB) error {
 
 // TopupRequest tracks customer top-up requests
 type TopupRequest struct {
-	ID             string     `gorm:"type:uuid;primaryKey" json:"id"`
-	CustomerID     string     `gorm:"type:uuid;not null;index" json:"customer_id"`
-	GatewayID      string     `gorm:"type:uuid;not null;index" json:"gateway_id"`
-	Amount         float64    `gorm:"type:decimal(15,2);not null" json:"amount"`
-	Fee            float64    `gorm:"type:decimal(15,2);default:0.00" json:"fee"`
-	TotalPaid      float64    `gorm:"type:decimal(15,2);not null" json:"total_paid"`
-	ExternalID     *string    `gorm:"size:255;uniqueIndex" json:"external_id,omitempty"`
-	PaymentURL     *string    `gorm:"type:text" json:"payment_url,omitempty"`
-	PaymentMethod  *string    `gorm:"size:100" json:"payment_method,omitempty"`
-	PaymentChannel *string    `gorm:"size:100" json:"payment_channel,omitempty"`
-	Status         string     `gorm:"type:topup_status;not null;default:PENDING;index" json:"status"`
-	PaidAt         *time.Time `json:"paid_at,omitempty"`
-	ExpiredAt      *time.Time `json:"expired_at,omitempty"`
-	WebhookData    string     `gorm:"type:jsonb" json:"webhook_data,omitempty"`
-	Notes          *string    `gorm:"type:text" json:"notes,omitempty"`
+	ID             string       `gorm:"type:uuid;primaryKey" json:"id"`
+	CustomerID     string       `gorm:"type:uuid;not null;index" json:"customer_id"`
+	GatewayID      string       `gorm:"type:uuid;not null;index" json:"gateway_id"`
+	Amount         money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Fee            money.Amount `gorm:"type:decimal(15,2);default:0.00" json:"fee"`
+	TotalPaid      money.Amount `gorm:"type:decimal(15,2);not null" json:"total_paid"`
+	ExternalID     *string      `gorm:"size:255;uniqueIndex" json:"external_id,omitempty"`
+	PaymentURL     *string      `gorm:"type:text" json:"payment_url,omitempty"`
+	PaymentMethod  *string      `gorm:"size:100" json:"payment_method,omitempty"`
+	PaymentChannel *string      `gorm:"size:100" json:"payment_channel,omitempty"`
+	Status         string       `gorm:"type:topup_status;not null;default:PENDING;index" json:"status"`
+	PaidAt         *time.Time   `json:"paid_at,omitempty"`
+	ExpiredAt      *time.Time   `json:"expired_at,omitempty"`
+	WebhookData    string       `gorm:"type:jsonb" json:"webhook_data,omitempty"`
+	Notes          *string      `gorm:"type:text" json:"notes,omitempty"`
+	PromotionID    *string      `gorm:"type:uuid" json:"promotion_id,omitempty"`
+	BonusAmount    money.Amount `gorm:"type:decimal(15,2);default:0.00" json:"bonus_amount"`
+	// Tax quoted at creation; TotalPaid includes TaxAmount unless TaxInclusive.
+	TaxRuleID    *string      `gorm:"type:uuid" json:"tax_rule_id,omitempty"`
+	TaxRate      money.Rate   `gorm:"type:decimal(5,2);default:0.00" json:"tax_rate"`
+	TaxInclusive bool         `gorm:"not null;default:false" json:"tax_inclusive"`
+	TaxAmount    money.Amount `gorm:"type:decimal(15,2);default:0.00" json:"tax_amount"`
+	// RiskStatus is set when a risk rule flagged the topup for review.
+	RiskStatus     *string    `gorm:"size:20" json:"risk_status,omitempty"` // FLAGGED, CLEARED, REJECTED
+	RiskReviewedBy *string    `gorm:"type:uuid" json:"risk_reviewed_by,omitempty"`
+	RiskReviewedAt *time.Time `json:"risk_reviewed_at,omitempty"`
 	CreatedAt      time.Time  `gorm:"index:idx_topup_created" json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 
@@ -77,18 +89,18 @@ func (t *TopupRequest) BeforeCreate(tx *gorm.DB) error {
 
 // WalletTransaction represents audit trail for all wallet mutations
 type WalletTransaction struct {
-	ID               string    `gorm:"type:uuid;primaryKey" json:"id"`
-	CustomerID       string    `gorm:"type:uuid;not null;index" json:"customer_id"`
-	Amount           float64   `gorm:"type:decimal(15,2);not null" json:"amount"` // Positive (credit), Negative (debit)
-	BalanceBefore    float64   `gorm:"type:decimal(15,2);not null" json:"balance_before"`
-	BalanceAfter     float64   `gorm:"type:decimal(15,2);not null" json:"balance_after"`
-	Type             string    `gorm:"type:transaction_type;not null;index" json:"type"` // TOPUP, PURCHASE, REFUND, etc
-	ReferenceID      *string   `gorm:"type:uuid;index" json:"reference_id,omitempty"`
-	ReferenceType    *string   `gorm:"size:100" json:"reference_type,omitempty"` // topup_request, container, manual
-	Description      string    `gorm:"type:text" json:"description"`
-	Metadata         string    `gorm:"type:jsonb" json:"metadata,omitempty"`
-	CreatedByAdminID *string   `gorm:"type:uuid" json:"created_by_admin_id,omitempty"`
-	CreatedAt        time.Time `gorm:"index:idx_wallet_txn_created" json:"created_at"`
+	ID               string       `gorm:"type:uuid;primaryKey" json:"id"`
+	CustomerID       string       `gorm:"type:uuid;not null;index" json:"customer_id"`
+	Amount           money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"` // Positive (credit), Negative (debit)
+	BalanceBefore    money.Amount `gorm:"type:decimal(15,2);not null" json:"balance_before"`
+	BalanceAfter     money.Amount `gorm:"type:decimal(15,2);not null" json:"balance_after"`
+	Type             string       `gorm:"type:transaction_type;not null;index" json:"type"` // TOPUP, PURCHASE, REFUND, etc
+	ReferenceID      *string      `gorm:"type:uuid;index" json:"reference_id,omitempty"`
+	ReferenceType    *string      `gorm:"size:100" json:"reference_type,omitempty"` // topup_request, container, manual
+	Description      string       `gorm:"type:text" json:"description"`
+	Metadata         string       `gorm:"type:jsonb" json:"metadata,omitempty"`
+	CreatedByAdminID *string      `gorm:"type:uuid" json:"created_by_admin_id,omitempty"`
+	CreatedAt        time.Time    `gorm:"index:idx_wallet_txn_created" json:"created_at"`
 }
 
 func (WalletTransaction) TableName() string { return "wallet_transactions" }
@@ -104,11 +116,610 @@ func (t *WalletTransaction) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// WebhookInboxEntry is one inbound payment webhook, stored before processing
+type WebhookInboxEntry struct {
+	ID             string     `gorm:"type:uuid;primaryKey" json:"id"`
+	Provider       string     `gorm:"size:50;not null" json:"provider"`
+	GatewayID      *string    `gorm:"type:uuid" json:"gateway_id,omitempty"`
+	EventID        *string    `gorm:"size:255" json:"event_id,omitempty"`
+	ExternalID     *string    `gorm:"size:255;index" json:"external_id,omitempty"`
+	Headers        string     `gorm:"type:jsonb" json:"headers,omitempty"`
+	Body           string     `gorm:"type:text;not null" json:"body"`
+	SignatureValid *bool      `json:"signature_valid"`
+	Status         string     `gorm:"size:20;not null;default:RECEIVED" json:"status"` // RECEIVED, PROCESSING, PROCESSED, FAILED, REJECTED, DUPLICATE, UNKNOWN
+	Error          *string    `gorm:"type:text" json:"error,omitempty"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	ProcessedAt    *time.Time `json:"processed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (WebhookInboxEntry) TableName() string { return "webhook_inbox" }
+
+func (e *WebhookInboxEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		e.ID = id
+	}
+	return nil
+}
+
+// Invoice is the receipt issued when a topup succeeds. Numbers are
+// sequential and gap-free per year.
+type Invoice struct {
+	ID              string       `gorm:"type:uuid;primaryKey" json:"id"`
+	InvoiceNumber   string       `gorm:"size:50;not null;uniqueIndex" json:"invoice_number"`
+	Year            int          `gorm:"not null" json:"year"`
+	Sequence        int          `gorm:"not null" json:"sequence"`
+	CustomerID      *string      `gorm:"type:uuid;index" json:"customer_id,omitempty"` // NULL once the customer is deleted
+	TopupID         *string      `gorm:"type:uuid;uniqueIndex" json:"topup_id,omitempty"`
+	Amount          money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Fee             money.Amount `gorm:"type:decimal(15,2);default:0.00" json:"fee"`
+	Total           money.Amount `gorm:"type:decimal(15,2);not null" json:"total"`
+	Currency        string       `gorm:"size:3;not null;default:IDR" json:"currency"`
+	CustomerName    string       `gorm:"size:255" json:"customer_name"`
+	CustomerEmail   string       `gorm:"size:255" json:"customer_email"`
+	GatewayName     string       `gorm:"size:100" json:"gateway_name"`
+	TaxName         *string      `gorm:"size:100" json:"tax_name,omitempty"`
+	TaxRate         money.Rate   `gorm:"type:decimal(5,2);default:0.00" json:"tax_rate"`
+	TaxInclusive    bool         `gorm:"not null;default:false" json:"tax_inclusive"`
+	TaxAmount       money.Amount `gorm:"type:decimal(15,2);default:0.00" json:"tax_amount"`
+	CustomerTaxID   *string      `gorm:"size:32" json:"customer_tax_id,omitempty"`
+	CustomerAddress *string      `gorm:"type:text" json:"customer_address,omitempty"`
+	HTMLKey         *string      `gorm:"column:html_key;size:500" json:"-"`
+	PDFKey          *string      `gorm:"column:pdf_key;size:500" json:"-"`
+	EmailedAt       *time.Time   `json:"emailed_at,omitempty"`
+	IssuedAt        time.Time    `gorm:"not null" json:"issued_at"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+func (Invoice) TableName() string { return "invoices" }
+
+func (i *Invoice) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		i.ID = id
+	}
+	return nil
+}
+
+// ContainerMeter tracks a RUNNING container whose usage since MeteredSince
+// has not been billed yet
+type ContainerMeter struct {
+	ContainerID  string    `gorm:"type:uuid;primaryKey" json:"container_id"`
+	CustomerID   string    `gorm:"type:uuid;not null" json:"customer_id"`
+	RamMB        int       `gorm:"not null" json:"ram_mb"`
+	CPUPercent   int       `gorm:"not null" json:"cpu_percent"`
+	MeteredSince time.Time `gorm:"not null" json:"metered_since"`
+	LastSeenAt   time.Time `gorm:"not null" json:"last_seen_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (ContainerMeter) TableName() string { return "container_meters" }
+
+// ContainerUsage is one metered period of a container, charged as RENEWAL
+type ContainerUsage struct {
+	ID                  string       `gorm:"type:uuid;primaryKey" json:"id"`
+	ContainerID         string       `gorm:"type:uuid;not null;index" json:"container_id"`
+	CustomerID          string       `gorm:"type:uuid;not null;index" json:"customer_id"`
+	PeriodStart         time.Time    `gorm:"not null" json:"period_start"`
+	PeriodEnd           time.Time    `gorm:"not null" json:"period_end"`
+	Hours               int          `gorm:"not null" json:"hours"`
+	RamMB               int          `gorm:"not null" json:"ram_mb"`
+	CPUPercent          int          `gorm:"not null" json:"cpu_percent"`
+	Amount              money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Status              string       `gorm:"size:20;not null;default:BILLED" json:"status"` // BILLED, UNPAID
+	WalletTransactionID *string      `gorm:"type:uuid" json:"wallet_transaction_id,omitempty"`
+	BilledAt            *time.Time   `json:"billed_at,omitempty"`
+	CreatedAt           time.Time    `json:"created_at"`
+}
+
+func (ContainerUsage) TableName() string { return "container_usage" }
+
+func (u *ContainerUsage) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		u.ID = id
+	}
+	return nil
+}
+
+// PricePlan is a rate card for containers, in effect from EffectiveFrom
+type PricePlan struct {
+	ID              string       `gorm:"type:uuid;primaryKey" json:"id"`
+	Name            string       `gorm:"size:100;not null" json:"name"`
+	Description     *string      `gorm:"type:text" json:"description,omitempty"`
+	EffectiveFrom   time.Time    `gorm:"not null" json:"effective_from"`
+	IsActive        bool         `gorm:"not null;default:true" json:"is_active"`
+	PricePerGBHour  money.Amount `gorm:"column:price_per_gb_hour;type:decimal(15,2);not null" json:"price_per_gb_hour"`
+	PricePerCPUHour money.Amount `gorm:"column:price_per_cpu_hour;type:decimal(15,2);not null" json:"price_per_cpu_hour"`
+	SetupFee        money.Amount `gorm:"type:decimal(15,2);default:0.00" json:"setup_fee"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+
+	// Relations
+	Tiers     []PricePlanTier `gorm:"foreignKey:PlanID" json:"tiers"`
+	Overrides []PriceOverride `gorm:"foreignKey:PlanID" json:"overrides"`
+}
+
+func (PricePlan) TableName() string { return "price_plans" }
+
+func (p *PricePlan) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		p.ID = id
+	}
+	return nil
+}
+
+// PricePlanTier replaces a plan's RAM or CPU rate inside a unit range
+type PricePlanTier struct {
+	ID           string       `gorm:"type:uuid;primaryKey" json:"id"`
+	PlanID       string       `gorm:"type:uuid;not null;index" json:"plan_id"`
+	Resource     string       `gorm:"size:10;not null" json:"resource"` // RAM (MB, priced per GB), CPU (%)
+	MinUnits     int          `gorm:"not null;default:0" json:"min_units"`
+	MaxUnits     *int         `json:"max_units,omitempty"`
+	PricePerHour money.Amount `gorm:"type:decimal(15,2);not null" json:"price_per_hour"`
+}
+
+func (PricePlanTier) TableName() string { return "price_plan_tiers" }
+
+func (t *PricePlanTier) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		t.ID = id
+	}
+	return nil
+}
+
+// PriceOverride sets rates for an app template and/or region
+type PriceOverride struct {
+	ID              string        `gorm:"type:uuid;primaryKey" json:"id"`
+	PlanID          string        `gorm:"type:uuid;not null;index" json:"plan_id"`
+	TemplateID      *string       `gorm:"type:uuid" json:"template_id,omitempty"`
+	RegionCode      *string       `gorm:"size:10" json:"region_code,omitempty"`
+	PricePerGBHour  *money.Amount `gorm:"column:price_per_gb_hour;type:decimal(15,2)" json:"price_per_gb_hour,omitempty"`
+	PricePerCPUHour *money.Amount `gorm:"column:price_per_cpu_hour;type:decimal(15,2)" json:"price_per_cpu_hour,omitempty"`
+	SetupFee        *money.Amount `gorm:"type:decimal(15,2)" json:"setup_fee,omitempty"`
+}
+
+func (PriceOverride) TableName() string { return "price_overrides" }
+
+func (o *PriceOverride) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		o.ID = id
+	}
+	return nil
+}
+
+// ContainerPrice pins the plan a container was bought under
+type ContainerPrice struct {
+	ContainerID        string       `gorm:"type:uuid;primaryKey" json:"container_id"`
+	CustomerID         string       `gorm:"type:uuid;not null" json:"customer_id"`
+	PlanID             *string      `gorm:"type:uuid" json:"plan_id,omitempty"` // nil = built-in default rates
+	TemplateID         *string      `gorm:"type:uuid" json:"template_id,omitempty"`
+	RegionCode         *string      `gorm:"size:10" json:"region_code,omitempty"`
+	SetupFee           money.Amount `gorm:"type:decimal(15,2);default:0.00" json:"setup_fee"`
+	SetupTransactionID *string      `gorm:"type:uuid" json:"setup_transaction_id,omitempty"`
+	SetupHoldID        *string      `gorm:"type:uuid" json:"setup_hold_id,omitempty"` // until the first deploy succeeds
+	PricedAt           time.Time    `gorm:"not null" json:"priced_at"`
+}
+
+func (ContainerPrice) TableName() string { return "container_prices" }
+
+// Promotion is a promo code that adds bonus credit to a topup
+type Promotion struct {
+	ID                        string        `gorm:"type:uuid;primaryKey" json:"id"`
+	Code                      string        `gorm:"size:50;not null;uniqueIndex" json:"code"`
+	Name                      string        `gorm:"size:100;not null" json:"name"`
+	Description               *string       `gorm:"type:text" json:"description,omitempty"`
+	BonusType                 string        `gorm:"size:20;not null" json:"bonus_type"` // PERCENT, FIXED
+	BonusPercent              money.Rate    `gorm:"type:decimal(5,2);default:0.00" json:"bonus_percent"`
+	BonusAmount               money.Amount  `gorm:"type:decimal(15,2);default:0.00" json:"bonus_amount"`
+	MaxBonus                  *money.Amount `gorm:"type:decimal(15,2)" json:"max_bonus,omitempty"`
+	MinTopupAmount            money.Amount  `gorm:"type:decimal(15,2);default:0.00" json:"min_topup_amount"`
+	FirstTopupOnly            bool          `gorm:"not null;default:false" json:"first_topup_only"`
+	MaxRedemptions            *int          `json:"max_redemptions,omitempty"`
+	MaxRedemptionsPerCustomer *int          `json:"max_redemptions_per_customer,omitempty"`
+	StartsAt                  *time.Time    `json:"starts_at,omitempty"`
+	EndsAt                    *time.Time    `json:"ends_at,omitempty"`
+	IsActive                  bool          `gorm:"not null;default:true" json:"is_active"`
+	CreatedAt                 time.Time     `json:"created_at"`
+	UpdatedAt                 time.Time     `json:"updated_at"`
+
+	// Relations
+	Gateways []PromotionGateway `gorm:"foreignKey:PromotionID" json:"gateways"`
+}
+
+func (Promotion) TableName() string { return "promotions" }
+
+func (p *Promotion) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		p.ID = id
+	}
+	return nil
+}
+
+// PromotionGateway restricts a promotion to a payment gateway
+type PromotionGateway struct {
+	PromotionID string `gorm:"type:uuid;primaryKey" json:"promotion_id"`
+	GatewayID   string `gorm:"type:uuid;primaryKey" json:"gateway_id"`
+}
+
+func (PromotionGateway) TableName() string { return "promotion_gateways" }
+
+// PromotionRedemption is a promo code applied to one topup
+type PromotionRedemption struct {
+	ID                  string       `gorm:"type:uuid;primaryKey" json:"id"`
+	PromotionID         string       `gorm:"type:uuid;not null;index" json:"promotion_id"`
+	CustomerID          string       `gorm:"type:uuid;not null;index" json:"customer_id"`
+	TopupID             string       `gorm:"type:uuid;not null;uniqueIndex" json:"topup_id"`
+	BonusAmount         money.Amount `gorm:"type:decimal(15,2);not null" json:"bonus_amount"`
+	Status              string       `gorm:"size:20;not null;default:RESERVED" json:"status"` // RESERVED, APPLIED, RELEASED
+	WalletTransactionID *string      `gorm:"type:uuid" json:"wallet_transaction_id,omitempty"`
+	AppliedAt           *time.Time   `json:"applied_at,omitempty"`
+	CreatedAt           time.Time    `json:"created_at"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+}
+
+func (PromotionRedemption) TableName() string { return "promotion_redemptions" }
+
+func (r *PromotionRedemption) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		r.ID = id
+	}
+	return nil
+}
+
+// LedgerAccount is an account in the double-entry ledger
+type LedgerAccount struct {
+	ID         string    `gorm:"type:uuid;primaryKey" json:"id"`
+	Code       string    `gorm:"size:100;not null;uniqueIndex" json:"code"`
+	Name       string    `gorm:"size:255;not null" json:"name"`
+	Type       string    `gorm:"size:20;not null" json:"type"` // ASSET, LIABILITY, EQUITY, REVENUE, EXPENSE
+	CustomerID *string   `gorm:"type:uuid;uniqueIndex" json:"customer_id,omitempty"`
+	GatewayID  *string   `gorm:"type:uuid" json:"gateway_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (LedgerAccount) TableName() string { return "ledger_accounts" }
+
+func (a *LedgerAccount) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		a.ID = id
+	}
+	return nil
+}
+
+// JournalEntry groups the balanced postings of one business event
+type JournalEntry struct {
+	ID                  string    `gorm:"type:uuid;primaryKey" json:"id"`
+	Description         string    `gorm:"type:text;not null" json:"description"`
+	ReferenceID         *string   `gorm:"type:uuid;index" json:"reference_id,omitempty"`
+	ReferenceType       *string   `gorm:"size:100" json:"reference_type,omitempty"`
+	WalletTransactionID *string   `gorm:"type:uuid;uniqueIndex" json:"wallet_transaction_id,omitempty"`
+	CreatedByAdminID    *string   `gorm:"type:uuid" json:"created_by_admin_id,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+
+	// Relations
+	Postings []JournalPosting `gorm:"foreignKey:EntryID" json:"postings,omitempty"`
+}
+
+func (JournalEntry) TableName() string { return "journal_entries" }
+
+func (e *JournalEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		e.ID = id
+	}
+	return nil
+}
+
+// JournalPosting is one leg of a journal entry: debits positive, credits
+// negative
+type JournalPosting struct {
+	ID        string       `gorm:"type:uuid;primaryKey" json:"id"`
+	EntryID   string       `gorm:"type:uuid;not null;index" json:"entry_id"`
+	AccountID string       `gorm:"type:uuid;not null;index" json:"account_id"`
+	Amount    money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"`
+	CreatedAt time.Time    `json:"created_at"`
+
+	// Relations
+	Account *LedgerAccount `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+}
+
+func (JournalPosting) TableName() string { return "journal_postings" }
+
+func (p *JournalPosting) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		p.ID = id
+	}
+	return nil
+}
+
+// ReconciliationRun records one wallet reconciliation and its report
+type ReconciliationRun struct {
+	ID               string     `gorm:"type:uuid;primaryKey" json:"id"`
+	TriggeredBy      string     `gorm:"size:20;not null" json:"triggered_by"` // CONSOLE, SCHEDULER
+	Fix              bool       `gorm:"not null;default:false" json:"fix"`
+	AdminID          *string    `gorm:"type:uuid" json:"admin_id,omitempty"`
+	CustomersChecked int        `gorm:"not null;default:0" json:"customers_checked"`
+	IssuesFound      int        `gorm:"not null;default:0" json:"issues_found"`
+	IssuesFixed      int        `gorm:"not null;default:0" json:"issues_fixed"`
+	Report           string     `gorm:"type:jsonb" json:"report,omitempty"`
+	StartedAt        time.Time  `gorm:"not null" json:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+}
+
+func (ReconciliationRun) TableName() string { return "reconciliation_runs" }
+
+func (r *ReconciliationRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		r.ID = id
+	}
+	return nil
+}
+
+// WalletRefund links a REFUND wallet transaction to the charge it gives back
+type WalletRefund struct {
+	ID                    string       `gorm:"type:uuid;primaryKey" json:"id"`
+	CustomerID            string       `gorm:"type:uuid;not null;index" json:"customer_id"`
+	OriginalTransactionID string       `gorm:"type:uuid;not null;index" json:"original_transaction_id"`
+	RefundTransactionID   string       `gorm:"type:uuid;not null;uniqueIndex" json:"refund_transaction_id"`
+	Amount                money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Kind                  string       `gorm:"size:20;not null" json:"kind"` // MANUAL, PRORATED, DEPLOY_FAILED
+	Reason                *string      `gorm:"type:text" json:"reason,omitempty"`
+	CreatedByAdminID      *string      `gorm:"type:uuid" json:"created_by_admin_id,omitempty"`
+	CreatedAt             time.Time    `json:"created_at"`
+}
+
+func (WalletRefund) TableName() string { return "wallet_refunds" }
+
+func (r *WalletRefund) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		r.ID = id
+	}
+	return nil
+}
+
+// BalanceApproval is a large adjustment or refund waiting for a second admin
+type BalanceApproval struct {
+	ID                    string       `gorm:"type:uuid;primaryKey" json:"id"`
+	Kind                  string       `gorm:"size:20;not null" json:"kind"` // ADJUSTMENT, REFUND
+	CustomerID            string       `gorm:"type:uuid;not null;index" json:"customer_id"`
+	Amount                money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"`
+	OriginalTransactionID *string      `gorm:"type:uuid" json:"original_transaction_id,omitempty"` // REFUND: the charge
+	Reason                string       `gorm:"type:text;not null" json:"reason"`
+	Status                string       `gorm:"size:20;not null;default:PENDING" json:"status"` // PENDING, APPROVED, REJECTED, CANCELLED, EXPIRED
+	RequestedBy           *string      `gorm:"type:uuid" json:"requested_by,omitempty"`
+	ReviewedBy            *string      `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt            *time.Time   `json:"reviewed_at,omitempty"`
+	ReviewNote            *string      `gorm:"type:text" json:"review_note,omitempty"`
+	WalletTransactionID   *string      `gorm:"type:uuid" json:"wallet_transaction_id,omitempty"`
+	ExpiresAt             time.Time    `gorm:"not null" json:"expires_at"`
+	CreatedAt             time.Time    `json:"created_at"`
+	UpdatedAt             time.Time    `json:"updated_at"`
+}
+
+func (BalanceApproval) TableName() string { return "balance_approvals" }
+
+func (a *BalanceApproval) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		a.ID = id
+	}
+	return nil
+}
+
+// WalletHold reserves funds for a pending charge
+type WalletHold struct {
+	ID            string       `gorm:"type:uuid;primaryKey" json:"id"`
+	CustomerID    string       `gorm:"type:uuid;not null;index" json:"customer_id"`
+	Amount        money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Status        string       `gorm:"size:20;not null;default:ACTIVE" json:"status"` // ACTIVE, CAPTURED, RELEASED, EXPIRED
+	ReferenceID   *string      `gorm:"type:uuid;index" json:"reference_id,omitempty"`
+	ReferenceType *string      `gorm:"size:100" json:"reference_type,omitempty"`
+	Description   string       `gorm:"type:text" json:"description"`
+	ExpiresAt     time.Time    `gorm:"not null" json:"expires_at"`
+	TransactionID *string      `gorm:"type:uuid" json:"transaction_id,omitempty"` // set when captured
+	ReleaseReason *string      `gorm:"type:text" json:"release_reason,omitempty"`
+	SettledAt     *time.Time   `json:"settled_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+func (WalletHold) TableName() string { return "wallet_holds" }
+
+func (h *WalletHold) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		h.ID = id
+	}
+	return nil
+}
+
+// TaxRule is a VAT (PPN) rate charged on topups during its effective window
+type TaxRule struct {
+	ID              string     `gorm:"type:uuid;primaryKey" json:"id"`
+	Name            string     `gorm:"size:100;not null" json:"name"`
+	Rate            money.Rate `gorm:"type:decimal(5,2);not null" json:"rate"`
+	Inclusive       bool       `gorm:"not null;default:false" json:"inclusive"`          // prices already include the tax
+	ExemptWithTaxID bool       `gorm:"not null;default:false" json:"exempt_with_tax_id"` // customers with a tax ID pay none
+	EffectiveFrom   time.Time  `gorm:"not null" json:"effective_from"`
+	EffectiveUntil  *time.Time `json:"effective_until,omitempty"`
+	IsActive        bool       `gorm:"not null;default:true" json:"is_active"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (TaxRule) TableName() string { return "tax_rules" }
+
+func (r *TaxRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		r.ID = id
+	}
+	return nil
+}
+
+// PaymentProof is a proof of transfer attached to a manual topup
+type PaymentProof struct {
+	ID              string     `gorm:"type:uuid;primaryKey" json:"id"`
+	TopupID         string     `gorm:"type:uuid;not null;index" json:"topup_id"`
+	CustomerID      string     `gorm:"type:uuid;not null" json:"customer_id"`
+	UploadedBy      *string    `gorm:"type:uuid" json:"uploaded_by,omitempty"`
+	StorageKey      string     `gorm:"type:text;not null" json:"-"`
+	Filename        string     `gorm:"size:255;not null" json:"filename"`
+	ContentType     string     `gorm:"size:100;not null" json:"content_type"`
+	SizeBytes       int64      `gorm:"not null" json:"size_bytes"`
+	Status          string     `gorm:"size:20;not null;default:PENDING" json:"status"` // PENDING, APPROVED, REJECTED, REPLACED
+	ReviewedBy      *string    `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	RejectionReason *string    `gorm:"type:text" json:"rejection_reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// Relations
+	Topup *TopupRequest `gorm:"foreignKey:TopupID" json:"topup,omitempty"`
+}
+
+func (PaymentProof) TableName() string { return "payment_proofs" }
+
+func (p *PaymentProof) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		p.ID = id
+	}
+	return nil
+}
+
 // Customer extension - we need to reference wallet_balance
 // This is just for reference, actual Customer model is in auth plugin
 type CustomerBalance struct {
-	ID            string  `gorm:"type:uuid;primaryKey" json:"id"`
-	WalletBalance float64 `gorm:"type:decimal(15,2);default:0.00;not null" json:"wallet_balance"`
+	ID            string       `gorm:"type:uuid;primaryKey" json:"id"`
+	WalletBalance money.Amount `gorm:"type:decimal(15,2);default:0.00;not null" json:"wallet_balance"`
+	// HeldBalance is the sum of ACTIVE wallet holds, not available to spend.
+	HeldBalance money.Amount `gorm:"type:decimal(15,2);default:0.00;not null" json:"held_balance"`
+	// TaxID is the customer's NPWP, owned by the auth profile.
+	TaxID *string `json:"tax_id,omitempty"`
+	// TopupsFrozenAt is set while the customer is suspended or banned.
+	TopupsFrozenAt *time.Time `json:"topups_frozen_at,omitempty"`
+	// UsageOverdueSince is set while container usage charges are unpaid;
+	// UsageStoppedAt once the grace period ran out and containers were stopped.
+	UsageOverdueSince *time.Time `json:"usage_overdue_since,omitempty"`
+	UsageStoppedAt    *time.Time `json:"usage_stopped_at,omitempty"`
 }
 
 func (CustomerBalance) TableName() string { return "customers" }
+
+// TopupRiskRule is a fraud or velocity limit checked when a topup is created
+type TopupRiskRule struct {
+	ID          string        `gorm:"type:uuid;primaryKey" json:"id"`
+	Name        string        `gorm:"size:100;not null" json:"name"`
+	Kind        string        `gorm:"size:30;not null" json:"kind"`                  // MAX_PENDING, DAILY_AMOUNT, MONTHLY_AMOUNT, ACCOUNT_AGE, UNVERIFIED_EMAIL, FAILED_TOPUPS
+	Action      string        `gorm:"size:10;not null;default:REVIEW" json:"action"` // BLOCK, REVIEW
+	MaxCount    *int          `json:"max_count,omitempty"`
+	MaxAmount   *money.Amount `gorm:"type:decimal(15,2)" json:"max_amount,omitempty"`
+	WindowHours *int          `json:"window_hours,omitempty"`
+	IsActive    bool          `gorm:"not null;default:true" json:"is_active"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+func (TopupRiskRule) TableName() string { return "topup_risk_rules" }
+
+func (r *TopupRiskRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		r.ID = id
+	}
+	return nil
+}
+
+// TopupRiskHit records a rule tripped by a topup attempt
+type TopupRiskHit struct {
+	ID         string       `gorm:"type:uuid;primaryKey" json:"id"`
+	RuleID     *string      `gorm:"type:uuid" json:"rule_id,omitempty"`
+	RuleName   string       `gorm:"size:100;not null" json:"rule_name"`
+	Kind       string       `gorm:"size:30;not null" json:"kind"`
+	Action     string       `gorm:"size:10;not null" json:"action"`
+	CustomerID string       `gorm:"type:uuid;not null;index" json:"customer_id"`
+	TopupID    *string      `gorm:"type:uuid" json:"topup_id,omitempty"` // nil: the attempt was blocked
+	Amount     money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Detail     string       `gorm:"type:text;not null" json:"detail"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+func (TopupRiskHit) TableName() string { return "topup_risk_hits" }
+
+func (h *TopupRiskHit) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == "" {
+		id, err := appuuid.New()
+		if err != nil {
+			return err
+		}
+		h.ID = id
+	}
+	return nil
+}
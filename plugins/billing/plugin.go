@@ -1,8 +1,11 @@
 package billing
 
 import (
+	"go_framework/internal/idempotency"
 	"go_framework/internal/plugins"
+	"go_framework/plugins/billing/gateways"
 	pluginhandlers "go_framework/plugins/billing/handlers"
+	pluginservices "go_framework/plugins/billing/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cobra"
@@ -17,7 +20,23 @@ func New() plugins.Plugin { return &Plugin{} }
 
 func (p *Plugin) ID() string { return "billing" }
 
-func (p *Plugin) RegisterServices(deps plugins.ServiceDeps) error { p.deps = deps; return nil }
+func (p *Plugin) RegisterServices(deps plugins.ServiceDeps) error {
+	p.deps = deps
+	pluginservices.RegisterClosureHooks()
+	pluginservices.RegisterSuspensionSubscribers()
+	gateways.RegisterDefaults()
+	pluginservices.RegisterTopupExpiryJob()
+	pluginservices.RegisterUnknownWebhookPurgeJob()
+	pluginservices.RegisterHoldExpiryJob()
+	pluginservices.RegisterApprovalExpiryJob()
+	pluginservices.SetInvoiceStore(deps.Store)
+	pluginservices.SetPaymentProofStore(deps.Store)
+	pluginservices.RegisterUsageMeterJob()
+	pluginservices.RegisterPricingHooks()
+	pluginservices.RegisterRefundSubscribers()
+	pluginservices.RegisterReconcileJob()
+	return nil
+}
 
 func (p *Plugin) RegisterMiddleware() []plugins.MiddlewareDescriptor { return nil }
 
@@ -28,7 +47,15 @@ func (p *Plugin) RegisterRoutes(router *gin.Engine, admin *gin.RouterGroup, api
 		// Wallet & Transactions
 		billing.GET("/balance/:customer_id", pluginhandlers.AdminGetCustomerBalance)
 		billing.GET("/transactions", pluginhandlers.AdminGetAllTransactions)
-		billing.POST("/adjust", pluginhandlers.AdminAdjustBalance)
+		billing.POST("/adjust", idempotency.Middleware(), pluginhandlers.AdminAdjustBalance)
+
+		// Ledger
+		billing.GET("/ledger/accounts", pluginhandlers.AdminListLedgerAccounts)
+		billing.GET("/ledger/accounts/:id", pluginhandlers.AdminGetLedgerAccount)
+		billing.GET("/ledger/accounts/:id/postings", pluginhandlers.AdminListLedgerPostings)
+		billing.GET("/ledger/entries", pluginhandlers.AdminListJournalEntries)
+		billing.GET("/ledger/entries/:id", pluginhandlers.AdminGetJournalEntry)
+		billing.GET("/ledger/trial-balance", pluginhandlers.AdminTrialBalance)
 
 		// Topup Management
 		billing.GET("/topups", pluginhandlers.AdminListTopups)
@@ -37,8 +64,46 @@ func (p *Plugin) RegisterRoutes(router *gin.Engine, admin *gin.RouterGroup, api
 		billing.POST("/topups/:id/confirm", pluginhandlers.AdminConfirmTopup)
 		billing.DELETE("/topups/:id", pluginhandlers.AdminCancelTopup)
 
+		// Topup risk controls
+		billing.GET("/risk-rules", pluginhandlers.AdminListRiskRules)
+		billing.GET("/risk-rules/:id", pluginhandlers.AdminGetRiskRule)
+		billing.POST("/risk-rules", pluginhandlers.AdminCreateRiskRule)
+		billing.PUT("/risk-rules/:id", pluginhandlers.AdminUpdateRiskRule)
+		billing.DELETE("/risk-rules/:id", pluginhandlers.AdminDeleteRiskRule)
+		billing.GET("/risk-hits", pluginhandlers.AdminListRiskHits)
+		billing.GET("/topup-reviews", pluginhandlers.AdminListTopupReviews)
+		billing.POST("/topup-reviews/:id/clear", pluginhandlers.AdminClearTopupReview)
+		billing.POST("/topup-reviews/:id/reject", pluginhandlers.AdminRejectTopupReview)
+
+		// Payment proof review (manual transfers)
+		billing.GET("/payment-proofs", pluginhandlers.AdminListPaymentProofs)
+		billing.GET("/payment-proofs/:id", pluginhandlers.AdminGetPaymentProof)
+		billing.GET("/payment-proofs/:id/file", pluginhandlers.AdminDownloadPaymentProof)
+		billing.POST("/payment-proofs/:id/approve", pluginhandlers.AdminApprovePaymentProof)
+		billing.POST("/payment-proofs/:id/reject", pluginhandlers.AdminRejectPaymentProof)
+
 		// Refund
-		billing.POST("/refund", pluginhandlers.AdminRefund)
+		billing.POST("/refund", idempotency.Middleware(), pluginhandlers.AdminRefund)
+		billing.GET("/transactions/:id/refunds", pluginhandlers.AdminGetTransactionRefunds)
+
+		// Maker-checker approvals for large adjustments and refunds
+		billing.GET("/approvals", pluginhandlers.AdminListApprovals)
+		billing.GET("/approvals/:id", pluginhandlers.AdminGetApproval)
+		billing.POST("/approvals/:id/approve", pluginhandlers.AdminApproveApproval)
+		billing.POST("/approvals/:id/reject", pluginhandlers.AdminRejectApproval)
+		billing.POST("/approvals/:id/cancel", pluginhandlers.AdminCancelApproval)
+
+		// Wallet holds
+		billing.GET("/holds", pluginhandlers.AdminListHolds)
+		billing.POST("/holds/:id/release", pluginhandlers.AdminReleaseHold)
+
+		// Reports (format=json|csv|xlsx)
+		billing.GET("/reports/topups", pluginhandlers.AdminTopupVolumeReport)
+		billing.GET("/reports/revenue", pluginhandlers.AdminRevenueReport)
+		billing.GET("/reports/purchases", pluginhandlers.AdminPurchaseReport)
+		billing.GET("/reports/refunds", pluginhandlers.AdminRefundReport)
+		billing.GET("/reports/liability", pluginhandlers.AdminLiabilityReport)
+		billing.GET("/reports/top-customers", pluginhandlers.AdminTopCustomersReport)
 
 		// Payment Gateway Management
 		billing.GET("/gateways", pluginhandlers.AdminListGateways)
@@ -47,6 +112,48 @@ func (p *Plugin) RegisterRoutes(router *gin.Engine, admin *gin.RouterGroup, api
 		billing.PUT("/gateways/:id", pluginhandlers.AdminUpdateGateway)
 		billing.DELETE("/gateways/:id", pluginhandlers.AdminDeleteGateway)
 		billing.PATCH("/gateways/:id/toggle", pluginhandlers.AdminToggleGateway)
+
+		// Webhook Inbox
+		billing.GET("/webhooks", pluginhandlers.AdminListWebhooks)
+		billing.GET("/webhooks/:id", pluginhandlers.AdminGetWebhook)
+		billing.POST("/webhooks/:id/replay", pluginhandlers.AdminReplayWebhook)
+
+		// Promotions
+		billing.GET("/promotions", pluginhandlers.AdminListPromotions)
+		billing.GET("/promotions/:id", pluginhandlers.AdminGetPromotion)
+		billing.POST("/promotions", pluginhandlers.AdminCreatePromotion)
+		billing.PUT("/promotions/:id", pluginhandlers.AdminUpdatePromotion)
+		billing.DELETE("/promotions/:id", pluginhandlers.AdminDeletePromotion)
+		billing.PATCH("/promotions/:id/toggle", pluginhandlers.AdminTogglePromotion)
+		billing.GET("/promotions/:id/redemptions", pluginhandlers.AdminListPromotionRedemptions)
+
+		// Tax rules
+		billing.GET("/tax-rules", pluginhandlers.AdminListTaxRules)
+		billing.GET("/tax-rules/:id", pluginhandlers.AdminGetTaxRule)
+		billing.POST("/tax-rules", pluginhandlers.AdminCreateTaxRule)
+		billing.PUT("/tax-rules/:id", pluginhandlers.AdminUpdateTaxRule)
+		billing.DELETE("/tax-rules/:id", pluginhandlers.AdminDeleteTaxRule)
+
+		// Pricing catalog
+		billing.GET("/price-plans", pluginhandlers.AdminListPricePlans)
+		billing.GET("/price-plans/:id", pluginhandlers.AdminGetPricePlan)
+		billing.POST("/price-plans", pluginhandlers.AdminCreatePricePlan)
+		billing.PUT("/price-plans/:id", pluginhandlers.AdminUpdatePricePlan)
+		billing.DELETE("/price-plans/:id", pluginhandlers.AdminDeletePricePlan)
+		billing.PATCH("/price-plans/:id/toggle", pluginhandlers.AdminTogglePricePlan)
+		billing.GET("/price-preview", pluginhandlers.AdminPricePreview)
+
+		// Container usage
+		billing.GET("/usage", pluginhandlers.AdminListUsage)
+		billing.GET("/usage/summary/:customer_id", pluginhandlers.AdminUsageSummary)
+		billing.POST("/usage/settle/:customer_id", pluginhandlers.AdminSettleUsage)
+
+		// Invoices
+		billing.GET("/invoices", pluginhandlers.AdminListInvoices)
+		billing.GET("/invoices/:id", pluginhandlers.AdminGetInvoice)
+		billing.GET("/invoices/:id/html", pluginhandlers.AdminDownloadInvoiceHTML)
+		billing.GET("/invoices/:id/pdf", pluginhandlers.AdminDownloadInvoicePDF)
+		billing.POST("/invoices/:id/resend", pluginhandlers.AdminResendInvoice)
 	}
 
 	// ========== CUSTOMER ROUTES (/api/billing/*) ==========
@@ -54,6 +161,7 @@ func (p *Plugin) RegisterRoutes(router *gin.Engine, admin *gin.RouterGroup, api
 	{
 		// Wallet
 		customerBilling.GET("/balance", pluginhandlers.CustomerGetBalance)
+		customerBilling.GET("/holds", pluginhandlers.CustomerListHolds)
 		customerBilling.GET("/transactions", pluginhandlers.CustomerGetTransactions)
 
 		// Payment Gateways (active only)
@@ -62,17 +170,32 @@ func (p *Plugin) RegisterRoutes(router *gin.Engine, admin *gin.RouterGroup, api
 		// Topup
 		customerBilling.GET("/topup", pluginhandlers.CustomerListTopups)
 		customerBilling.GET("/topup/:id", pluginhandlers.CustomerGetTopup)
-		customerBilling.POST("/topup", pluginhandlers.CustomerCreateTopup)
+		customerBilling.POST("/topup", idempotency.Middleware(), pluginhandlers.CustomerCreateTopup)
 		customerBilling.DELETE("/topup/:id", pluginhandlers.CustomerCancelTopup)
+		customerBilling.POST("/topup/:id/proof", pluginhandlers.CustomerUploadPaymentProof)
+		customerBilling.GET("/topup/:id/proofs", pluginhandlers.CustomerListPaymentProofs)
+		customerBilling.GET("/topup/:id/proofs/:proof_id/file", pluginhandlers.CustomerDownloadPaymentProof)
+
+		// Pricing
+		customerBilling.GET("/price-preview", pluginhandlers.CustomerPricePreview)
+
+		// Container usage
+		customerBilling.GET("/usage", pluginhandlers.CustomerListUsage)
+		customerBilling.GET("/usage/summary", pluginhandlers.CustomerUsageSummary)
+
+		// Invoices
+		customerBilling.GET("/invoices", pluginhandlers.CustomerListInvoices)
+		customerBilling.GET("/invoices/:id", pluginhandlers.CustomerGetInvoice)
+		customerBilling.GET("/invoices/:id/html", pluginhandlers.CustomerDownloadInvoiceHTML)
+		customerBilling.GET("/invoices/:id/pdf", pluginhandlers.CustomerDownloadInvoicePDF)
 	}
 
 	// ========== PUBLIC WEBHOOK ROUTES (/webhooks/*) ==========
 	// No authentication required - payment gateway callbacks
 	webhooks := router.Group("/webhooks")
 	{
-		webhooks.POST("/midtrans", pluginhandlers.WebhookMidtrans)
-		webhooks.POST("/xendit", pluginhandlers.WebhookXendit)
 		webhooks.POST("/payment", pluginhandlers.WebhookGeneric) // Generic for testing
+		webhooks.POST("/:slug", pluginhandlers.WebhookGateway)   // midtrans, xendit, ...
 	}
 
 	return nil
@@ -89,5 +212,5 @@ func (p *Plugin) ConsoleCommands() []*cobra.Command {
 			cmd.Printf("hello from plugin billing\\n")
 		},
 	}
-	return []*cobra.Command{cmd}
+	return []*cobra.Command{cmd, webhookConsoleCommand(), reconcileConsoleCommand()}
 }
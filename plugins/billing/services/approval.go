@@ -0,0 +1,609 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go_framework/internal/db"
+	"go_framework/internal/money"
+	"go_framework/internal/scheduler"
+	appuuid "go_framework/internal/uuid"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrApprovalNotFound     = errors.New("approval request not found")
+	ErrApprovalNotPending   = errors.New("approval request has already been decided")
+	ErrApprovalExpired      = errors.New("approval request has expired")
+	ErrApprovalSelf         = errors.New("approval requests must be decided by a different admin")
+	ErrApprovalForbidden    = errors.New("only an active SUPERADMIN can decide approval requests")
+	ErrApprovalNotRequester = errors.New("only the requesting admin can cancel an approval request")
+	ErrApprovalNoteRequired = errors.New("a rejection note is required")
+)
+
+// Approval kinds
+const (
+	ApprovalAdjustment = "ADJUSTMENT"
+	ApprovalRefund     = "REFUND"
+)
+
+// Approval statuses
+const (
+	ApprovalPending   = "PENDING"
+	ApprovalApproved  = "APPROVED"
+	ApprovalRejected  = "REJECTED"
+	ApprovalCancelled = "CANCELLED" // withdrawn by the requester
+	ApprovalExpired   = "EXPIRED"
+)
+
+// ApprovalExpiryJob is the scheduler name of the stale-request sweep.
+const ApprovalExpiryJob = "billing.approval-expiry"
+
+// approverLevel is the admin level allowed to decide approval requests.
+const approverLevel = "SUPERADMIN"
+
+const (
+	defaultApprovalTTL           = 72 * time.Hour
+	defaultApprovalSweepInterval = 5 * time.Minute
+	approvalSweepBatch           = 200
+)
+
+// ApprovalTTL is how long a request waits for a decision before it expires:
+// BILLING_APPROVAL_TTL, default 72h.
+func ApprovalTTL() time.Duration {
+	if v := strings.TrimSpace(os.Getenv("BILLING_APPROVAL_TTL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultApprovalTTL
+}
+
+// approvalThreshold reads an amount from key above which a second admin
+// must approve. Unset, zero or invalid disables approval.
+func approvalThreshold(key string) money.Amount {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if a, err := money.Parse(v); err == nil && a.IsPositive() {
+			return a
+		}
+	}
+	return 0
+}
+
+// AdjustmentApprovalThreshold - BILLING_APPROVAL_ADJUST_THRESHOLD, compared
+// with the absolute value of an adjustment
+func AdjustmentApprovalThreshold() money.Amount {
+	return approvalThreshold("BILLING_APPROVAL_ADJUST_THRESHOLD")
+}
+
+// RefundApprovalThreshold - BILLING_APPROVAL_REFUND_THRESHOLD
+func RefundApprovalThreshold() money.Amount {
+	return approvalThreshold("BILLING_APPROVAL_REFUND_THRESHOLD")
+}
+
+// needsApproval reports whether amount is above threshold; a zero threshold
+// never requires approval.
+func needsApproval(amount, threshold money.Amount) bool {
+	return threshold.IsPositive() && amount.Abs() > threshold
+}
+
+type ApprovalService struct {
+	db *gorm.DB
+}
+
+func NewApprovalService(gdb *gorm.DB) (*ApprovalService, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &ApprovalService{db: gdb}, nil
+}
+
+func NewApprovalServiceFromDefault() (*ApprovalService, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewApprovalService(gdb)
+}
+
+// SubmitAdjustment - Adjust a balance, or queue the adjustment for approval
+// when it is above the threshold. Exactly one of the results is non-nil.
+// Usage: Admin only
+func (s *ApprovalService) SubmitAdjustment(adminID, customerID string, amount money.Amount, reason string) (*models.WalletTransaction, *models.BalanceApproval, error) {
+	if amount.IsZero() {
+		return nil, nil, ErrNegativeAmount
+	}
+	if !needsApproval(amount, AdjustmentApprovalThreshold()) {
+		var txn *models.WalletTransaction
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			t, err := postAdjustment(tx, customerID, amount, reason, adminID, nil)
+			if err != nil {
+				return err
+			}
+			txn = t
+			return writeAudit(tx, &adminID, "billing.adjustment.posted", "wallet_transaction", t.ID, map[string]interface{}{
+				"customer_id": customerID,
+				"amount":      amount,
+				"reason":      reason,
+			})
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return txn, nil, nil
+	}
+
+	var exists int64
+	if err := s.db.Table("customers").Where("id = ?", customerID).Count(&exists).Error; err != nil {
+		return nil, nil, err
+	}
+	if exists == 0 {
+		return nil, nil, ErrCustomerNotFound
+	}
+	approval := &models.BalanceApproval{
+		Kind:       ApprovalAdjustment,
+		CustomerID: customerID,
+		Amount:     amount,
+		Reason:     reason,
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return createApproval(tx, adminID, approval)
+	}); err != nil {
+		return nil, nil, err
+	}
+	return nil, approval, nil
+}
+
+// SubmitRefund - Refund a charge, or queue the refund for approval when it is
+// above the threshold. A zero amount is the remaining refundable amount, less
+// refunds already awaiting approval. Exactly one of the results is non-nil.
+// Usage: Admin only
+func (s *ApprovalService) SubmitRefund(adminID, transactionID string, amount money.Amount, reason string) (*models.WalletRefund, *models.BalanceApproval, error) {
+	var refund *models.WalletRefund
+	var approval *models.BalanceApproval
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		original, refunded, err := lockRefundable(tx, transactionID)
+		if err != nil {
+			return err
+		}
+		var pending money.Amount
+		if err := tx.Model(&models.BalanceApproval{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("original_transaction_id = ? AND status = ?", original.ID, ApprovalPending).
+			Scan(&pending).Error; err != nil {
+			return err
+		}
+		amount, err = checkRefundAmount(amount, refundRemaining(original.Amount, refunded.Add(pending)))
+		if err != nil {
+			return err
+		}
+
+		if !needsApproval(amount, RefundApprovalThreshold()) {
+			r, err := recordRefund(tx, original, amount, RefundManual, reason, &adminID, nil)
+			if err != nil {
+				return err
+			}
+			refund = r
+			return writeAudit(tx, &adminID, "billing.refund.posted", "wallet_transaction", r.RefundTransactionID, map[string]interface{}{
+				"customer_id":             original.CustomerID,
+				"original_transaction_id": original.ID,
+				"amount":                  amount,
+				"reason":                  reason,
+			})
+		}
+
+		approval = &models.BalanceApproval{
+			Kind:                  ApprovalRefund,
+			CustomerID:            original.CustomerID,
+			Amount:                amount,
+			OriginalTransactionID: &original.ID,
+			Reason:                reason,
+		}
+		return createApproval(tx, adminID, approval)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return refund, approval, nil
+}
+
+// ListApprovals - List approval requests, oldest PENDING first by default
+// Usage: Admin only
+func (s *ApprovalService) ListApprovals(filters struct {
+	Status     *string
+	Kind       *string
+	CustomerID *string
+	Limit      int
+	Offset     int
+}) ([]models.BalanceApproval, int64, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	status := ApprovalPending
+	if filters.Status != nil {
+		status = strings.ToUpper(*filters.Status)
+	}
+	query := s.db.Model(&models.BalanceApproval{}).Where("status = ?", status)
+	if filters.Kind != nil {
+		query = query.Where("kind = ?", strings.ToUpper(*filters.Kind))
+	}
+	if filters.CustomerID != nil {
+		if !appuuid.Valid(*filters.CustomerID) {
+			return []models.BalanceApproval{}, 0, nil
+		}
+		query = query.Where("customer_id = ?", *filters.CustomerID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "created_at DESC"
+	if status == ApprovalPending {
+		order = "created_at ASC"
+	}
+	var approvals []models.BalanceApproval
+	if err := query.Order(order).Limit(limit).Offset(filters.Offset).Find(&approvals).Error; err != nil {
+		return nil, 0, err
+	}
+	return approvals, total, nil
+}
+
+// GetApproval - Get an approval request
+// Usage: Admin only
+func (s *ApprovalService) GetApproval(id string) (*models.BalanceApproval, error) {
+	if !appuuid.Valid(id) {
+		return nil, ErrApprovalNotFound
+	}
+	var approval models.BalanceApproval
+	if err := s.db.Where("id = ?", id).First(&approval).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrApprovalNotFound
+		}
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// Approve - Post the adjustment or refund of a PENDING request. The approver
+// must be an active SUPERADMIN other than the requester; both admin IDs are
+// recorded in the wallet transaction metadata. If posting fails (e.g. the
+// wallet can no longer cover a debit) the request stays PENDING.
+// Usage: Admin only (SUPERADMIN)
+func (s *ApprovalService) Approve(approverID, id, note string) (*models.BalanceApproval, error) {
+	var approval models.BalanceApproval
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.lockDecidable(tx, approverID, id, &approval); err != nil {
+			return err
+		}
+
+		requester := ""
+		if approval.RequestedBy != nil {
+			requester = *approval.RequestedBy
+		}
+		extra := map[string]string{
+			"approval_id":  approval.ID,
+			"requested_by": requester,
+			"approved_by":  approverID,
+		}
+		var txnID string
+		switch approval.Kind {
+		case ApprovalAdjustment:
+			txn, err := postAdjustment(tx, approval.CustomerID, approval.Amount, approval.Reason, requester, extra)
+			if err != nil {
+				return err
+			}
+			txnID = txn.ID
+		case ApprovalRefund:
+			original, refunded, err := lockRefundable(tx, *approval.OriginalTransactionID)
+			if err != nil {
+				return err
+			}
+			amount, err := checkRefundAmount(approval.Amount, refundRemaining(original.Amount, refunded))
+			if err != nil {
+				return err
+			}
+			refund, err := recordRefund(tx, original, amount, RefundManual, approval.Reason, approval.RequestedBy, extra)
+			if err != nil {
+				return err
+			}
+			txnID = refund.RefundTransactionID
+		default:
+			return fmt.Errorf("unknown approval kind %q", approval.Kind)
+		}
+
+		if err := decideApproval(tx, &approval, ApprovalApproved, &approverID, note); err != nil {
+			return err
+		}
+		approval.WalletTransactionID = &txnID
+		if err := tx.Model(&approval).Update("wallet_transaction_id", txnID).Error; err != nil {
+			return err
+		}
+		return auditApproval(tx, &approverID, "billing.approval.approved", &approval, map[string]interface{}{
+			"wallet_transaction_id": txnID,
+			"note":                  note,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// Reject - Reject a PENDING request with a note
+// Usage: Admin only (SUPERADMIN)
+func (s *ApprovalService) Reject(approverID, id, note string) (*models.BalanceApproval, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, ErrApprovalNoteRequired
+	}
+	var approval models.BalanceApproval
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.lockDecidable(tx, approverID, id, &approval); err != nil {
+			return err
+		}
+		if err := decideApproval(tx, &approval, ApprovalRejected, &approverID, note); err != nil {
+			return err
+		}
+		return auditApproval(tx, &approverID, "billing.approval.rejected", &approval, map[string]interface{}{"note": note})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// Cancel - Withdraw a PENDING request
+// Usage: Admin only (the requester)
+func (s *ApprovalService) Cancel(adminID, id string) (*models.BalanceApproval, error) {
+	var approval models.BalanceApproval
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPendingApproval(tx, id, &approval); err != nil {
+			return err
+		}
+		if approval.RequestedBy == nil || *approval.RequestedBy != adminID {
+			return ErrApprovalNotRequester
+		}
+		if err := decideApproval(tx, &approval, ApprovalCancelled, nil, ""); err != nil {
+			return err
+		}
+		return auditApproval(tx, &adminID, "billing.approval.cancelled", &approval, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// ExpireApprovals marks PENDING requests past their expiry as EXPIRED, each
+// in its own transaction under a row lock so a decision racing the sweep
+// wins or loses cleanly.
+func (s *ApprovalService) ExpireApprovals(ctx context.Context, now time.Time, limit int) (int, error) {
+	if limit <= 0 {
+		limit = approvalSweepBatch
+	}
+	var ids []string
+	if err := s.db.Model(&models.BalanceApproval{}).
+		Where("status = ? AND expires_at <= ?", ApprovalPending, now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var approval models.BalanceApproval
+			if err := lockPendingApproval(tx, id, &approval); err != nil {
+				return err
+			}
+			if err := decideApproval(tx, &approval, ApprovalExpired, nil, ""); err != nil {
+				return err
+			}
+			return auditApproval(tx, nil, "billing.approval.expired", &approval, nil)
+		})
+		switch {
+		case err == nil:
+			expired++
+		case errors.Is(err, ErrApprovalNotPending):
+			// decided since the query
+		default:
+			log.Printf("billing: approval expiry approval=%s: %v", id, err)
+		}
+	}
+	return expired, nil
+}
+
+// RegisterApprovalExpiryJob schedules ExpireApprovals every
+// BILLING_APPROVAL_SWEEP_INTERVAL (default 5m).
+func RegisterApprovalExpiryJob() {
+	interval := defaultApprovalSweepInterval
+	if v := strings.TrimSpace(os.Getenv("BILLING_APPROVAL_SWEEP_INTERVAL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		}
+	}
+	scheduler.Register(scheduler.Job{
+		Name:     ApprovalExpiryJob,
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			svc, err := NewApprovalServiceFromDefault()
+			if err != nil {
+				return err
+			}
+			n, err := svc.ExpireApprovals(ctx, time.Now(), approvalSweepBatch)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("billing: approval sweep expired=%d", n)
+			}
+			return nil
+		},
+	})
+}
+
+// postAdjustment records an ADMIN_ADJUSTMENT. extra is merged into the
+// transaction metadata. MUST be called within a transaction context.
+func postAdjustment(tx *gorm.DB, customerID string, amount money.Amount, reason, adminID string, extra map[string]string) (*models.WalletTransaction, error) {
+	meta := map[string]string{"reason": reason, "admin_id": adminID}
+	for k, v := range extra {
+		meta[k] = v
+	}
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	var createdBy *string
+	if adminID != "" {
+		createdBy = &adminID
+	}
+	var refID, refType *string
+	if id, ok := extra["approval_id"]; ok {
+		t := "BALANCE_APPROVAL"
+		refID, refType = &id, &t
+	}
+	wallet := &WalletService{db: tx}
+	return wallet.RecordTransaction(tx, struct {
+		CustomerID       string
+		Amount           money.Amount
+		Type             string
+		ReferenceID      *string
+		ReferenceType    *string
+		Description      string
+		Metadata         string
+		CreatedByAdminID *string
+	}{
+		CustomerID:       customerID,
+		Amount:           amount,
+		Type:             "ADMIN_ADJUSTMENT",
+		ReferenceID:      refID,
+		ReferenceType:    refType,
+		Description:      fmt.Sprintf("Admin adjustment: %s", reason),
+		Metadata:         string(metadata),
+		CreatedByAdminID: createdBy,
+	})
+}
+
+// createApproval stores a PENDING request expiring after ApprovalTTL and
+// audits it.
+func createApproval(tx *gorm.DB, adminID string, approval *models.BalanceApproval) error {
+	approval.Status = ApprovalPending
+	approval.RequestedBy = &adminID
+	approval.ExpiresAt = time.Now().Add(ApprovalTTL())
+	if err := tx.Create(approval).Error; err != nil {
+		return err
+	}
+	return auditApproval(tx, &adminID, "billing.approval.requested", approval, nil)
+}
+
+func lockPendingApproval(tx *gorm.DB, id string, approval *models.BalanceApproval) error {
+	if !appuuid.Valid(id) {
+		return ErrApprovalNotFound
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(approval).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrApprovalNotFound
+		}
+		return err
+	}
+	if approval.Status != ApprovalPending {
+		return ErrApprovalNotPending
+	}
+	return nil
+}
+
+// lockDecidable locks a PENDING, unexpired request and checks approverID
+// may decide it.
+func (s *ApprovalService) lockDecidable(tx *gorm.DB, approverID, id string, approval *models.BalanceApproval) error {
+	if err := lockPendingApproval(tx, id, approval); err != nil {
+		return err
+	}
+	if !approval.ExpiresAt.After(time.Now()) {
+		return ErrApprovalExpired
+	}
+	if approval.RequestedBy != nil && *approval.RequestedBy == approverID {
+		return ErrApprovalSelf
+	}
+	var allowed int64
+	if err := tx.Table("admins").
+		Where("id = ? AND level = ? AND is_active = ?", approverID, approverLevel, true).
+		Count(&allowed).Error; err != nil {
+		return err
+	}
+	if allowed == 0 {
+		return ErrApprovalForbidden
+	}
+	return nil
+}
+
+// decideApproval moves a locked PENDING request to status.
+func decideApproval(tx *gorm.DB, approval *models.BalanceApproval, status string, reviewerID *string, note string) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      status,
+		"reviewed_by": reviewerID,
+		"reviewed_at": now,
+	}
+	if note != "" {
+		updates["review_note"] = note
+		approval.ReviewNote = &note
+	}
+	if err := tx.Model(approval).Updates(updates).Error; err != nil {
+		return err
+	}
+	approval.Status = status
+	approval.ReviewedBy = reviewerID
+	approval.ReviewedAt = &now
+	return nil
+}
+
+func auditApproval(tx *gorm.DB, adminID *string, action string, approval *models.BalanceApproval, extra map[string]interface{}) error {
+	meta := map[string]interface{}{
+		"kind":         approval.Kind,
+		"customer_id":  approval.CustomerID,
+		"amount":       approval.Amount,
+		"reason":       approval.Reason,
+		"requested_by": approval.RequestedBy,
+	}
+	if approval.OriginalTransactionID != nil {
+		meta["original_transaction_id"] = *approval.OriginalTransactionID
+	}
+	for k, v := range extra {
+		meta[k] = v
+	}
+	return writeAudit(tx, adminID, action, "balance_approval", approval.ID, meta)
+}
+
+// writeAudit appends to the shared admin_audit_logs table. adminID is nil
+// for actions taken by the system.
+func writeAudit(tx *gorm.DB, adminID *string, action, targetType, targetID string, meta map[string]interface{}) error {
+	id, err := appuuid.New()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return tx.Exec(`INSERT INTO admin_audit_logs (id, admin_id, action, target_type, target_id, meta, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, NOW())`, id, adminID, action, targetType, targetID, string(raw)).Error
+}
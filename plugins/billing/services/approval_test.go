@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"go_framework/internal/money"
+)
+
+func TestNeedsApproval(t *testing.T) {
+	threshold := money.MustParse("10000000")
+	cases := []struct {
+		amount    string
+		threshold money.Amount
+		want      bool
+	}{
+		{"10000000", threshold, false}, // at the threshold posts directly
+		{"10000000.01", threshold, true},
+		{"-10000000.01", threshold, true}, // debits compare by absolute value
+		{"-500", threshold, false},
+		{"999999999", 0, false}, // disabled
+	}
+	for _, tc := range cases {
+		if got := needsApproval(money.MustParse(tc.amount), tc.threshold); got != tc.want {
+			t.Errorf("needsApproval(%s, %s) = %v, want %v", tc.amount, tc.threshold, got, tc.want)
+		}
+	}
+}
+
+func TestApprovalThreshold(t *testing.T) {
+	for raw, want := range map[string]money.Amount{
+		"":           0,
+		"0":          0,
+		"-5":         0,
+		"abc":        0,
+		" 2500000 ":  money.MustParse("2500000"),
+		"1000000.50": money.MustParse("1000000.50"),
+	} {
+		t.Setenv("BILLING_APPROVAL_ADJUST_THRESHOLD", raw)
+		if got := AdjustmentApprovalThreshold(); got != want {
+			t.Errorf("threshold %q = %s, want %s", raw, got, want)
+		}
+	}
+
+	t.Setenv("BILLING_APPROVAL_TTL", "")
+	if ApprovalTTL() != defaultApprovalTTL {
+		t.Errorf("default TTL = %s", ApprovalTTL())
+	}
+	t.Setenv("BILLING_APPROVAL_TTL", "24h")
+	if ApprovalTTL().Hours() != 24 {
+		t.Errorf("TTL = %s", ApprovalTTL())
+	}
+}
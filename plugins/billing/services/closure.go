@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go_framework/internal/events"
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrBalanceRemaining = errors.New("wallet balance must be zero before closing the account")
+
+// Account closure balance policies (BILLING_CLOSURE_BALANCE_POLICY).
+const (
+	ClosurePolicyBlock   = "block"   // refuse closure while the wallet holds funds
+	ClosurePolicyForfeit = "forfeit" // debit the remaining balance as ACCOUNT_CLOSURE
+)
+
+// ClosureBalancePolicy returns the configured policy, defaulting to block.
+func ClosureBalancePolicy() string {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("BILLING_CLOSURE_BALANCE_POLICY"))) {
+	case ClosurePolicyForfeit:
+		return ClosurePolicyForfeit
+	default:
+		return ClosurePolicyBlock
+	}
+}
+
+// CheckAccountClosure vetoes closing an account that still holds funds when
+// the policy is block.
+// Usage: events.CustomerCloseCheck hook
+func (s *WalletService) CheckAccountClosure(customerID string) error {
+	if ClosureBalancePolicy() != ClosurePolicyBlock {
+		return nil
+	}
+	balance, err := s.GetBalance(customerID)
+	if err != nil {
+		return err
+	}
+	if balance > 0 {
+		return ErrBalanceRemaining
+	}
+	return nil
+}
+
+// SettleAccountClosure cancels pending topups, releases wallet holds and,
+// under the forfeit policy, debits the remaining balance so the wallet ends
+// at zero. Automatic payments are closed at the provider first so they
+// cannot be paid after the closure; if a provider cannot be reached the
+// closure fails and is retried.
+// Usage: events.CustomerClosing hook
+func (s *WalletService) SettleAccountClosure(customerID, reason string) error {
+	var pending []models.TopupRequest
+	if err := s.db.Preload("Gateway").
+		Where("customer_id = ? AND status = ? AND external_id IS NOT NULL", customerID, "PENDING").
+		Find(&pending).Error; err != nil {
+		return err
+	}
+	for i := range pending {
+		if err := cancelCharge(&pending[i]); err != nil {
+			return fmt.Errorf("cancel topup %s: %w", pending[i].ID, err)
+		}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.TopupRequest{}).
+			Where("customer_id = ? AND status = ?", customerID, "PENDING").
+			Updates(map[string]interface{}{"status": "CANCELLED", "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PromotionRedemption{}).
+			Where("customer_id = ? AND status = ?", customerID, RedemptionReserved).
+			Updates(map[string]interface{}{"status": RedemptionReleased, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		var holds []string
+		if err := tx.Model(&models.WalletHold{}).
+			Where("customer_id = ? AND status = ?", customerID, HoldActive).
+			Pluck("id", &holds).Error; err != nil {
+			return err
+		}
+		for _, id := range holds {
+			if err := s.ReleaseHold(tx, id, HoldReleased, "account closed"); err != nil && !errors.Is(err, ErrHoldNotActive) {
+				return err
+			}
+		}
+
+		var customer models.CustomerBalance
+		if err := tx.Table("customers").Where("id = ?", customerID).First(&customer).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCustomerNotFound
+			}
+			return err
+		}
+		if customer.WalletBalance <= 0 {
+			return nil
+		}
+		if ClosureBalancePolicy() != ClosurePolicyForfeit {
+			return ErrBalanceRemaining
+		}
+
+		refType := "customer"
+		_, err := s.RecordTransaction(tx, struct {
+			CustomerID       string
+			Amount           money.Amount
+			Type             string
+			ReferenceID      *string
+			ReferenceType    *string
+			Description      string
+			Metadata         string
+			CreatedByAdminID *string
+		}{
+			CustomerID:    customerID,
+			Amount:        -customer.WalletBalance,
+			Type:          "ACCOUNT_CLOSURE",
+			ReferenceID:   &customerID,
+			ReferenceType: &refType,
+			Description:   "Balance forfeited on account closure",
+			Metadata:      fmt.Sprintf(`{"reason": %q, "policy": %q}`, reason, ClosurePolicyForfeit),
+		})
+		return err
+	})
+}
+
+// RegisterClosureHooks wires the wallet into the customer closure flow.
+func RegisterClosureHooks() {
+	events.RegisterHook(events.CustomerCloseCheck, func(ctx context.Context, payload interface{}) error {
+		ev, ok := payload.(events.CustomerEvent)
+		if !ok {
+			return nil
+		}
+		svc, err := NewWalletServiceFromDefault()
+		if err != nil {
+			return err
+		}
+		return svc.CheckAccountClosure(ev.CustomerID)
+	})
+	events.RegisterHook(events.CustomerClosing, func(ctx context.Context, payload interface{}) error {
+		ev, ok := payload.(events.CustomerEvent)
+		if !ok {
+			return nil
+		}
+		svc, err := NewWalletServiceFromDefault()
+		if err != nil {
+			return err
+		}
+		return svc.SettleAccountClosure(ev.CustomerID, ev.Reason)
+	})
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeDB is a scripted database/sql driver for tests that drive service code
+// through GORM without Postgres. Every statement is recorded and answered by
+// the test's handler; transactions are recorded as BEGIN, COMMIT and
+// ROLLBACK.
+type fakeDB struct {
+	mu     sync.Mutex
+	stmts  []fakeStmt
+	handle func(fakeStmt) fakeReply
+}
+
+type fakeStmt struct {
+	SQL  string
+	Args []driver.Value
+}
+
+// fakeReply answers a statement: rows for queries, an affected count for
+// exec, or an error.
+type fakeReply struct {
+	Columns  []string
+	Rows     [][]driver.Value
+	Affected int64
+	Err      error
+}
+
+func newFakeGorm(t *testing.T, handle func(fakeStmt) fakeReply) (*gorm.DB, *fakeDB) {
+	t.Helper()
+	f := &fakeDB{handle: handle}
+	sqlDB := sql.OpenDB(f)
+	t.Cleanup(func() { sqlDB.Close() })
+	gdb, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gdb, f
+}
+
+// matching returns the recorded statements containing every fragment.
+func (f *fakeDB) matching(fragments ...string) []fakeStmt {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeStmt
+	for _, st := range f.stmts {
+		ok := true
+		for _, frag := range fragments {
+			if !strings.Contains(st.SQL, frag) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+func (f *fakeDB) run(query string, args []driver.NamedValue) fakeReply {
+	st := fakeStmt{SQL: query}
+	for _, a := range args {
+		st.Args = append(st.Args, a.Value)
+	}
+	f.mu.Lock()
+	f.stmts = append(f.stmts, st)
+	f.mu.Unlock()
+	if f.handle == nil {
+		return fakeReply{}
+	}
+	return f.handle(st)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakedb: open through the connector")
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.run("BEGIN", nil)
+	return fakeTx{db: c.db}, nil
+}
+
+// CheckNamedValue passes values through as the driver sees them after
+// driver.Valuer, so tests can assert on them.
+func (c *fakeConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if v, ok := nv.Value.(driver.Valuer); ok {
+		val, err := v.Value()
+		nv.Value = val
+		return err
+	}
+	if val, err := driver.DefaultParameterConverter.ConvertValue(nv.Value); err == nil {
+		nv.Value = val
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	r := c.db.run(query, args)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return driver.RowsAffected(r.Affected), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r := c.db.run(query, args)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return &fakeRows{columns: r.Columns, rows: r.Rows}, nil
+}
+
+type fakeTx struct{ db *fakeDB }
+
+func (t fakeTx) Commit() error   { t.db.run("COMMIT", nil); return nil }
+func (t fakeTx) Rollback() error { t.db.run("ROLLBACK", nil); return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
+
+// inserted returns the rows of an INSERT statement keyed by column name.
+func (st fakeStmt) inserted() []map[string]driver.Value {
+	open := strings.Index(st.SQL, "(")
+	end := strings.Index(st.SQL, ")")
+	if !strings.HasPrefix(st.SQL, "INSERT") || open < 0 || end < open {
+		return nil
+	}
+	var cols []string
+	for _, c := range strings.Split(st.SQL[open+1:end], ",") {
+		cols = append(cols, strings.Trim(strings.TrimSpace(c), `"`))
+	}
+	var rows []map[string]driver.Value
+	for i := 0; i+len(cols) <= len(st.Args); i += len(cols) {
+		row := make(map[string]driver.Value, len(cols))
+		for j, c := range cols {
+			row[c] = st.Args[i+j]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
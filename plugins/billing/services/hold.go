@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/internal/scheduler"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrHoldNotFound  = errors.New("wallet hold not found")
+	ErrHoldNotActive = errors.New("wallet hold is no longer active")
+)
+
+// Hold statuses
+const (
+	HoldActive   = "ACTIVE"
+	HoldCaptured = "CAPTURED"
+	HoldReleased = "RELEASED"
+	HoldExpired  = "EXPIRED"
+)
+
+// HoldExpiryJob is the scheduler name of the expired-hold sweep.
+const HoldExpiryJob = "billing.hold-expiry"
+
+const (
+	defaultHoldTTL           = 15 * time.Minute
+	defaultHoldSweepInterval = time.Minute
+	holdSweepBatch           = 200
+
+	// deploymentHoldTTL is the minimum lifetime of a setup fee hold: far
+	// beyond the node agent's deploy timeout and the capture retries.
+	deploymentHoldTTL = time.Hour
+)
+
+// HoldTTL is how long a hold reserves funds before the sweep releases it:
+// BILLING_HOLD_TTL, default 15m.
+func HoldTTL() time.Duration {
+	if v := strings.TrimSpace(os.Getenv("BILLING_HOLD_TTL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultHoldTTL
+}
+
+// WalletBalance splits a wallet into what is reserved by holds and what is
+// left to spend.
+type WalletBalance struct {
+	CustomerID string       `json:"customer_id"`
+	Balance    money.Amount `json:"balance"` // ledger balance
+	Held       money.Amount `json:"held"`
+	Available  money.Amount `json:"available"`
+}
+
+// availableBalance is the balance not reserved by holds.
+func availableBalance(balance, held money.Amount) money.Amount {
+	available := balance.Sub(held)
+	if available.IsNegative() {
+		return 0
+	}
+	return available
+}
+
+// GetBalanceSummary - Wallet balance with held and available amounts
+// Usage: Customer (own), Admin (any customer)
+func (s *WalletService) GetBalanceSummary(customerID string) (*WalletBalance, error) {
+	var customer models.CustomerBalance
+	if err := s.db.Table("customers").Where("id = ?", customerID).First(&customer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCustomerNotFound
+		}
+		return nil, err
+	}
+	return &WalletBalance{
+		CustomerID: customerID,
+		Balance:    customer.WalletBalance,
+		Held:       customer.HeldBalance,
+		Available:  availableBalance(customer.WalletBalance, customer.HeldBalance),
+	}, nil
+}
+
+// PlaceHold - Reserve funds for a pending charge. The available balance
+// drops by the amount; the wallet balance and the ledger do not move until
+// the hold is captured.
+// Usage: Internal (deploy pricing)
+// MUST be called within a transaction context
+func (s *WalletService) PlaceHold(tx *gorm.DB, input struct {
+	CustomerID    string
+	Amount        money.Amount
+	ReferenceID   *string
+	ReferenceType *string
+	Description   string
+	TTL           time.Duration // zero: HoldTTL
+}) (*models.WalletHold, error) {
+	if !input.Amount.IsPositive() {
+		return nil, ErrNegativeAmount
+	}
+	ttl := input.TTL
+	if ttl <= 0 {
+		ttl = HoldTTL()
+	}
+
+	var customer models.CustomerBalance
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Table("customers").
+		Where("id = ?", input.CustomerID).
+		First(&customer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCustomerNotFound
+		}
+		return nil, err
+	}
+	if availableBalance(customer.WalletBalance, customer.HeldBalance) < input.Amount {
+		return nil, ErrInsufficientBalance
+	}
+
+	hold := &models.WalletHold{
+		CustomerID:    input.CustomerID,
+		Amount:        input.Amount,
+		Status:        HoldActive,
+		ReferenceID:   input.ReferenceID,
+		ReferenceType: input.ReferenceType,
+		Description:   input.Description,
+		ExpiresAt:     time.Now().Add(ttl),
+	}
+	if err := tx.Create(hold).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Table("customers").Where("id = ?", input.CustomerID).
+		Update("held_balance", customer.HeldBalance.Add(input.Amount)).Error; err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// lockActiveHold locks a hold and checks it still reserves funds.
+func lockActiveHold(tx *gorm.DB, holdID string) (*models.WalletHold, error) {
+	var hold models.WalletHold
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", holdID).First(&hold).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHoldNotFound
+		}
+		return nil, err
+	}
+	if hold.Status != HoldActive {
+		return &hold, ErrHoldNotActive
+	}
+	return &hold, nil
+}
+
+// unreserve settles a locked ACTIVE hold and returns its funds to the
+// available balance.
+func unreserve(tx *gorm.DB, hold *models.WalletHold, updates map[string]interface{}) error {
+	now := time.Now()
+	updates["settled_at"] = now
+	updates["updated_at"] = now
+	if err := tx.Model(&models.WalletHold{}).Where("id = ?", hold.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	return tx.Table("customers").Where("id = ?", hold.CustomerID).
+		Update("held_balance", gorm.Expr("GREATEST(held_balance - ?, 0)", hold.Amount)).Error
+}
+
+// CaptureHold - Turn a hold into the wallet transaction it reserved funds
+// for, e.g. a PURCHASE once the deploy succeeded.
+// Usage: Internal (deploy pricing)
+// MUST be called within a transaction context
+func (s *WalletService) CaptureHold(tx *gorm.DB, holdID string, input struct {
+	Type        string
+	Description string
+	Metadata    string
+}) (*models.WalletTransaction, error) {
+	hold, err := lockActiveHold(tx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if err := unreserve(tx, hold, map[string]interface{}{"status": HoldCaptured}); err != nil {
+		return nil, err
+	}
+	txn, err := s.RecordTransaction(tx, struct {
+		CustomerID       string
+		Amount           money.Amount
+		Type             string
+		ReferenceID      *string
+		ReferenceType    *string
+		Description      string
+		Metadata         string
+		CreatedByAdminID *string
+	}{
+		CustomerID:    hold.CustomerID,
+		Amount:        -hold.Amount,
+		Type:          input.Type,
+		ReferenceID:   hold.ReferenceID,
+		ReferenceType: hold.ReferenceType,
+		Description:   input.Description,
+		Metadata:      input.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.WalletHold{}).Where("id = ?", hold.ID).Update("transaction_id", txn.ID).Error; err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
+
+// ReleaseHold - Give a hold's funds back without charging, e.g. when the
+// deploy failed. status is RELEASED or, for the sweep, EXPIRED.
+// Usage: Internal (deploy pricing, hold sweep, account closure)
+// MUST be called within a transaction context
+func (s *WalletService) ReleaseHold(tx *gorm.DB, holdID, status, reason string) error {
+	hold, err := lockActiveHold(tx, holdID)
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"status": status}
+	if reason != "" {
+		updates["release_reason"] = reason
+	}
+	return unreserve(tx, hold, updates)
+}
+
+// CancelHold - Release an active hold on request
+// Usage: Admin (stuck deploys)
+func (s *WalletService) CancelHold(holdID, reason string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.ReleaseHold(tx, holdID, HoldReleased, reason)
+	})
+}
+
+// ListHolds - List wallet holds with filters
+// Usage: Admin (any customer), Customer (own)
+func (s *WalletService) ListHolds(filters struct {
+	CustomerID *string
+	Status     *string
+	Limit      int
+	Offset     int
+}) ([]models.WalletHold, int64, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := s.db.Model(&models.WalletHold{})
+	if filters.CustomerID != nil {
+		query = query.Where("customer_id = ?", *filters.CustomerID)
+	}
+	if filters.Status != nil {
+		query = query.Where("status = ?", *filters.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	holds := []models.WalletHold{}
+	if err := query.Order("created_at DESC").Limit(limit).Offset(filters.Offset).Find(&holds).Error; err != nil {
+		return nil, 0, err
+	}
+	return holds, total, nil
+}
+
+// ExpireHolds releases ACTIVE holds past their expiry as EXPIRED. Each hold
+// is settled in its own transaction under a row lock, so a capture racing
+// the sweep wins or loses cleanly.
+func (s *WalletService) ExpireHolds(ctx context.Context, now time.Time, limit int) (int, error) {
+	if limit <= 0 {
+		limit = holdSweepBatch
+	}
+	var ids []string
+	if err := s.db.Model(&models.WalletHold{}).
+		Where("status = ? AND expires_at <= ?", HoldActive, now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			return s.ReleaseHold(tx, id, HoldExpired, "expired")
+		})
+		switch {
+		case err == nil:
+			expired++
+		case errors.Is(err, ErrHoldNotActive):
+			// captured or released since the query
+		default:
+			log.Printf("billing: hold expiry hold=%s: %v", id, err)
+		}
+	}
+	return expired, nil
+}
+
+// RegisterHoldExpiryJob schedules ExpireHolds every
+// BILLING_HOLD_SWEEP_INTERVAL (default 1m).
+func RegisterHoldExpiryJob() {
+	interval := defaultHoldSweepInterval
+	if v := strings.TrimSpace(os.Getenv("BILLING_HOLD_SWEEP_INTERVAL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		}
+	}
+	scheduler.Register(scheduler.Job{
+		Name:     HoldExpiryJob,
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			svc, err := NewWalletServiceFromDefault()
+			if err != nil {
+				return err
+			}
+			n, err := svc.ExpireHolds(ctx, time.Now(), holdSweepBatch)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("billing: hold sweep expired=%d", n)
+			}
+			return nil
+		},
+	})
+}
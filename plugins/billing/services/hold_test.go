@@ -0,0 +1,40 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"go_framework/internal/money"
+)
+
+func TestAvailableBalance(t *testing.T) {
+	cases := []struct {
+		balance, held, want money.Amount
+	}{
+		{money.FromMajor(100), 0, money.FromMajor(100)},
+		{money.FromMajor(100), money.FromMajor(30), money.FromMajor(70)},
+		{money.FromMajor(100), money.FromMajor(100), 0},
+		// held above balance only after drift; never report negative funds
+		{money.FromMajor(10), money.FromMajor(30), 0},
+	}
+	for _, c := range cases {
+		if got := availableBalance(c.balance, c.held); got != c.want {
+			t.Errorf("availableBalance(%s, %s) = %s, want %s", c.balance, c.held, got, c.want)
+		}
+	}
+}
+
+func TestHoldTTL(t *testing.T) {
+	t.Setenv("BILLING_HOLD_TTL", "")
+	if got := HoldTTL(); got != defaultHoldTTL {
+		t.Errorf("default = %v", got)
+	}
+	t.Setenv("BILLING_HOLD_TTL", "2h")
+	if got := HoldTTL(); got != 2*time.Hour {
+		t.Errorf("2h = %v", got)
+	}
+	t.Setenv("BILLING_HOLD_TTL", "-5m")
+	if got := HoldTTL(); got != defaultHoldTTL {
+		t.Errorf("negative ttl = %v", got)
+	}
+}
@@ -0,0 +1,530 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go_framework/internal/db"
+	"go_framework/internal/mail"
+	"go_framework/internal/money"
+	"go_framework/internal/pdf"
+	"go_framework/internal/storage"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvoiceNotFound = errors.New("invoice not found")
+)
+
+const (
+	invoiceHTMLTemplate   = "templates/billing/invoice.html"
+	invoiceMailTemplate   = "templates/email/invoice"
+	defaultInvoicePrefix  = "INV"
+	invoiceDeliverTimeout = 2 * time.Minute
+)
+
+var (
+	invoiceStoreMu sync.RWMutex
+	invoiceStore   storage.Store
+)
+
+// SetInvoiceStore sets where rendered receipts are kept. Without a store
+// receipts are rendered on every download.
+func SetInvoiceStore(store storage.Store) {
+	invoiceStoreMu.Lock()
+	defer invoiceStoreMu.Unlock()
+	invoiceStore = store
+}
+
+func getInvoiceStore() storage.Store {
+	invoiceStoreMu.RLock()
+	defer invoiceStoreMu.RUnlock()
+	return invoiceStore
+}
+
+// BillingTimeZone is the IANA name of the business time zone:
+// BILLING_TIMEZONE, default Asia/Jakarta.
+func BillingTimeZone() string {
+	if name := strings.TrimSpace(os.Getenv("BILLING_TIMEZONE")); name != "" {
+		return name
+	}
+	return "Asia/Jakarta"
+}
+
+// InvoiceLocation is the time zone invoice dates and yearly numbering use
+// (BillingTimeZone).
+func InvoiceLocation() *time.Location {
+	if loc, err := time.LoadLocation(BillingTimeZone()); err == nil {
+		return loc
+	}
+	// No tzdata on the host; WIB has no DST so a fixed offset is exact.
+	return time.FixedZone("WIB", 7*60*60)
+}
+
+// FormatInvoiceNumber renders e.g. INV/2026/000042.
+func FormatInvoiceNumber(prefix string, year, sequence int) string {
+	return fmt.Sprintf("%s/%d/%06d", prefix, year, sequence)
+}
+
+func invoicePrefix() string {
+	if v := strings.TrimSpace(os.Getenv("BILLING_INVOICE_PREFIX")); v != "" {
+		return v
+	}
+	return defaultInvoicePrefix
+}
+
+// issueInvoice numbers and stores the invoice for a topup that tx is marking
+// SUCCESS. The yearly counter row stays locked until tx ends, so numbers are
+// handed out in commit order and a rolled-back topup gives its number back.
+func issueInvoice(tx *gorm.DB, topup *models.TopupRequest, now time.Time) (*models.Invoice, error) {
+	var existing models.Invoice
+	err := tx.Where("topup_id = ?", topup.ID).Take(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	issuedAt := now.In(InvoiceLocation())
+	year := issuedAt.Year()
+	var sequence int
+	if err := tx.Raw(`INSERT INTO invoice_sequences (year, last_number) VALUES (?, 1)
+		ON CONFLICT (year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+		RETURNING last_number`, year).Scan(&sequence).Error; err != nil {
+		return nil, err
+	}
+
+	var customer struct {
+		Email          string
+		FullName       string
+		TaxID          *string
+		BillingAddress *string
+	}
+	if err := tx.Table("customers").Select(contactEmailColumn+", full_name, tax_id, billing_address").Where("id = ?", topup.CustomerID).Take(&customer).Error; err != nil {
+		return nil, err
+	}
+	var gatewayName string
+	if err := tx.Model(&models.PaymentGateway{}).Select("name").Where("id = ?", topup.GatewayID).Scan(&gatewayName).Error; err != nil {
+		return nil, err
+	}
+
+	var taxName *string
+	if topup.TaxRuleID != nil {
+		var name string
+		if err := tx.Model(&models.TaxRule{}).Select("name").Where("id = ?", *topup.TaxRuleID).Scan(&name).Error; err != nil {
+			return nil, err
+		}
+		if name != "" {
+			taxName = &name
+		}
+	}
+
+	customerID := topup.CustomerID
+	topupID := topup.ID
+	inv := &models.Invoice{
+		InvoiceNumber:   FormatInvoiceNumber(invoicePrefix(), year, sequence),
+		Year:            year,
+		Sequence:        sequence,
+		CustomerID:      &customerID,
+		TopupID:         &topupID,
+		Amount:          topup.Amount,
+		Fee:             topup.Fee,
+		Total:           topup.TotalPaid,
+		Currency:        "IDR",
+		CustomerName:    customer.FullName,
+		CustomerEmail:   customer.Email,
+		GatewayName:     gatewayName,
+		TaxName:         taxName,
+		TaxRate:         topup.TaxRate,
+		TaxInclusive:    topup.TaxInclusive,
+		TaxAmount:       topup.TaxAmount,
+		CustomerTaxID:   customer.TaxID,
+		CustomerAddress: customer.BillingAddress,
+		IssuedAt:        now,
+	}
+	if err := tx.Create(inv).Error; err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// deliverInvoiceAsync renders, stores and emails a freshly issued invoice
+// after its transaction has committed. Failures are logged; the receipt can
+// still be downloaded (it is rendered on demand) or resent by an admin.
+func deliverInvoiceAsync(inv *models.Invoice) {
+	if inv == nil {
+		return
+	}
+	go func() {
+		svc, err := NewInvoiceServiceFromDefault()
+		if err != nil {
+			log.Printf("billing: invoice %s: %v", inv.InvoiceNumber, err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), invoiceDeliverTimeout)
+		defer cancel()
+		if err := svc.SendInvoice(ctx, inv); err != nil {
+			log.Printf("billing: invoice %s: %v", inv.InvoiceNumber, err)
+		}
+	}()
+}
+
+type InvoiceService struct {
+	db *gorm.DB
+}
+
+func NewInvoiceService(gdb *gorm.DB) (*InvoiceService, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &InvoiceService{db: gdb}, nil
+}
+
+func NewInvoiceServiceFromDefault() (*InvoiceService, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewInvoiceService(gdb)
+}
+
+// ListInvoices - List invoices, newest first
+// Usage: Customer (own, CustomerID set), Admin (all)
+func (s *InvoiceService) ListInvoices(filters struct {
+	CustomerID *string
+	Year       *int
+	Limit      int
+	Offset     int
+}) ([]models.Invoice, int64, error) {
+	query := s.db.Model(&models.Invoice{})
+	if filters.CustomerID != nil {
+		query = query.Where("customer_id = ?", *filters.CustomerID)
+	}
+	if filters.Year != nil {
+		query = query.Where("year = ?", *filters.Year)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if filters.Limit <= 0 || filters.Limit > 100 {
+		filters.Limit = 20
+	}
+
+	var invoices []models.Invoice
+	if err := query.Order("issued_at DESC, sequence DESC").
+		Limit(filters.Limit).
+		Offset(filters.Offset).
+		Find(&invoices).Error; err != nil {
+		return nil, 0, err
+	}
+	return invoices, total, nil
+}
+
+// GetInvoice - Get one invoice
+// Usage: Admin
+func (s *InvoiceService) GetInvoice(id string) (*models.Invoice, error) {
+	var inv models.Invoice
+	if err := s.db.Where("id = ?", id).First(&inv).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// GetCustomerInvoice - Get one invoice owned by customerID
+// Usage: Customer
+func (s *InvoiceService) GetCustomerInvoice(customerID, id string) (*models.Invoice, error) {
+	var inv models.Invoice
+	if err := s.db.Where("id = ? AND customer_id = ?", id, customerID).First(&inv).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// Document returns the receipt as "html" or "pdf". The stored copy is used
+// when there is one; otherwise it is rendered and, if a store is configured,
+// saved for next time.
+func (s *InvoiceService) Document(ctx context.Context, inv *models.Invoice, format string) ([]byte, error) {
+	var keyPtr **string
+	var column string
+	switch format {
+	case "html":
+		keyPtr, column = &inv.HTMLKey, "html_key"
+	case "pdf":
+		keyPtr, column = &inv.PDFKey, "pdf_key"
+	default:
+		return nil, fmt.Errorf("unknown invoice format %q", format)
+	}
+
+	store := getInvoiceStore()
+	if store != nil && *keyPtr != nil {
+		if rc, err := store.Get(ctx, **keyPtr); err == nil {
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+		// Missing or unreadable; fall through and render again.
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if format == "html" {
+		data, err = RenderInvoiceHTML(inv)
+	} else {
+		data = RenderInvoicePDF(inv)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if store != nil {
+		key := invoiceStorageKey(inv, format)
+		if err := store.Put(ctx, key, bytes.NewReader(data)); err != nil {
+			log.Printf("billing: store invoice %s: %v", inv.InvoiceNumber, err)
+		} else if err := s.db.Model(&models.Invoice{}).Where("id = ?", inv.ID).Update(column, key).Error; err == nil {
+			*keyPtr = &key
+		}
+	}
+	return data, nil
+}
+
+// SendInvoice emails the receipt with the PDF attached and records when.
+// Usage: System (after issue), Admin (resend)
+func (s *InvoiceService) SendInvoice(ctx context.Context, inv *models.Invoice) error {
+	if inv.CustomerEmail == "" {
+		return errors.New("invoice has no customer email")
+	}
+	if _, err := s.Document(ctx, inv, "html"); err != nil {
+		return err
+	}
+	pdfData, err := s.Document(ctx, inv, "pdf")
+	if err != nil {
+		return err
+	}
+
+	m := mail.NewTemplateMailable("Receipt "+inv.InvoiceNumber, invoiceMailTemplate, invoiceView(inv)).
+		WithAttachments(mail.Attachment{
+			Filename:    InvoiceFilename(inv, "pdf"),
+			ContentType: "application/pdf",
+			Data:        pdfData,
+		})
+	mail.NewMailer().Queue(inv.CustomerEmail, m)
+
+	now := time.Now()
+	inv.EmailedAt = &now
+	return s.db.Model(&models.Invoice{}).Where("id = ?", inv.ID).Update("emailed_at", now).Error
+}
+
+func invoiceStorageKey(inv *models.Invoice, format string) string {
+	return fmt.Sprintf("invoices/%d/%s.%s", inv.Year, inv.ID, format)
+}
+
+// InvoiceFilename is the download name, e.g. INV-2026-000042.pdf.
+func InvoiceFilename(inv *models.Invoice, format string) string {
+	return strings.NewReplacer("/", "-", " ", "-").Replace(inv.InvoiceNumber) + "." + format
+}
+
+// ========== RENDERING ==========
+
+type invoiceLine struct {
+	Description string
+	Amount      string
+}
+
+// invoiceView is the data shared by the HTML receipt, the PDF and the email.
+func invoiceView(inv *models.Invoice) map[string]interface{} {
+	lines := []invoiceLine{{Description: "Wallet top-up", Amount: FormatIDR(inv.Amount)}}
+	if !inv.Fee.IsZero() {
+		desc := "Payment fee"
+		if inv.GatewayName != "" {
+			desc += " (" + inv.GatewayName + ")"
+		}
+		lines = append(lines, invoiceLine{Description: desc, Amount: FormatIDR(inv.Fee)})
+	}
+	// Exclusive tax is its own line; inclusive tax is shown under the total.
+	taxNote := ""
+	if !inv.TaxAmount.IsZero() {
+		desc := fmt.Sprintf("%s %s%%", invoiceTaxName(inv), inv.TaxRate)
+		if inv.TaxInclusive {
+			taxNote = fmt.Sprintf("Includes %s: %s", desc, FormatIDR(inv.TaxAmount))
+		} else {
+			lines = append(lines, invoiceLine{Description: desc, Amount: FormatIDR(inv.TaxAmount)})
+		}
+	}
+	return map[string]interface{}{
+		"Name":          inv.CustomerName,
+		"Email":         inv.CustomerEmail,
+		"TaxID":         deref(inv.CustomerTaxID),
+		"Address":       invoiceAddressLines(deref(inv.CustomerAddress)),
+		"TaxNote":       taxNote,
+		"InvoiceNumber": inv.InvoiceNumber,
+		"IssuedAt":      inv.IssuedAt.In(InvoiceLocation()).Format("02 Jan 2006 15:04 MST"),
+		"GatewayName":   inv.GatewayName,
+		"Lines":         lines,
+		"Total":         FormatIDR(inv.Total),
+		"SellerName":    envOr("BILLING_SELLER_NAME", "go_framework"),
+		"SellerAddress": sellerAddressLines(),
+		"SellerTaxID":   strings.TrimSpace(os.Getenv("BILLING_SELLER_TAX_ID")),
+	}
+}
+
+// RenderInvoiceHTML renders templates/billing/invoice.html.
+func RenderInvoiceHTML(inv *models.Invoice) ([]byte, error) {
+	tpl, err := template.ParseFiles(invoiceHTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, invoiceView(inv)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderInvoicePDF lays the receipt out on one A4 page.
+func RenderInvoicePDF(inv *models.Invoice) []byte {
+	v := invoiceView(inv)
+	str := func(k string) string { s, _ := v[k].(string); return s }
+
+	doc := pdf.New()
+	doc.Title = "Receipt " + inv.InvoiceNumber
+	p := doc.AddPage()
+
+	const left, right = 56.0, pdf.A4Width - 56
+	y := pdf.A4Height - 72
+
+	p.Text(left, y, 20, true, "RECEIPT")
+	p.TextRight(right, y, 10, true, "PAID")
+	y -= 30
+
+	// Seller on the left, invoice details on the right.
+	top := y
+	p.Text(left, y, 11, true, str("SellerName"))
+	for _, line := range v["SellerAddress"].([]string) {
+		y -= 14
+		p.Text(left, y, 9, false, line)
+	}
+	if id := str("SellerTaxID"); id != "" {
+		y -= 14
+		p.Text(left, y, 9, false, "NPWP: "+id)
+	}
+	p.TextRight(right, top, 9, false, "Invoice no. "+inv.InvoiceNumber)
+	p.TextRight(right, top-14, 9, false, "Issued "+str("IssuedAt"))
+	if inv.GatewayName != "" {
+		p.TextRight(right, top-28, 9, false, "Paid via "+inv.GatewayName)
+	}
+	if top-28 < y {
+		y = top - 28
+	}
+
+	y -= 36
+	p.Text(left, y, 9, true, "BILLED TO")
+	y -= 14
+	p.Text(left, y, 10, false, str("Name"))
+	y -= 14
+	p.Text(left, y, 9, false, str("Email"))
+	for _, line := range v["Address"].([]string) {
+		y -= 14
+		p.Text(left, y, 9, false, line)
+	}
+	if id := str("TaxID"); id != "" {
+		y -= 14
+		p.Text(left, y, 9, false, "NPWP: "+id)
+	}
+
+	y -= 36
+	p.FillRect(left, y-6, right-left, 20, 0.92)
+	p.Text(left+8, y, 9, true, "Description")
+	p.TextRight(right-8, y, 9, true, "Amount")
+	for _, line := range v["Lines"].([]invoiceLine) {
+		y -= 22
+		p.Text(left+8, y, 10, false, line.Description)
+		p.TextRight(right-8, y, 10, false, line.Amount)
+	}
+	y -= 12
+	p.Line(left, y, right, y, 0.5)
+	y -= 18
+	p.Text(left+8, y, 11, true, "Total paid")
+	p.TextRight(right-8, y, 11, true, str("Total"))
+	if note := str("TaxNote"); note != "" {
+		y -= 16
+		p.TextRight(right-8, y, 9, false, note)
+	}
+
+	p.Text(left, 56, 8, false, "This receipt was issued electronically and is valid without a signature.")
+	return doc.Bytes()
+}
+
+// FormatIDR formats an amount for receipts, e.g. "IDR 1,250,000.00".
+func FormatIDR(a money.Amount) string {
+	s := a.String()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i:]
+	}
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return "IDR " + sign + b.String() + frac
+}
+
+// sellerAddressLines splits BILLING_SELLER_ADDRESS on newlines, written as
+// a literal \n in env files.
+func sellerAddressLines() []string {
+	return invoiceAddressLines(strings.ReplaceAll(os.Getenv("BILLING_SELLER_ADDRESS"), `\n`, "\n"))
+}
+
+// invoiceAddressLines splits an address into its non-empty lines.
+func invoiceAddressLines(raw string) []string {
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// invoiceTaxName is the tax label, PPN unless the rule had a name.
+func invoiceTaxName(inv *models.Invoice) string {
+	if inv.TaxName != nil && *inv.TaxName != "" {
+		return *inv.TaxName
+	}
+	return "PPN"
+}
+
+func envOr(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+func TestFormatInvoiceNumber(t *testing.T) {
+	if got := FormatInvoiceNumber("INV", 2026, 42); got != "INV/2026/000042" {
+		t.Errorf("got %q", got)
+	}
+	inv := &models.Invoice{InvoiceNumber: "INV/2026/000042"}
+	if got := InvoiceFilename(inv, "pdf"); got != "INV-2026-000042.pdf" {
+		t.Errorf("filename %q", got)
+	}
+}
+
+func TestFormatIDR(t *testing.T) {
+	cases := map[string]string{
+		"0":          "IDR 0.00",
+		"950":        "IDR 950.00",
+		"1000":       "IDR 1,000.00",
+		"1250000.5":  "IDR 1,250,000.50",
+		"-100000000": "IDR -100,000,000.00",
+	}
+	for in, want := range cases {
+		if got := FormatIDR(money.MustParse(in)); got != want {
+			t.Errorf("FormatIDR(%s) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRenderInvoicePDF(t *testing.T) {
+	t.Setenv("BILLING_SELLER_NAME", "PT Contoh")
+	t.Setenv("BILLING_SELLER_ADDRESS", `Jl. Sudirman 1\nJakarta`)
+	inv := &models.Invoice{
+		InvoiceNumber: "INV/2026/000001",
+		Amount:        money.MustParse("100000"),
+		Fee:           money.MustParse("4000"),
+		Total:         money.MustParse("104000"),
+		CustomerName:  "Budi",
+		GatewayName:   "Xendit",
+		IssuedAt:      time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC),
+	}
+	out := RenderInvoicePDF(inv)
+	for _, want := range []string{"(PT Contoh)", "(Jakarta)", "(Invoice no. INV/2026/000001)", "(IDR 104,000.00)", "(Payment fee \\(Xendit\\))"} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("PDF missing %s", want)
+		}
+	}
+	// Dates are shown in Jakarta time, where it is already 07:30.
+	if !strings.Contains(string(out), "01 Jan 2026 07:30") {
+		t.Errorf("issue date not in invoice time zone")
+	}
+}
+
+func TestInvoiceViewTax(t *testing.T) {
+	taxID, address := "0123456789012345", "Jl. Thamrin 2\nJakarta"
+	inv := &models.Invoice{
+		InvoiceNumber:   "INV/2026/000002",
+		Amount:          money.MustParse("100000"),
+		Fee:             money.MustParse("4000"),
+		TaxRate:         money.Rate(1100),
+		TaxAmount:       money.MustParse("11440"),
+		Total:           money.MustParse("115440"),
+		CustomerTaxID:   &taxID,
+		CustomerAddress: &address,
+	}
+	v := invoiceView(inv)
+	lines := v["Lines"].([]invoiceLine)
+	if len(lines) != 3 || lines[2].Description != "PPN 11.00%" || lines[2].Amount != "IDR 11,440.00" {
+		t.Errorf("exclusive tax lines = %+v", lines)
+	}
+	if v["TaxNote"] != "" || v["TaxID"] != taxID || len(v["Address"].([]string)) != 2 {
+		t.Errorf("view = %+v", v)
+	}
+
+	inv.TaxInclusive = true
+	v = invoiceView(inv)
+	if lines := v["Lines"].([]invoiceLine); len(lines) != 2 {
+		t.Errorf("inclusive tax should not add a line: %+v", lines)
+	}
+	if v["TaxNote"] != "Includes PPN 11.00%: IDR 11,440.00" {
+		t.Errorf("tax note = %q", v["TaxNote"])
+	}
+}
@@ -0,0 +1,437 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"go_framework/internal/db"
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrLedgerAccountNotFound = errors.New("ledger account not found")
+	ErrJournalEntryNotFound  = errors.New("journal entry not found")
+	ErrUnbalancedEntry       = errors.New("journal entry is not balanced")
+)
+
+// Ledger account types
+const (
+	AccountAsset     = "ASSET"
+	AccountLiability = "LIABILITY"
+	AccountEquity    = "EQUITY"
+	AccountRevenue   = "REVENUE"
+	AccountExpense   = "EXPENSE"
+)
+
+// Platform accounts. Customer wallets and gateway clearing accounts are
+// keyed per customer / gateway, see customerWalletAccount and
+// gatewayClearingAccount.
+var (
+	accountFeeRevenue     = ledgerAccountSpec{Code: "revenue:fees", Name: "Topup fee revenue", Type: AccountRevenue}
+	accountServiceRevenue = ledgerAccountSpec{Code: "revenue:services", Name: "Container revenue", Type: AccountRevenue}
+	accountRefunds        = ledgerAccountSpec{Code: "revenue:refunds", Name: "Refunds (contra revenue)", Type: AccountRevenue}
+	accountForfeited      = ledgerAccountSpec{Code: "revenue:forfeited", Name: "Forfeited balances", Type: AccountRevenue}
+	accountPromoExpense   = ledgerAccountSpec{Code: "expense:promotions", Name: "Promotion bonuses", Type: AccountExpense}
+	accountAdjustments    = ledgerAccountSpec{Code: "expense:adjustments", Name: "Manual balance adjustments", Type: AccountExpense}
+	accountOpening        = ledgerAccountSpec{Code: "equity:opening_balances", Name: "Opening wallet balances", Type: AccountEquity}
+	accountTaxPayable     = ledgerAccountSpec{Code: "liability:tax-payable", Name: "Tax payable (PPN)", Type: AccountLiability}
+)
+
+type ledgerAccountSpec struct {
+	Code       string
+	Name       string
+	Type       string
+	CustomerID *string
+	GatewayID  *string
+}
+
+// ledgerLeg is one posting before its account is resolved.
+type ledgerLeg struct {
+	Account ledgerAccountSpec
+	Amount  money.Amount // debit positive, credit negative
+}
+
+func customerWalletAccount(customerID string) ledgerAccountSpec {
+	return ledgerAccountSpec{
+		Code:       "customer_wallet:" + customerID,
+		Name:       "Customer wallet " + customerID,
+		Type:       AccountLiability,
+		CustomerID: &customerID,
+	}
+}
+
+func gatewayClearingAccount(gateway *models.PaymentGateway) ledgerAccountSpec {
+	id := gateway.ID
+	return ledgerAccountSpec{
+		Code:      "gateway_clearing:" + gateway.Slug,
+		Name:      "Gateway clearing - " + gateway.Name,
+		Type:      AccountAsset,
+		GatewayID: &id,
+	}
+}
+
+// IsDebitNormal reports whether an account type's balance grows with debits.
+func IsDebitNormal(accountType string) bool {
+	return accountType == AccountAsset || accountType == AccountExpense
+}
+
+// NormalBalance turns a sum of postings (debits positive) into the balance
+// on the account's normal side, e.g. a customer wallet's credit balance
+// comes out positive.
+func NormalBalance(accountType string, sum money.Amount) money.Amount {
+	if IsDebitNormal(accountType) {
+		return sum
+	}
+	return -sum
+}
+
+// walletEntryLegs returns the postings for a wallet transaction of the
+// given type and signed amount. The customer's wallet leg is the mirror of
+// the amount (a credit to the wallet is a credit to the liability); the
+// other legs depend on where the money came from or went. A TOPUP needs its
+// topup request: the gateway clears the total paid, of which the tax is
+// owed to the tax office and the fee is revenue. Exclusive tax was charged on
+// top; inclusive tax is carved out of what the customer paid, so the fee
+// revenue is net of it (the wallet is still credited the full amount).
+func walletEntryLegs(customerID, txnType string, amount money.Amount, topup *models.TopupRequest) ([]ledgerLeg, error) {
+	wallet := ledgerLeg{Account: customerWalletAccount(customerID), Amount: -amount}
+
+	var contra ledgerAccountSpec
+	switch txnType {
+	case "TOPUP":
+		if topup == nil || topup.Gateway == nil {
+			return nil, errors.New("ledger: topup transaction without its topup request")
+		}
+		paid := topup.TotalPaid
+		legs := []ledgerLeg{
+			{Account: gatewayClearingAccount(topup.Gateway), Amount: paid},
+			wallet,
+		}
+		if !topup.TaxAmount.IsZero() {
+			legs = append(legs, ledgerLeg{Account: accountTaxPayable, Amount: -topup.TaxAmount})
+		}
+		if revenue := topupFeeRevenue(topup); !revenue.IsZero() {
+			legs = append(legs, ledgerLeg{Account: accountFeeRevenue, Amount: -revenue})
+		}
+		return legs, nil
+	case "BONUS":
+		contra = accountPromoExpense
+	case "PURCHASE", "RENEWAL":
+		contra = accountServiceRevenue
+	case "REFUND":
+		contra = accountRefunds
+	case "ACCOUNT_CLOSURE":
+		contra = accountForfeited
+	case "ADMIN_ADJUSTMENT":
+		contra = accountAdjustments
+	default:
+		return nil, fmt.Errorf("ledger: no posting rule for transaction type %s", txnType)
+	}
+	return []ledgerLeg{wallet, {Account: contra, Amount: amount}}, nil
+}
+
+// checkBalanced refuses entries whose postings do not sum to zero. The
+// database checks the same at commit.
+func checkBalanced(legs []ledgerLeg) error {
+	if len(legs) < 2 {
+		return ErrUnbalancedEntry
+	}
+	var sum money.Amount
+	for _, l := range legs {
+		sum = sum.Add(l.Amount)
+	}
+	if !sum.IsZero() {
+		return fmt.Errorf("%w: off by %s", ErrUnbalancedEntry, sum)
+	}
+	return nil
+}
+
+// ensureLedgerAccount returns the account for spec, creating it on first use.
+func ensureLedgerAccount(tx *gorm.DB, spec ledgerAccountSpec) (*models.LedgerAccount, bool, error) {
+	account := models.LedgerAccount{
+		Code:       spec.Code,
+		Name:       spec.Name,
+		Type:       spec.Type,
+		CustomerID: spec.CustomerID,
+		GatewayID:  spec.GatewayID,
+	}
+	res := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "code"}}, DoNothing: true}).Create(&account)
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return &account, true, nil
+	}
+	var existing models.LedgerAccount
+	if err := tx.Where("code = ?", spec.Code).Take(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+// postJournalEntry writes an entry and its postings.
+func postJournalEntry(tx *gorm.DB, entry *models.JournalEntry, legs []ledgerLeg) error {
+	if err := checkBalanced(legs); err != nil {
+		return err
+	}
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+	postings := make([]models.JournalPosting, 0, len(legs))
+	for _, l := range legs {
+		if l.Amount.IsZero() {
+			continue
+		}
+		account, _, err := ensureLedgerAccount(tx, l.Account)
+		if err != nil {
+			return err
+		}
+		postings = append(postings, models.JournalPosting{EntryID: entry.ID, AccountID: account.ID, Amount: l.Amount})
+	}
+	return tx.Create(&postings).Error
+}
+
+// openCustomerWallet creates a customer's wallet account on its first
+// mutation. A balance that predates the ledger is brought in with an
+// opening entry against equity, so the account always equals
+// customers.wallet_balance. The customer row must be locked.
+func openCustomerWallet(tx *gorm.DB, customerID string, balance money.Amount) error {
+	_, created, err := ensureLedgerAccount(tx, customerWalletAccount(customerID))
+	if err != nil || !created || balance.IsZero() {
+		return err
+	}
+	referenceType := "customer"
+	return postJournalEntry(tx, &models.JournalEntry{
+		Description:   "Opening wallet balance",
+		ReferenceID:   &customerID,
+		ReferenceType: &referenceType,
+	}, []ledgerLeg{
+		{Account: accountOpening, Amount: balance},
+		{Account: customerWalletAccount(customerID), Amount: -balance},
+	})
+}
+
+// postWalletTransaction journals a wallet transaction that RecordTransaction
+// has just written, in the same database transaction.
+func postWalletTransaction(tx *gorm.DB, txn *models.WalletTransaction) error {
+	var topup *models.TopupRequest
+	if txn.Type == "TOPUP" && txn.ReferenceType != nil && *txn.ReferenceType == "topup_request" && txn.ReferenceID != nil {
+		var t models.TopupRequest
+		if err := tx.Preload("Gateway").Where("id = ?", *txn.ReferenceID).Take(&t).Error; err != nil {
+			return err
+		}
+		topup = &t
+	}
+
+	legs, err := walletEntryLegs(txn.CustomerID, txn.Type, txn.Amount, topup)
+	if err != nil {
+		return err
+	}
+	return postJournalEntry(tx, &models.JournalEntry{
+		Description:         txn.Description,
+		ReferenceID:         txn.ReferenceID,
+		ReferenceType:       txn.ReferenceType,
+		WalletTransactionID: &txn.ID,
+		CreatedByAdminID:    txn.CreatedByAdminID,
+	}, legs)
+}
+
+// topupFeeRevenue is what a paid topup earns: the total paid less the
+// wallet credit and the tax owed. That is the fee under exclusive tax and
+// the fee less the tax under inclusive tax, since the wallet is credited the
+// full amount. The revenue report sums the same expression.
+func topupFeeRevenue(topup *models.TopupRequest) money.Amount {
+	return topup.TotalPaid - topup.Amount - topup.TaxAmount
+}
+
+// LedgerAccountBalance is an account with its balance on the normal side.
+type LedgerAccountBalance struct {
+	models.LedgerAccount
+	Debits  money.Amount `json:"debits"`
+	Credits money.Amount `json:"credits"`
+	Balance money.Amount `json:"balance"`
+}
+
+type LedgerService struct {
+	db *gorm.DB
+}
+
+func NewLedgerService(gdb *gorm.DB) (*LedgerService, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &LedgerService{db: gdb}, nil
+}
+
+func NewLedgerServiceFromDefault() (*LedgerService, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewLedgerService(gdb)
+}
+
+func (s *LedgerService) balances(query *gorm.DB) ([]LedgerAccountBalance, error) {
+	var rows []struct {
+		models.LedgerAccount
+		Debits  money.Amount
+		Credits money.Amount
+	}
+	if err := query.
+		Select(`ledger_accounts.*,
+			COALESCE(SUM(CASE WHEN journal_postings.amount > 0 THEN journal_postings.amount END), 0) AS debits,
+			COALESCE(-SUM(CASE WHEN journal_postings.amount < 0 THEN journal_postings.amount END), 0) AS credits`).
+		Joins("LEFT JOIN journal_postings ON journal_postings.account_id = ledger_accounts.id").
+		Group("ledger_accounts.id").
+		Order("ledger_accounts.code ASC").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]LedgerAccountBalance, len(rows))
+	for i, r := range rows {
+		out[i] = LedgerAccountBalance{
+			LedgerAccount: r.LedgerAccount,
+			Debits:        r.Debits,
+			Credits:       r.Credits,
+			Balance:       NormalBalance(r.Type, r.Debits-r.Credits),
+		}
+	}
+	return out, nil
+}
+
+// ListAccounts - Chart of accounts with balances
+// Usage: Admin (finance)
+// Filters: Type, IncludeCustomers (customer wallets are left out by default)
+func (s *LedgerService) ListAccounts(filters struct {
+	Type             *string
+	IncludeCustomers bool
+}) ([]LedgerAccountBalance, error) {
+	query := s.db.Model(&models.LedgerAccount{})
+	if filters.Type != nil {
+		query = query.Where("ledger_accounts.type = ?", *filters.Type)
+	}
+	if !filters.IncludeCustomers {
+		query = query.Where("ledger_accounts.code NOT LIKE ?", "customer_wallet:%")
+	}
+	return s.balances(query)
+}
+
+// GetAccount - Account with its balance
+// Usage: Admin (finance)
+func (s *LedgerService) GetAccount(id string) (*LedgerAccountBalance, error) {
+	accounts, err := s.balances(s.db.Model(&models.LedgerAccount{}).Where("ledger_accounts.id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, ErrLedgerAccountNotFound
+	}
+	return &accounts[0], nil
+}
+
+// CustomerLiability returns the balance of a customer's wallet account as
+// derived from the journal, and whether the account exists yet.
+func (s *LedgerService) CustomerLiability(customerID string) (money.Amount, bool, error) {
+	accounts, err := s.balances(s.db.Model(&models.LedgerAccount{}).Where("ledger_accounts.code = ?", customerWalletAccount(customerID).Code))
+	if err != nil || len(accounts) == 0 {
+		return 0, false, err
+	}
+	return accounts[0].Balance, true, nil
+}
+
+// TrialBalance - Every account's balance; debits and credits totals are
+// equal when the books are consistent
+// Usage: Admin (finance)
+func (s *LedgerService) TrialBalance() ([]LedgerAccountBalance, money.Amount, money.Amount, error) {
+	accounts, err := s.balances(s.db.Model(&models.LedgerAccount{}))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var debits, credits money.Amount
+	for _, a := range accounts {
+		debits += a.Debits
+		credits += a.Credits
+	}
+	return accounts, debits, credits, nil
+}
+
+// ListPostings - An account's postings with their entries, newest first
+// Usage: Admin (finance)
+func (s *LedgerService) ListPostings(accountID string, limit, offset int) ([]models.JournalPosting, int64, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	query := s.db.Model(&models.JournalPosting{}).Where("account_id = ?", accountID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var postings []models.JournalPosting
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&postings).Error; err != nil {
+		return nil, 0, err
+	}
+	return postings, total, nil
+}
+
+// ListEntries - Journal entries with their postings
+// Usage: Admin (finance)
+func (s *LedgerService) ListEntries(filters struct {
+	ReferenceID *string
+	StartDate   *string
+	EndDate     *string
+	Limit       int
+	Offset      int
+}) ([]models.JournalEntry, int64, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	query := s.db.Model(&models.JournalEntry{})
+	if filters.ReferenceID != nil {
+		query = query.Where("reference_id = ?", *filters.ReferenceID)
+	}
+	if filters.StartDate != nil {
+		query = query.Where("created_at >= ?", *filters.StartDate)
+	}
+	if filters.EndDate != nil {
+		query = query.Where("created_at <= ?", *filters.EndDate)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.JournalEntry
+	if err := query.Preload("Postings.Account").Order("created_at DESC").Limit(limit).Offset(filters.Offset).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// GetEntry - Journal entry with its postings
+// Usage: Admin (finance)
+func (s *LedgerService) GetEntry(id string) (*models.JournalEntry, error) {
+	var entry models.JournalEntry
+	if err := s.db.Preload("Postings.Account").Where("id = ?", id).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJournalEntryNotFound
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
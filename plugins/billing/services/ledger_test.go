@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+func legFor(legs []ledgerLeg, code string) money.Amount {
+	for _, l := range legs {
+		if l.Account.Code == code {
+			return l.Amount
+		}
+	}
+	return 0
+}
+
+func TestWalletEntryLegsTopup(t *testing.T) {
+	topup := &models.TopupRequest{
+		Amount:    money.FromMajor(100000),
+		Fee:       money.FromMajor(2500),
+		TotalPaid: money.FromMajor(102500),
+		Gateway:   &models.PaymentGateway{ID: "gw", Slug: "midtrans", Name: "Midtrans"},
+	}
+	legs, err := walletEntryLegs("cust", "TOPUP", topup.Amount, topup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkBalanced(legs); err != nil {
+		t.Fatal(err)
+	}
+	if got := legFor(legs, "gateway_clearing:midtrans"); got != money.FromMajor(102500) {
+		t.Errorf("clearing debit = %s, want the total paid", got)
+	}
+	if got := legFor(legs, "customer_wallet:cust"); got != -money.FromMajor(100000) {
+		t.Errorf("wallet credit = %s", got)
+	}
+	if got := legFor(legs, accountFeeRevenue.Code); got != -money.FromMajor(2500) {
+		t.Errorf("fee revenue = %s", got)
+	}
+
+	if _, err := walletEntryLegs("cust", "TOPUP", topup.Amount, nil); err == nil {
+		t.Error("topup without its request should not post")
+	}
+}
+
+func TestWalletEntryLegsTopupWithTax(t *testing.T) {
+	gateway := &models.PaymentGateway{ID: "gw", Slug: "midtrans", Name: "Midtrans"}
+	ppn := &models.TaxRule{ID: "r1", Name: "PPN", Rate: money.Rate(1100)}
+	amount, fee := money.FromMajor(100000), money.FromMajor(2500)
+
+	for _, inclusive := range []bool{false, true} {
+		rule := *ppn
+		rule.Inclusive = inclusive
+		tax := ComputeTax(amount+fee, &rule, false)
+		topup := &models.TopupRequest{
+			Amount:       amount,
+			Fee:          fee,
+			TotalPaid:    tax.Total(amount + fee),
+			TaxInclusive: inclusive,
+			TaxAmount:    tax.Amount,
+			Gateway:      gateway,
+		}
+		legs, err := walletEntryLegs("cust", "TOPUP", amount, topup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkBalanced(legs); err != nil {
+			t.Errorf("inclusive=%v: %v", inclusive, err)
+		}
+		if got := legFor(legs, "gateway_clearing:midtrans"); got != topup.TotalPaid {
+			t.Errorf("inclusive=%v: clearing debit = %s, want %s", inclusive, got, topup.TotalPaid)
+		}
+		if got := legFor(legs, accountTaxPayable.Code); got != -tax.Amount {
+			t.Errorf("inclusive=%v: tax payable = %s, want %s", inclusive, got, -tax.Amount)
+		}
+		if got := legFor(legs, "customer_wallet:cust"); got != -amount {
+			t.Errorf("inclusive=%v: wallet credit = %s", inclusive, got)
+		}
+		wantRevenue := fee
+		if inclusive {
+			wantRevenue = fee - tax.Amount
+		}
+		if got := legFor(legs, accountFeeRevenue.Code); got != -wantRevenue {
+			t.Errorf("inclusive=%v: fee revenue = %s, want %s", inclusive, got, -wantRevenue)
+		}
+	}
+}
+
+func TestWalletEntryLegsInclusiveTaxAboveFee(t *testing.T) {
+	// A manual transfer has no fee, so the inclusive tax comes out of fee
+	// revenue and the ledger and the revenue report both show it negative.
+	gateway := &models.PaymentGateway{ID: "gw", Slug: "manual", Name: "Bank transfer"}
+	rule := &models.TaxRule{ID: "r1", Name: "PPN", Rate: money.Rate(1100), Inclusive: true}
+	amount := money.FromMajor(100000)
+	tax := ComputeTax(amount, rule, false)
+	topup := &models.TopupRequest{
+		Amount:       amount,
+		TotalPaid:    tax.Total(amount),
+		TaxInclusive: true,
+		TaxAmount:    tax.Amount,
+		Gateway:      gateway,
+	}
+	if topup.TotalPaid != amount {
+		t.Fatalf("inclusive total paid = %s, want %s", topup.TotalPaid, amount)
+	}
+
+	legs, err := walletEntryLegs("cust", "TOPUP", amount, topup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkBalanced(legs); err != nil {
+		t.Fatal(err)
+	}
+	revenue := topupFeeRevenue(topup)
+	if revenue != -tax.Amount {
+		t.Fatalf("fee revenue = %s, want %s", revenue, -tax.Amount)
+	}
+	if got := legFor(legs, accountFeeRevenue.Code); got != -revenue {
+		t.Errorf("fee revenue leg = %s, want %s", got, -revenue)
+	}
+	if got := legFor(legs, "customer_wallet:cust"); got != -amount {
+		t.Errorf("wallet credit = %s, want %s", got, -amount)
+	}
+}
+
+func TestWalletEntryLegsBalanceForEveryType(t *testing.T) {
+	cases := []struct {
+		txnType string
+		amount  money.Amount
+		contra  string
+	}{
+		{"BONUS", money.FromMajor(50000), accountPromoExpense.Code},
+		{"PURCHASE", -money.FromMajor(1200), accountServiceRevenue.Code},
+		{"RENEWAL", -money.FromMajor(300), accountServiceRevenue.Code},
+		{"REFUND", money.FromMajor(1200), accountRefunds.Code},
+		{"ACCOUNT_CLOSURE", -money.FromMajor(7000), accountForfeited.Code},
+		{"ADMIN_ADJUSTMENT", money.FromMajor(10), accountAdjustments.Code},
+		{"ADMIN_ADJUSTMENT", -money.FromMajor(10), accountAdjustments.Code},
+	}
+	for _, c := range cases {
+		legs, err := walletEntryLegs("cust", c.txnType, c.amount, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", c.txnType, err)
+		}
+		if err := checkBalanced(legs); err != nil {
+			t.Errorf("%s: %v", c.txnType, err)
+		}
+		// The customer's liability moves with the wallet.
+		if got := NormalBalance(AccountLiability, legFor(legs, "customer_wallet:cust")); got != c.amount {
+			t.Errorf("%s: wallet moved %s, want %s", c.txnType, got, c.amount)
+		}
+		if legFor(legs, c.contra) != c.amount {
+			t.Errorf("%s: contra %s = %s", c.txnType, c.contra, legFor(legs, c.contra))
+		}
+	}
+
+	if _, err := walletEntryLegs("cust", "MYSTERY", money.FromMajor(1), nil); err == nil {
+		t.Error("unknown type should not post")
+	}
+}
+
+func TestCheckBalanced(t *testing.T) {
+	if err := checkBalanced([]ledgerLeg{{Amount: money.FromMajor(5)}}); !errors.Is(err, ErrUnbalancedEntry) {
+		t.Errorf("single leg: err = %v", err)
+	}
+	if err := checkBalanced([]ledgerLeg{{Amount: money.FromMajor(5)}, {Amount: -money.FromMajor(4)}}); !errors.Is(err, ErrUnbalancedEntry) {
+		t.Errorf("off by one: err = %v", err)
+	}
+}
+
+func TestNormalBalance(t *testing.T) {
+	if NormalBalance(AccountAsset, money.FromMajor(3)) != money.FromMajor(3) {
+		t.Error("assets are debit-normal")
+	}
+	if NormalBalance(AccountRevenue, -money.FromMajor(3)) != money.FromMajor(3) {
+		t.Error("revenue is credit-normal")
+	}
+}
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go_framework/internal/mail"
+	"go_framework/internal/money"
+	"go_framework/internal/storage"
+	appuuid "go_framework/internal/uuid"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrProofNotFound       = errors.New("payment proof not found")
+	ErrProofNotPending     = errors.New("payment proof has already been reviewed")
+	ErrProofTooLarge       = errors.New("payment proof is too large")
+	ErrProofType           = errors.New("payment proof must be a JPEG, PNG or WebP image or a PDF")
+	ErrProofNotAccepted    = errors.New("payment proofs can only be attached to pending manual transfer topups")
+	ErrTooManyProofs       = errors.New("too many payment proofs for this topup")
+	ErrProofStoreMissing   = errors.New("payment proof storage is not configured")
+	ErrProofReasonRequired = errors.New("a rejection reason is required")
+)
+
+// Payment proof statuses
+const (
+	ProofPending  = "PENDING"
+	ProofApproved = "APPROVED"
+	ProofRejected = "REJECTED"
+	ProofReplaced = "REPLACED" // superseded by a newer upload before review
+)
+
+const (
+	defaultProofMaxBytes = 5 << 20
+	maxProofsPerTopup    = 10
+	maxProofFilenameLen  = 255
+)
+
+// proofTypes are the sniffed content types accepted, with the extension
+// their stored object gets.
+var proofTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+var (
+	proofStoreMu sync.RWMutex
+	proofStore   storage.Store
+)
+
+// SetPaymentProofStore sets where uploaded payment proofs are kept. Without a
+// store uploads are refused.
+func SetPaymentProofStore(store storage.Store) {
+	proofStoreMu.Lock()
+	defer proofStoreMu.Unlock()
+	proofStore = store
+}
+
+func getPaymentProofStore() storage.Store {
+	proofStoreMu.RLock()
+	defer proofStoreMu.RUnlock()
+	return proofStore
+}
+
+// PaymentProofMaxBytes is the largest accepted upload:
+// BILLING_PAYMENT_PROOF_MAX_BYTES, default 5 MiB.
+func PaymentProofMaxBytes() int64 {
+	if v := strings.TrimSpace(os.Getenv("BILLING_PAYMENT_PROOF_MAX_BYTES")); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultProofMaxBytes
+}
+
+// readPaymentProof reads at most max bytes of an upload and sniffs its type
+// from the content; the client's declared type is ignored.
+func readPaymentProof(r io.Reader, max int64) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > max {
+		return nil, "", ErrProofTooLarge
+	}
+	if len(data) == 0 {
+		return nil, "", ErrProofType
+	}
+	contentType := http.DetectContentType(data)
+	if _, ok := proofTypes[contentType]; !ok {
+		return nil, "", ErrProofType
+	}
+	return data, contentType, nil
+}
+
+// proofFilename keeps the base name of an uploaded file for display.
+func proofFilename(name string) string {
+	name = strings.ToValidUTF8(filepath.Base(strings.ReplaceAll(name, `\`, "/")), "")
+	name = strings.TrimSpace(name)
+	if name == "." || name == "/" || name == "" {
+		return "proof"
+	}
+	for len(name) > maxProofFilenameLen {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// UploadPaymentProof - Attach a proof of transfer to a PENDING manual topup.
+// A proof still awaiting review is replaced by the new one.
+// Usage: Customer (topup account, checked by the caller)
+func (s *TopupService) UploadPaymentProof(ctx context.Context, topupID, uploadedBy, filename string, r io.Reader) (*models.PaymentProof, error) {
+	store := getPaymentProofStore()
+	if store == nil {
+		return nil, ErrProofStoreMissing
+	}
+	topup, err := s.GetTopupDetail(topupID)
+	if err != nil {
+		return nil, err
+	}
+	if topup.Status != "PENDING" || topup.Gateway == nil || isAutomatic(topup.Gateway) {
+		return nil, ErrProofNotAccepted
+	}
+
+	data, contentType, err := readPaymentProof(r, PaymentProofMaxBytes())
+	if err != nil {
+		return nil, err
+	}
+	id, err := appuuid.New()
+	if err != nil {
+		return nil, err
+	}
+	proof := &models.PaymentProof{
+		ID:          id,
+		TopupID:     topup.ID,
+		CustomerID:  topup.CustomerID,
+		StorageKey:  fmt.Sprintf("payment-proofs/%s/%s%s", topup.ID, id, proofTypes[contentType]),
+		Filename:    proofFilename(filename),
+		ContentType: contentType,
+		SizeBytes:   int64(len(data)),
+		Status:      ProofPending,
+	}
+	if uploadedBy != "" {
+		proof.UploadedBy = &uploadedBy
+	}
+
+	if err := store.Put(ctx, proof.StorageKey, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var locked models.TopupRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", topup.ID).First(&locked).Error; err != nil {
+			return err
+		}
+		if locked.Status != "PENDING" {
+			return ErrProofNotAccepted
+		}
+		var count int64
+		if err := tx.Model(&models.PaymentProof{}).Where("topup_id = ?", topup.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= maxProofsPerTopup {
+			return ErrTooManyProofs
+		}
+		if err := tx.Model(&models.PaymentProof{}).
+			Where("topup_id = ? AND status = ?", topup.ID, ProofPending).
+			Updates(map[string]interface{}{"status": ProofReplaced, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		return tx.Create(proof).Error
+	})
+	if err != nil {
+		if derr := store.Delete(ctx, proof.StorageKey); derr != nil {
+			log.Printf("billing: remove payment proof %s: %v", proof.StorageKey, derr)
+		}
+		return nil, err
+	}
+	return proof, nil
+}
+
+// ListTopupPaymentProofs - Proofs attached to a topup, newest first
+// Usage: Customer (topup account), Admin
+func (s *TopupService) ListTopupPaymentProofs(topupID string) ([]models.PaymentProof, error) {
+	proofs := []models.PaymentProof{}
+	if err := s.db.Where("topup_id = ?", topupID).Order("created_at DESC").Find(&proofs).Error; err != nil {
+		return nil, err
+	}
+	return proofs, nil
+}
+
+// ListPaymentProofs - Review queue: proofs by status (default PENDING),
+// oldest first, with their topup. Pending proofs of topups that were
+// cancelled or paid otherwise are left out.
+// Usage: Admin
+func (s *TopupService) ListPaymentProofs(filters struct {
+	Status     *string
+	CustomerID *string
+	Limit      int
+	Offset     int
+}) ([]models.PaymentProof, int64, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	status := ProofPending
+	if filters.Status != nil {
+		status = strings.ToUpper(*filters.Status)
+	}
+	query := s.db.Model(&models.PaymentProof{}).Where("payment_proofs.status = ?", status)
+	if status == ProofPending {
+		query = query.Joins("JOIN topup_requests t ON t.id = payment_proofs.topup_id").
+			Where("t.status IN ?", []string{"PENDING", "EXPIRED"})
+	}
+	if filters.CustomerID != nil {
+		query = query.Where("payment_proofs.customer_id = ?", *filters.CustomerID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	proofs := []models.PaymentProof{}
+	order := "payment_proofs.created_at ASC"
+	if status != ProofPending {
+		order = "payment_proofs.created_at DESC"
+	}
+	if err := query.Preload("Topup.Gateway").Order(order).Limit(limit).Offset(filters.Offset).Find(&proofs).Error; err != nil {
+		return nil, 0, err
+	}
+	return proofs, total, nil
+}
+
+// GetPaymentProof - Get one payment proof with its topup
+// Usage: Customer (topup account), Admin
+func (s *TopupService) GetPaymentProof(id string) (*models.PaymentProof, error) {
+	var proof models.PaymentProof
+	if err := s.db.Preload("Topup.Gateway").Where("id = ?", id).First(&proof).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProofNotFound
+		}
+		return nil, err
+	}
+	return &proof, nil
+}
+
+// OpenPaymentProof - Read the uploaded file of a proof
+// Usage: Customer (topup account), Admin
+func (s *TopupService) OpenPaymentProof(ctx context.Context, proof *models.PaymentProof) (io.ReadCloser, error) {
+	store := getPaymentProofStore()
+	if store == nil {
+		return nil, ErrProofStoreMissing
+	}
+	return store.Get(ctx, proof.StorageKey)
+}
+
+// ApprovePaymentProof - Accept a proof and confirm its topup as a manual
+// transfer (see ManualConfirmation). The customer is emailed.
+// Usage: Admin only
+func (s *TopupService) ApprovePaymentProof(adminID, proofID, notes string) (*models.PaymentProof, error) {
+	var proof models.PaymentProof
+	var invoice *models.Invoice
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPendingProof(tx, proofID, &proof); err != nil {
+			return err
+		}
+		if strings.TrimSpace(notes) == "" {
+			notes = "Payment proof approved"
+		}
+		var err error
+		invoice, err = s.confirmManualTopup(tx, adminID, proof.TopupID, notes)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	deliverInvoiceAsync(invoice)
+
+	approved, err := s.GetPaymentProof(proof.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyProofReviewed(approved)
+	return approved, nil
+}
+
+// RejectPaymentProof - Reject a proof with a reason. The topup stays payable
+// so the customer can upload a correct proof; the customer is emailed.
+// Usage: Admin only
+func (s *TopupService) RejectPaymentProof(adminID, proofID, reason string) (*models.PaymentProof, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrProofReasonRequired
+	}
+	var proof models.PaymentProof
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPendingProof(tx, proofID, &proof); err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&proof).Updates(map[string]interface{}{
+			"status":           ProofRejected,
+			"reviewed_by":      adminID,
+			"reviewed_at":      now,
+			"rejection_reason": reason,
+			"updated_at":       now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rejected, err := s.GetPaymentProof(proof.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyProofReviewed(rejected)
+	return rejected, nil
+}
+
+func lockPendingProof(tx *gorm.DB, id string, proof *models.PaymentProof) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(proof).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProofNotFound
+		}
+		return err
+	}
+	if proof.Status != ProofPending {
+		return ErrProofNotPending
+	}
+	return nil
+}
+
+// notifyProofReviewed emails the topup account the outcome of a review.
+func (s *TopupService) notifyProofReviewed(proof *models.PaymentProof) {
+	var customer struct {
+		Email    string
+		FullName string
+	}
+	if err := s.db.Table("customers").Select(contactEmailColumn+", full_name").
+		Where("id = ?", proof.CustomerID).Take(&customer).Error; err != nil || customer.Email == "" {
+		return
+	}
+	var amount money.Amount
+	if proof.Topup != nil {
+		amount = proof.Topup.TotalPaid
+	}
+	data := map[string]interface{}{
+		"Name":     customer.FullName,
+		"Amount":   FormatIDR(amount),
+		"Filename": proof.Filename,
+	}
+	switch proof.Status {
+	case ProofApproved:
+		mail.QueueTemplate(customer.Email, "Your transfer has been confirmed", "templates/email/payment_proof_approved", data)
+	case ProofRejected:
+		if proof.RejectionReason != nil {
+			data["Reason"] = *proof.RejectionReason
+		}
+		mail.QueueTemplate(customer.Email, "Your payment proof was not accepted", "templates/email/payment_proof_rejected", data)
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadPaymentProof(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+	jpeg := append([]byte("\xff\xd8\xff\xe0"), make([]byte, 32)...)
+	pdf := []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n")
+
+	cases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"png", png, "image/png"},
+		{"jpeg", jpeg, "image/jpeg"},
+		{"pdf", pdf, "application/pdf"},
+	}
+	for _, tc := range cases {
+		data, ct, err := readPaymentProof(bytes.NewReader(tc.data), 1024)
+		if err != nil || ct != tc.want || !bytes.Equal(data, tc.data) {
+			t.Errorf("%s: type %q err %v", tc.name, ct, err)
+		}
+	}
+
+	if _, _, err := readPaymentProof(strings.NewReader("<html><script>alert(1)</script>"), 1024); !errors.Is(err, ErrProofType) {
+		t.Errorf("html accepted: %v", err)
+	}
+	if _, _, err := readPaymentProof(strings.NewReader(""), 1024); !errors.Is(err, ErrProofType) {
+		t.Errorf("empty accepted: %v", err)
+	}
+	if _, _, err := readPaymentProof(bytes.NewReader(png), int64(len(png))); err != nil {
+		t.Errorf("exactly max rejected: %v", err)
+	}
+	if _, _, err := readPaymentProof(bytes.NewReader(png), int64(len(png)-1)); !errors.Is(err, ErrProofTooLarge) {
+		t.Errorf("over max = %v", err)
+	}
+}
+
+func TestProofFilename(t *testing.T) {
+	cases := map[string]string{
+		"transfer.jpg":              "transfer.jpg",
+		"../../etc/passwd":          "passwd",
+		`C:\Users\me\bukti bca.pdf`: "bukti bca.pdf",
+		"  receipt.png ":            "receipt.png",
+		"":                          "proof",
+		"/":                         "proof",
+		"bad\xffname.png":           "badname.png",
+	}
+	for in, want := range cases {
+		if got := proofFilename(in); got != want {
+			t.Errorf("proofFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	long := strings.Repeat("é", 200) // 400 bytes
+	got := proofFilename(long)
+	if len(got) > maxProofFilenameLen || !strings.HasPrefix(long, got) || len(got) != 254 {
+		t.Errorf("truncated to %d bytes", len(got))
+	}
+}
@@ -0,0 +1,679 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go_framework/internal/db"
+	"go_framework/internal/events"
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPricePlanNotFound = errors.New("price plan not found")
+	ErrPricePlanLocked   = errors.New("price plan is already in effect; create a new plan instead")
+	ErrPricePlanInUse    = errors.New("price plan is pinned by containers")
+	ErrInvalidPricePlan  = errors.New("invalid price plan")
+)
+
+// Tier resources
+const (
+	TierRAM = "RAM"
+	TierCPU = "CPU"
+)
+
+// hoursPerMonth is used for the monthly estimate in quotes (365*24/12).
+const hoursPerMonth = 730
+
+// defaultPricePlan prices containers when no plan has been created yet.
+func defaultPricePlan() *models.PricePlan {
+	return &models.PricePlan{
+		Name:            "default",
+		IsActive:        true,
+		PricePerGBHour:  pricePerGBHour,
+		PricePerCPUHour: pricePerCPUHour,
+	}
+}
+
+// PriceQuote is the resolved price of a container under one plan.
+type PriceQuote struct {
+	PlanID          *string      `json:"plan_id"` // nil = built-in default rates
+	PlanName        string       `json:"plan_name"`
+	EffectiveFrom   *time.Time   `json:"effective_from,omitempty"`
+	TemplateID      string       `json:"template_id,omitempty"`
+	RegionCode      string       `json:"region_code,omitempty"`
+	RamMB           int          `json:"ram_mb"`
+	CPUPercent      int          `json:"cpu_percent"`
+	PricePerGBHour  money.Amount `json:"price_per_gb_hour"`
+	PricePerCPUHour money.Amount `json:"price_per_cpu_hour"`
+	SetupFee        money.Amount `json:"setup_fee"`
+	Hourly          money.Amount `json:"hourly"`
+	Daily           money.Amount `json:"daily"`
+	Monthly         money.Amount `json:"monthly"` // 730 hours
+}
+
+// QuotePlan resolves a plan's rates for one container: a matching tier
+// replaces the base RAM or CPU rate, then the most specific override
+// (template and region, then template, then region) replaces whatever it
+// sets.
+func QuotePlan(plan *models.PricePlan, templateID, regionCode string, ramMB, cpuPercent int) (*PriceQuote, error) {
+	if ramMB <= 0 || cpuPercent <= 0 {
+		return nil, errors.New("invalid pricing parameters")
+	}
+	q := &PriceQuote{
+		PlanName:        plan.Name,
+		TemplateID:      templateID,
+		RegionCode:      regionCode,
+		RamMB:           ramMB,
+		CPUPercent:      cpuPercent,
+		PricePerGBHour:  plan.PricePerGBHour,
+		PricePerCPUHour: plan.PricePerCPUHour,
+		SetupFee:        plan.SetupFee,
+	}
+	if plan.ID != "" {
+		id := plan.ID
+		effective := plan.EffectiveFrom
+		q.PlanID = &id
+		q.EffectiveFrom = &effective
+	}
+
+	if t := matchTier(plan.Tiers, TierRAM, ramMB); t != nil {
+		q.PricePerGBHour = t.PricePerHour
+	}
+	if t := matchTier(plan.Tiers, TierCPU, cpuPercent); t != nil {
+		q.PricePerCPUHour = t.PricePerHour
+	}
+	if o := matchOverride(plan.Overrides, templateID, regionCode); o != nil {
+		if o.PricePerGBHour != nil {
+			q.PricePerGBHour = *o.PricePerGBHour
+		}
+		if o.PricePerCPUHour != nil {
+			q.PricePerCPUHour = *o.PricePerCPUHour
+		}
+		if o.SetupFee != nil {
+			q.SetupFee = *o.SetupFee
+		}
+	}
+
+	q.Hourly = q.Price(1)
+	q.Daily = q.Price(24)
+	q.Monthly = q.Price(hoursPerMonth)
+	return q, nil
+}
+
+// Price is the cost of running the quoted container for hours. RAM is billed
+// per MB as a fraction of a GB; the total is rounded half-up once.
+func (q *PriceQuote) Price(hours int) money.Amount {
+	ramCost1024 := q.PricePerGBHour.Mul(int64(q.RamMB) * int64(hours))
+	cpuCost1024 := q.PricePerCPUHour.Mul(int64(q.CPUPercent) * int64(hours) * 1024)
+	return (ramCost1024 + cpuCost1024).MulRat(1, 1024, money.RoundHalfUp)
+}
+
+// matchTier returns the tier for units with the highest lower bound.
+func matchTier(tiers []models.PricePlanTier, resource string, units int) *models.PricePlanTier {
+	var best *models.PricePlanTier
+	for i := range tiers {
+		t := &tiers[i]
+		if t.Resource != resource || units < t.MinUnits || (t.MaxUnits != nil && units > *t.MaxUnits) {
+			continue
+		}
+		if best == nil || t.MinUnits > best.MinUnits {
+			best = t
+		}
+	}
+	return best
+}
+
+func matchOverride(overrides []models.PriceOverride, templateID, regionCode string) *models.PriceOverride {
+	var best *models.PriceOverride
+	bestScore := 0
+	for i := range overrides {
+		o := &overrides[i]
+		score := 0
+		if o.TemplateID != nil {
+			if *o.TemplateID != templateID {
+				continue
+			}
+			score += 2
+		}
+		if o.RegionCode != nil {
+			if *o.RegionCode != regionCode {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = o, score
+		}
+	}
+	return best
+}
+
+// ValidatePricePlan checks rates, tiers and overrides before saving.
+func ValidatePricePlan(plan *models.PricePlan) error {
+	if plan.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPricePlan)
+	}
+	if plan.PricePerGBHour.IsNegative() || plan.PricePerCPUHour.IsNegative() || plan.SetupFee.IsNegative() {
+		return fmt.Errorf("%w: prices cannot be negative", ErrInvalidPricePlan)
+	}
+	for _, t := range plan.Tiers {
+		if t.Resource != TierRAM && t.Resource != TierCPU {
+			return fmt.Errorf("%w: tier resource must be RAM or CPU", ErrInvalidPricePlan)
+		}
+		if t.MinUnits < 0 || (t.MaxUnits != nil && *t.MaxUnits < t.MinUnits) || t.PricePerHour.IsNegative() {
+			return fmt.Errorf("%w: bad %s tier starting at %d", ErrInvalidPricePlan, t.Resource, t.MinUnits)
+		}
+	}
+	for _, o := range plan.Overrides {
+		if o.TemplateID == nil && o.RegionCode == nil {
+			return fmt.Errorf("%w: an override needs a template_id or region_code", ErrInvalidPricePlan)
+		}
+		for _, p := range []*money.Amount{o.PricePerGBHour, o.PricePerCPUHour, o.SetupFee} {
+			if p != nil && p.IsNegative() {
+				return fmt.Errorf("%w: prices cannot be negative", ErrInvalidPricePlan)
+			}
+		}
+	}
+	return nil
+}
+
+type PricingService struct {
+	db *gorm.DB
+}
+
+func NewPricingService(gdb *gorm.DB) (*PricingService, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &PricingService{db: gdb}, nil
+}
+
+func NewPricingServiceFromDefault() (*PricingService, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewPricingService(gdb)
+}
+
+// ListPricePlans - List plans, newest effective date first
+// Usage: Admin
+func (s *PricingService) ListPricePlans() ([]models.PricePlan, error) {
+	var plans []models.PricePlan
+	if err := s.db.Preload("Tiers").Preload("Overrides").
+		Order("effective_from DESC, created_at DESC").
+		Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// GetPricePlan - Get plan with tiers and overrides
+// Usage: Admin
+func (s *PricingService) GetPricePlan(id string) (*models.PricePlan, error) {
+	return s.getPlan(s.db, id)
+}
+
+func (s *PricingService) getPlan(tx *gorm.DB, id string) (*models.PricePlan, error) {
+	var plan models.PricePlan
+	if err := tx.Preload("Tiers").Preload("Overrides").Where("id = ?", id).First(&plan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPricePlanNotFound
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// CreatePricePlan - Create plan with its tiers and overrides
+// Usage: Admin only
+func (s *PricingService) CreatePricePlan(plan *models.PricePlan) error {
+	if err := ValidatePricePlan(plan); err != nil {
+		return err
+	}
+	if err := checkEffectiveFrom(plan, time.Now()); err != nil {
+		return err
+	}
+	return s.db.Create(plan).Error
+}
+
+// checkEffectiveFrom defaults a missing date to now and refuses backdating,
+// which would reprice history.
+func checkEffectiveFrom(plan *models.PricePlan, now time.Time) error {
+	if plan.EffectiveFrom.IsZero() {
+		plan.EffectiveFrom = now
+		return nil
+	}
+	if plan.EffectiveFrom.Before(now.Add(-time.Minute)) {
+		return fmt.Errorf("%w: effective_from cannot be in the past", ErrInvalidPricePlan)
+	}
+	return nil
+}
+
+// UpdatePricePlan - Replace a plan that is not yet in effect, including its
+// tiers and overrides
+// Usage: Admin only
+func (s *PricingService) UpdatePricePlan(plan *models.PricePlan) error {
+	if err := ValidatePricePlan(plan); err != nil {
+		return err
+	}
+	if err := checkEffectiveFrom(plan, time.Now()); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.PricePlan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", plan.ID).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPricePlanNotFound
+			}
+			return err
+		}
+		if !current.EffectiveFrom.After(time.Now()) {
+			return ErrPricePlanLocked
+		}
+
+		if err := tx.Where("plan_id = ?", plan.ID).Delete(&models.PricePlanTier{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_id = ?", plan.ID).Delete(&models.PriceOverride{}).Error; err != nil {
+			return err
+		}
+		for i := range plan.Tiers {
+			plan.Tiers[i].ID = ""
+			plan.Tiers[i].PlanID = plan.ID
+		}
+		for i := range plan.Overrides {
+			plan.Overrides[i].ID = ""
+			plan.Overrides[i].PlanID = plan.ID
+		}
+		plan.CreatedAt = current.CreatedAt
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(plan).Error
+	})
+}
+
+// SetPricePlanActive - Enable/disable a plan for new deployments. Containers
+// already pinned to it keep their prices.
+// Usage: Admin only
+func (s *PricingService) SetPricePlanActive(id string, isActive bool) error {
+	res := s.db.Model(&models.PricePlan{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"is_active":  isActive,
+		"updated_at": time.Now(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPricePlanNotFound
+	}
+	return nil
+}
+
+// DeletePricePlan - Delete a plan no container is priced with
+// Usage: Admin only
+func (s *PricingService) DeletePricePlan(id string) error {
+	var count int64
+	if err := s.db.Model(&models.ContainerPrice{}).Where("plan_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrPricePlanInUse
+	}
+	res := s.db.Delete(&models.PricePlan{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPricePlanNotFound
+	}
+	return nil
+}
+
+// PlanAt returns the plan in effect at t, or the built-in default rates
+// when there is none.
+func (s *PricingService) PlanAt(tx *gorm.DB, t time.Time) (*models.PricePlan, error) {
+	var plan models.PricePlan
+	err := tx.Preload("Tiers").Preload("Overrides").
+		Where("is_active = ? AND effective_from <= ?", true, t).
+		Order("effective_from DESC, created_at DESC").
+		First(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultPricePlan(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// Quote - Price a container under the plan in effect at t
+// Usage: Customer (price preview), deploy
+func (s *PricingService) Quote(t time.Time, templateID, regionCode string, ramMB, cpuPercent int) (*PriceQuote, error) {
+	plan, err := s.PlanAt(s.db, t)
+	if err != nil {
+		return nil, err
+	}
+	return QuotePlan(plan, templateID, regionCode, ramMB, cpuPercent)
+}
+
+// TemplateDefaults returns an app template's default RAM and CPU.
+func (s *PricingService) TemplateDefaults(templateID string) (ramMB, cpuPercent int, err error) {
+	var tpl struct {
+		DefaultRamMB    int
+		DefaultCPULimit int
+	}
+	if err := s.db.Table("app_templates").
+		Select("default_ram_mb, default_cpu_limit").
+		Where("id = ?", templateID).
+		Take(&tpl).Error; err != nil {
+		return 0, 0, err
+	}
+	return tpl.DefaultRamMB, tpl.DefaultCPULimit, nil
+}
+
+// pinContainerPrice records the plan in effect at t for a container, unless
+// it already has one. It returns the pin, the container's quote under it and
+// whether the pin was created now. The setup fee is left to the caller.
+func (s *PricingService) pinContainerPrice(tx *gorm.DB, containerID, customerID, templateID, regionCode string, ramMB, cpuPercent int, t time.Time) (*models.ContainerPrice, *PriceQuote, bool, error) {
+	var pin models.ContainerPrice
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("container_id = ?", containerID).First(&pin).Error
+	if err == nil {
+		quote, qerr := s.quotePin(tx, &pin, ramMB, cpuPercent)
+		return &pin, quote, false, qerr
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, false, err
+	}
+
+	plan, err := s.PlanAt(tx, t)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	quote, err := QuotePlan(plan, templateID, regionCode, ramMB, cpuPercent)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	pin = models.ContainerPrice{
+		ContainerID: containerID,
+		CustomerID:  customerID,
+		PlanID:      quote.PlanID,
+		PricedAt:    t,
+	}
+	if templateID != "" {
+		pin.TemplateID = &templateID
+	}
+	if regionCode != "" {
+		pin.RegionCode = &regionCode
+	}
+	if err := tx.Create(&pin).Error; err != nil {
+		return nil, nil, false, err
+	}
+	return &pin, quote, true, nil
+}
+
+// quotePin prices a container under its pinned plan at its current size.
+func (s *PricingService) quotePin(tx *gorm.DB, pin *models.ContainerPrice, ramMB, cpuPercent int) (*PriceQuote, error) {
+	plan := defaultPricePlan()
+	if pin.PlanID != nil {
+		p, err := s.getPlan(tx, *pin.PlanID)
+		if err != nil {
+			return nil, err
+		}
+		plan = p
+	}
+	var templateID, regionCode string
+	if pin.TemplateID != nil {
+		templateID = *pin.TemplateID
+	}
+	if pin.RegionCode != nil {
+		regionCode = *pin.RegionCode
+	}
+	return QuotePlan(plan, templateID, regionCode, ramMB, cpuPercent)
+}
+
+// ContainerQuote prices a container with the plan it was bought under.
+// Containers deployed before any pin existed are pinned to the plan that
+// was in effect when they were created.
+// Usage: Usage meter
+func (s *PricingService) ContainerQuote(tx *gorm.DB, containerID string, ramMB, cpuPercent int) (*PriceQuote, error) {
+	var pin models.ContainerPrice
+	err := tx.Where("container_id = ?", containerID).First(&pin).Error
+	if err == nil {
+		return s.quotePin(tx, &pin, ramMB, cpuPercent)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var c struct {
+		CustomerID string
+		TemplateID *string
+		RegionCode *string
+		CreatedAt  time.Time
+	}
+	if err := tx.Table("containers").
+		Select("containers.customer_id, containers.template_id, nodes.region_code, containers.created_at").
+		Joins("LEFT JOIN nodes ON nodes.id = containers.node_id").
+		Where("containers.id = ?", containerID).
+		Take(&c).Error; err != nil {
+		return nil, err
+	}
+	var templateID, regionCode string
+	if c.TemplateID != nil {
+		templateID = *c.TemplateID
+	}
+	if c.RegionCode != nil {
+		regionCode = *c.RegionCode
+	}
+	_, quote, _, err := s.pinContainerPrice(tx, containerID, c.CustomerID, templateID, regionCode, ramMB, cpuPercent, c.CreatedAt)
+	return quote, err
+}
+
+// PriceDeployment pins a container about to be deployed to the plan in
+// effect now and puts its setup fee on hold; CaptureDeployment charges it
+// as a PURCHASE once the container runs. A redeploy keeps the original pin
+// and is not charged again. Returns ErrInsufficientBalance when the
+// available balance cannot cover the fee, which aborts the deploy.
+// Usage: events.ContainerDeploying hook
+func (s *PricingService) PriceDeployment(ev events.ContainerEvent) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		pin, quote, created, err := s.pinContainerPrice(tx, ev.ContainerID, ev.CustomerID, ev.TemplateID, ev.RegionCode, ev.RamMB, ev.CPUPercent, time.Now())
+		if err != nil {
+			return err
+		}
+		if !created || !quote.SetupFee.IsPositive() {
+			return nil
+		}
+
+		wallet := &WalletService{db: s.db}
+		referenceID := ev.ContainerID
+		referenceType := "container"
+		ttl := HoldTTL()
+		if ttl < deploymentHoldTTL {
+			ttl = deploymentHoldTTL
+		}
+		hold, err := wallet.PlaceHold(tx, struct {
+			CustomerID    string
+			Amount        money.Amount
+			ReferenceID   *string
+			ReferenceType *string
+			Description   string
+			TTL           time.Duration
+		}{
+			CustomerID:    ev.CustomerID,
+			Amount:        quote.SetupFee,
+			ReferenceID:   &referenceID,
+			ReferenceType: &referenceType,
+			Description:   fmt.Sprintf("Container setup fee (%s)", quote.PlanName),
+			TTL:           ttl,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.ContainerPrice{}).Where("container_id = ?", pin.ContainerID).Updates(map[string]interface{}{
+			"setup_fee":     quote.SetupFee,
+			"setup_hold_id": hold.ID,
+		}).Error
+	})
+}
+
+// CaptureDeployment charges the held setup fee of a container whose deploy
+// succeeded. A hold that expired while the deploy ran is charged directly
+// if the wallet still covers it; otherwise the error makes the node plugin
+// stop the container.
+// Usage: events.ContainerDeployed hook
+func (s *PricingService) CaptureDeployment(containerID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var pin models.ContainerPrice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("container_id = ?", containerID).First(&pin).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if pin.SetupHoldID == nil || pin.SetupTransactionID != nil {
+			return nil // no fee, or already charged
+		}
+
+		wallet := &WalletService{db: s.db}
+		metadata := fmt.Sprintf(`{"plan_id": "%s", "template_id": "%s", "region_code": "%s", "hold_id": "%s"}`,
+			deref(pin.PlanID), deref(pin.TemplateID), deref(pin.RegionCode), *pin.SetupHoldID)
+		txn, err := wallet.CaptureHold(tx, *pin.SetupHoldID, struct {
+			Type        string
+			Description string
+			Metadata    string
+		}{
+			Type:        "PURCHASE",
+			Description: "Container setup fee",
+			Metadata:    metadata,
+		})
+		if errors.Is(err, ErrHoldNotActive) {
+			referenceID := containerID
+			referenceType := "container"
+			txn, err = wallet.RecordTransaction(tx, struct {
+				CustomerID       string
+				Amount           money.Amount
+				Type             string
+				ReferenceID      *string
+				ReferenceType    *string
+				Description      string
+				Metadata         string
+				CreatedByAdminID *string
+			}{
+				CustomerID:    pin.CustomerID,
+				Amount:        -pin.SetupFee,
+				Type:          "PURCHASE",
+				ReferenceID:   &referenceID,
+				ReferenceType: &referenceType,
+				Description:   "Container setup fee",
+				Metadata:      metadata,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.ContainerPrice{}).Where("container_id = ?", containerID).
+			Update("setup_transaction_id", txn.ID).Error
+	})
+}
+
+// ReleaseDeployment undoes PriceDeployment for a container whose first
+// deploy failed: the setup fee hold is released and the pin removed, so the
+// next attempt is priced afresh. Containers that have run keep their pin.
+// Usage: events.ContainerDeployFailed subscriber
+func (s *PricingService) ReleaseDeployment(containerID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var pin models.ContainerPrice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("container_id = ?", containerID).First(&pin).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if pin.SetupHoldID != nil && pin.SetupTransactionID != nil {
+			return nil // the fee was captured: an earlier deploy succeeded
+		}
+
+		var ran int64
+		if err := tx.Model(&models.ContainerUsage{}).Where("container_id = ?", containerID).Count(&ran).Error; err != nil {
+			return err
+		}
+		if ran == 0 {
+			if err := tx.Model(&models.ContainerMeter{}).Where("container_id = ?", containerID).Count(&ran).Error; err != nil {
+				return err
+			}
+		}
+		if ran > 0 {
+			return nil
+		}
+
+		if pin.SetupHoldID != nil {
+			wallet := &WalletService{db: s.db}
+			if err := wallet.ReleaseHold(tx, *pin.SetupHoldID, HoldReleased, "container deploy failed"); err != nil && !errors.Is(err, ErrHoldNotActive) {
+				return err
+			}
+		} else if pin.SetupTransactionID != nil && pin.SetupFee.IsPositive() {
+			// Charged up front before setup fees were held.
+			_, err := refundTransaction(tx, *pin.SetupTransactionID, 0, RefundDeployFailed, "container deploy failed", nil)
+			if err != nil && !errors.Is(err, ErrAlreadyRefunded) {
+				return err
+			}
+		}
+		return tx.Where("container_id = ?", containerID).Delete(&models.ContainerPrice{}).Error
+	})
+}
+
+// RegisterPricingHooks prices deployments, charges the ones that succeed
+// and releases the ones that fail.
+func RegisterPricingHooks() {
+	events.RegisterHook(events.ContainerDeploying, func(ctx context.Context, payload interface{}) error {
+		ev, ok := payload.(events.ContainerEvent)
+		if !ok {
+			return nil
+		}
+		svc, err := NewPricingServiceFromDefault()
+		if err != nil {
+			return err
+		}
+		return svc.PriceDeployment(ev)
+	})
+	events.RegisterHook(events.ContainerDeployed, func(ctx context.Context, payload interface{}) error {
+		ev, ok := payload.(events.ContainerEvent)
+		if !ok {
+			return nil
+		}
+		svc, err := NewPricingServiceFromDefault()
+		if err != nil {
+			return err
+		}
+		return svc.CaptureDeployment(ev.ContainerID)
+	})
+	events.Subscribe(events.ContainerDeployFailed, func(ctx context.Context, payload interface{}) {
+		ev, ok := payload.(events.ContainerEvent)
+		if !ok {
+			return
+		}
+		svc, err := NewPricingServiceFromDefault()
+		if err != nil {
+			log.Printf("[billing] release deployment container=%s: %v", ev.ContainerID, err)
+			return
+		}
+		if err := svc.ReleaseDeployment(ev.ContainerID); err != nil {
+			log.Printf("[billing] release deployment container=%s: %v", ev.ContainerID, err)
+		}
+	})
+}
+
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
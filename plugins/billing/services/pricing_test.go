@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+func amountPtr(a money.Amount) *money.Amount { return &a }
+func strPtr(s string) *string                { return &s }
+func intPtr(n int) *int                      { return &n }
+
+func testPlan() *models.PricePlan {
+	return &models.PricePlan{
+		ID:              "plan-1",
+		Name:            "2026",
+		PricePerGBHour:  money.FromMajor(100),
+		PricePerCPUHour: money.FromMajor(10),
+		SetupFee:        money.FromMajor(5000),
+		Tiers: []models.PricePlanTier{
+			{Resource: TierRAM, MinUnits: 0, MaxUnits: intPtr(1023), PricePerHour: money.FromMajor(120)},
+			{Resource: TierRAM, MinUnits: 4096, PricePerHour: money.FromMajor(80)},
+		},
+		Overrides: []models.PriceOverride{
+			{RegionCode: strPtr("SG"), PricePerCPUHour: amountPtr(money.FromMajor(15))},
+			{TemplateID: strPtr("tpl-db"), SetupFee: amountPtr(money.FromMajor(0))},
+			{TemplateID: strPtr("tpl-db"), RegionCode: strPtr("SG"), PricePerGBHour: amountPtr(money.FromMajor(150))},
+		},
+	}
+}
+
+func TestQuotePlanTiers(t *testing.T) {
+	plan := testPlan()
+	cases := []struct {
+		ram  int
+		want money.Amount
+	}{
+		{512, money.FromMajor(120)},
+		{2048, money.FromMajor(100)},
+		{8192, money.FromMajor(80)},
+	}
+	for _, c := range cases {
+		q, err := QuotePlan(plan, "", "", c.ram, 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.PricePerGBHour != c.want {
+			t.Errorf("ram %d: price per GB hour = %s, want %s", c.ram, q.PricePerGBHour, c.want)
+		}
+	}
+}
+
+func TestQuotePlanOverridePrecedence(t *testing.T) {
+	plan := testPlan()
+
+	q, _ := QuotePlan(plan, "tpl-web", "SG", 2048, 50)
+	if q.PricePerCPUHour != money.FromMajor(15) || q.SetupFee != money.FromMajor(5000) {
+		t.Errorf("region override: cpu %s setup %s", q.PricePerCPUHour, q.SetupFee)
+	}
+
+	q, _ = QuotePlan(plan, "tpl-db", "ID", 2048, 50)
+	if !q.SetupFee.IsZero() || q.PricePerCPUHour != money.FromMajor(10) {
+		t.Errorf("template override: cpu %s setup %s", q.PricePerCPUHour, q.SetupFee)
+	}
+
+	// Template+region beats both; fields it leaves unset keep the plan rates.
+	q, _ = QuotePlan(plan, "tpl-db", "SG", 2048, 50)
+	if q.PricePerGBHour != money.FromMajor(150) || q.PricePerCPUHour != money.FromMajor(10) || q.SetupFee != money.FromMajor(5000) {
+		t.Errorf("template+region override: gb %s cpu %s setup %s", q.PricePerGBHour, q.PricePerCPUHour, q.SetupFee)
+	}
+	if q.PlanID == nil || *q.PlanID != "plan-1" {
+		t.Errorf("plan id = %v", q.PlanID)
+	}
+}
+
+func TestPriceQuotePrice(t *testing.T) {
+	q, err := QuotePlan(defaultPricePlan(), "", "", 512, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.PlanID != nil {
+		t.Errorf("default plan should have no id")
+	}
+	// 0.5 GB and 50% CPU for one hour, then a day.
+	want := pricePerGBHour.MulRat(1, 2, money.RoundHalfUp) + pricePerCPUHour.Mul(50)
+	if q.Hourly != want {
+		t.Errorf("hourly = %s, want %s", q.Hourly, want)
+	}
+	if q.Price(24) != q.Daily || q.Daily != want.Mul(24) {
+		t.Errorf("daily = %s, want %s", q.Daily, want.Mul(24))
+	}
+	if q.Price(0) != 0 {
+		t.Errorf("zero hours should be free")
+	}
+}
+
+func TestValidatePricePlan(t *testing.T) {
+	if err := ValidatePricePlan(testPlan()); err != nil {
+		t.Fatalf("valid plan rejected: %v", err)
+	}
+
+	bad := []func(p *models.PricePlan){
+		func(p *models.PricePlan) { p.Name = "" },
+		func(p *models.PricePlan) { p.SetupFee = money.FromMajor(-1) },
+		func(p *models.PricePlan) { p.Tiers[0].Resource = "DISK" },
+		func(p *models.PricePlan) { p.Tiers[0].MinUnits = 2048 },
+		func(p *models.PricePlan) { p.Overrides[0].RegionCode = nil },
+		func(p *models.PricePlan) { p.Overrides[1].SetupFee = amountPtr(money.FromMajor(-5)) },
+	}
+	for i, mutate := range bad {
+		p := testPlan()
+		mutate(p)
+		if err := ValidatePricePlan(p); !errors.Is(err, ErrInvalidPricePlan) {
+			t.Errorf("case %d: err = %v, want ErrInvalidPricePlan", i, err)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go_framework/internal/db"
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPromotionNotFound  = errors.New("promotion not found")
+	ErrInvalidPromotion   = errors.New("invalid promotion")
+	ErrPromotionInUse     = errors.New("promotion has been redeemed; deactivate it instead")
+	ErrPromoCodeInvalid   = errors.New("promo code is invalid or expired")
+	ErrPromoNotApplicable = errors.New("promo code does not apply to this topup")
+	ErrPromoLimitReached  = errors.New("promo code usage limit reached")
+)
+
+// Promotion bonus types
+const (
+	PromoBonusPercent = "PERCENT"
+	PromoBonusFixed   = "FIXED"
+)
+
+// Redemption statuses
+const (
+	RedemptionReserved = "RESERVED"
+	RedemptionApplied  = "APPLIED"
+	RedemptionReleased = "RELEASED"
+)
+
+// NormalizePromoCode makes codes case-insensitive.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// PromotionBonus is the bonus credit a topup of amount earns.
+func PromotionBonus(p *models.Promotion, amount money.Amount) money.Amount {
+	if p.BonusType == PromoBonusFixed {
+		return p.BonusAmount
+	}
+	bonus := amount.Percent(p.BonusPercent, money.RoundDown)
+	if p.MaxBonus != nil && bonus > *p.MaxBonus {
+		bonus = *p.MaxBonus
+	}
+	return bonus
+}
+
+// ValidatePromotion checks a promotion before saving.
+func ValidatePromotion(p *models.Promotion) error {
+	if p.Code == "" || strings.ContainsAny(p.Code, " \t\n") {
+		return fmt.Errorf("%w: code is required and cannot contain spaces", ErrInvalidPromotion)
+	}
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPromotion)
+	}
+	switch p.BonusType {
+	case PromoBonusPercent:
+		if p.BonusPercent <= 0 || p.BonusPercent > money.Rate(money.FromMajor(100)) {
+			return fmt.Errorf("%w: bonus_percent must be between 0 and 100", ErrInvalidPromotion)
+		}
+		if p.MaxBonus != nil && !p.MaxBonus.IsPositive() {
+			return fmt.Errorf("%w: max_bonus must be positive", ErrInvalidPromotion)
+		}
+	case PromoBonusFixed:
+		if !p.BonusAmount.IsPositive() {
+			return fmt.Errorf("%w: bonus_amount must be positive", ErrInvalidPromotion)
+		}
+	default:
+		return fmt.Errorf("%w: bonus_type must be PERCENT or FIXED", ErrInvalidPromotion)
+	}
+	if p.MinTopupAmount.IsNegative() {
+		return fmt.Errorf("%w: min_topup_amount cannot be negative", ErrInvalidPromotion)
+	}
+	if (p.MaxRedemptions != nil && *p.MaxRedemptions <= 0) ||
+		(p.MaxRedemptionsPerCustomer != nil && *p.MaxRedemptionsPerCustomer <= 0) {
+		return fmt.Errorf("%w: redemption limits must be positive", ErrInvalidPromotion)
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromotion)
+	}
+	return nil
+}
+
+// checkPromotionTerms checks the parts of a promotion that depend only on
+// the topup: validity window, minimum amount and gateway.
+func checkPromotionTerms(p *models.Promotion, gatewayID string, amount money.Amount, now time.Time) error {
+	if !p.IsActive || (p.StartsAt != nil && now.Before(*p.StartsAt)) || (p.EndsAt != nil && !now.Before(*p.EndsAt)) {
+		return ErrPromoCodeInvalid
+	}
+	if amount < p.MinTopupAmount {
+		return fmt.Errorf("%w: minimum topup is %s", ErrPromoNotApplicable, p.MinTopupAmount)
+	}
+	if len(p.Gateways) > 0 {
+		allowed := false
+		for _, g := range p.Gateways {
+			if g.GatewayID == gatewayID {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: not valid for this payment method", ErrPromoNotApplicable)
+		}
+	}
+	return nil
+}
+
+// checkPromotionLimits counts live redemptions (reserved or applied) against
+// the global and per-customer limits and enforces first-topup-only. The
+// promotion row must be locked so concurrent topups cannot both take the
+// last redemption. excludeTopupID leaves one topup's own redemption out.
+func checkPromotionLimits(tx *gorm.DB, p *models.Promotion, customerID, excludeTopupID string) error {
+	live := func() *gorm.DB {
+		q := tx.Model(&models.PromotionRedemption{}).
+			Where("promotion_id = ? AND status IN ?", p.ID, []string{RedemptionReserved, RedemptionApplied})
+		if excludeTopupID != "" {
+			q = q.Where("topup_id <> ?", excludeTopupID)
+		}
+		return q
+	}
+
+	if p.MaxRedemptions != nil {
+		var n int64
+		if err := live().Count(&n).Error; err != nil {
+			return err
+		}
+		if n >= int64(*p.MaxRedemptions) {
+			return ErrPromoLimitReached
+		}
+	}
+	if p.MaxRedemptionsPerCustomer != nil {
+		var n int64
+		if err := live().Where("customer_id = ?", customerID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n >= int64(*p.MaxRedemptionsPerCustomer) {
+			return fmt.Errorf("%w: already used on this account", ErrPromoLimitReached)
+		}
+	}
+	if p.FirstTopupOnly {
+		q := tx.Model(&models.TopupRequest{}).Where("customer_id = ? AND status = ?", customerID, "SUCCESS")
+		if excludeTopupID != "" {
+			q = q.Where("id <> ?", excludeTopupID)
+		}
+		var n int64
+		if err := q.Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("%w: only valid on the first topup", ErrPromoNotApplicable)
+		}
+	}
+	return nil
+}
+
+// reservePromotion locks the promotion behind code and checks that a topup
+// may use it. It returns the promotion and the bonus; the caller stores the
+// topup and then the redemption in the same transaction.
+func reservePromotion(tx *gorm.DB, code, customerID, gatewayID string, amount money.Amount, now time.Time) (*models.Promotion, money.Amount, error) {
+	var promo models.Promotion
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", NormalizePromoCode(code)).
+		First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, ErrPromoCodeInvalid
+		}
+		return nil, 0, err
+	}
+	if err := tx.Where("promotion_id = ?", promo.ID).Find(&promo.Gateways).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := checkPromotionTerms(&promo, gatewayID, amount, now); err != nil {
+		return nil, 0, err
+	}
+	if err := checkPromotionLimits(tx, &promo, customerID, ""); err != nil {
+		return nil, 0, err
+	}
+	bonus := PromotionBonus(&promo, amount)
+	if !bonus.IsPositive() {
+		return nil, 0, ErrPromoNotApplicable
+	}
+	return &promo, bonus, nil
+}
+
+// applyPromotionBonus credits the bonus reserved for a topup that has just
+// succeeded, as a BONUS transaction referencing the topup. The limits and
+// first-topup-only are checked again under the promotion lock, since another
+// topup may have been paid since the reservation; a redemption that no
+// longer qualifies is released without a bonus. Topups without a code are a
+// no-op.
+func applyPromotionBonus(tx *gorm.DB, wallet *WalletService, topup *models.TopupRequest, adminID *string, now time.Time) error {
+	if topup.PromotionID == nil {
+		return nil
+	}
+
+	var promo models.Promotion
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", *topup.PromotionID).First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	var redemption models.PromotionRedemption
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("topup_id = ?", topup.ID).First(&redemption).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if redemption.Status == RedemptionApplied {
+		return nil
+	}
+	if err := checkPromotionLimits(tx, &promo, topup.CustomerID, topup.ID); err != nil {
+		if errors.Is(err, ErrPromoLimitReached) || errors.Is(err, ErrPromoNotApplicable) {
+			return releasePromotion(tx, topup.ID)
+		}
+		return err
+	}
+
+	referenceID := topup.ID
+	referenceType := "topup_request"
+	txn, err := wallet.RecordTransaction(tx, struct {
+		CustomerID       string
+		Amount           money.Amount
+		Type             string
+		ReferenceID      *string
+		ReferenceType    *string
+		Description      string
+		Metadata         string
+		CreatedByAdminID *string
+	}{
+		CustomerID:       topup.CustomerID,
+		Amount:           redemption.BonusAmount,
+		Type:             "BONUS",
+		ReferenceID:      &referenceID,
+		ReferenceType:    &referenceType,
+		Description:      fmt.Sprintf("Promo bonus %s", promo.Code),
+		Metadata:         fmt.Sprintf(`{"topup_id": "%s", "promotion_id": "%s", "code": "%s"}`, topup.ID, promo.ID, promo.Code),
+		CreatedByAdminID: adminID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&redemption).Updates(map[string]interface{}{
+		"status":                RedemptionApplied,
+		"wallet_transaction_id": txn.ID,
+		"applied_at":            now,
+		"updated_at":            now,
+	}).Error
+}
+
+// releasePromotion frees the redemption of a topup that will not be paid so
+// it stops counting against the limits.
+func releasePromotion(tx *gorm.DB, topupID string) error {
+	return tx.Model(&models.PromotionRedemption{}).
+		Where("topup_id = ? AND status = ?", topupID, RedemptionReserved).
+		Updates(map[string]interface{}{
+			"status":     RedemptionReleased,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+type PromotionService struct {
+	db *gorm.DB
+}
+
+func NewPromotionService(gdb *gorm.DB) (*PromotionService, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &PromotionService{db: gdb}, nil
+}
+
+func NewPromotionServiceFromDefault() (*PromotionService, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewPromotionService(gdb)
+}
+
+// ListPromotions - List promotions
+// Usage: Admin
+func (s *PromotionService) ListPromotions(filters struct {
+	IsActive *bool
+	Limit    int
+	Offset   int
+}) ([]models.Promotion, int64, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := s.db.Model(&models.Promotion{})
+	if filters.IsActive != nil {
+		query = query.Where("is_active = ?", *filters.IsActive)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var promos []models.Promotion
+	if err := query.Preload("Gateways").Order("created_at DESC").Limit(limit).Offset(filters.Offset).Find(&promos).Error; err != nil {
+		return nil, 0, err
+	}
+	return promos, total, nil
+}
+
+// GetPromotion - Get promotion with its gateway restrictions
+// Usage: Admin
+func (s *PromotionService) GetPromotion(id string) (*models.Promotion, error) {
+	var promo models.Promotion
+	if err := s.db.Preload("Gateways").Where("id = ?", id).First(&promo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, err
+	}
+	return &promo, nil
+}
+
+// CreatePromotion - Create promotion
+// Usage: Admin only
+func (s *PromotionService) CreatePromotion(promo *models.Promotion) error {
+	promo.Code = NormalizePromoCode(promo.Code)
+	if err := ValidatePromotion(promo); err != nil {
+		return err
+	}
+	return s.db.Create(promo).Error
+}
+
+// UpdatePromotion - Replace promotion terms and gateway restrictions.
+// Redemptions already reserved keep the bonus they were quoted.
+// Usage: Admin only
+func (s *PromotionService) UpdatePromotion(promo *models.Promotion) error {
+	promo.Code = NormalizePromoCode(promo.Code)
+	if err := ValidatePromotion(promo); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Promotion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", promo.ID).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPromotionNotFound
+			}
+			return err
+		}
+		if err := tx.Where("promotion_id = ?", promo.ID).Delete(&models.PromotionGateway{}).Error; err != nil {
+			return err
+		}
+		for i := range promo.Gateways {
+			promo.Gateways[i].PromotionID = promo.ID
+		}
+		promo.CreatedAt = current.CreatedAt
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(promo).Error
+	})
+}
+
+// SetPromotionActive - Enable/disable a promotion. Reserved redemptions are
+// still honoured when their topup is paid.
+// Usage: Admin only
+func (s *PromotionService) SetPromotionActive(id string, isActive bool) error {
+	res := s.db.Model(&models.Promotion{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"is_active":  isActive,
+		"updated_at": time.Now(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
+
+// DeletePromotion - Delete a promotion that was never redeemed
+// Usage: Admin only
+func (s *PromotionService) DeletePromotion(id string) error {
+	var count int64
+	if err := s.db.Model(&models.PromotionRedemption{}).Where("promotion_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrPromotionInUse
+	}
+	res := s.db.Delete(&models.Promotion{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
+
+// ListRedemptions - List a promotion's redemptions
+// Usage: Admin
+func (s *PromotionService) ListRedemptions(filters struct {
+	PromotionID string
+	Status      *string
+	Limit       int
+	Offset      int
+}) ([]models.PromotionRedemption, int64, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := s.db.Model(&models.PromotionRedemption{}).Where("promotion_id = ?", filters.PromotionID)
+	if filters.Status != nil {
+		query = query.Where("status = ?", *filters.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var redemptions []models.PromotionRedemption
+	if err := query.Order("created_at DESC").Limit(limit).Offset(filters.Offset).Find(&redemptions).Error; err != nil {
+		return nil, 0, err
+	}
+	return redemptions, total, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+func percentPromo(t *testing.T, percent string) *models.Promotion {
+	t.Helper()
+	rate, err := money.ParseRate(percent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.Promotion{
+		Code:         "TOPUP10",
+		Name:         "Topup 10%",
+		BonusType:    PromoBonusPercent,
+		BonusPercent: rate,
+		IsActive:     true,
+	}
+}
+
+func TestPromotionBonus(t *testing.T) {
+	p := percentPromo(t, "10")
+	if got := PromotionBonus(p, money.FromMajor(500000)); got != money.FromMajor(50000) {
+		t.Errorf("10%% of 500k = %s", got)
+	}
+	// Fractions of a minor unit are never given away.
+	if got := PromotionBonus(p, money.MustParse("0.09")); got != 0 {
+		t.Errorf("10%% of 0.09 = %s, want 0.00", got)
+	}
+
+	limit := money.FromMajor(25000)
+	p.MaxBonus = &limit
+	if got := PromotionBonus(p, money.FromMajor(500000)); got != limit {
+		t.Errorf("capped bonus = %s, want %s", got, limit)
+	}
+
+	fixed := &models.Promotion{BonusType: PromoBonusFixed, BonusAmount: money.FromMajor(50000)}
+	if got := PromotionBonus(fixed, money.FromMajor(10000)); got != money.FromMajor(50000) {
+		t.Errorf("fixed bonus = %s", got)
+	}
+}
+
+func TestValidatePromotion(t *testing.T) {
+	if err := ValidatePromotion(percentPromo(t, "10")); err != nil {
+		t.Fatalf("valid promotion rejected: %v", err)
+	}
+
+	zero := 0
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	bad := []func(p *models.Promotion){
+		func(p *models.Promotion) { p.Code = "" },
+		func(p *models.Promotion) { p.Code = "TWO WORDS" },
+		func(p *models.Promotion) { p.BonusType = "CASHBACK" },
+		func(p *models.Promotion) { p.BonusPercent = 0 },
+		func(p *models.Promotion) { p.BonusPercent = money.Rate(money.FromMajor(101)) },
+		func(p *models.Promotion) { p.BonusType = PromoBonusFixed },
+		func(p *models.Promotion) { p.MaxRedemptions = &zero },
+		func(p *models.Promotion) { p.StartsAt, p.EndsAt = &start, &start },
+	}
+	for i, mutate := range bad {
+		p := percentPromo(t, "10")
+		mutate(p)
+		if err := ValidatePromotion(p); !errors.Is(err, ErrInvalidPromotion) {
+			t.Errorf("case %d: err = %v, want ErrInvalidPromotion", i, err)
+		}
+	}
+}
+
+func TestCheckPromotionTerms(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	start, end := now.Add(-24*time.Hour), now.Add(24*time.Hour)
+
+	p := percentPromo(t, "10")
+	p.StartsAt, p.EndsAt = &start, &end
+	p.MinTopupAmount = money.FromMajor(500000)
+	p.Gateways = []models.PromotionGateway{{GatewayID: "gw-qris"}}
+
+	if err := checkPromotionTerms(p, "gw-qris", money.FromMajor(500000), now); err != nil {
+		t.Fatalf("eligible topup rejected: %v", err)
+	}
+	if err := checkPromotionTerms(p, "gw-qris", money.FromMajor(499999), now); !errors.Is(err, ErrPromoNotApplicable) {
+		t.Errorf("below minimum: err = %v", err)
+	}
+	if err := checkPromotionTerms(p, "gw-manual", money.FromMajor(500000), now); !errors.Is(err, ErrPromoNotApplicable) {
+		t.Errorf("other gateway: err = %v", err)
+	}
+	if err := checkPromotionTerms(p, "gw-qris", money.FromMajor(500000), end); !errors.Is(err, ErrPromoCodeInvalid) {
+		t.Errorf("at ends_at: err = %v", err)
+	}
+	if err := checkPromotionTerms(p, "gw-qris", money.FromMajor(500000), start.Add(-time.Second)); !errors.Is(err, ErrPromoCodeInvalid) {
+		t.Errorf("before starts_at: err = %v", err)
+	}
+	p.IsActive = false
+	if err := checkPromotionTerms(p, "gw-qris", money.FromMajor(500000), now); !errors.Is(err, ErrPromoCodeInvalid) {
+		t.Errorf("inactive: err = %v", err)
+	}
+}
@@ -2,9 +2,11 @@ package services
 
 import (
 	"errors"
-	"fmt"
+	"time"
 
 	"go_framework/internal/db"
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
 
 	"gorm.io/gorm"
 )
@@ -33,19 +35,20 @@ func NewPurchaseServiceFromDefault() (*PurchaseService, error) {
 	return NewPurchaseService(gdb)
 }
 
-// ValidateBalance - Check if customer has enough balance
+// ValidateBalance - Check if customer has enough available balance (not
+// reserved by holds)
 // Usage: Internal (called before purchase)
-func (s *PurchaseService) ValidateBalance(customerID string, requiredAmount float64) (bool, float64, error) {
-	balance, err := s.walletService.GetBalance(customerID)
+func (s *PurchaseService) ValidateBalance(customerID string, requiredAmount money.Amount) (bool, money.Amount, error) {
+	summary, err := s.walletService.GetBalanceSummary(customerID)
 	if err != nil {
 		return false, 0, err
 	}
 
-	if balance < requiredAmount {
-		return false, balance, nil
+	if summary.Available < requiredAmount {
+		return false, summary.Available, nil
 	}
 
-	return true, balance, nil
+	return true, summary.Available, nil
 }
 
 // DeductBalance - Deduct balance for purchase (container deployment, addon, etc)
@@ -53,7 +56,7 @@ func (s *PurchaseService) ValidateBalance(customerID string, requiredAmount floa
 // MUST be called within transaction context
 func (s *PurchaseService) DeductBalance(tx *gorm.DB, input struct {
 	CustomerID    string
-	Amount        float64
+	Amount        money.Amount
 	ReferenceID   string // Container ID, addon ID, etc
 	ReferenceType string // 'container', 'addon', 'domain', etc
 	Description   string
@@ -68,7 +71,7 @@ func (s *PurchaseService) DeductBalance(tx *gorm.DB, input struct {
 	refType := input.ReferenceType
 	_, err := s.walletService.RecordTransaction(tx, struct {
 		CustomerID       string
-		Amount           float64
+		Amount           money.Amount
 		Type             string
 		ReferenceID      *string
 		ReferenceType    *string
@@ -89,73 +92,55 @@ func (s *PurchaseService) DeductBalance(tx *gorm.DB, input struct {
 	return err
 }
 
-// RefundBalance - Refund balance (on container delete, error, etc)
-// Usage: Internal (called from node plugin delete/error flow), Admin (manual refund)
-// MUST be called within transaction context if part of larger operation
+// RefundBalance - Refund a PURCHASE or RENEWAL, in full or in part. The
+// refund cannot exceed what is left of the charge after earlier refunds.
+// Usage: Admin (manual refund)
 func (s *PurchaseService) RefundBalance(input struct {
-	CustomerID    string
-	Amount        float64
-	ReferenceID   string // Original container ID, etc
-	ReferenceType string // 'container', 'addon', etc
+	TransactionID string       // The PURCHASE or RENEWAL being refunded
+	Amount        money.Amount // Zero refunds the remaining amount
 	Reason        string
 	AdminID       *string // If manual refund by admin
-}) error {
-	if input.Amount <= 0 {
-		return ErrNegativeAmount
-	}
-
+}) (*models.WalletRefund, error) {
+	var refund *models.WalletRefund
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		refID := input.ReferenceID
-		refType := input.ReferenceType
-		description := fmt.Sprintf("Refund: %s", input.Reason)
-
-		_, err := s.walletService.RecordTransaction(tx, struct {
-			CustomerID       string
-			Amount           float64
-			Type             string
-			ReferenceID      *string
-			ReferenceType    *string
-			Description      string
-			Metadata         string
-			CreatedByAdminID *string
-		}{
-			CustomerID:       input.CustomerID,
-			Amount:           input.Amount, // Positive for credit
-			Type:             "REFUND",
-			ReferenceID:      &refID,
-			ReferenceType:    &refType,
-			Description:      description,
-			Metadata:         fmt.Sprintf(`{"reason": "%s", "original_reference": "%s"}`, input.Reason, input.ReferenceID),
-			CreatedByAdminID: input.AdminID,
-		})
-
+		r, err := refundTransaction(tx, input.TransactionID, input.Amount, RefundManual, input.Reason, input.AdminID)
+		refund = r
 		return err
 	})
-
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
 }
 
-// CalculateContainerPrice - Calculate container deployment price
-// Usage: Node plugin (before deploy), Customer (price preview)
-// TODO: Implement pricing logic based on RAM, CPU, duration, etc
-func (s *PurchaseService) CalculateContainerPrice(ramMB, cpuPercent int, durationHours int) (float64, error) {
-	// Simplified pricing logic - customize based on business model
-	// Example: Rp 500 per GB RAM per hour + Rp 100 per CPU % per hour
+// Built-in container rates, used until a price plan is in effect.
+var (
+	pricePerGBHour  = money.FromMajor(500) // Rp 500 per GB RAM per hour
+	pricePerCPUHour = money.FromMajor(100) // Rp 100 per CPU % per hour
+)
 
+// CalculateContainerPrice - Calculate container price for a duration under
+// the plan in effect now, ignoring template and region overrides (see
+// PricingService.Quote for those)
+// Usage: Node plugin (before deploy), Customer (price preview)
+func (s *PurchaseService) CalculateContainerPrice(ramMB, cpuPercent int, durationHours int) (money.Amount, error) {
 	if ramMB <= 0 || cpuPercent <= 0 || durationHours <= 0 {
 		return 0, errors.New("invalid pricing parameters")
 	}
 
-	ramGB := float64(ramMB) / 1024.0
-	pricePerGBHour := 500.0
-	pricePerCPUHour := 100.0
-
-	ramCost := ramGB * pricePerGBHour * float64(durationHours)
-	cpuCost := float64(cpuPercent) * pricePerCPUHour * float64(durationHours)
-
-	totalPrice := ramCost + cpuCost
-
-	return totalPrice, nil
+	plan := defaultPricePlan()
+	if s.db != nil {
+		p, err := (&PricingService{db: s.db}).PlanAt(s.db, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		plan = p
+	}
+	quote, err := QuotePlan(plan, "", "", ramMB, cpuPercent)
+	if err != nil {
+		return 0, err
+	}
+	return quote.Price(durationHours), nil
 }
 
 // GetPurchaseHistory - Get purchase history for customer
@@ -0,0 +1,534 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/internal/scheduler"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReconcileJob is the scheduler name of the nightly wallet reconciliation.
+const ReconcileJob = "billing.reconcile"
+
+const (
+	defaultReconcileInterval = 24 * time.Hour
+	reconcileBatch           = 500
+)
+
+// Reconciliation issue kinds
+const (
+	// The cached wallet balance differs from the sum of the customer's
+	// wallet transactions.
+	IssueBalanceMismatch = "BALANCE_MISMATCH"
+	// A transaction's balance_before is not the previous balance_after, or
+	// its balance_after is not balance_before + amount.
+	IssueChainBreak = "CHAIN_BREAK"
+	// A SUCCESS topup was credited less than its amount (usually never).
+	IssueMissingTopupCredit = "MISSING_TOPUP_CREDIT"
+	// A topup was credited more than its amount, e.g. twice.
+	IssueDuplicateTopupCredit = "DUPLICATE_TOPUP_CREDIT"
+	// A TOPUP transaction without a SUCCESS topup behind it.
+	IssueOrphanTopupCredit = "ORPHAN_TOPUP_CREDIT"
+	// The customer's ledger wallet account differs from the cached balance.
+	IssueLedgerMismatch = "LEDGER_MISMATCH"
+	// The cached held balance differs from the sum of ACTIVE wallet holds.
+	IssueHoldMismatch = "HOLD_MISMATCH"
+)
+
+// Who started a reconciliation
+const (
+	ReconcileByConsole   = "CONSOLE"
+	ReconcileByScheduler = "SCHEDULER"
+)
+
+// ReconcileIssue is one inconsistency found for a customer. Expected and
+// Actual are the amounts compared; Difference = Expected - Actual is what a
+// fix posts.
+type ReconcileIssue struct {
+	Kind          string       `json:"kind"`
+	CustomerID    string       `json:"customer_id"`
+	TopupID       *string      `json:"topup_id,omitempty"`
+	TransactionID *string      `json:"transaction_id,omitempty"`
+	Expected      money.Amount `json:"expected"`
+	Actual        money.Amount `json:"actual"`
+	Difference    money.Amount `json:"difference"`
+	Detail        string       `json:"detail"`
+	Fixable       bool         `json:"fixable"`
+	Fixed         bool         `json:"fixed"`
+	FixReference  *string      `json:"fix_reference,omitempty"` // wallet transaction or journal entry posted
+	FixError      string       `json:"fix_error,omitempty"`
+}
+
+// ReconcileReport is the machine-readable result of a run.
+type ReconcileReport struct {
+	RunID            string           `json:"run_id"`
+	TriggeredBy      string           `json:"triggered_by"`
+	Fix              bool             `json:"fix"`
+	StartedAt        time.Time        `json:"started_at"`
+	FinishedAt       time.Time        `json:"finished_at"`
+	CustomersChecked int              `json:"customers_checked"`
+	IssuesFound      int              `json:"issues_found"`
+	IssuesFixed      int              `json:"issues_fixed"`
+	Counts           map[string]int   `json:"counts"`
+	Issues           []ReconcileIssue `json:"issues"`
+}
+
+// ReconcileOptions selects what a run checks and whether it corrects.
+type ReconcileOptions struct {
+	Fix         bool
+	CustomerID  *string // nil = every customer
+	AdminID     *string // recorded on correcting transactions
+	TriggeredBy string
+}
+
+// RegisterReconcileJob schedules a read-only reconciliation every
+// BILLING_RECONCILE_INTERVAL (default 24h). Fixes are only posted from the
+// console, after someone has read the report.
+func RegisterReconcileJob() {
+	interval := defaultReconcileInterval
+	if v := strings.TrimSpace(os.Getenv("BILLING_RECONCILE_INTERVAL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		}
+	}
+	scheduler.Register(scheduler.Job{
+		Name:     ReconcileJob,
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			svc, err := NewWalletServiceFromDefault()
+			if err != nil {
+				return err
+			}
+			report, err := svc.Reconcile(ctx, ReconcileOptions{TriggeredBy: ReconcileByScheduler})
+			if err != nil {
+				return err
+			}
+			if report.IssuesFound > 0 {
+				log.Printf("billing: reconcile run=%s found %d issue(s) across %d customers: %v",
+					report.RunID, report.IssuesFound, report.CustomersChecked, report.Counts)
+			}
+			return nil
+		},
+	})
+}
+
+// checkWalletHistory walks a customer's transactions in order and returns
+// their sum and any breaks in the balance_before/balance_after chain.
+func checkWalletHistory(customerID string, txns []models.WalletTransaction) (money.Amount, []ReconcileIssue) {
+	var (
+		sum    money.Amount
+		issues []ReconcileIssue
+	)
+	for i := range txns {
+		t := &txns[i]
+		sum = sum.Add(t.Amount)
+		id := t.ID
+		if i > 0 && t.BalanceBefore != txns[i-1].BalanceAfter {
+			issues = append(issues, ReconcileIssue{
+				Kind:          IssueChainBreak,
+				CustomerID:    customerID,
+				TransactionID: &id,
+				Expected:      txns[i-1].BalanceAfter,
+				Actual:        t.BalanceBefore,
+				Difference:    txns[i-1].BalanceAfter - t.BalanceBefore,
+				Detail:        fmt.Sprintf("balance_before does not match previous transaction %s", txns[i-1].ID),
+			})
+		}
+		if t.BalanceAfter != t.BalanceBefore.Add(t.Amount) {
+			issues = append(issues, ReconcileIssue{
+				Kind:          IssueChainBreak,
+				CustomerID:    customerID,
+				TransactionID: &id,
+				Expected:      t.BalanceBefore.Add(t.Amount),
+				Actual:        t.BalanceAfter,
+				Difference:    t.BalanceBefore.Add(t.Amount) - t.BalanceAfter,
+				Detail:        "balance_after is not balance_before + amount",
+			})
+		}
+	}
+	return sum, issues
+}
+
+// checkTopupCredits cross-checks a customer's topups against the
+// transactions that credited them: TOPUP rows, plus ADMIN_ADJUSTMENT rows a
+// previous reconciliation posted against the topup.
+func checkTopupCredits(customerID string, txns []models.WalletTransaction, topups []models.TopupRequest) []ReconcileIssue {
+	byID := make(map[string]*models.TopupRequest, len(topups))
+	for i := range topups {
+		byID[topups[i].ID] = &topups[i]
+	}
+
+	credited := make(map[string]money.Amount)
+	credits := make(map[string]int)
+	var issues []ReconcileIssue
+	for i := range txns {
+		t := &txns[i]
+		linked := t.ReferenceType != nil && *t.ReferenceType == "topup_request" && t.ReferenceID != nil
+		switch {
+		case t.Type == "TOPUP" && !linked:
+			id := t.ID
+			issues = append(issues, ReconcileIssue{
+				Kind:          IssueOrphanTopupCredit,
+				CustomerID:    customerID,
+				TransactionID: &id,
+				Actual:        t.Amount,
+				Difference:    -t.Amount,
+				Detail:        "TOPUP transaction without a topup reference",
+			})
+		case t.Type == "TOPUP":
+			topup, ok := byID[*t.ReferenceID]
+			if !ok || topup.Status != "SUCCESS" {
+				id := t.ID
+				detail := "credited topup does not exist"
+				if ok {
+					detail = fmt.Sprintf("credited topup is %s", topup.Status)
+				}
+				issues = append(issues, ReconcileIssue{
+					Kind:          IssueOrphanTopupCredit,
+					CustomerID:    customerID,
+					TopupID:       t.ReferenceID,
+					TransactionID: &id,
+					Actual:        t.Amount,
+					Difference:    -t.Amount,
+					Detail:        detail,
+				})
+				continue
+			}
+			credited[topup.ID] += t.Amount
+			credits[topup.ID]++
+		case t.Type == "ADMIN_ADJUSTMENT" && linked:
+			credited[*t.ReferenceID] += t.Amount
+		}
+	}
+
+	for i := range topups {
+		topup := &topups[i]
+		if topup.Status != "SUCCESS" || credited[topup.ID] == topup.Amount {
+			continue
+		}
+		id := topup.ID
+		issue := ReconcileIssue{
+			CustomerID: customerID,
+			TopupID:    &id,
+			Expected:   topup.Amount,
+			Actual:     credited[topup.ID],
+			Difference: topup.Amount - credited[topup.ID],
+			Fixable:    true,
+		}
+		if issue.Difference.IsPositive() {
+			issue.Kind = IssueMissingTopupCredit
+			issue.Detail = fmt.Sprintf("SUCCESS topup credited %s of %s", issue.Actual, issue.Expected)
+		} else {
+			issue.Kind = IssueDuplicateTopupCredit
+			issue.Detail = fmt.Sprintf("topup credited %s by %d transaction(s), expected %s", issue.Actual, credits[topup.ID], issue.Expected)
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// reconcileCustomer runs every check for one customer. ledger is the
+// customer's wallet account balance, nil while the account is not open.
+func reconcileCustomer(customerID string, balance money.Amount, txns []models.WalletTransaction, topups []models.TopupRequest, ledger *money.Amount) []ReconcileIssue {
+	sum, issues := checkWalletHistory(customerID, txns)
+	if sum != balance {
+		issues = append([]ReconcileIssue{{
+			Kind:       IssueBalanceMismatch,
+			CustomerID: customerID,
+			Expected:   balance,
+			Actual:     sum,
+			Difference: balance - sum,
+			Detail:     "wallet_balance differs from the sum of wallet transactions",
+			Fixable:    true,
+		}}, issues...)
+	}
+	if ledger != nil && *ledger != balance {
+		issues = append(issues, ReconcileIssue{
+			Kind:       IssueLedgerMismatch,
+			CustomerID: customerID,
+			Expected:   balance,
+			Actual:     *ledger,
+			Difference: balance - *ledger,
+			Detail:     "ledger wallet account differs from wallet_balance",
+			Fixable:    true,
+		})
+	}
+	return append(issues, checkTopupCredits(customerID, txns, topups)...)
+}
+
+// checkHeldBalance compares the cached held balance with the customer's
+// ACTIVE holds.
+func checkHeldBalance(customerID string, held, activeHolds money.Amount) []ReconcileIssue {
+	if held == activeHolds {
+		return nil
+	}
+	return []ReconcileIssue{{
+		Kind:       IssueHoldMismatch,
+		CustomerID: customerID,
+		Expected:   activeHolds,
+		Actual:     held,
+		Difference: activeHolds - held,
+		Detail:     "held_balance differs from the sum of active wallet holds",
+		Fixable:    true,
+	}}
+}
+
+// Reconcile checks every customer's wallet (or one, see opts.CustomerID)
+// and stores the report as a reconciliation run. With opts.Fix, fixable
+// issues are corrected under the customer's row lock:
+//   - a balance mismatch gets an ADMIN_ADJUSTMENT row that brings the
+//     history to the cached balance the customer has been seeing, without
+//     moving it;
+//   - a ledger mismatch gets a journal entry against expense:adjustments;
+//   - a held balance mismatch resets held_balance to the active holds;
+//   - missing or duplicate topup credits get an ADMIN_ADJUSTMENT for the
+//     difference, referencing the topup.
+//
+// Chain breaks and orphan credits are reported for manual review.
+// Usage: billing:reconcile command, scheduled job
+func (s *WalletService) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error) {
+	if opts.TriggeredBy == "" {
+		opts.TriggeredBy = ReconcileByConsole
+	}
+	run := &models.ReconciliationRun{
+		TriggeredBy: opts.TriggeredBy,
+		Fix:         opts.Fix,
+		AdminID:     opts.AdminID,
+		Report:      "{}",
+		StartedAt:   time.Now(),
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, err
+	}
+	report := &ReconcileReport{
+		RunID:       run.ID,
+		TriggeredBy: run.TriggeredBy,
+		Fix:         run.Fix,
+		StartedAt:   run.StartedAt,
+		Counts:      map[string]int{},
+		Issues:      []ReconcileIssue{},
+	}
+
+	lastID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var ids []string
+		query := s.db.Table("customers").Order("id ASC").Limit(reconcileBatch)
+		if opts.CustomerID != nil {
+			query = query.Where("id = ?", *opts.CustomerID)
+		} else if lastID != "" {
+			query = query.Where("id > ?", lastID)
+		}
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			issues, err := s.reconcileOne(id, run, opts)
+			if err != nil {
+				return nil, fmt.Errorf("reconcile customer %s: %w", id, err)
+			}
+			report.CustomersChecked++
+			for _, issue := range issues {
+				report.IssuesFound++
+				report.Counts[issue.Kind]++
+				if issue.Fixed {
+					report.IssuesFixed++
+				}
+			}
+			report.Issues = append(report.Issues, issues...)
+		}
+		if opts.CustomerID != nil || len(ids) < reconcileBatch {
+			break
+		}
+		lastID = ids[len(ids)-1]
+	}
+
+	report.FinishedAt = time.Now()
+	body, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(run).Updates(map[string]interface{}{
+		"customers_checked": report.CustomersChecked,
+		"issues_found":      report.IssuesFound,
+		"issues_fixed":      report.IssuesFixed,
+		"report":            string(body),
+		"finished_at":       report.FinishedAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// reconcileOne checks one customer. A dry run reads from a single snapshot;
+// a fix run locks the customer row so the wallet cannot move between the
+// check and the correction.
+func (s *WalletService) reconcileOne(customerID string, run *models.ReconciliationRun, opts ReconcileOptions) ([]ReconcileIssue, error) {
+	var issues []ReconcileIssue
+	check := func(tx *gorm.DB) error {
+		query := tx.Table("customers").Where("id = ?", customerID)
+		if opts.Fix {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		var customer models.CustomerBalance
+		if err := query.Take(&customer).Error; err != nil {
+			return err
+		}
+
+		var txns []models.WalletTransaction
+		if err := tx.Where("customer_id = ?", customerID).Order("created_at ASC, id ASC").Find(&txns).Error; err != nil {
+			return err
+		}
+		var topups []models.TopupRequest
+		if err := tx.Where("customer_id = ?", customerID).Find(&topups).Error; err != nil {
+			return err
+		}
+		ledger, opened, err := (&LedgerService{db: tx}).CustomerLiability(customerID)
+		if err != nil {
+			return err
+		}
+		var ledgerPtr *money.Amount
+		if opened {
+			ledgerPtr = &ledger
+		}
+
+		var activeHolds money.Amount
+		if err := tx.Model(&models.WalletHold{}).Select("COALESCE(SUM(amount), 0)").
+			Where("customer_id = ? AND status = ?", customerID, HoldActive).
+			Scan(&activeHolds).Error; err != nil {
+			return err
+		}
+
+		issues = reconcileCustomer(customerID, customer.WalletBalance, txns, topups, ledgerPtr)
+		issues = append(issues, checkHeldBalance(customerID, customer.HeldBalance, activeHolds)...)
+		if !opts.Fix {
+			return nil
+		}
+		return s.fixIssues(tx, issues, customer.WalletBalance, run)
+	}
+
+	if opts.Fix {
+		return issues, s.db.Transaction(check)
+	}
+	return issues, s.db.Transaction(check, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+// fixIssues posts the corrections described on Reconcile. Balance and ledger
+// corrections come first, as they are computed against the balance before
+// any topup correction moves it. A correction that fails (e.g. a duplicate
+// credit already spent) is reported on the issue and the rest still run.
+func (s *WalletService) fixIssues(tx *gorm.DB, issues []ReconcileIssue, balance money.Amount, run *models.ReconciliationRun) error {
+	runID := run.ID
+	referenceType := "reconciliation_run"
+	var balanceFix *models.WalletTransaction
+
+	for i := range issues {
+		issue := &issues[i]
+		switch issue.Kind {
+		case IssueBalanceMismatch:
+			// Recorded directly: the adjustment documents a balance the
+			// customer already has, so the balance itself must not move.
+			// With an intact chain, balance - difference is the last
+			// balance_after.
+			txn := &models.WalletTransaction{
+				CustomerID:       issue.CustomerID,
+				Amount:           issue.Difference,
+				BalanceBefore:    balance - issue.Difference,
+				BalanceAfter:     balance,
+				Type:             "ADMIN_ADJUSTMENT",
+				ReferenceID:      &runID,
+				ReferenceType:    &referenceType,
+				Description:      "Reconciliation: wallet history brought to the cached balance",
+				Metadata:         fmt.Sprintf(`{"reconciliation_run_id": %q, "issue": %q}`, runID, issue.Kind),
+				CreatedByAdminID: run.AdminID,
+			}
+			if err := tx.Create(txn).Error; err != nil {
+				return err
+			}
+			balanceFix = txn
+			issue.Fixed, issue.FixReference = true, &txn.ID
+
+		case IssueLedgerMismatch:
+			legs, err := walletEntryLegs(issue.CustomerID, "ADMIN_ADJUSTMENT", issue.Difference, nil)
+			if err != nil {
+				return err
+			}
+			entry := &models.JournalEntry{
+				Description:      "Reconciliation: ledger wallet account brought to the cached balance",
+				ReferenceID:      &runID,
+				ReferenceType:    &referenceType,
+				CreatedByAdminID: run.AdminID,
+			}
+			// The same gap in history and ledger is one correction.
+			if balanceFix != nil && balanceFix.Amount == issue.Difference {
+				entry.WalletTransactionID = &balanceFix.ID
+			}
+			if err := postJournalEntry(tx, entry, legs); err != nil {
+				return err
+			}
+			issue.Fixed, issue.FixReference = true, &entry.ID
+
+		case IssueHoldMismatch:
+			if err := tx.Table("customers").Where("id = ?", issue.CustomerID).
+				Update("held_balance", issue.Expected).Error; err != nil {
+				return err
+			}
+			issue.Fixed = true
+		}
+	}
+
+	for i := range issues {
+		issue := &issues[i]
+		if issue.Kind != IssueMissingTopupCredit && issue.Kind != IssueDuplicateTopupCredit {
+			continue
+		}
+		topupType := "topup_request"
+		var txn *models.WalletTransaction
+		err := tx.Transaction(func(sp *gorm.DB) error {
+			var err error
+			txn, err = s.RecordTransaction(sp, struct {
+				CustomerID       string
+				Amount           money.Amount
+				Type             string
+				ReferenceID      *string
+				ReferenceType    *string
+				Description      string
+				Metadata         string
+				CreatedByAdminID *string
+			}{
+				CustomerID:       issue.CustomerID,
+				Amount:           issue.Difference,
+				Type:             "ADMIN_ADJUSTMENT",
+				ReferenceID:      issue.TopupID,
+				ReferenceType:    &topupType,
+				Description:      fmt.Sprintf("Reconciliation: %s", strings.ToLower(strings.ReplaceAll(issue.Kind, "_", " "))),
+				Metadata:         fmt.Sprintf(`{"reconciliation_run_id": %q, "issue": %q, "topup_id": %q}`, runID, issue.Kind, *issue.TopupID),
+				CreatedByAdminID: run.AdminID,
+			})
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, ErrInsufficientBalance) {
+				issue.FixError = err.Error()
+				continue
+			}
+			return err
+		}
+		issue.Fixed, issue.FixReference = true, &txn.ID
+	}
+	return nil
+}
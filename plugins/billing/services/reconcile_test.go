@@ -0,0 +1,127 @@
+package services
+
+import (
+	"testing"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+func walletTxn(id, txnType string, before, amount money.Amount, topupID string) models.WalletTransaction {
+	t := models.WalletTransaction{
+		ID:            id,
+		Type:          txnType,
+		Amount:        amount,
+		BalanceBefore: before,
+		BalanceAfter:  before + amount,
+	}
+	if topupID != "" {
+		ref := "topup_request"
+		t.ReferenceID, t.ReferenceType = &topupID, &ref
+	}
+	return t
+}
+
+func issueKinds(issues []ReconcileIssue) map[string]int {
+	out := map[string]int{}
+	for _, i := range issues {
+		out[i.Kind]++
+	}
+	return out
+}
+
+func TestReconcileCustomerClean(t *testing.T) {
+	txns := []models.WalletTransaction{
+		walletTxn("t1", "TOPUP", 0, money.FromMajor(100), "p1"),
+		walletTxn("t2", "PURCHASE", money.FromMajor(100), -money.FromMajor(30), ""),
+	}
+	topups := []models.TopupRequest{{ID: "p1", Amount: money.FromMajor(100), Status: "SUCCESS"}}
+	ledger := money.FromMajor(70)
+
+	if issues := reconcileCustomer("c", money.FromMajor(70), txns, topups, &ledger); len(issues) != 0 {
+		t.Fatalf("clean wallet reported %+v", issues)
+	}
+}
+
+func TestReconcileCustomerBalanceAndChain(t *testing.T) {
+	txns := []models.WalletTransaction{
+		walletTxn("t1", "ADMIN_ADJUSTMENT", 0, money.FromMajor(100), ""),
+		walletTxn("t2", "PURCHASE", money.FromMajor(90), -money.FromMajor(30), ""), // before should be 100
+	}
+	ledger := money.FromMajor(80)
+	issues := reconcileCustomer("c", money.FromMajor(75), txns, nil, &ledger)
+
+	kinds := issueKinds(issues)
+	if kinds[IssueBalanceMismatch] != 1 || kinds[IssueChainBreak] != 1 || kinds[IssueLedgerMismatch] != 1 {
+		t.Fatalf("kinds = %v", kinds)
+	}
+	for _, i := range issues {
+		switch i.Kind {
+		case IssueBalanceMismatch:
+			if i.Difference != money.FromMajor(5) || !i.Fixable {
+				t.Errorf("balance mismatch = %+v", i)
+			}
+		case IssueLedgerMismatch:
+			if i.Difference != -money.FromMajor(5) {
+				t.Errorf("ledger mismatch = %+v", i)
+			}
+		case IssueChainBreak:
+			if i.Fixable || *i.TransactionID != "t2" {
+				t.Errorf("chain break = %+v", i)
+			}
+		}
+	}
+}
+
+func TestCheckTopupCredits(t *testing.T) {
+	topups := []models.TopupRequest{
+		{ID: "missing", Amount: money.FromMajor(50), Status: "SUCCESS"},
+		{ID: "twice", Amount: money.FromMajor(20), Status: "SUCCESS"},
+		{ID: "pending", Amount: money.FromMajor(10), Status: "PENDING"},
+		{ID: "repaired", Amount: money.FromMajor(40), Status: "SUCCESS"},
+	}
+	txns := []models.WalletTransaction{
+		walletTxn("t1", "TOPUP", 0, money.FromMajor(20), "twice"),
+		walletTxn("t2", "TOPUP", money.FromMajor(20), money.FromMajor(20), "twice"),
+		walletTxn("t3", "TOPUP", money.FromMajor(40), money.FromMajor(10), "pending"),
+		walletTxn("t4", "TOPUP", money.FromMajor(50), money.FromMajor(5), ""),
+		walletTxn("t5", "TOPUP", money.FromMajor(55), money.FromMajor(5), "gone"),
+		// A previous --fix already made up this one.
+		walletTxn("t6", "ADMIN_ADJUSTMENT", money.FromMajor(60), money.FromMajor(40), "repaired"),
+	}
+	issues := checkTopupCredits("c", txns, topups)
+
+	kinds := issueKinds(issues)
+	if kinds[IssueMissingTopupCredit] != 1 || kinds[IssueDuplicateTopupCredit] != 1 || kinds[IssueOrphanTopupCredit] != 3 {
+		t.Fatalf("kinds = %v", kinds)
+	}
+	for _, i := range issues {
+		switch i.Kind {
+		case IssueMissingTopupCredit:
+			if *i.TopupID != "missing" || i.Difference != money.FromMajor(50) {
+				t.Errorf("missing credit = %+v", i)
+			}
+		case IssueDuplicateTopupCredit:
+			if *i.TopupID != "twice" || i.Difference != -money.FromMajor(20) {
+				t.Errorf("duplicate credit = %+v", i)
+			}
+		case IssueOrphanTopupCredit:
+			if i.Fixable {
+				t.Errorf("orphan credits are left for review: %+v", i)
+			}
+		}
+	}
+}
+
+func TestCheckHeldBalance(t *testing.T) {
+	if issues := checkHeldBalance("c", money.FromMajor(25), money.FromMajor(25)); len(issues) != 0 {
+		t.Fatalf("matching held balance reported %v", issues)
+	}
+	issues := checkHeldBalance("c", money.FromMajor(40), money.FromMajor(25))
+	if len(issues) != 1 || issues[0].Kind != IssueHoldMismatch || !issues[0].Fixable {
+		t.Fatalf("issues = %+v", issues)
+	}
+	if issues[0].Expected != money.FromMajor(25) || issues[0].Difference != -money.FromMajor(15) {
+		t.Errorf("issue = %+v", issues[0])
+	}
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go_framework/internal/events"
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTransactionNotFound    = errors.New("wallet transaction not found")
+	ErrNotRefundable          = errors.New("only PURCHASE and RENEWAL transactions can be refunded")
+	ErrAlreadyRefunded        = errors.New("transaction has been fully refunded")
+	ErrRefundExceedsRemaining = errors.New("refund exceeds the remaining refundable amount")
+)
+
+// Refund kinds
+const (
+	RefundManual       = "MANUAL"
+	RefundProrated     = "PRORATED"
+	RefundDeployFailed = "DEPLOY_FAILED"
+)
+
+// RefundSummary is a charge with what has been and can still be refunded.
+type RefundSummary struct {
+	Transaction models.WalletTransaction `json:"transaction"`
+	Refunded    money.Amount             `json:"refunded"`
+	Refundable  money.Amount             `json:"refundable"`
+	Refunds     []models.WalletRefund    `json:"refunds"`
+}
+
+// isRefundableType reports whether a transaction type is a charge that can
+// be refunded.
+func isRefundableType(txnType string) bool {
+	return txnType == "PURCHASE" || txnType == "RENEWAL"
+}
+
+// refundRemaining is what is left to refund of a charge (a negative
+// amount) after refunds totalling refunded.
+func refundRemaining(charged, refunded money.Amount) money.Amount {
+	remaining := -charged - refunded
+	if remaining.IsNegative() {
+		return 0
+	}
+	return remaining
+}
+
+// checkRefundAmount resolves the amount to refund: zero asks for the whole
+// remainder.
+func checkRefundAmount(amount, remaining money.Amount) (money.Amount, error) {
+	if amount.IsNegative() {
+		return 0, ErrNegativeAmount
+	}
+	if !remaining.IsPositive() {
+		return 0, ErrAlreadyRefunded
+	}
+	if amount.IsZero() {
+		return remaining, nil
+	}
+	if amount > remaining {
+		return 0, ErrRefundExceedsRemaining
+	}
+	return amount, nil
+}
+
+// ProratedRefund is the unused share of a charge for [start, end) when the
+// resource was given up at usedUntil, rounded down in the operator's favour.
+func ProratedRefund(amount money.Amount, start, end, usedUntil time.Time) money.Amount {
+	if !amount.IsPositive() || !end.After(start) || !usedUntil.Before(end) {
+		return 0
+	}
+	if !usedUntil.After(start) {
+		return amount
+	}
+	return amount.MulRat(int64(end.Sub(usedUntil)), int64(end.Sub(start)), money.RoundDown)
+}
+
+// lockRefundable locks a charge so concurrent refunds of it serialize, and
+// returns it with the amount already refunded.
+func lockRefundable(tx *gorm.DB, transactionID string) (*models.WalletTransaction, money.Amount, error) {
+	var txn models.WalletTransaction
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", transactionID).
+		First(&txn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, ErrTransactionNotFound
+		}
+		return nil, 0, err
+	}
+	if !isRefundableType(txn.Type) {
+		return nil, 0, ErrNotRefundable
+	}
+	var refunded money.Amount
+	if err := tx.Model(&models.WalletRefund{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("original_transaction_id = ?", txn.ID).
+		Scan(&refunded).Error; err != nil {
+		return nil, 0, err
+	}
+	return &txn, refunded, nil
+}
+
+// recordRefund credits amount back to the wallet as a REFUND referencing
+// the same resource as the charge, and links the two. extra is merged into
+// the transaction metadata. The charge must be locked with lockRefundable.
+func recordRefund(tx *gorm.DB, original *models.WalletTransaction, amount money.Amount, kind, reason string, adminID *string, extra map[string]string) (*models.WalletRefund, error) {
+	meta := map[string]string{
+		"original_transaction_id": original.ID,
+		"kind":                    kind,
+		"reason":                  reason,
+	}
+	for k, v := range extra {
+		meta[k] = v
+	}
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	wallet := &WalletService{db: tx}
+	txn, err := wallet.RecordTransaction(tx, struct {
+		CustomerID       string
+		Amount           money.Amount
+		Type             string
+		ReferenceID      *string
+		ReferenceType    *string
+		Description      string
+		Metadata         string
+		CreatedByAdminID *string
+	}{
+		CustomerID:       original.CustomerID,
+		Amount:           amount,
+		Type:             "REFUND",
+		ReferenceID:      original.ReferenceID,
+		ReferenceType:    original.ReferenceType,
+		Description:      fmt.Sprintf("Refund: %s", reason),
+		Metadata:         string(metadata),
+		CreatedByAdminID: adminID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &models.WalletRefund{
+		CustomerID:            original.CustomerID,
+		OriginalTransactionID: original.ID,
+		RefundTransactionID:   txn.ID,
+		Amount:                amount,
+		Kind:                  kind,
+		CreatedByAdminID:      adminID,
+	}
+	if reason != "" {
+		refund.Reason = &reason
+	}
+	if err := tx.Create(refund).Error; err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// refundTransaction refunds amount (zero: the whole remainder) of a charge.
+// MUST be called within a transaction context.
+func refundTransaction(tx *gorm.DB, transactionID string, amount money.Amount, kind, reason string, adminID *string) (*models.WalletRefund, error) {
+	original, refunded, err := lockRefundable(tx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	amount, err = checkRefundAmount(amount, refundRemaining(original.Amount, refunded))
+	if err != nil {
+		return nil, err
+	}
+	return recordRefund(tx, original, amount, kind, reason, adminID, nil)
+}
+
+// GetRefundSummary - A charge with its refunds and the remaining refundable
+// amount
+// Usage: Admin (before a manual refund)
+func (s *PurchaseService) GetRefundSummary(transactionID string) (*RefundSummary, error) {
+	var txn models.WalletTransaction
+	if err := s.db.Where("id = ?", transactionID).First(&txn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, err
+	}
+	if !isRefundableType(txn.Type) {
+		return nil, ErrNotRefundable
+	}
+
+	refunds := []models.WalletRefund{}
+	if err := s.db.Where("original_transaction_id = ?", txn.ID).Order("created_at ASC").Find(&refunds).Error; err != nil {
+		return nil, err
+	}
+	var refunded money.Amount
+	for _, r := range refunds {
+		refunded = refunded.Add(r.Amount)
+	}
+	return &RefundSummary{
+		Transaction: txn,
+		Refunded:    refunded,
+		Refundable:  refundRemaining(txn.Amount, refunded),
+		Refunds:     refunds,
+	}, nil
+}
+
+// ProrateDeletedContainer settles a container deleted at deletedAt: its
+// meter is closed at the deletion, and the unused rest of every paid usage
+// charge that reaches past it (the remainder of a started hour) is refunded
+// pro rata. Running it again for the same container refunds nothing more.
+// Usage: events.ContainerDeleted subscriber
+func (s *UsageService) ProrateDeletedContainer(containerID string, deletedAt time.Time, cfg UsageConfig) error {
+	var notices []usageNotice
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var meter models.ContainerMeter
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("container_id = ?", containerID).
+			First(&meter).Error
+		switch {
+		case err == nil:
+			end := deletedAt
+			if deletedAt.Sub(meter.LastSeenAt) > cfg.staleAfter() {
+				end = meter.LastSeenAt // stopped well before the delete
+			}
+			n, err := s.chargeSpan(tx, &meter, meter.MeteredSince, end, &UsageMeterResult{})
+			if err != nil {
+				return err
+			}
+			notices = n
+			if err := tx.Where("container_id = ?", containerID).Delete(&models.ContainerMeter{}).Error; err != nil {
+				return err
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		var usages []models.ContainerUsage
+		if err := tx.Where("container_id = ? AND status = ? AND wallet_transaction_id IS NOT NULL", containerID, UsageBilled).
+			Where("period_start + hours * INTERVAL '1 hour' > ?", deletedAt).
+			Find(&usages).Error; err != nil {
+			return err
+		}
+		for _, u := range usages {
+			paidUntil := u.PeriodStart.Add(time.Duration(u.Hours) * time.Hour)
+			refund := ProratedRefund(u.Amount, u.PeriodStart, paidUntil, deletedAt)
+			if !refund.IsPositive() {
+				continue
+			}
+			original, refunded, err := lockRefundable(tx, *u.WalletTransactionID)
+			if err != nil {
+				return err
+			}
+			var prorated int64
+			if err := tx.Model(&models.WalletRefund{}).
+				Where("original_transaction_id = ? AND kind = ?", original.ID, RefundProrated).
+				Count(&prorated).Error; err != nil {
+				return err
+			}
+			if prorated > 0 {
+				continue
+			}
+			if remaining := refundRemaining(original.Amount, refunded); refund > remaining {
+				refund = remaining // partly refunded by hand already
+			}
+			if !refund.IsPositive() {
+				continue
+			}
+			if _, err := recordRefund(tx, original, refund, RefundProrated, "container deleted before the paid period ended", nil, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, notice := range notices {
+		s.notify(notice, time.Now(), cfg)
+	}
+	return nil
+}
+
+// RegisterRefundSubscribers refunds the unused part of deleted containers.
+func RegisterRefundSubscribers() {
+	events.Subscribe(events.ContainerDeleted, func(ctx context.Context, payload interface{}) {
+		ev, ok := payload.(events.ContainerEvent)
+		if !ok {
+			return
+		}
+		deletedAt := ev.At
+		if deletedAt.IsZero() {
+			deletedAt = time.Now()
+		}
+		svc, err := NewUsageServiceFromDefault()
+		if err != nil {
+			log.Printf("[billing] prorate deleted container=%s: %v", ev.ContainerID, err)
+			return
+		}
+		if err := svc.ProrateDeletedContainer(ev.ContainerID, deletedAt, UsageConfigFromEnv()); err != nil {
+			log.Printf("[billing] prorate deleted container=%s: %v", ev.ContainerID, err)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go_framework/internal/money"
+)
+
+func TestRefundRemaining(t *testing.T) {
+	charged := -money.FromMajor(10000)
+	if got := refundRemaining(charged, 0); got != money.FromMajor(10000) {
+		t.Errorf("nothing refunded: %s", got)
+	}
+	if got := refundRemaining(charged, money.FromMajor(4000)); got != money.FromMajor(6000) {
+		t.Errorf("4000 refunded: %s", got)
+	}
+	if got := refundRemaining(charged, money.FromMajor(12000)); got != 0 {
+		t.Errorf("over-refunded: %s, want 0.00", got)
+	}
+}
+
+func TestCheckRefundAmount(t *testing.T) {
+	remaining := money.FromMajor(6000)
+	cases := []struct {
+		amount, remaining money.Amount
+		want              money.Amount
+		err               error
+	}{
+		{0, remaining, remaining, nil},
+		{money.FromMajor(2500), remaining, money.FromMajor(2500), nil},
+		{remaining, remaining, remaining, nil},
+		{remaining + 1, remaining, 0, ErrRefundExceedsRemaining},
+		{0, 0, 0, ErrAlreadyRefunded},
+		{money.FromMajor(1), 0, 0, ErrAlreadyRefunded},
+		{-money.FromMajor(1), remaining, 0, ErrNegativeAmount},
+	}
+	for _, tc := range cases {
+		got, err := checkRefundAmount(tc.amount, tc.remaining)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("checkRefundAmount(%s, %s) err = %v, want %v", tc.amount, tc.remaining, err, tc.err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("checkRefundAmount(%s, %s) = %s, want %s", tc.amount, tc.remaining, got, tc.want)
+		}
+	}
+}
+
+func TestProratedRefund(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Hour)
+	amount := money.FromMajor(3000)
+
+	if got := ProratedRefund(amount, start, end, start.Add(2*time.Hour+15*time.Minute)); got != money.FromMajor(750) {
+		t.Errorf("45 of 180 minutes unused = %s, want 750.00", got)
+	}
+	if got := ProratedRefund(amount, start, end, end); got != 0 {
+		t.Errorf("fully used = %s", got)
+	}
+	if got := ProratedRefund(amount, start, end, end.Add(time.Minute)); got != 0 {
+		t.Errorf("used past the end = %s", got)
+	}
+	if got := ProratedRefund(amount, start, end, start.Add(-time.Minute)); got != amount {
+		t.Errorf("never used = %s, want %s", got, amount)
+	}
+	// Fractions of a minor unit stay with the operator.
+	if got := ProratedRefund(money.MustParse("0.10"), start, end, start.Add(time.Hour)); got != money.MustParse("0.06") {
+		t.Errorf("2/3 of 0.10 = %s, want 0.06", got)
+	}
+}
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go_framework/internal/db"
+	"go_framework/internal/money"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidReportRange = errors.New("invalid report range")
+
+// Report intervals, as Postgres date_trunc fields. Weeks start on Monday.
+const (
+	ReportDaily   = "day"
+	ReportWeekly  = "week"
+	ReportMonthly = "month"
+)
+
+const (
+	reportDateLayout   = "2006-01-02"
+	defaultReportDays  = 30
+	maxReportDays      = 3 * 366
+	maxReportTopRanked = 100
+)
+
+// ReportRange is a span of whole business days in the billing time zone.
+// From and To bound it as instants: [From, To).
+type ReportRange struct {
+	StartDate string         `json:"start_date"`
+	EndDate   string         `json:"end_date"` // inclusive
+	Interval  string         `json:"interval"`
+	TimeZone  string         `json:"timezone"`
+	From      time.Time      `json:"-"`
+	To        time.Time      `json:"-"`
+	Location  *time.Location `json:"-"`
+}
+
+// ParseReportRange reads start and end business days (YYYY-MM-DD, both
+// inclusive) and a bucket interval. Missing dates default to the 30 days
+// ending today; a missing interval to daily.
+func ParseReportRange(startDate, endDate, interval string, now time.Time, tz string, loc *time.Location) (ReportRange, error) {
+	if interval == "" {
+		interval = ReportDaily
+	}
+	if interval != ReportDaily && interval != ReportWeekly && interval != ReportMonthly {
+		return ReportRange{}, fmt.Errorf("%w: interval must be day, week or month", ErrInvalidReportRange)
+	}
+
+	today := now.In(loc)
+	end := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, loc)
+	if endDate != "" {
+		d, err := time.ParseInLocation(reportDateLayout, endDate, loc)
+		if err != nil {
+			return ReportRange{}, fmt.Errorf("%w: end_date must be YYYY-MM-DD", ErrInvalidReportRange)
+		}
+		end = d
+	}
+	start := end.AddDate(0, 0, -(defaultReportDays - 1))
+	if startDate != "" {
+		d, err := time.ParseInLocation(reportDateLayout, startDate, loc)
+		if err != nil {
+			return ReportRange{}, fmt.Errorf("%w: start_date must be YYYY-MM-DD", ErrInvalidReportRange)
+		}
+		start = d
+	}
+	if end.Before(start) {
+		return ReportRange{}, fmt.Errorf("%w: end_date is before start_date", ErrInvalidReportRange)
+	}
+	to := end.AddDate(0, 0, 1)
+	if to.Sub(start) > maxReportDays*24*time.Hour {
+		return ReportRange{}, fmt.Errorf("%w: span is longer than three years", ErrInvalidReportRange)
+	}
+
+	return ReportRange{
+		StartDate: start.Format(reportDateLayout),
+		EndDate:   end.Format(reportDateLayout),
+		Interval:  interval,
+		TimeZone:  tz,
+		From:      start,
+		To:        to,
+		Location:  loc,
+	}, nil
+}
+
+// NewReportRange is ParseReportRange in the billing time zone as of now.
+func NewReportRange(startDate, endDate, interval string) (ReportRange, error) {
+	return ParseReportRange(startDate, endDate, interval, time.Now(), BillingTimeZone(), InvoiceLocation())
+}
+
+// args are the named SQL parameters every report query shares. Bucketing
+// happens in the database in the business time zone so a day runs from
+// local midnight to midnight.
+func (r ReportRange) args() map[string]interface{} {
+	return map[string]interface{}{
+		"interval": r.Interval,
+		"tz":       r.TimeZone,
+		"from":     r.From,
+		"to":       r.To,
+	}
+}
+
+// TopupVolumeRow is the topups created in one period through one gateway
+// that are in one status.
+type TopupVolumeRow struct {
+	Period      string       `json:"period"`
+	GatewayID   string       `json:"gateway_id"`
+	GatewayName string       `json:"gateway_name"`
+	Status      string       `json:"status"`
+	Count       int64        `json:"count"`
+	Amount      money.Amount `json:"amount"`
+	Fee         money.Amount `json:"fee"`
+	Tax         money.Amount `json:"tax"`
+	TotalPaid   money.Amount `json:"total_paid"`
+}
+
+// RevenueRow is the revenue of one period. Fees are counted when the topup
+// was paid and, as in the ledger, net of inclusive tax (see
+// topupFeeRevenue), so they can be negative when the tax exceeds the fee;
+// Net is fees plus purchases and usage, less refunds. Bonus credit
+// is promotional expense and not deducted. TopupTax is tax collected on paid
+// topups, owed to the tax office and not part of Net.
+type RevenueRow struct {
+	Period    string       `json:"period"`
+	TopupFees money.Amount `json:"topup_fees"`
+	TopupTax  money.Amount `json:"topup_tax"`
+	Purchases money.Amount `json:"purchases"`
+	Usage     money.Amount `json:"usage"`
+	Refunds   money.Amount `json:"refunds"`
+	Bonuses   money.Amount `json:"bonuses"`
+	Net       money.Amount `json:"net"`
+}
+
+// PurchaseRevenueRow is what containers of one template in one region were
+// charged in one period, as setup fees (PURCHASE) and usage (RENEWAL).
+type PurchaseRevenueRow struct {
+	Period       string       `json:"period"`
+	TemplateID   *string      `json:"template_id"`
+	TemplateName string       `json:"template_name"`
+	RegionCode   string       `json:"region_code"`
+	Transactions int64        `json:"transactions"`
+	Purchases    money.Amount `json:"purchases"`
+	Usage        money.Amount `json:"usage"`
+	Total        money.Amount `json:"total"`
+}
+
+// RefundReportRow is the refunds of one kind in one period. Refunds made
+// before refunds were linked to their charge have kind UNLINKED.
+type RefundReportRow struct {
+	Period string       `json:"period"`
+	Kind   string       `json:"kind"`
+	Count  int64        `json:"count"`
+	Amount money.Amount `json:"amount"`
+}
+
+// LiabilityReport is what the platform owes customers in wallet balances.
+type LiabilityReport struct {
+	AsOf      time.Time    `json:"as_of"`
+	Customers int64        `json:"customers"` // with a non-zero balance
+	Liability money.Amount `json:"liability"`
+}
+
+// TopCustomerRow is one customer's wallet activity in the range.
+type TopCustomerRow struct {
+	CustomerID string       `json:"customer_id"`
+	Email      string       `json:"email"`
+	FullName   string       `json:"full_name"`
+	Topups     money.Amount `json:"topups"`
+	Spend      money.Amount `json:"spend"`
+	Refunds    money.Amount `json:"refunds"`
+	Balance    money.Amount `json:"balance"`
+}
+
+type ReportService struct {
+	db *gorm.DB
+}
+
+func NewReportService(gdb *gorm.DB) (*ReportService, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &ReportService{db: gdb}, nil
+}
+
+func NewReportServiceFromDefault() (*ReportService, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewReportService(gdb)
+}
+
+// TopupVolume - Topup count and amounts per period, gateway and status,
+// bucketed by creation time
+// Usage: Admin (finance reports)
+func (s *ReportService) TopupVolume(r ReportRange, filters struct {
+	GatewayID *string
+	Status    *string
+}) ([]TopupVolumeRow, error) {
+	args := r.args()
+	where := "t.created_at >= @from AND t.created_at < @to"
+	if filters.GatewayID != nil {
+		where += " AND t.gateway_id = @gateway_id"
+		args["gateway_id"] = *filters.GatewayID
+	}
+	if filters.Status != nil {
+		where += " AND t.status = @status"
+		args["status"] = *filters.Status
+	}
+
+	rows := []TopupVolumeRow{}
+	err := s.db.Raw(`
+		SELECT to_char(date_trunc(@interval, t.created_at AT TIME ZONE @tz), 'YYYY-MM-DD') AS period,
+			t.gateway_id, g.name AS gateway_name, t.status::text AS status,
+			COUNT(*) AS count,
+			COALESCE(SUM(t.amount), 0) AS amount,
+			COALESCE(SUM(t.fee), 0) AS fee,
+			COALESCE(SUM(t.tax_amount), 0) AS tax,
+			COALESCE(SUM(t.total_paid), 0) AS total_paid
+		FROM topup_requests t
+		JOIN payment_gateways g ON g.id = t.gateway_id
+		WHERE `+where+`
+		GROUP BY 1, 2, 3, 4
+		ORDER BY 1, 3, 4`, args).Scan(&rows).Error
+	return rows, err
+}
+
+// Revenue - Fee, purchase and usage revenue, refunds, bonuses and tax
+// collected per period
+// Usage: Admin (finance reports)
+func (s *ReportService) Revenue(r ReportRange) ([]RevenueRow, error) {
+	rows := []RevenueRow{}
+	err := s.db.Raw(`
+		WITH fees AS (
+			SELECT date_trunc(@interval, paid_at AT TIME ZONE @tz) AS period,
+				SUM(total_paid - amount - tax_amount) AS topup_fees, SUM(tax_amount) AS topup_tax
+			FROM topup_requests
+			WHERE status = 'SUCCESS' AND paid_at >= @from AND paid_at < @to
+			GROUP BY 1
+		), txns AS (
+			SELECT date_trunc(@interval, created_at AT TIME ZONE @tz) AS period,
+				SUM(CASE WHEN type = 'PURCHASE' THEN -amount ELSE 0 END) AS purchases,
+				SUM(CASE WHEN type = 'RENEWAL' THEN -amount ELSE 0 END) AS usage,
+				SUM(CASE WHEN type = 'REFUND' THEN amount ELSE 0 END) AS refunds,
+				SUM(CASE WHEN type = 'BONUS' THEN amount ELSE 0 END) AS bonuses
+			FROM wallet_transactions
+			WHERE type IN ('PURCHASE', 'RENEWAL', 'REFUND', 'BONUS')
+				AND created_at >= @from AND created_at < @to
+			GROUP BY 1
+		)
+		SELECT to_char(COALESCE(f.period, x.period), 'YYYY-MM-DD') AS period,
+			COALESCE(f.topup_fees, 0) AS topup_fees,
+			COALESCE(f.topup_tax, 0) AS topup_tax,
+			COALESCE(x.purchases, 0) AS purchases,
+			COALESCE(x.usage, 0) AS usage,
+			COALESCE(x.refunds, 0) AS refunds,
+			COALESCE(x.bonuses, 0) AS bonuses
+		FROM fees f
+		FULL OUTER JOIN txns x ON x.period = f.period
+		ORDER BY 1`, r.args()).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].Net = rows[i].TopupFees.Add(rows[i].Purchases).Add(rows[i].Usage).Sub(rows[i].Refunds)
+	}
+	return rows, nil
+}
+
+// PurchaseRevenue - Container charges per period, template and region. The
+// template and region are those the container was priced with.
+// Usage: Admin (finance reports)
+func (s *ReportService) PurchaseRevenue(r ReportRange) ([]PurchaseRevenueRow, error) {
+	rows := []PurchaseRevenueRow{}
+	err := s.db.Raw(`
+		SELECT to_char(date_trunc(@interval, w.created_at AT TIME ZONE @tz), 'YYYY-MM-DD') AS period,
+			cp.template_id,
+			COALESCE(t.app_name, '') AS template_name,
+			COALESCE(cp.region_code, '') AS region_code,
+			COUNT(*) AS transactions,
+			SUM(CASE WHEN w.type = 'PURCHASE' THEN -w.amount ELSE 0 END) AS purchases,
+			SUM(CASE WHEN w.type = 'RENEWAL' THEN -w.amount ELSE 0 END) AS usage,
+			SUM(-w.amount) AS total
+		FROM wallet_transactions w
+		LEFT JOIN container_prices cp ON w.reference_type = 'container' AND cp.container_id = w.reference_id
+		LEFT JOIN app_templates t ON t.id = cp.template_id
+		WHERE w.type IN ('PURCHASE', 'RENEWAL')
+			AND w.created_at >= @from AND w.created_at < @to
+		GROUP BY 1, 2, 3, 4
+		ORDER BY 1, total DESC`, r.args()).Scan(&rows).Error
+	return rows, err
+}
+
+// Refunds - Refund count and amount per period and kind
+// Usage: Admin (finance reports)
+func (s *ReportService) Refunds(r ReportRange) ([]RefundReportRow, error) {
+	rows := []RefundReportRow{}
+	err := s.db.Raw(`
+		SELECT to_char(date_trunc(@interval, w.created_at AT TIME ZONE @tz), 'YYYY-MM-DD') AS period,
+			COALESCE(r.kind, 'UNLINKED') AS kind,
+			COUNT(*) AS count,
+			SUM(w.amount) AS amount
+		FROM wallet_transactions w
+		LEFT JOIN wallet_refunds r ON r.refund_transaction_id = w.id
+		WHERE w.type = 'REFUND' AND w.created_at >= @from AND w.created_at < @to
+		GROUP BY 1, 2
+		ORDER BY 1, 2`, r.args()).Scan(&rows).Error
+	return rows, err
+}
+
+// Liability - Wallet balances owed to customers at asOf: each customer's
+// balance after their last transaction before it
+// Usage: Admin (finance reports)
+func (s *ReportService) Liability(asOf time.Time) (*LiabilityReport, error) {
+	report := &LiabilityReport{AsOf: asOf}
+	err := s.db.Raw(`
+		SELECT COUNT(*) FILTER (WHERE b.balance_after <> 0) AS customers,
+			COALESCE(SUM(b.balance_after), 0) AS liability
+		FROM (
+			SELECT DISTINCT ON (customer_id) balance_after
+			FROM wallet_transactions
+			WHERE created_at < ?
+			ORDER BY customer_id, created_at DESC, id DESC
+		) b`, asOf).Scan(report).Error
+	if err != nil {
+		return nil, err
+	}
+	report.AsOf = asOf
+	return report, nil
+}
+
+// TopCustomers - Customers ranked by spend (PURCHASE and RENEWAL, the
+// default) or by topups in the range
+// Usage: Admin (finance reports)
+func (s *ReportService) TopCustomers(r ReportRange, by string, limit int) ([]TopCustomerRow, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > maxReportTopRanked {
+		limit = maxReportTopRanked
+	}
+	order := "spend DESC"
+	if by == "topups" {
+		order = "topups DESC"
+	}
+	args := r.args()
+	args["limit"] = limit
+
+	rows := []TopCustomerRow{}
+	err := s.db.Raw(`
+		SELECT w.customer_id, c.email, c.full_name, c.wallet_balance AS balance,
+			SUM(CASE WHEN w.type = 'TOPUP' THEN w.amount ELSE 0 END) AS topups,
+			SUM(CASE WHEN w.type IN ('PURCHASE', 'RENEWAL') THEN -w.amount ELSE 0 END) AS spend,
+			SUM(CASE WHEN w.type = 'REFUND' THEN w.amount ELSE 0 END) AS refunds
+		FROM wallet_transactions w
+		JOIN customers c ON c.id = w.customer_id
+		WHERE w.created_at >= @from AND w.created_at < @to
+		GROUP BY w.customer_id, c.email, c.full_name, c.wallet_balance
+		ORDER BY `+order+`, w.customer_id
+		LIMIT @limit`, args).Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseReportRange(t *testing.T) {
+	wib := time.FixedZone("WIB", 7*60*60)
+	// 20:00 UTC on 31 March is already 1 April in Jakarta.
+	now := time.Date(2026, 3, 31, 20, 0, 0, 0, time.UTC)
+
+	r, err := ParseReportRange("", "", "", now, "Asia/Jakarta", wib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StartDate != "2026-03-03" || r.EndDate != "2026-04-01" || r.Interval != ReportDaily {
+		t.Errorf("default range = %s..%s %s", r.StartDate, r.EndDate, r.Interval)
+	}
+	if want := time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC); !r.From.Equal(want) {
+		t.Errorf("From = %s, want %s", r.From.UTC(), want)
+	}
+	if want := time.Date(2026, 4, 1, 17, 0, 0, 0, time.UTC); !r.To.Equal(want) {
+		t.Errorf("To = %s, want %s", r.To.UTC(), want)
+	}
+
+	r, err = ParseReportRange("2026-01-01", "2026-01-01", ReportMonthly, now, "Asia/Jakarta", wib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.To.Sub(r.From) != 24*time.Hour {
+		t.Errorf("single day spans %s", r.To.Sub(r.From))
+	}
+
+	for _, tc := range []struct{ start, end, interval string }{
+		{"2026-02-01", "2026-01-01", ""},
+		{"2026-02-30", "", ""},
+		{"", "01/02/2026", ""},
+		{"", "", "year"},
+		{"2020-01-01", "2026-01-01", ""},
+	} {
+		if _, err := ParseReportRange(tc.start, tc.end, tc.interval, now, "Asia/Jakarta", wib); !errors.Is(err, ErrInvalidReportRange) {
+			t.Errorf("ParseReportRange(%q, %q, %q) err = %v", tc.start, tc.end, tc.interval, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go_framework/internal/events"
+	"go_framework/plugins/billing/models"
+)
+
+// FreezeTopups blocks new topup requests for a customer. Pending topups are
+// left alone so payments already in flight can still be credited.
+// Usage: events.CustomerSuspended subscriber
+func (s *WalletService) FreezeTopups(customerID string) error {
+	return s.db.Model(&models.CustomerBalance{}).
+		Where("id = ? AND topups_frozen_at IS NULL", customerID).
+		Update("topups_frozen_at", time.Now()).Error
+}
+
+// UnfreezeTopups re-enables topups for a customer.
+// Usage: events.CustomerReinstated subscriber
+func (s *WalletService) UnfreezeTopups(customerID string) error {
+	return s.db.Model(&models.CustomerBalance{}).
+		Where("id = ?", customerID).
+		Update("topups_frozen_at", nil).Error
+}
+
+// RegisterSuspensionSubscribers freezes and unfreezes topups as customers are
+// suspended and reinstated.
+func RegisterSuspensionSubscribers() {
+	events.Subscribe(events.CustomerSuspended, func(ctx context.Context, payload interface{}) {
+		ev, ok := payload.(events.CustomerEvent)
+		if !ok {
+			return
+		}
+		svc, err := NewWalletServiceFromDefault()
+		if err != nil {
+			log.Printf("[billing] freeze topups customer=%s: %v", ev.CustomerID, err)
+			return
+		}
+		if err := svc.FreezeTopups(ev.CustomerID); err != nil {
+			log.Printf("[billing] freeze topups customer=%s: %v", ev.CustomerID, err)
+		}
+	})
+	events.Subscribe(events.CustomerReinstated, func(ctx context.Context, payload interface{}) {
+		ev, ok := payload.(events.CustomerEvent)
+		if !ok {
+			return
+		}
+		svc, err := NewWalletServiceFromDefault()
+		if err != nil {
+			log.Printf("[billing] unfreeze topups customer=%s: %v", ev.CustomerID, err)
+			return
+		}
+		if err := svc.UnfreezeTopups(ev.CustomerID); err != nil {
+			log.Printf("[billing] unfreeze topups customer=%s: %v", ev.CustomerID, err)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go_framework/internal/db"
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTaxRuleNotFound = errors.New("tax rule not found")
+	ErrInvalidTaxRule  = errors.New("invalid tax rule")
+	ErrTaxRuleInUse    = errors.New("tax rule has been charged; deactivate or end it instead")
+)
+
+// TaxQuote is the tax on one charge.
+type TaxQuote struct {
+	Rule      *models.TaxRule
+	Rate      money.Rate
+	Inclusive bool
+	Amount    money.Amount
+}
+
+// ComputeTax quotes the tax on base under rule. Exclusive tax is added on
+// top of base; inclusive tax is the part of base that is tax,
+// base * rate / (100 + rate). No rule, or an exempt customer, pays nothing.
+func ComputeTax(base money.Amount, rule *models.TaxRule, hasTaxID bool) TaxQuote {
+	if rule == nil || !base.IsPositive() {
+		return TaxQuote{}
+	}
+	q := TaxQuote{Rule: rule, Inclusive: rule.Inclusive}
+	if rule.ExemptWithTaxID && hasTaxID {
+		return q
+	}
+	q.Rate = rule.Rate
+	if rule.Inclusive {
+		q.Amount = base.MulRat(int64(rule.Rate), int64(rule.Rate)+int64(money.FromMajor(100)), money.RoundHalfUp)
+	} else {
+		q.Amount = base.Percent(rule.Rate, money.RoundHalfUp)
+	}
+	return q
+}
+
+// Total is what the customer pays for base including this tax.
+func (q TaxQuote) Total(base money.Amount) money.Amount {
+	if q.Inclusive {
+		return base
+	}
+	return base.Add(q.Amount)
+}
+
+// activeTaxRule is the active rule covering at with the latest start, or
+// nil when no tax applies.
+func activeTaxRule(tx *gorm.DB, at time.Time) (*models.TaxRule, error) {
+	var rule models.TaxRule
+	err := tx.Where("is_active = ? AND effective_from <= ? AND (effective_until IS NULL OR effective_until > ?)", true, at, at).
+		Order("effective_from DESC").
+		Take(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ValidateTaxRule checks a tax rule before saving.
+func ValidateTaxRule(r *models.TaxRule) error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTaxRule)
+	}
+	if r.Rate < 0 || r.Rate > money.Rate(money.FromMajor(100)) {
+		return fmt.Errorf("%w: rate must be between 0 and 100", ErrInvalidTaxRule)
+	}
+	if r.EffectiveFrom.IsZero() {
+		return fmt.Errorf("%w: effective_from is required", ErrInvalidTaxRule)
+	}
+	if r.EffectiveUntil != nil && !r.EffectiveUntil.After(r.EffectiveFrom) {
+		return fmt.Errorf("%w: effective_until must be after effective_from", ErrInvalidTaxRule)
+	}
+	return nil
+}
+
+type TaxService struct {
+	db *gorm.DB
+}
+
+func NewTaxService(gdb *gorm.DB) (*TaxService, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &TaxService{db: gdb}, nil
+}
+
+func NewTaxServiceFromDefault() (*TaxService, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewTaxService(gdb)
+}
+
+// ListTaxRules - List tax rules, latest start first
+// Usage: Admin
+func (s *TaxService) ListTaxRules(filters struct {
+	IsActive *bool
+	Limit    int
+	Offset   int
+}) ([]models.TaxRule, int64, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := s.db.Model(&models.TaxRule{})
+	if filters.IsActive != nil {
+		query = query.Where("is_active = ?", *filters.IsActive)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	rules := []models.TaxRule{}
+	if err := query.Order("effective_from DESC").Limit(limit).Offset(filters.Offset).Find(&rules).Error; err != nil {
+		return nil, 0, err
+	}
+	return rules, total, nil
+}
+
+// GetTaxRule - Get one tax rule
+// Usage: Admin
+func (s *TaxService) GetTaxRule(id string) (*models.TaxRule, error) {
+	var rule models.TaxRule
+	if err := s.db.Where("id = ?", id).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTaxRuleNotFound
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// CreateTaxRule - Create tax rule
+// Usage: Admin only
+func (s *TaxService) CreateTaxRule(rule *models.TaxRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if err := ValidateTaxRule(rule); err != nil {
+		return err
+	}
+	return s.db.Create(rule).Error
+}
+
+// UpdateTaxRule - Replace a tax rule's terms. Topups already created keep
+// the tax they were quoted.
+// Usage: Admin only
+func (s *TaxService) UpdateTaxRule(rule *models.TaxRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if err := ValidateTaxRule(rule); err != nil {
+		return err
+	}
+	current, err := s.GetTaxRule(rule.ID)
+	if err != nil {
+		return err
+	}
+	rule.CreatedAt = current.CreatedAt
+	return s.db.Save(rule).Error
+}
+
+// DeleteTaxRule - Delete a tax rule no topup was taxed under
+// Usage: Admin only
+func (s *TaxService) DeleteTaxRule(id string) error {
+	var count int64
+	if err := s.db.Model(&models.TopupRequest{}).Where("tax_rule_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTaxRuleInUse
+	}
+	res := s.db.Delete(&models.TaxRule{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTaxRuleNotFound
+	}
+	return nil
+}
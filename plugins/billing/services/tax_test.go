@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+func TestComputeTax(t *testing.T) {
+	ppn := &models.TaxRule{ID: "r1", Name: "PPN", Rate: money.Rate(1100)}
+	base := money.MustParse("104000")
+
+	q := ComputeTax(base, ppn, false)
+	if q.Amount != money.MustParse("11440") || q.Total(base) != money.MustParse("115440") || q.Rule != ppn {
+		t.Errorf("exclusive = %+v total %s", q, q.Total(base))
+	}
+
+	inclusive := *ppn
+	inclusive.Inclusive = true
+	q = ComputeTax(money.MustParse("111000"), &inclusive, false)
+	if q.Amount != money.MustParse("11000") || q.Total(money.MustParse("111000")) != money.MustParse("111000") {
+		t.Errorf("inclusive = %+v", q)
+	}
+	// 100000 * 11/111 = 9909.909..., rounded half up to the cent
+	if q := ComputeTax(money.MustParse("100000"), &inclusive, false); q.Amount != money.MustParse("9909.91") {
+		t.Errorf("inclusive rounding = %s", q.Amount)
+	}
+
+	exempt := *ppn
+	exempt.ExemptWithTaxID = true
+	if q := ComputeTax(base, &exempt, true); !q.Amount.IsZero() || q.Rate != 0 || q.Total(base) != base || q.Rule == nil {
+		t.Errorf("exempt = %+v", q)
+	}
+	if q := ComputeTax(base, &exempt, false); q.Amount != money.MustParse("11440") {
+		t.Errorf("exempt rule without tax ID = %+v", q)
+	}
+	if q := ComputeTax(base, nil, false); !q.Amount.IsZero() || q.Total(base) != base {
+		t.Errorf("no rule = %+v", q)
+	}
+}
+
+func TestValidateTaxRule(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := from.Add(-time.Hour)
+	cases := []struct {
+		name string
+		rule models.TaxRule
+		ok   bool
+	}{
+		{"valid", models.TaxRule{Name: "PPN", Rate: 1200, EffectiveFrom: from}, true},
+		{"zero rate", models.TaxRule{Name: "PPN 0", Rate: 0, EffectiveFrom: from}, true},
+		{"no name", models.TaxRule{Rate: 1100, EffectiveFrom: from}, false},
+		{"over 100", models.TaxRule{Name: "PPN", Rate: 10001, EffectiveFrom: from}, false},
+		{"no start", models.TaxRule{Name: "PPN", Rate: 1100}, false},
+		{"ends before start", models.TaxRule{Name: "PPN", Rate: 1100, EffectiveFrom: from, EffectiveUntil: &before}, false},
+	}
+	for _, c := range cases {
+		err := ValidateTaxRule(&c.rule)
+		if c.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+		if !c.ok && !errors.Is(err, ErrInvalidTaxRule) {
+			t.Errorf("%s: err = %v, want ErrInvalidTaxRule", c.name, err)
+		}
+	}
+}
+
+func TestQuoteTopupWholeUnits(t *testing.T) {
+	gateway := &models.PaymentGateway{FeePercentage: money.Rate(250)} // 2.5%
+	ppn := &models.TaxRule{ID: "r1", Name: "PPN", Rate: money.Rate(1100)}
+
+	// 2.5% of 10001 is 250.025; the provider can only bill whole rupiah
+	fee, tax, total, err := quoteTopup(money.MustParse("10001"), gateway, ppn, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee != money.MustParse("250") || tax.Amount != money.MustParse("1128") || total != money.MustParse("11379") {
+		t.Errorf("whole units: fee %s tax %s total %s", fee, tax.Amount, total)
+	}
+	if total.RoundMajor(money.RoundDown) != total || total != money.Sum(money.MustParse("10001"), fee, tax.Amount) {
+		t.Errorf("total %s is not whole or does not add up", total)
+	}
+
+	// Manual gateways keep the exact cents
+	fee, tax, total, err = quoteTopup(money.MustParse("10001"), gateway, ppn, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee != money.MustParse("250.03") || tax.Amount != money.MustParse("1127.61") || total != money.MustParse("11378.64") {
+		t.Errorf("exact: fee %s tax %s total %s", fee, tax.Amount, total)
+	}
+
+	inclusive := *ppn
+	inclusive.Inclusive = true
+	_, _, total, err = quoteTopup(money.MustParse("10001"), gateway, &inclusive, false, true)
+	if err != nil || total != money.MustParse("10251") {
+		t.Errorf("inclusive whole units: total %s err %v", total, err)
+	}
+
+	if _, _, _, err := quoteTopup(money.MustParse("10000.50"), gateway, ppn, false, true); !errors.Is(err, ErrFractionalAmount) {
+		t.Errorf("fractional amount err = %v", err)
+	}
+}
@@ -3,9 +3,12 @@ package services
 import (
 	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"go_framework/internal/db"
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/gateways"
 	"go_framework/plugins/billing/models"
 
 	"gorm.io/gorm"
@@ -20,6 +23,8 @@ var (
 	ErrDuplicateExternalID = errors.New("duplicate external_id detected")
 	ErrTopupAlreadyPaid    = errors.New("topup already paid")
 	ErrInvalidTopupStatus  = errors.New("invalid topup status for this operation")
+	ErrTopupsFrozen        = errors.New("topups are frozen for this account")
+	ErrFractionalAmount    = errors.New("payment gateway only accepts whole amounts")
 )
 
 type TopupService struct {
@@ -46,17 +51,48 @@ func NewTopupServiceFromDefault() (*TopupService, error) {
 	return NewTopupService(gdb)
 }
 
-// CreateTopupRequest - Create new topup request
+// quoteTopup calculates the fee, then tax on the amount plus fee. Providers
+// that only charge whole units get a whole amount, with fee and exclusive tax
+// rounded to whole units, so TotalPaid is exactly what the provider bills.
+func quoteTopup(amount money.Amount, gateway *models.PaymentGateway, rule *models.TaxRule, hasTaxID, wholeUnits bool) (money.Amount, TaxQuote, money.Amount, error) {
+	if wholeUnits && amount.RoundMajor(money.RoundDown) != amount {
+		return 0, TaxQuote{}, 0, ErrFractionalAmount
+	}
+	fee := amount.Percent(gateway.FeePercentage, money.RoundHalfUp) + gateway.FeeFixed
+	if wholeUnits {
+		fee = fee.RoundMajor(money.RoundHalfUp)
+	}
+	tax := ComputeTax(amount+fee, rule, hasTaxID)
+	if wholeUnits && !tax.Inclusive {
+		tax.Amount = tax.Amount.RoundMajor(money.RoundHalfUp)
+	}
+	return fee, tax, tax.Total(amount + fee), nil
+}
+
+// CreateTopupRequest - Create new topup request. A promo code, when given,
+// is reserved for the topup and its bonus credited once it is paid.
 // Usage: Customer (own), Admin (for any customer)
 func (s *TopupService) CreateTopupRequest(input struct {
 	CustomerID string
 	GatewayID  string
-	Amount     float64
+	Amount     money.Amount
+	PromoCode  string
 }) (*models.TopupRequest, error) {
 	if input.Amount <= 0 {
 		return nil, ErrNegativeAmount
 	}
 
+	var customer models.CustomerBalance
+	if err := s.db.Where("id = ?", input.CustomerID).First(&customer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCustomerNotFound
+		}
+		return nil, err
+	}
+	if customer.TopupsFrozenAt != nil {
+		return nil, ErrTopupsFrozen
+	}
+
 	// Verify gateway exists and active
 	var gateway models.PaymentGateway
 	if err := s.db.Where("id = ?", input.GatewayID).First(&gateway).Error; err != nil {
@@ -75,27 +111,93 @@ func (s *TopupService) CreateTopupRequest(input struct {
 		return nil, ErrInvalidAmount
 	}
 
-	// Calculate fee
-	fee := (input.Amount * gateway.FeePercentage / 100) + gateway.FeeFixed
-	totalPaid := input.Amount + fee
+	rule, err := activeTaxRule(s.db, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	fee, tax, totalPaid, err := quoteTopup(input.Amount, &gateway, rule, customer.TaxID != nil, gateways.RequiresWholeUnits(&gateway))
+	if err != nil {
+		return nil, err
+	}
 
-	// Create topup request
+	// Create topup request; it expires if not paid within the gateway's window
+	expiresAt := time.Now().Add(GatewayTopupExpiry(&gateway))
 	topup := &models.TopupRequest{
-		CustomerID: input.CustomerID,
-		GatewayID:  input.GatewayID,
-		Amount:     input.Amount,
-		Fee:        fee,
-		TotalPaid:  totalPaid,
-		Status:     "PENDING",
+		CustomerID:   input.CustomerID,
+		GatewayID:    input.GatewayID,
+		Amount:       input.Amount,
+		Fee:          fee,
+		TotalPaid:    totalPaid,
+		TaxRate:      tax.Rate,
+		TaxInclusive: tax.Inclusive,
+		TaxAmount:    tax.Amount,
+		Status:       "PENDING",
+		ExpiredAt:    &expiresAt,
+	}
+	if tax.Rule != nil {
+		topup.TaxRuleID = &tax.Rule.ID
 	}
 
-	// TODO: If gateway is AUTOMATIC, call payment gateway API to generate payment URL
-	// For now, we'll leave payment_url and external_id empty (manual flow)
+	// Risk rules are checked under the customer row lock so concurrent
+	// attempts see each other. A BLOCK hit refuses the topup; REVIEW hits
+	// create it FLAGGED for an admin.
+	var blocked []riskHit
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		hits, err := assessTopupRisk(tx, input.CustomerID, input.Amount, time.Now())
+		if err != nil {
+			return err
+		}
+		if hit := blockingHit(hits); hit != nil {
+			blocked = hits
+			return fmt.Errorf("%w: %s", ErrTopupLimited, hit.Rule.Name)
+		}
+		if len(hits) > 0 {
+			flagged := RiskFlagged
+			topup.RiskStatus = &flagged
+		}
 
-	if err := s.db.Create(topup).Error; err != nil {
+		var promo *models.Promotion
+		if input.PromoCode != "" {
+			var bonus money.Amount
+			var err error
+			promo, bonus, err = reservePromotion(tx, input.PromoCode, input.CustomerID, input.GatewayID, input.Amount, time.Now())
+			if err != nil {
+				return err
+			}
+			topup.PromotionID = &promo.ID
+			topup.BonusAmount = bonus
+		}
+		if err := tx.Create(topup).Error; err != nil {
+			return err
+		}
+		if err := recordRiskHits(tx, hits, input.CustomerID, &topup.ID, input.Amount); err != nil {
+			return err
+		}
+		if promo == nil {
+			return nil
+		}
+		return tx.Create(&models.PromotionRedemption{
+			PromotionID: promo.ID,
+			CustomerID:  input.CustomerID,
+			TopupID:     topup.ID,
+			BonusAmount: topup.BonusAmount,
+			Status:      RedemptionReserved,
+		}).Error
+	}); err != nil {
+		if len(blocked) > 0 {
+			s.logBlockedTopup(blocked, input.CustomerID, input.Amount)
+		}
 		return nil, err
 	}
 
+	// AUTOMATIC gateways get a payment page from the provider; MANUAL ones
+	// are confirmed by an admin. A FLAGGED topup gets its charge once cleared.
+	if isAutomatic(&gateway) && topup.RiskStatus == nil {
+		if err := s.openCharge(topup, &gateway); err != nil {
+			return nil, err
+		}
+	}
+
 	// Preload gateway info
 	if err := s.db.Preload("Gateway").Where("id = ?", topup.ID).First(topup).Error; err != nil {
 		return nil, err
@@ -160,14 +262,36 @@ func (s *TopupService) ListTopupRequests(filters struct {
 }
 
 // ProcessWebhook - Process payment gateway webhook (Idempotent)
-// Usage: System/Public endpoint (called by payment gateway)
-func (s *TopupService) ProcessWebhook(externalID string, webhookData map[string]interface{}) error {
-	// This is a simplified version - actual implementation depends on gateway
+// Usage: System/Public endpoint (called by payment gateway) after the
+// caller has authenticated the notification. When paidAmount is set it must
+// equal the topup's TotalPaid. webhookData["status"] carries the topup status.
+func (s *TopupService) ProcessWebhook(externalID string, paidAmount *money.Amount, webhookData map[string]interface{}) error {
+	status, ok := webhookData["status"].(string)
+	if !ok {
+		return errors.New("invalid webhook data: missing status")
+	}
+	return s.applyPaymentStatus(externalID, status, paidAmount, "", "", webhookData)
+}
+
+// applyPaymentStatus moves the topup with this external_id to the status
+// reported by its gateway.
+func (s *TopupService) applyPaymentStatus(externalID, status string, paidAmount *money.Amount, method, channel string, webhookData map[string]interface{}) error {
+	return s.transitionTopup("external_id = ?", externalID, status, paidAmount, method, channel, webhookData)
+}
+
+// transitionTopup is the single path for gateway-driven status changes
+// (webhooks, replays, the expiry job). It locks the topup and credits the
+// wallet exactly once on SUCCESS. A SUCCESS is accepted from any unpaid state,
+// since the money has arrived, while every other status only applies to a
+// PENDING topup, so late or repeated notifications cannot reopen or rewrite
+// a finished one. A payment for an account that is closing or closed is not
+// credited: the topup is marked REFUND_DUE for a manual refund instead.
+func (s *TopupService) transitionTopup(cond string, arg interface{}, status string, paidAmount *money.Amount, method, channel string, webhookData map[string]interface{}) error {
+	var invoice *models.Invoice
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// Find topup by external_id
 		var topup models.TopupRequest
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("external_id = ?", externalID).
+			Where(cond, arg).
 			First(&topup).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return ErrTopupNotFound
@@ -179,23 +303,41 @@ func (s *TopupService) ProcessWebhook(externalID string, webhookData map[string]
 		if topup.Status == "SUCCESS" {
 			return nil // Already processed
 		}
+		if status != "SUCCESS" && topup.Status != "PENDING" {
+			return nil
+		}
 
-		// Check if webhook indicates success payment
-		// TODO: Parse webhook data based on gateway type
-		status, ok := webhookData["status"].(string)
-		if !ok {
-			return errors.New("invalid webhook data: missing status")
+		if paidAmount != nil && *paidAmount != topup.TotalPaid {
+			return fmt.Errorf("%w: got %s, expected %s", ErrAmountMismatch, paidAmount, topup.TotalPaid)
+		}
+		if status == "SUCCESS" {
+			closed, err := customerClosed(tx, topup.CustomerID)
+			if err != nil {
+				return err
+			}
+			if closed {
+				status = "REFUND_DUE"
+				log.Printf("[billing] topup=%s paid after account closure of customer=%s, refund due", topup.ID, topup.CustomerID)
+			}
 		}
 
 		// Update topup request
 		now := time.Now()
 		updateData := map[string]interface{}{
-			"status":       status,
-			"webhook_data": webhookData,
-			"updated_at":   now,
+			"status":     status,
+			"updated_at": now,
+		}
+		if webhookData != nil {
+			updateData["webhook_data"] = webhookData
+		}
+		if method != "" {
+			updateData["payment_method"] = method
+		}
+		if channel != "" {
+			updateData["payment_channel"] = channel
 		}
 
-		if status == "SUCCESS" {
+		if status == "SUCCESS" || status == "REFUND_DUE" {
 			updateData["paid_at"] = now
 		}
 
@@ -205,11 +347,15 @@ func (s *TopupService) ProcessWebhook(externalID string, webhookData map[string]
 
 		// If payment successful, credit wallet
 		if status == "SUCCESS" {
+			reference := topup.ID
+			if topup.ExternalID != nil {
+				reference = *topup.ExternalID
+			}
 			referenceID := topup.ID
 			referenceType := "topup_request"
 			_, err := s.walletService.RecordTransaction(tx, struct {
 				CustomerID       string
-				Amount           float64
+				Amount           money.Amount
 				Type             string
 				ReferenceID      *string
 				ReferenceType    *string
@@ -222,81 +368,154 @@ func (s *TopupService) ProcessWebhook(externalID string, webhookData map[string]
 				Type:             "TOPUP",
 				ReferenceID:      &referenceID,
 				ReferenceType:    &referenceType,
-				Description:      fmt.Sprintf("Top-up via %s", externalID),
+				Description:      fmt.Sprintf("Top-up via %s", reference),
 				Metadata:         fmt.Sprintf(`{"topup_id": "%s", "gateway_id": "%s"}`, topup.ID, topup.GatewayID),
 				CreatedByAdminID: nil,
 			})
 			if err != nil {
 				return err
 			}
+
+			if err := applyPromotionBonus(tx, s.walletService, &topup, nil, now); err != nil {
+				return err
+			}
+
+			invoice, err = issueInvoice(tx, &topup, now)
+			if err != nil {
+				return err
+			}
+		} else if status != "PENDING" {
+			if err := releasePromotion(tx, topup.ID); err != nil {
+				return err
+			}
 		}
 
 		return nil
 	})
-
+	if err == nil {
+		deliverInvoiceAsync(invoice)
+	}
 	return err
 }
 
+// customerClosed reports whether a customer's account is being or has been
+// closed, after which its wallet must not be credited.
+func customerClosed(tx *gorm.DB, customerID string) (bool, error) {
+	var statuses []string
+	if err := tx.Table("customers").Where("id = ?", customerID).Pluck("status", &statuses).Error; err != nil {
+		return false, err
+	}
+	return len(statuses) > 0 && (statuses[0] == "CLOSING" || statuses[0] == "CLOSED"), nil
+}
+
 // ManualConfirmation - Admin manually confirm payment (for manual transfer)
 // Usage: Admin only
 func (s *TopupService) ManualConfirmation(adminID, topupID, notes string) error {
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		var topup models.TopupRequest
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("id = ?", topupID).
-			First(&topup).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return ErrTopupNotFound
-			}
-			return err
-		}
+	var invoice *models.Invoice
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoice, err = s.confirmManualTopup(tx, adminID, topupID, notes)
+		return err
+	})
+	if err == nil {
+		deliverInvoiceAsync(invoice)
+	}
+	return err
+}
 
-		// Only PENDING can be confirmed
-		if topup.Status != "PENDING" {
-			return ErrInvalidTopupStatus
+// confirmManualTopup marks a manual topup paid, credits the wallet and issues
+// its invoice. Payment proofs still awaiting review are approved with it.
+func (s *TopupService) confirmManualTopup(tx *gorm.DB, adminID, topupID, notes string) (*models.Invoice, error) {
+	var topup models.TopupRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", topupID).
+		First(&topup).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTopupNotFound
 		}
+		return nil, err
+	}
 
-		// Update status to SUCCESS
-		now := time.Now()
-		if err := tx.Model(&topup).Updates(map[string]interface{}{
-			"status":     "SUCCESS",
-			"paid_at":    now,
-			"notes":      notes,
-			"updated_at": now,
-		}).Error; err != nil {
-			return err
-		}
+	// Only PENDING can be confirmed; an EXPIRED one too, for transfers
+	// that arrive after the payment window
+	if topup.Status != "PENDING" && topup.Status != "EXPIRED" {
+		return nil, ErrInvalidTopupStatus
+	}
+	if topup.RiskStatus != nil && *topup.RiskStatus == RiskFlagged {
+		return nil, ErrTopupUnderReview
+	}
 
-		// Credit wallet
-		referenceID := topup.ID
-		referenceType := "topup_request"
-		_, err := s.walletService.RecordTransaction(tx, struct {
-			CustomerID       string
-			Amount           float64
-			Type             string
-			ReferenceID      *string
-			ReferenceType    *string
-			Description      string
-			Metadata         string
-			CreatedByAdminID *string
-		}{
-			CustomerID:       topup.CustomerID,
-			Amount:           topup.Amount,
-			Type:             "TOPUP",
-			ReferenceID:      &referenceID,
-			ReferenceType:    &referenceType,
-			Description:      fmt.Sprintf("Manual top-up confirmation (Admin: %s)", adminID),
-			Metadata:         fmt.Sprintf(`{"topup_id": "%s", "confirmed_by": "%s", "notes": "%s"}`, topup.ID, adminID, notes),
-			CreatedByAdminID: &adminID,
-		})
+	// Update status to SUCCESS
+	now := time.Now()
+	if err := tx.Model(&topup).Updates(map[string]interface{}{
+		"status":     "SUCCESS",
+		"paid_at":    now,
+		"notes":      notes,
+		"updated_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
 
-		return err
+	// Credit wallet
+	referenceID := topup.ID
+	referenceType := "topup_request"
+	_, err := s.walletService.RecordTransaction(tx, struct {
+		CustomerID       string
+		Amount           money.Amount
+		Type             string
+		ReferenceID      *string
+		ReferenceType    *string
+		Description      string
+		Metadata         string
+		CreatedByAdminID *string
+	}{
+		CustomerID:       topup.CustomerID,
+		Amount:           topup.Amount,
+		Type:             "TOPUP",
+		ReferenceID:      &referenceID,
+		ReferenceType:    &referenceType,
+		Description:      fmt.Sprintf("Manual top-up confirmation (Admin: %s)", adminID),
+		Metadata:         fmt.Sprintf(`{"topup_id": "%s", "confirmed_by": "%s", "notes": "%s"}`, topup.ID, adminID, notes),
+		CreatedByAdminID: &adminID,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyPromotionBonus(tx, s.walletService, &topup, &adminID, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&models.PaymentProof{}).
+		Where("topup_id = ? AND status = ?", topup.ID, ProofPending).
+		Updates(map[string]interface{}{
+			"status":      ProofApproved,
+			"reviewed_by": adminID,
+			"reviewed_at": now,
+			"updated_at":  now,
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	return issueInvoice(tx, &topup, now)
 }
 
 // CancelTopup - Cancel pending topup
 // Usage: Customer (own PENDING), Admin (any PENDING)
+// Automatic payments are closed at the provider first so they cannot be paid
+// after the cancellation.
 func (s *TopupService) CancelTopup(topupID string) error {
+	current, err := s.GetTopupDetail(topupID)
+	if err != nil {
+		return err
+	}
+	if current.Status != "PENDING" {
+		return ErrInvalidTopupStatus
+	}
+	if err := cancelCharge(current); err != nil {
+		return err
+	}
+
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		var topup models.TopupRequest
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
@@ -313,10 +532,13 @@ func (s *TopupService) CancelTopup(topupID string) error {
 			return ErrInvalidTopupStatus
 		}
 
-		return tx.Model(&topup).Updates(map[string]interface{}{
+		if err := tx.Model(&topup).Updates(map[string]interface{}{
 			"status":     "CANCELLED",
 			"updated_at": time.Now(),
-		}).Error
+		}).Error; err != nil {
+			return err
+		}
+		return releasePromotion(tx, topup.ID)
 	})
 }
 
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go_framework/internal/scheduler"
+	"go_framework/plugins/billing/gateways"
+	"go_framework/plugins/billing/models"
+)
+
+// TopupExpiryJob is the scheduler name of the pending-topup sweep.
+const TopupExpiryJob = "billing.topup-expiry"
+
+const (
+	defaultTopupSweepInterval = 5 * time.Minute
+	topupSweepBatch           = 200
+)
+
+// TopupSweepResult counts what one sweep did.
+type TopupSweepResult struct {
+	Checked  int `json:"checked"`
+	Credited int `json:"credited"`
+	Expired  int `json:"expired"`
+	Failed   int `json:"failed"`
+	Skipped  int `json:"skipped"` // provider unreachable or still settling; retried next run
+}
+
+// RegisterTopupExpiryJob schedules ExpireOverdueTopups every
+// BILLING_TOPUP_SWEEP_INTERVAL (default 5m).
+func RegisterTopupExpiryJob() {
+	interval := defaultTopupSweepInterval
+	if v := strings.TrimSpace(os.Getenv("BILLING_TOPUP_SWEEP_INTERVAL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		}
+	}
+	scheduler.Register(scheduler.Job{
+		Name:     TopupExpiryJob,
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			svc, err := NewTopupServiceFromDefault()
+			if err != nil {
+				return err
+			}
+			res, err := svc.ExpireOverdueTopups(ctx, time.Now(), topupSweepBatch)
+			if err != nil {
+				return err
+			}
+			if res.Checked > 0 {
+				log.Printf("billing: topup sweep checked=%d credited=%d expired=%d failed=%d skipped=%d",
+					res.Checked, res.Credited, res.Expired, res.Failed, res.Skipped)
+			}
+			return nil
+		},
+	})
+}
+
+// ExpireOverdueTopups settles PENDING topups past their expiry; FLAGGED ones
+// wait for risk review instead. Topups of
+// AUTOMATIC gateways are first checked with the provider: a payment whose
+// webhook never arrived is credited, and an open charge is cancelled before
+// the topup is expired. Every change goes through transitionTopup, so a
+// webhook racing the sweep cannot double-credit.
+func (s *TopupService) ExpireOverdueTopups(ctx context.Context, now time.Time, limit int) (*TopupSweepResult, error) {
+	if limit <= 0 {
+		limit = topupSweepBatch
+	}
+	var topups []models.TopupRequest
+	if err := s.db.Preload("Gateway").
+		Where("status = ?", "PENDING").
+		Where("risk_status IS DISTINCT FROM ?", RiskFlagged).
+		Where("(expired_at IS NOT NULL AND expired_at <= ?) OR (expired_at IS NULL AND created_at <= ?)",
+			now, now.Add(-TopupExpiry())).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&topups).Error; err != nil {
+		return nil, err
+	}
+
+	res := &TopupSweepResult{}
+	for i := range topups {
+		if ctx.Err() != nil {
+			break
+		}
+		res.Checked++
+		status, err := s.settleOverdueTopup(ctx, &topups[i])
+		switch {
+		case err != nil:
+			log.Printf("billing: topup sweep id=%s: %v", topups[i].ID, err)
+			res.Skipped++
+		case status == "SUCCESS":
+			res.Credited++
+		case status == "EXPIRED":
+			res.Expired++
+		case status == "FAILED":
+			res.Failed++
+		default:
+			res.Skipped++
+		}
+	}
+	return res, nil
+}
+
+// settleOverdueTopup returns the status it applied, or "" when the topup was
+// left for a later run.
+func (s *TopupService) settleOverdueTopup(ctx context.Context, topup *models.TopupRequest) (string, error) {
+	if topup.ExternalID == nil || !isAutomatic(topup.Gateway) {
+		return "EXPIRED", s.transitionTopup("id = ?", topup.ID, "EXPIRED", nil, "", "", nil)
+	}
+
+	driver, err := gateways.ForGateway(topup.Gateway)
+	if err != nil {
+		return "", err
+	}
+	externalID := *topup.ExternalID
+
+	result, err := driver.QueryStatus(ctx, topup.Gateway, externalID)
+	if errors.Is(err, gateways.ErrChargeNotFound) {
+		// Never opened at the provider, so it cannot have been paid.
+		return "EXPIRED", s.transitionTopup("id = ?", topup.ID, "EXPIRED", nil, "", "", nil)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch result.Status {
+	case gateways.StatusSuccess, gateways.StatusFailed, gateways.StatusExpired:
+		return result.Status, s.applyPaymentStatus(externalID, result.Status, result.PaidAmount, "", "", result.Data)
+	}
+
+	// Still open at the provider: close it first so it cannot be paid after
+	// we expire it. If that fails (e.g. it was paid a moment ago) the next
+	// run sees the new state.
+	if err := driver.Cancel(ctx, topup.Gateway, externalID); err != nil && !errors.Is(err, gateways.ErrChargeNotFound) {
+		return "", err
+	}
+	return "EXPIRED", s.transitionTopup("id = ?", topup.ID, "EXPIRED", nil, "", "", result.Data)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go_framework/plugins/billing/gateways"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrGatewayUnavailable = errors.New("payment gateway request failed")
+
+const defaultTopupExpiry = 24 * time.Hour
+
+// gatewayCallTimeout bounds a single provider round trip made on behalf of
+// an API request.
+const gatewayCallTimeout = 20 * time.Second
+
+// TopupExpiry is how long a topup stays payable unless its gateway says
+// otherwise (BILLING_TOPUP_EXPIRY, a Go duration such as "24h").
+func TopupExpiry() time.Duration {
+	if v := strings.TrimSpace(os.Getenv("BILLING_TOPUP_EXPIRY")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultTopupExpiry
+}
+
+// GatewayTopupExpiry is the payment window of a gateway: its config
+// "topup_expiry" (a Go duration) or TopupExpiry.
+func GatewayTopupExpiry(g *models.PaymentGateway) time.Duration {
+	if v := gateways.ConfigString(g, "topup_expiry"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return TopupExpiry()
+}
+
+func isAutomatic(g *models.PaymentGateway) bool {
+	return g != nil && strings.EqualFold(g.GatewayType, "AUTOMATIC")
+}
+
+// openCharge asks the gateway's driver for a payment page and stores the
+// resulting external_id, payment_url and expiry on the topup. The topup's own
+// ID is used as the provider reference. A rejected charge marks the topup
+// FAILED so it does not linger as PENDING.
+func (s *TopupService) openCharge(topup *models.TopupRequest, gateway *models.PaymentGateway) error {
+	driver, err := gateways.ForGateway(gateway)
+	if err != nil {
+		return err
+	}
+
+	var customer struct {
+		Email    string
+		FullName string
+	}
+	if err := s.db.Table("customers").Select(contactEmailColumn+", full_name").Where("id = ?", topup.CustomerID).Take(&customer).Error; err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gatewayCallTimeout)
+	defer cancel()
+	charge, err := driver.CreateCharge(ctx, gateway, gateways.ChargeRequest{
+		ExternalID:    topup.ID,
+		Amount:        topup.TotalPaid,
+		Description:   fmt.Sprintf("Wallet top-up %s", topup.Amount),
+		CustomerName:  customer.FullName,
+		CustomerEmail: customer.Email,
+		SuccessURL:    os.Getenv("BILLING_TOPUP_RETURN_URL"),
+		Expiry:        chargeExpiry(topup, gateway),
+	})
+	if err != nil {
+		note := err.Error()
+		if uerr := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(topup).Updates(map[string]interface{}{
+				"status":     "FAILED",
+				"notes":      note,
+				"updated_at": time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+			return releasePromotion(tx, topup.ID)
+		}); uerr != nil {
+			return fmt.Errorf("%w: %v (marking topup failed: %v)", ErrGatewayUnavailable, err, uerr)
+		}
+		return fmt.Errorf("%w: %v", ErrGatewayUnavailable, err)
+	}
+
+	externalID := charge.ExternalID
+	paymentURL := charge.PaymentURL
+	updates := map[string]interface{}{
+		"external_id": externalID,
+		"payment_url": paymentURL,
+		"updated_at":  time.Now(),
+	}
+	if charge.ExpiresAt != nil {
+		updates["expired_at"] = *charge.ExpiresAt
+	}
+	return s.db.Model(topup).Updates(updates).Error
+}
+
+func chargeExpiry(topup *models.TopupRequest, gateway *models.PaymentGateway) time.Duration {
+	if topup.ExpiredAt != nil {
+		if d := time.Until(*topup.ExpiredAt); d > 0 {
+			return d
+		}
+	}
+	return GatewayTopupExpiry(gateway)
+}
+
+// cancelCharge closes the payment at the provider so it can no longer be
+// paid. Charges the provider never saw count as cancelled.
+func cancelCharge(topup *models.TopupRequest) error {
+	if topup.ExternalID == nil || !isAutomatic(topup.Gateway) {
+		return nil
+	}
+	driver, err := gateways.ForGateway(topup.Gateway)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gatewayCallTimeout)
+	defer cancel()
+	if err := driver.Cancel(ctx, topup.Gateway, *topup.ExternalID); err != nil && !errors.Is(err, gateways.ErrChargeNotFound) {
+		return fmt.Errorf("%w: %v", ErrGatewayUnavailable, err)
+	}
+	return nil
+}
@@ -0,0 +1,586 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go_framework/internal/db"
+	"go_framework/internal/money"
+	appuuid "go_framework/internal/uuid"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTopupLimited       = errors.New("topup refused by account limits")
+	ErrTopupUnderReview   = errors.New("topup is awaiting risk review")
+	ErrTopupNotFlagged    = errors.New("topup is not awaiting risk review")
+	ErrRiskRuleNotFound   = errors.New("risk rule not found")
+	ErrInvalidRiskRule    = errors.New("invalid risk rule")
+	ErrRiskReasonRequired = errors.New("a rejection reason is required")
+)
+
+// Risk rule kinds
+const (
+	RiskMaxPending      = "MAX_PENDING"
+	RiskDailyAmount     = "DAILY_AMOUNT"
+	RiskMonthlyAmount   = "MONTHLY_AMOUNT"
+	RiskAccountAge      = "ACCOUNT_AGE"
+	RiskUnverifiedEmail = "UNVERIFIED_EMAIL"
+	RiskFailedTopups    = "FAILED_TOPUPS"
+)
+
+// Risk rule actions
+const (
+	RiskBlock  = "BLOCK"
+	RiskReview = "REVIEW"
+)
+
+// Topup risk statuses
+const (
+	RiskFlagged  = "FLAGGED"
+	RiskCleared  = "CLEARED"
+	RiskRejected = "REJECTED"
+)
+
+// defaultFailureWindow is the FAILED_TOPUPS window when a rule sets none.
+const defaultFailureWindow = 24
+
+// riskFacts is what the rules are checked against for one topup attempt.
+type riskFacts struct {
+	Pending       int64
+	DayAmount     money.Amount // created today, PENDING or SUCCESS
+	MonthAmount   money.Amount // created this month, PENDING or SUCCESS
+	AccountAge    time.Duration
+	EmailVerified bool
+	Failures      map[int]int64 // FAILED or EXPIRED topups by window in hours
+}
+
+// riskHit is one rule tripped by a topup attempt.
+type riskHit struct {
+	Rule   models.TopupRiskRule
+	Detail string
+}
+
+func ruleWindow(r *models.TopupRiskRule, def int) int {
+	if r.WindowHours != nil && *r.WindowHours > 0 {
+		return *r.WindowHours
+	}
+	return def
+}
+
+func ruleMaxAmount(r *models.TopupRiskRule) money.Amount {
+	if r.MaxAmount != nil {
+		return *r.MaxAmount
+	}
+	return 0
+}
+
+func ruleMaxCount(r *models.TopupRiskRule) int64 {
+	if r.MaxCount != nil {
+		return int64(*r.MaxCount)
+	}
+	return 0
+}
+
+// checkRiskRule reports whether a topup of amount trips rule given facts,
+// with a description of what was observed.
+func checkRiskRule(rule *models.TopupRiskRule, facts riskFacts, amount money.Amount) (string, bool) {
+	switch rule.Kind {
+	case RiskMaxPending:
+		if limit := ruleMaxCount(rule); facts.Pending >= limit {
+			return fmt.Sprintf("%d pending topups open (limit %d)", facts.Pending, limit), true
+		}
+	case RiskDailyAmount:
+		if total, limit := facts.DayAmount.Add(amount), ruleMaxAmount(rule); total > limit {
+			return fmt.Sprintf("%s today including this topup (limit %s)", total, limit), true
+		}
+	case RiskMonthlyAmount:
+		if total, limit := facts.MonthAmount.Add(amount), ruleMaxAmount(rule); total > limit {
+			return fmt.Sprintf("%s this month including this topup (limit %s)", total, limit), true
+		}
+	case RiskAccountAge:
+		window := time.Duration(ruleWindow(rule, 0)) * time.Hour
+		if limit := ruleMaxAmount(rule); facts.AccountAge < window && amount > limit {
+			return fmt.Sprintf("account is %dh old, topup %s (limit %s within %s)",
+				int(facts.AccountAge.Hours()), amount, limit, window), true
+		}
+	case RiskUnverifiedEmail:
+		if limit := ruleMaxAmount(rule); !facts.EmailVerified && amount > limit {
+			return fmt.Sprintf("email not verified, topup %s (limit %s)", amount, limit), true
+		}
+	case RiskFailedTopups:
+		window := ruleWindow(rule, defaultFailureWindow)
+		if n, limit := facts.Failures[window], ruleMaxCount(rule); n >= limit {
+			return fmt.Sprintf("%d failed or expired topups in %dh (limit %d)", n, window, limit), true
+		}
+	}
+	return "", false
+}
+
+// evaluateRiskRules returns every active rule a topup of amount trips.
+func evaluateRiskRules(rules []models.TopupRiskRule, facts riskFacts, amount money.Amount) []riskHit {
+	var hits []riskHit
+	for i := range rules {
+		if !rules[i].IsActive {
+			continue
+		}
+		if detail, hit := checkRiskRule(&rules[i], facts, amount); hit {
+			hits = append(hits, riskHit{Rule: rules[i], Detail: detail})
+		}
+	}
+	return hits
+}
+
+// blockingHit is the first BLOCK hit, or nil when the topup may proceed.
+func blockingHit(hits []riskHit) *riskHit {
+	for i := range hits {
+		if hits[i].Rule.Action == RiskBlock {
+			return &hits[i]
+		}
+	}
+	return nil
+}
+
+// startOfDay and startOfMonth are the boundaries of now's day and month in
+// the business time zone.
+func startOfDay(now time.Time) time.Time {
+	t := now.In(InvoiceLocation())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(now time.Time) time.Time {
+	t := now.In(InvoiceLocation())
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// assessTopupRisk checks the active rules for a topup of amount. The
+// customer row is locked so concurrent attempts are counted one at a time.
+// MUST be called within a transaction context.
+func assessTopupRisk(tx *gorm.DB, customerID string, amount money.Amount, now time.Time) ([]riskHit, error) {
+	var rules []models.TopupRiskRule
+	if err := tx.Where("is_active = ?", true).Order("created_at ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var account struct {
+		CreatedAt       time.Time
+		EmailVerifiedAt *time.Time
+		AccountType     string
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Table("customers").
+		Select("created_at, email_verified_at, account_type").
+		Where("id = ?", customerID).
+		Take(&account).Error; err != nil {
+		return nil, err
+	}
+	facts := riskFacts{
+		AccountAge: now.Sub(account.CreatedAt),
+		// Organization accounts have no mailbox of their own.
+		EmailVerified: account.EmailVerifiedAt != nil || account.AccountType == "ORGANIZATION",
+		Failures:      map[int]int64{},
+	}
+
+	topups := func() *gorm.DB {
+		return tx.Model(&models.TopupRequest{}).Where("customer_id = ?", customerID)
+	}
+	for i := range rules {
+		var err error
+		switch rules[i].Kind {
+		case RiskMaxPending:
+			err = topups().Where("status = ?", "PENDING").Count(&facts.Pending).Error
+		case RiskDailyAmount:
+			err = topups().Select("COALESCE(SUM(amount), 0)").
+				Where("status IN ? AND created_at >= ?", []string{"PENDING", "SUCCESS"}, startOfDay(now)).
+				Scan(&facts.DayAmount).Error
+		case RiskMonthlyAmount:
+			err = topups().Select("COALESCE(SUM(amount), 0)").
+				Where("status IN ? AND created_at >= ?", []string{"PENDING", "SUCCESS"}, startOfMonth(now)).
+				Scan(&facts.MonthAmount).Error
+		case RiskFailedTopups:
+			window := ruleWindow(&rules[i], defaultFailureWindow)
+			if _, ok := facts.Failures[window]; ok {
+				continue
+			}
+			var n int64
+			err = topups().Where("status IN ? AND created_at >= ?", []string{"FAILED", "EXPIRED"},
+				now.Add(-time.Duration(window)*time.Hour)).Count(&n).Error
+			facts.Failures[window] = n
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return evaluateRiskRules(rules, facts, amount), nil
+}
+
+// recordRiskHits stores the hits of one topup attempt; topupID is nil when
+// it was blocked.
+func recordRiskHits(tx *gorm.DB, hits []riskHit, customerID string, topupID *string, amount money.Amount) error {
+	for i := range hits {
+		rule := hits[i].Rule
+		hit := &models.TopupRiskHit{
+			RuleID:     &rule.ID,
+			RuleName:   rule.Name,
+			Kind:       rule.Kind,
+			Action:     rule.Action,
+			CustomerID: customerID,
+			TopupID:    topupID,
+			Amount:     amount,
+			Detail:     hits[i].Detail,
+		}
+		if err := tx.Create(hit).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListFlaggedTopups - Risk review queue: topups by risk status, oldest
+// FLAGGED first by default
+// Usage: Admin only
+func (s *TopupService) ListFlaggedTopups(filters struct {
+	RiskStatus *string
+	CustomerID *string
+	Limit      int
+	Offset     int
+}) ([]models.TopupRequest, int64, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	status := RiskFlagged
+	if filters.RiskStatus != nil {
+		status = strings.ToUpper(*filters.RiskStatus)
+	}
+	query := s.db.Model(&models.TopupRequest{}).Where("risk_status = ?", status)
+	if status == RiskFlagged {
+		query = query.Where("status = ?", "PENDING")
+	}
+	if filters.CustomerID != nil {
+		if !appuuid.Valid(*filters.CustomerID) {
+			return []models.TopupRequest{}, 0, nil
+		}
+		query = query.Where("customer_id = ?", *filters.CustomerID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "created_at DESC"
+	if status == RiskFlagged {
+		order = "created_at ASC"
+	}
+	var topups []models.TopupRequest
+	if err := query.Preload("Gateway").Order(order).Limit(limit).Offset(filters.Offset).Find(&topups).Error; err != nil {
+		return nil, 0, err
+	}
+	return topups, total, nil
+}
+
+// ClearFlaggedTopup - Let a FLAGGED topup proceed. Its payment window
+// restarts, and an AUTOMATIC gateway's charge is opened now.
+// Usage: Admin only
+func (s *TopupService) ClearFlaggedTopup(adminID, topupID, notes string) (*models.TopupRequest, error) {
+	var topup models.TopupRequest
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockFlaggedTopup(tx, topupID, &topup); err != nil {
+			return err
+		}
+		if err := tx.Preload("Gateway").Where("id = ?", topup.ID).First(&topup).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		updates := map[string]interface{}{
+			"risk_status":      RiskCleared,
+			"risk_reviewed_by": adminID,
+			"risk_reviewed_at": now,
+			"expired_at":       now.Add(GatewayTopupExpiry(topup.Gateway)),
+			"updated_at":       now,
+		}
+		if err := tx.Model(&topup).Updates(updates).Error; err != nil {
+			return err
+		}
+		return writeAudit(tx, &adminID, "billing.topup_risk.cleared", "topup_request", topup.ID, map[string]interface{}{
+			"customer_id": topup.CustomerID,
+			"amount":      topup.Amount,
+			"notes":       notes,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if isAutomatic(topup.Gateway) {
+		if err := s.openCharge(&topup, topup.Gateway); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetTopupDetail(topup.ID)
+}
+
+// RejectFlaggedTopup - Cancel a FLAGGED topup with a reason
+// Usage: Admin only
+func (s *TopupService) RejectFlaggedTopup(adminID, topupID, reason string) (*models.TopupRequest, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrRiskReasonRequired
+	}
+	var topup models.TopupRequest
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockFlaggedTopup(tx, topupID, &topup); err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&topup).Updates(map[string]interface{}{
+			"status":           "CANCELLED",
+			"risk_status":      RiskRejected,
+			"risk_reviewed_by": adminID,
+			"risk_reviewed_at": now,
+			"notes":            reason,
+			"updated_at":       now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := releasePromotion(tx, topup.ID); err != nil {
+			return err
+		}
+		return writeAudit(tx, &adminID, "billing.topup_risk.rejected", "topup_request", topup.ID, map[string]interface{}{
+			"customer_id": topup.CustomerID,
+			"amount":      topup.Amount,
+			"reason":      reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetTopupDetail(topup.ID)
+}
+
+func lockFlaggedTopup(tx *gorm.DB, id string, topup *models.TopupRequest) error {
+	if !appuuid.Valid(id) {
+		return ErrTopupNotFound
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(topup).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTopupNotFound
+		}
+		return err
+	}
+	if topup.RiskStatus == nil || *topup.RiskStatus != RiskFlagged || topup.Status != "PENDING" {
+		return ErrTopupNotFlagged
+	}
+	return nil
+}
+
+// ValidateRiskRule checks a risk rule before saving.
+func ValidateRiskRule(r *models.TopupRiskRule) error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRiskRule)
+	}
+	if r.Action != RiskBlock && r.Action != RiskReview {
+		return fmt.Errorf("%w: action must be BLOCK or REVIEW", ErrInvalidRiskRule)
+	}
+	if r.MaxCount != nil && *r.MaxCount <= 0 {
+		return fmt.Errorf("%w: max_count must be positive", ErrInvalidRiskRule)
+	}
+	if r.MaxAmount != nil && r.MaxAmount.IsNegative() {
+		return fmt.Errorf("%w: max_amount cannot be negative", ErrInvalidRiskRule)
+	}
+	if r.WindowHours != nil && *r.WindowHours <= 0 {
+		return fmt.Errorf("%w: window_hours must be positive", ErrInvalidRiskRule)
+	}
+	switch r.Kind {
+	case RiskMaxPending, RiskFailedTopups:
+		if r.MaxCount == nil {
+			return fmt.Errorf("%w: %s requires max_count", ErrInvalidRiskRule, r.Kind)
+		}
+	case RiskDailyAmount, RiskMonthlyAmount:
+		if r.MaxAmount == nil {
+			return fmt.Errorf("%w: %s requires max_amount", ErrInvalidRiskRule, r.Kind)
+		}
+	case RiskAccountAge:
+		if r.WindowHours == nil {
+			return fmt.Errorf("%w: %s requires window_hours", ErrInvalidRiskRule, r.Kind)
+		}
+	case RiskUnverifiedEmail:
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidRiskRule, r.Kind)
+	}
+	return nil
+}
+
+type RiskService struct {
+	db *gorm.DB
+}
+
+func NewRiskService(gdb *gorm.DB) (*RiskService, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	return &RiskService{db: gdb}, nil
+}
+
+func NewRiskServiceFromDefault() (*RiskService, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewRiskService(gdb)
+}
+
+// ListRiskRules - List topup risk rules
+// Usage: Admin
+func (s *RiskService) ListRiskRules(filters struct {
+	IsActive *bool
+	Kind     *string
+}) ([]models.TopupRiskRule, error) {
+	query := s.db.Model(&models.TopupRiskRule{})
+	if filters.IsActive != nil {
+		query = query.Where("is_active = ?", *filters.IsActive)
+	}
+	if filters.Kind != nil {
+		query = query.Where("kind = ?", strings.ToUpper(*filters.Kind))
+	}
+	rules := []models.TopupRiskRule{}
+	if err := query.Order("created_at ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// GetRiskRule - Get one risk rule
+// Usage: Admin
+func (s *RiskService) GetRiskRule(id string) (*models.TopupRiskRule, error) {
+	if !appuuid.Valid(id) {
+		return nil, ErrRiskRuleNotFound
+	}
+	var rule models.TopupRiskRule
+	if err := s.db.Where("id = ?", id).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRiskRuleNotFound
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// CreateRiskRule - Create risk rule
+// Usage: Admin only
+func (s *RiskService) CreateRiskRule(rule *models.TopupRiskRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if err := ValidateRiskRule(rule); err != nil {
+		return err
+	}
+	return s.db.Create(rule).Error
+}
+
+// UpdateRiskRule - Replace a risk rule. Recorded hits keep the name and
+// kind they were recorded under.
+// Usage: Admin only
+func (s *RiskService) UpdateRiskRule(rule *models.TopupRiskRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if err := ValidateRiskRule(rule); err != nil {
+		return err
+	}
+	current, err := s.GetRiskRule(rule.ID)
+	if err != nil {
+		return err
+	}
+	rule.CreatedAt = current.CreatedAt
+	return s.db.Save(rule).Error
+}
+
+// DeleteRiskRule - Delete a risk rule; its recorded hits are kept
+// Usage: Admin only
+func (s *RiskService) DeleteRiskRule(id string) error {
+	if !appuuid.Valid(id) {
+		return ErrRiskRuleNotFound
+	}
+	res := s.db.Delete(&models.TopupRiskRule{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRiskRuleNotFound
+	}
+	return nil
+}
+
+// ListRiskHits - Recorded rule hits, newest first
+// Usage: Admin only
+func (s *RiskService) ListRiskHits(filters struct {
+	CustomerID *string
+	RuleID     *string
+	TopupID    *string
+	Kind       *string
+	Action     *string
+	StartDate  *string
+	EndDate    *string
+	Limit      int
+	Offset     int
+}) ([]models.TopupRiskHit, int64, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := s.db.Model(&models.TopupRiskHit{})
+	for col, v := range map[string]*string{"customer_id": filters.CustomerID, "rule_id": filters.RuleID, "topup_id": filters.TopupID} {
+		if v == nil {
+			continue
+		}
+		if !appuuid.Valid(*v) {
+			return []models.TopupRiskHit{}, 0, nil
+		}
+		query = query.Where(col+" = ?", *v)
+	}
+	if filters.Kind != nil {
+		query = query.Where("kind = ?", strings.ToUpper(*filters.Kind))
+	}
+	if filters.Action != nil {
+		query = query.Where("action = ?", strings.ToUpper(*filters.Action))
+	}
+	if filters.StartDate != nil {
+		query = query.Where("created_at >= ?", *filters.StartDate)
+	}
+	if filters.EndDate != nil {
+		query = query.Where("created_at <= ?", *filters.EndDate)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	hits := []models.TopupRiskHit{}
+	if err := query.Order("created_at DESC").Limit(limit).Offset(filters.Offset).Find(&hits).Error; err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
+
+// logBlockedTopup records the hits of a refused attempt outside the
+// rolled-back topup transaction.
+func (s *TopupService) logBlockedTopup(hits []riskHit, customerID string, amount money.Amount) {
+	if err := recordRiskHits(s.db, hits, customerID, nil, amount); err != nil {
+		log.Printf("billing: record risk hits customer=%s: %v", customerID, err)
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+func TestCheckRiskRule(t *testing.T) {
+	facts := riskFacts{
+		Pending:       3,
+		DayAmount:     money.MustParse("40000000"),
+		MonthAmount:   money.MustParse("150000000"),
+		AccountAge:    10 * time.Hour,
+		EmailVerified: false,
+		Failures:      map[int]int64{24: 5, 1: 1},
+	}
+	cases := []struct {
+		name   string
+		rule   models.TopupRiskRule
+		amount string
+		want   bool
+	}{
+		{"pending at limit", models.TopupRiskRule{Kind: RiskMaxPending, MaxCount: intPtr(3)}, "10000", true},
+		{"pending below limit", models.TopupRiskRule{Kind: RiskMaxPending, MaxCount: intPtr(4)}, "10000", false},
+		{"daily at cap", models.TopupRiskRule{Kind: RiskDailyAmount, MaxAmount: amountPtr(money.MustParse("50000000"))}, "10000000", false},
+		{"daily over cap", models.TopupRiskRule{Kind: RiskDailyAmount, MaxAmount: amountPtr(money.MustParse("50000000"))}, "10000000.01", true},
+		{"monthly over cap", models.TopupRiskRule{Kind: RiskMonthlyAmount, MaxAmount: amountPtr(money.MustParse("200000000"))}, "60000000", true},
+		{"new account over cap", models.TopupRiskRule{Kind: RiskAccountAge, WindowHours: intPtr(72), MaxAmount: amountPtr(money.MustParse("5000000"))}, "6000000", true},
+		{"new account under cap", models.TopupRiskRule{Kind: RiskAccountAge, WindowHours: intPtr(72), MaxAmount: amountPtr(money.MustParse("5000000"))}, "5000000", false},
+		{"old enough account", models.TopupRiskRule{Kind: RiskAccountAge, WindowHours: intPtr(6)}, "6000000", false},
+		{"unverified any amount", models.TopupRiskRule{Kind: RiskUnverifiedEmail}, "10000", true},
+		{"unverified under cap", models.TopupRiskRule{Kind: RiskUnverifiedEmail, MaxAmount: amountPtr(money.MustParse("1000000"))}, "10000", false},
+		{"failures default window", models.TopupRiskRule{Kind: RiskFailedTopups, MaxCount: intPtr(5)}, "10000", true},
+		{"failures short window", models.TopupRiskRule{Kind: RiskFailedTopups, MaxCount: intPtr(2), WindowHours: intPtr(1)}, "10000", false},
+	}
+	for _, tc := range cases {
+		detail, hit := checkRiskRule(&tc.rule, facts, money.MustParse(tc.amount))
+		if hit != tc.want {
+			t.Errorf("%s: hit = %v (%s), want %v", tc.name, hit, detail, tc.want)
+		}
+		if hit && detail == "" {
+			t.Errorf("%s: hit without detail", tc.name)
+		}
+	}
+
+	verified := facts
+	verified.EmailVerified = true
+	if _, hit := checkRiskRule(&models.TopupRiskRule{Kind: RiskUnverifiedEmail}, verified, money.MustParse("10000")); hit {
+		t.Error("verified email tripped UNVERIFIED_EMAIL")
+	}
+}
+
+func TestEvaluateRiskRules(t *testing.T) {
+	rules := []models.TopupRiskRule{
+		{ID: "r1", Name: "pending", Kind: RiskMaxPending, Action: RiskBlock, MaxCount: intPtr(5), IsActive: true},
+		{ID: "r2", Name: "daily", Kind: RiskDailyAmount, Action: RiskReview, MaxAmount: amountPtr(money.MustParse("1000")), IsActive: true},
+		{ID: "r3", Name: "unverified", Kind: RiskUnverifiedEmail, Action: RiskBlock, IsActive: false},
+	}
+	facts := riskFacts{Pending: 1, Failures: map[int]int64{}}
+
+	hits := evaluateRiskRules(rules, facts, money.MustParse("2000"))
+	if len(hits) != 1 || hits[0].Rule.ID != "r2" || blockingHit(hits) != nil {
+		t.Fatalf("review only: %+v", hits)
+	}
+
+	facts.Pending = 5
+	hits = evaluateRiskRules(rules, facts, money.MustParse("2000"))
+	if len(hits) != 2 {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if b := blockingHit(hits); b == nil || b.Rule.ID != "r1" {
+		t.Errorf("blocking = %+v", b)
+	}
+	if hits := evaluateRiskRules(rules, riskFacts{}, money.MustParse("10")); len(hits) != 0 {
+		t.Errorf("clean attempt hits = %+v", hits)
+	}
+}
+
+func TestValidateRiskRule(t *testing.T) {
+	valid := []models.TopupRiskRule{
+		{Name: "p", Kind: RiskMaxPending, Action: RiskBlock, MaxCount: intPtr(3)},
+		{Name: "d", Kind: RiskDailyAmount, Action: RiskReview, MaxAmount: amountPtr(money.MustParse("100"))},
+		{Name: "a", Kind: RiskAccountAge, Action: RiskReview, WindowHours: intPtr(24)},
+		{Name: "u", Kind: RiskUnverifiedEmail, Action: RiskBlock},
+		{Name: "f", Kind: RiskFailedTopups, Action: RiskReview, MaxCount: intPtr(5)},
+	}
+	for _, r := range valid {
+		if err := ValidateRiskRule(&r); err != nil {
+			t.Errorf("%s: %v", r.Kind, err)
+		}
+	}
+	invalid := []models.TopupRiskRule{
+		{Name: "", Kind: RiskMaxPending, Action: RiskBlock, MaxCount: intPtr(3)},
+		{Name: "x", Kind: "VELOCITY", Action: RiskBlock},
+		{Name: "x", Kind: RiskMaxPending, Action: "ALLOW", MaxCount: intPtr(3)},
+		{Name: "x", Kind: RiskMaxPending, Action: RiskBlock},
+		{Name: "x", Kind: RiskMaxPending, Action: RiskBlock, MaxCount: intPtr(0)},
+		{Name: "x", Kind: RiskMonthlyAmount, Action: RiskReview},
+		{Name: "x", Kind: RiskDailyAmount, Action: RiskReview, MaxAmount: amountPtr(money.MustParse("-1"))},
+		{Name: "x", Kind: RiskAccountAge, Action: RiskReview},
+	}
+	for _, r := range invalid {
+		if err := ValidateRiskRule(&r); !errors.Is(err, ErrInvalidRiskRule) {
+			t.Errorf("%+v accepted: %v", r, err)
+		}
+	}
+}
+
+func TestRiskPeriodBoundaries(t *testing.T) {
+	t.Setenv("BILLING_TIMEZONE", "Asia/Jakarta")
+	// 2026-03-31 20:00 UTC is already April 1st in Jakarta (UTC+7).
+	now := time.Date(2026, 3, 31, 20, 0, 0, 0, time.UTC)
+	if got := startOfDay(now).UTC(); !got.Equal(time.Date(2026, 3, 31, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("startOfDay = %s", got)
+	}
+	if got := startOfMonth(now).UTC(); !got.Equal(time.Date(2026, 3, 31, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("startOfMonth = %s", got)
+	}
+}
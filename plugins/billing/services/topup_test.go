@@ -0,0 +1,40 @@
+package services
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestPaymentForClosedAccountIsNotCredited(t *testing.T) {
+	gdb, f := newFakeGorm(t, func(st fakeStmt) fakeReply {
+		switch {
+		case strings.HasPrefix(st.SQL, "SELECT") && strings.Contains(st.SQL, `FROM "topup_requests"`):
+			return fakeReply{
+				Columns: []string{"id", "customer_id", "status", "amount", "total_paid"},
+				Rows:    [][]driver.Value{{"topup-1", "cust-1", "PENDING", "100.00", "100.00"}},
+			}
+		case strings.HasPrefix(st.SQL, "SELECT") && strings.Contains(st.SQL, `FROM "customers"`):
+			return fakeReply{Columns: []string{"status"}, Rows: [][]driver.Value{{"CLOSED"}}}
+		}
+		return fakeReply{Affected: 1}
+	})
+	svc, err := NewTopupService(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.ProcessWebhook("ext-1", nil, map[string]interface{}{"status": "SUCCESS"}); err != nil {
+		t.Fatal(err)
+	}
+	updates := f.matching(`UPDATE "topup_requests"`)
+	if len(updates) != 1 || !containsValue(updates[0].Args, "REFUND_DUE") {
+		t.Fatalf("topup update = %+v", updates)
+	}
+	if n := len(f.matching(`INSERT INTO "wallet_transactions"`)); n != 0 {
+		t.Fatalf("closed account credited %d times", n)
+	}
+	if n := len(f.matching(`UPDATE "customers"`)); n != 0 {
+		t.Fatalf("closed account balance updated %d times", n)
+	}
+}
@@ -0,0 +1,624 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go_framework/internal/db"
+	"go_framework/internal/events"
+	"go_framework/internal/mail"
+	"go_framework/internal/money"
+	"go_framework/internal/scheduler"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UsageMeterJob is the scheduler name of the container usage meter.
+const UsageMeterJob = "billing.usage-meter"
+
+// Usage statuses
+const (
+	UsageBilled = "BILLED"
+	UsageUnpaid = "UNPAID"
+)
+
+const (
+	defaultUsageSweepInterval = 5 * time.Minute
+	defaultUsageGrace         = 24 * time.Hour
+)
+
+// UsageConfig controls how running containers are charged.
+type UsageConfig struct {
+	// Period is how often usage is debited: an hour (default) or a day.
+	// Prices are always per hour.
+	Period time.Duration
+	// Interval is how often the meter runs. A container not seen RUNNING for
+	// three intervals is treated as having stopped at its last sighting.
+	Interval time.Duration
+	// Grace is how long usage may stay unpaid before containers are stopped.
+	Grace time.Duration
+}
+
+// UsageConfigFromEnv reads BILLING_USAGE_PERIOD (hourly|daily),
+// BILLING_USAGE_SWEEP_INTERVAL and BILLING_USAGE_GRACE.
+func UsageConfigFromEnv() UsageConfig {
+	cfg := UsageConfig{Period: time.Hour, Interval: defaultUsageSweepInterval, Grace: defaultUsageGrace}
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("BILLING_USAGE_PERIOD")), "daily") {
+		cfg.Period = 24 * time.Hour
+	}
+	if v := strings.TrimSpace(os.Getenv("BILLING_USAGE_SWEEP_INTERVAL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.Interval = d
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("BILLING_USAGE_GRACE")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.Grace = d
+		}
+	}
+	return cfg
+}
+
+func (c UsageConfig) staleAfter() time.Duration { return 3 * c.Interval }
+
+// UsageMeterResult counts what one meter run did.
+type UsageMeterResult struct {
+	Containers int          `json:"containers"`
+	Periods    int          `json:"periods"`
+	Charged    money.Amount `json:"charged"`
+	Unpaid     money.Amount `json:"unpaid"`
+	Settled    int          `json:"settled"`     // customers whose arrears were paid off
+	Stopped    int          `json:"stopped"`     // customers whose containers were stopped
+	StopFailed int          `json:"stop_failed"` // stops that failed and are retried next run
+}
+
+type UsageService struct {
+	db             *gorm.DB
+	walletService  *WalletService
+	pricingService *PricingService
+}
+
+func NewUsageService(gdb *gorm.DB) (*UsageService, error) {
+	if gdb == nil {
+		return nil, errors.New("db is nil")
+	}
+	walletSvc, err := NewWalletService(gdb)
+	if err != nil {
+		return nil, err
+	}
+	return &UsageService{db: gdb, walletService: walletSvc, pricingService: &PricingService{db: gdb}}, nil
+}
+
+func NewUsageServiceFromDefault() (*UsageService, error) {
+	gdb, err := db.GetGormDB()
+	if err != nil {
+		return nil, err
+	}
+	return NewUsageService(gdb)
+}
+
+// RegisterUsageMeterJob schedules MeterUsage with UsageConfigFromEnv.
+func RegisterUsageMeterJob() {
+	cfg := UsageConfigFromEnv()
+	scheduler.Register(scheduler.Job{
+		Name:     UsageMeterJob,
+		Interval: cfg.Interval,
+		Run: func(ctx context.Context) error {
+			svc, err := NewUsageServiceFromDefault()
+			if err != nil {
+				return err
+			}
+			res, err := svc.MeterUsage(ctx, time.Now(), cfg)
+			if err != nil {
+				return err
+			}
+			if res.Periods > 0 || res.Settled > 0 || res.Stopped > 0 || res.StopFailed > 0 {
+				log.Printf("billing: usage meter containers=%d periods=%d charged=%s unpaid=%s settled=%d stopped=%d stop_failed=%d",
+					res.Containers, res.Periods, res.Charged, res.Unpaid, res.Settled, res.Stopped, res.StopFailed)
+			}
+			return nil
+		},
+	})
+}
+
+// runningContainer is the part of the node plugin's containers table the
+// meter needs.
+type runningContainer struct {
+	ID         string
+	CustomerID string
+	RamMB      int
+	CPUPercent int
+}
+
+// usageNotice is an email to send once the charging transaction committed.
+type usageNotice struct {
+	customerID string
+	kind       string // "grace" or "stopped"
+}
+
+// MeterUsage charges every RUNNING container for each full period since it
+// was last billed, closes meters of containers that stopped (charging the
+// started hours), settles arrears from the wallet and stops the containers
+// of customers whose grace period ran out.
+//
+// Charges are RENEWAL wallet transactions referencing the container. A
+// charge the wallet cannot cover is recorded as UNPAID and starts the grace
+// period; later charges stay UNPAID until the arrears are paid in order.
+func (s *UsageService) MeterUsage(ctx context.Context, now time.Time, cfg UsageConfig) (*UsageMeterResult, error) {
+	if cfg.Period <= 0 {
+		cfg.Period = time.Hour
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultUsageSweepInterval
+	}
+	res := &UsageMeterResult{}
+	var notices []usageNotice
+
+	var running []runningContainer
+	if err := s.db.Table("containers").
+		Select("id, customer_id, ram_mb, cpu_percent").
+		Where("status = ?", "RUNNING").
+		Find(&running).Error; err != nil {
+		return nil, err
+	}
+	for i := range running {
+		if ctx.Err() != nil {
+			return res, nil
+		}
+		res.Containers++
+		n, err := s.meterContainer(&running[i], now, cfg, res)
+		if err != nil {
+			log.Printf("billing: usage meter container=%s: %v", running[i].ID, err)
+			continue
+		}
+		notices = append(notices, n...)
+	}
+
+	// Meters whose container is no longer RUNNING: bill up to the last
+	// sighting and drop the meter.
+	var closed []models.ContainerMeter
+	if err := s.db.Where("container_id NOT IN (?)",
+		s.db.Table("containers").Select("id").Where("status = ?", "RUNNING")).
+		Find(&closed).Error; err != nil {
+		return nil, err
+	}
+	for i := range closed {
+		if ctx.Err() != nil {
+			return res, nil
+		}
+		n, err := s.closeMeter(closed[i].ContainerID, res)
+		if err != nil {
+			log.Printf("billing: usage meter close container=%s: %v", closed[i].ContainerID, err)
+			continue
+		}
+		notices = append(notices, n...)
+	}
+
+	n, err := s.enforceArrears(ctx, now, cfg, res)
+	if err != nil {
+		return nil, err
+	}
+	notices = append(notices, n...)
+
+	for _, notice := range notices {
+		s.notify(notice, now, cfg)
+	}
+	return res, nil
+}
+
+// meterContainer advances one running container's meter under a row lock,
+// so concurrent meters on several replicas charge each period once.
+func (s *UsageService) meterContainer(c *runningContainer, now time.Time, cfg UsageConfig, res *UsageMeterResult) ([]usageNotice, error) {
+	var notices []usageNotice
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ContainerMeter{
+			ContainerID:  c.ID,
+			CustomerID:   c.CustomerID,
+			RamMB:        c.RamMB,
+			CPUPercent:   c.CPUPercent,
+			MeteredSince: now,
+			LastSeenAt:   now,
+		}).Error; err != nil {
+			return err
+		}
+		var meter models.ContainerMeter
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("container_id = ?", c.ID).
+			First(&meter).Error; err != nil {
+			return err
+		}
+
+		// Not seen for a while (stopped and started again between runs, or
+		// the meter was down): bill what we saw and start a new span.
+		// A resize bills the old size up to now.
+		restart := now.Sub(meter.LastSeenAt) > cfg.staleAfter()
+		resized := meter.RamMB != c.RamMB || meter.CPUPercent != c.CPUPercent
+		if restart || resized {
+			end := meter.LastSeenAt
+			if !restart {
+				end = now
+			}
+			n, err := s.chargeSpan(tx, &meter, meter.MeteredSince, end, res)
+			if err != nil {
+				return err
+			}
+			notices = append(notices, n...)
+			meter.MeteredSince = now
+			meter.RamMB = c.RamMB
+			meter.CPUPercent = c.CPUPercent
+		}
+
+		for !meter.MeteredSince.Add(cfg.Period).After(now) {
+			end := meter.MeteredSince.Add(cfg.Period)
+			n, err := s.chargeSpan(tx, &meter, meter.MeteredSince, end, res)
+			if err != nil {
+				return err
+			}
+			notices = append(notices, n...)
+			meter.MeteredSince = end
+		}
+
+		return tx.Model(&models.ContainerMeter{}).Where("container_id = ?", c.ID).Updates(map[string]interface{}{
+			"customer_id":   c.CustomerID,
+			"ram_mb":        meter.RamMB,
+			"cpu_percent":   meter.CPUPercent,
+			"metered_since": meter.MeteredSince,
+			"last_seen_at":  now,
+			"updated_at":    now,
+		}).Error
+	})
+	return notices, err
+}
+
+// closeMeter bills a stopped container up to its last sighting.
+func (s *UsageService) closeMeter(containerID string, res *UsageMeterResult) ([]usageNotice, error) {
+	var notices []usageNotice
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var meter models.ContainerMeter
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("container_id = ?", containerID).
+			First(&meter).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil // closed by another run
+			}
+			return err
+		}
+		n, err := s.chargeSpan(tx, &meter, meter.MeteredSince, meter.LastSeenAt, res)
+		if err != nil {
+			return err
+		}
+		notices = n
+		return tx.Where("container_id = ?", containerID).Delete(&models.ContainerMeter{}).Error
+	})
+	return notices, err
+}
+
+// usageHours is the number of started hours in [start, end).
+func usageHours(start, end time.Time) int {
+	d := end.Sub(start)
+	if d <= 0 {
+		return 0
+	}
+	hours := int(d / time.Hour)
+	if d%time.Hour != 0 {
+		hours++
+	}
+	return hours
+}
+
+// chargeSpan records the usage of [start, end) at the container's pinned
+// price and debits it, or records it UNPAID when the customer is in arrears
+// or cannot cover it.
+func (s *UsageService) chargeSpan(tx *gorm.DB, meter *models.ContainerMeter, start, end time.Time, res *UsageMeterResult) ([]usageNotice, error) {
+	hours := usageHours(start, end)
+	if hours == 0 {
+		return nil, nil
+	}
+	quote, err := s.pricingService.ContainerQuote(tx, meter.ContainerID, meter.RamMB, meter.CPUPercent)
+	if err != nil {
+		return nil, err
+	}
+	amount := quote.Price(hours)
+
+	usage := &models.ContainerUsage{
+		ContainerID: meter.ContainerID,
+		CustomerID:  meter.CustomerID,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Hours:       hours,
+		RamMB:       meter.RamMB,
+		CPUPercent:  meter.CPUPercent,
+		Amount:      amount,
+		Status:      UsageUnpaid,
+	}
+	created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(usage)
+	if created.Error != nil {
+		return nil, created.Error
+	}
+	if created.RowsAffected == 0 {
+		return nil, nil // this period was charged already
+	}
+	res.Periods++
+
+	var customer models.CustomerBalance
+	if err := tx.Table("customers").Select("id, usage_overdue_since").
+		Where("id = ?", meter.CustomerID).Take(&customer).Error; err != nil {
+		return nil, err
+	}
+	if customer.UsageOverdueSince == nil {
+		err := s.payUsage(tx, usage)
+		if err == nil {
+			res.Charged = res.Charged.Add(amount)
+			return nil, nil
+		}
+		if !errors.Is(err, ErrInsufficientBalance) {
+			return nil, err
+		}
+	}
+
+	res.Unpaid = res.Unpaid.Add(amount)
+	marked := tx.Table("customers").
+		Where("id = ? AND usage_overdue_since IS NULL", meter.CustomerID).
+		Update("usage_overdue_since", end)
+	if marked.Error != nil {
+		return nil, marked.Error
+	}
+	if marked.RowsAffected == 1 {
+		return []usageNotice{{customerID: meter.CustomerID, kind: "grace"}}, nil
+	}
+	return nil, nil
+}
+
+// payUsage debits one usage row as a RENEWAL and marks it BILLED.
+func (s *UsageService) payUsage(tx *gorm.DB, usage *models.ContainerUsage) error {
+	referenceID := usage.ContainerID
+	referenceType := "container"
+	txn, err := s.walletService.RecordTransaction(tx, struct {
+		CustomerID       string
+		Amount           money.Amount
+		Type             string
+		ReferenceID      *string
+		ReferenceType    *string
+		Description      string
+		Metadata         string
+		CreatedByAdminID *string
+	}{
+		CustomerID:    usage.CustomerID,
+		Amount:        -usage.Amount,
+		Type:          "RENEWAL",
+		ReferenceID:   &referenceID,
+		ReferenceType: &referenceType,
+		Description:   fmt.Sprintf("Container usage %dh (%d MB, %d%% CPU)", usage.Hours, usage.RamMB, usage.CPUPercent),
+		Metadata: fmt.Sprintf(`{"usage_id": "%s", "period_start": "%s", "period_end": "%s"}`,
+			usage.ID, usage.PeriodStart.UTC().Format(time.RFC3339), usage.PeriodEnd.UTC().Format(time.RFC3339)),
+	})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	usage.Status = UsageBilled
+	usage.WalletTransactionID = &txn.ID
+	usage.BilledAt = &now
+	return tx.Model(&models.ContainerUsage{}).Where("id = ?", usage.ID).Updates(map[string]interface{}{
+		"status":                UsageBilled,
+		"wallet_transaction_id": txn.ID,
+		"billed_at":             now,
+	}).Error
+}
+
+// enforceArrears pays off what it can for customers in arrears, lifts the
+// stop once everything is paid, and stops containers of customers past the
+// grace period through the UsageOverdue hooks.
+func (s *UsageService) enforceArrears(ctx context.Context, now time.Time, cfg UsageConfig, res *UsageMeterResult) ([]usageNotice, error) {
+	var overdue []models.CustomerBalance
+	if err := s.db.Table("customers").
+		Select("id, usage_overdue_since, usage_stopped_at").
+		Where("usage_overdue_since IS NOT NULL").
+		Find(&overdue).Error; err != nil {
+		return nil, err
+	}
+
+	var notices []usageNotice
+	for i := range overdue {
+		customerID := overdue[i].ID
+		settled, wasStopped, err := s.SettleUsageArrears(customerID)
+		if err != nil {
+			log.Printf("billing: usage arrears customer=%s: %v", customerID, err)
+			continue
+		}
+		if settled {
+			res.Settled++
+			if wasStopped {
+				PublishUsageSettled(customerID)
+			}
+			continue
+		}
+		if overdue[i].UsageStoppedAt != nil || overdue[i].UsageOverdueSince.Add(cfg.Grace).After(now) {
+			continue
+		}
+		// The stop is recorded only once the containers are down; a failed
+		// hook leaves the customer for the next run to retry.
+		ev := events.CustomerEvent{CustomerID: customerID, Reason: "usage unpaid"}
+		if err := events.RunHooks(ctx, events.UsageOverdue, ev); err != nil {
+			log.Printf("billing: stop unpaid usage customer=%s: %v", customerID, err)
+			res.StopFailed++
+			continue
+		}
+		stopped := s.db.Table("customers").
+			Where("id = ? AND usage_stopped_at IS NULL", customerID).
+			Update("usage_stopped_at", now)
+		if stopped.Error != nil {
+			return nil, stopped.Error
+		}
+		if stopped.RowsAffected == 1 {
+			res.Stopped++
+			notices = append(notices, usageNotice{customerID: customerID, kind: "stopped"})
+		}
+	}
+	return notices, nil
+}
+
+// SettleUsageArrears pays a customer's UNPAID usage from the wallet, oldest
+// first, stopping at the first period the balance cannot cover. When nothing
+// is left unpaid the arrears flags are cleared; wasStopped reports whether
+// containers had been stopped and should now be resumed.
+// Usage: Meter job, Admin
+func (s *UsageService) SettleUsageArrears(customerID string) (settled, wasStopped bool, err error) {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var customer models.CustomerBalance
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Table("customers").
+			Select("id, wallet_balance, usage_overdue_since, usage_stopped_at").
+			Where("id = ?", customerID).
+			Take(&customer).Error; err != nil {
+			return err
+		}
+
+		var unpaid []models.ContainerUsage
+		if err := tx.Where("customer_id = ? AND status = ?", customerID, UsageUnpaid).
+			Order("period_start ASC").
+			Find(&unpaid).Error; err != nil {
+			return err
+		}
+		for i := range unpaid {
+			if err := s.payUsage(tx, &unpaid[i]); err != nil {
+				if errors.Is(err, ErrInsufficientBalance) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		settled = true
+		wasStopped = customer.UsageStoppedAt != nil
+		return tx.Table("customers").Where("id = ?", customerID).Updates(map[string]interface{}{
+			"usage_overdue_since": nil,
+			"usage_stopped_at":    nil,
+		}).Error
+	})
+	if err != nil {
+		return false, false, err
+	}
+	return settled, wasStopped, nil
+}
+
+// PublishUsageSettled tells other plugins to resume what they stopped for
+// unpaid usage.
+func PublishUsageSettled(customerID string) {
+	events.Publish(events.UsageSettled, events.CustomerEvent{CustomerID: customerID, Reason: "usage paid"})
+}
+
+// notify emails a customer about arrears. The amount owed is read fresh so
+// one email covers every period charged in the same run.
+func (s *UsageService) notify(n usageNotice, now time.Time, cfg UsageConfig) {
+	var customer struct {
+		Email             string
+		FullName          string
+		UsageOverdueSince *time.Time
+	}
+	if err := s.db.Table("customers").Select(contactEmailColumn+", full_name, usage_overdue_since").
+		Where("id = ?", n.customerID).Take(&customer).Error; err != nil || customer.Email == "" {
+		return
+	}
+	var owed money.Amount
+	if err := s.db.Model(&models.ContainerUsage{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("customer_id = ? AND status = ?", n.customerID, UsageUnpaid).
+		Scan(&owed).Error; err != nil {
+		log.Printf("billing: usage notice customer=%s: %v", n.customerID, err)
+	}
+
+	data := map[string]interface{}{
+		"Name": customer.FullName,
+		"Owed": FormatIDR(owed),
+	}
+	switch n.kind {
+	case "grace":
+		stopAt := now.Add(cfg.Grace)
+		if customer.UsageOverdueSince != nil {
+			stopAt = customer.UsageOverdueSince.Add(cfg.Grace)
+		}
+		data["StopAt"] = stopAt.In(InvoiceLocation()).Format("02 Jan 2006 15:04 MST")
+		mail.QueueTemplate(customer.Email, "Your balance has run out", "templates/email/usage_grace", data)
+	case "stopped":
+		mail.QueueTemplate(customer.Email, "Your containers have been stopped", "templates/email/usage_stopped", data)
+	}
+}
+
+// ListUsage - List usage line items, newest first
+// Usage: Customer (own, CustomerID set), Admin (all)
+func (s *UsageService) ListUsage(filters struct {
+	CustomerID  *string
+	ContainerID *string
+	Status      *string
+	Limit       int
+	Offset      int
+}) ([]models.ContainerUsage, int64, error) {
+	query := s.db.Model(&models.ContainerUsage{})
+	if filters.CustomerID != nil {
+		query = query.Where("customer_id = ?", *filters.CustomerID)
+	}
+	if filters.ContainerID != nil {
+		query = query.Where("container_id = ?", *filters.ContainerID)
+	}
+	if filters.Status != nil {
+		query = query.Where("status = ?", *filters.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if filters.Limit <= 0 || filters.Limit > 100 {
+		filters.Limit = 20
+	}
+
+	var items []models.ContainerUsage
+	if err := query.Order("period_start DESC").
+		Limit(filters.Limit).
+		Offset(filters.Offset).
+		Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// ContainerUsageSummary totals one container's usage.
+type ContainerUsageSummary struct {
+	ContainerID string       `json:"container_id"`
+	Hours       int          `json:"hours"`
+	Billed      money.Amount `json:"billed"`
+	Unpaid      money.Amount `json:"unpaid"`
+	FirstPeriod time.Time    `json:"first_period"`
+	LastPeriod  time.Time    `json:"last_period"`
+}
+
+// UsageSummary - Usage totals per container
+// Usage: Customer (own), Admin (any customer)
+func (s *UsageService) UsageSummary(customerID string, since *time.Time) ([]ContainerUsageSummary, error) {
+	query := s.db.Model(&models.ContainerUsage{}).
+		Select(`container_id,
+			SUM(hours) AS hours,
+			COALESCE(SUM(amount) FILTER (WHERE status = 'BILLED'), 0) AS billed,
+			COALESCE(SUM(amount) FILTER (WHERE status = 'UNPAID'), 0) AS unpaid,
+			MIN(period_start) AS first_period,
+			MAX(period_end) AS last_period`).
+		Where("customer_id = ?", customerID)
+	if since != nil {
+		query = query.Where("period_start >= ?", *since)
+	}
+
+	var rows []ContainerUsageSummary
+	if err := query.Group("container_id").Order("last_period DESC").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsageHoursCountsStartedHours(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		end  time.Duration
+		want int
+	}{
+		{0, 0},
+		{-time.Minute, 0},
+		{time.Second, 1},
+		{time.Hour, 1},
+		{time.Hour + time.Second, 2},
+		{24 * time.Hour, 24},
+	}
+	for _, c := range cases {
+		if got := usageHours(start, start.Add(c.end)); got != c.want {
+			t.Errorf("usageHours(%s) = %d, want %d", c.end, got, c.want)
+		}
+	}
+}
+
+func TestUsageConfigFromEnv(t *testing.T) {
+	t.Setenv("BILLING_USAGE_PERIOD", "")
+	t.Setenv("BILLING_USAGE_SWEEP_INTERVAL", "")
+	t.Setenv("BILLING_USAGE_GRACE", "")
+	cfg := UsageConfigFromEnv()
+	if cfg.Period != time.Hour || cfg.Interval != 5*time.Minute || cfg.Grace != 24*time.Hour {
+		t.Fatalf("defaults = %+v", cfg)
+	}
+
+	t.Setenv("BILLING_USAGE_PERIOD", "Daily")
+	t.Setenv("BILLING_USAGE_SWEEP_INTERVAL", "1m")
+	t.Setenv("BILLING_USAGE_GRACE", "0s")
+	cfg = UsageConfigFromEnv()
+	if cfg.Period != 24*time.Hour || cfg.Interval != time.Minute || cfg.Grace != 0 {
+		t.Fatalf("from env = %+v", cfg)
+	}
+	if cfg.staleAfter() != 3*time.Minute {
+		t.Fatalf("staleAfter = %s", cfg.staleAfter())
+	}
+}
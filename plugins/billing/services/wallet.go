@@ -2,9 +2,9 @@ package services
 
 import (
 	"errors"
-	"fmt"
 
 	"go_framework/internal/db"
+	"go_framework/internal/money"
 	"go_framework/plugins/billing/models"
 
 	"gorm.io/gorm"
@@ -18,6 +18,16 @@ var (
 	ErrInvalidBalance      = errors.New("invalid balance state")
 )
 
+// contactEmailColumn selects the address billing mail for a customers row
+// goes to: the customer's own, or for an organization account (which has no
+// mailbox) its earliest owner's.
+const contactEmailColumn = `COALESCE((
+	SELECT oc.email FROM organizations o
+	JOIN organization_members om ON om.organization_id = o.id
+	JOIN customers oc ON oc.id = om.customer_id
+	WHERE o.account_id = customers.id AND om.role = 'owner'
+	ORDER BY om.created_at ASC LIMIT 1), customers.email) AS email`
+
 type WalletService struct {
 	db *gorm.DB
 }
@@ -39,7 +49,7 @@ func NewWalletServiceFromDefault() (*WalletService, error) {
 
 // GetBalance - Get customer wallet balance
 // Usage: Customer (own), Admin (any customer)
-func (s *WalletService) GetBalance(customerID string) (float64, error) {
+func (s *WalletService) GetBalance(customerID string) (money.Amount, error) {
 	var customer models.CustomerBalance
 	if err := s.db.Table("customers").Where("id = ?", customerID).First(&customer).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -80,10 +90,12 @@ func (s *WalletService) GetTransactionHistory(customerID string, limit, offset i
 // RecordTransaction - Internal helper to record wallet mutation
 // Usage: Internal only (called from topup/purchase services)
 // MUST be called within a transaction context
+// Every mutation is also journaled as a balanced ledger entry; the cached
+// customers.wallet_balance mirrors the customer's wallet liability account.
 func (s *WalletService) RecordTransaction(tx *gorm.DB, input struct {
 	CustomerID       string
-	Amount           float64 // Positive for credit, negative for debit
-	Type             string  // TOPUP, PURCHASE, REFUND, etc
+	Amount           money.Amount // Positive for credit, negative for debit
+	Type             string       // TOPUP, PURCHASE, REFUND, etc
 	ReferenceID      *string
 	ReferenceType    *string
 	Description      string
@@ -107,13 +119,20 @@ func (s *WalletService) RecordTransaction(tx *gorm.DB, input struct {
 	}
 
 	balanceBefore := customer.WalletBalance
-	balanceAfter := balanceBefore + input.Amount
-
-	// Validate balance tidak negatif (kecuali allowed)
-	if balanceAfter < 0 {
+	balanceAfter, err := applyWalletMutation(balanceBefore, input.Amount)
+	if err != nil {
+		return nil, err
+	}
+	// Debits cannot spend funds reserved by wallet holds; a capture releases
+	// its hold first.
+	if input.Amount.IsNegative() && balanceAfter < customer.HeldBalance {
 		return nil, ErrInsufficientBalance
 	}
 
+	if err := openCustomerWallet(tx, input.CustomerID, balanceBefore); err != nil {
+		return nil, err
+	}
+
 	// Update customer balance
 	if err := tx.Table("customers").
 		Where("id = ?", input.CustomerID).
@@ -139,37 +158,38 @@ func (s *WalletService) RecordTransaction(tx *gorm.DB, input struct {
 		return nil, err
 	}
 
+	if err := postWalletTransaction(tx, transaction); err != nil {
+		return nil, err
+	}
+
 	return transaction, nil
 }
 
-// AdjustBalance - Manual balance adjustment by admin
-// Usage: Admin only (for correction, compensation, etc)
-func (s *WalletService) AdjustBalance(adminID, customerID string, amount float64, reason string) (*models.WalletTransaction, error) {
+// applyWalletMutation returns the balance after adding amount. The wallet may
+// never go negative. Keeping this pure lets the ledger invariant
+// (balance == sum of transactions) be tested without a database.
+func applyWalletMutation(balance, amount money.Amount) (money.Amount, error) {
+	if amount.IsZero() {
+		return balance, errors.New("amount cannot be zero")
+	}
+	after := balance.Add(amount)
+	if after.IsNegative() {
+		return balance, ErrInsufficientBalance
+	}
+	return after, nil
+}
+
+// AdjustBalance - Manual balance adjustment by admin, posted immediately
+// Usage: Admin only (for correction, compensation, etc). The admin API goes
+// through ApprovalService.SubmitAdjustment so large amounts need a second admin.
+func (s *WalletService) AdjustBalance(adminID, customerID string, amount money.Amount, reason string) (*models.WalletTransaction, error) {
 	if amount == 0 {
 		return nil, ErrNegativeAmount
 	}
 
 	var transaction *models.WalletTransaction
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		txn, err := s.RecordTransaction(tx, struct {
-			CustomerID       string
-			Amount           float64
-			Type             string
-			ReferenceID      *string
-			ReferenceType    *string
-			Description      string
-			Metadata         string
-			CreatedByAdminID *string
-		}{
-			CustomerID:       customerID,
-			Amount:           amount,
-			Type:             "ADMIN_ADJUSTMENT",
-			ReferenceID:      nil,
-			ReferenceType:    nil,
-			Description:      fmt.Sprintf("Admin adjustment: %s", reason),
-			Metadata:         fmt.Sprintf(`{"reason": "%s", "admin_id": "%s"}`, reason, adminID),
-			CreatedByAdminID: &adminID,
-		})
+		txn, err := postAdjustment(tx, customerID, amount, reason, adminID, nil)
 		if err != nil {
 			return err
 		}
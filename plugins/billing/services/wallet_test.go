@@ -0,0 +1,206 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"go_framework/internal/money"
+	"go_framework/plugins/billing/models"
+)
+
+// replayLedger applies amounts the way RecordTransaction does and returns the
+// accepted transactions and the final balance. Rejected mutations (overdraft)
+// leave no record, just like a rolled back database transaction.
+func replayLedger(amounts []money.Amount) ([]models.WalletTransaction, money.Amount) {
+	var (
+		balance money.Amount
+		txns    []models.WalletTransaction
+	)
+	for _, amt := range amounts {
+		after, err := applyWalletMutation(balance, amt)
+		if err != nil {
+			continue
+		}
+		txns = append(txns, models.WalletTransaction{Amount: amt, BalanceBefore: balance, BalanceAfter: after})
+		balance = after
+	}
+	return txns, balance
+}
+
+func randomAmounts(r *rand.Rand) []money.Amount {
+	n := r.Intn(200)
+	out := make([]money.Amount, n)
+	for i := range out {
+		// Mix credits and debits, including sub-unit amounts.
+		out[i] = money.FromMinor(r.Int63n(2_000_000_00) - 1_000_000_00)
+	}
+	return out
+}
+
+func TestWalletBalanceEqualsSumOfTransactions(t *testing.T) {
+	prop := func(seed int64) bool {
+		txns, balance := replayLedger(randomAmounts(rand.New(rand.NewSource(seed))))
+		var sum money.Amount
+		for i, tx := range txns {
+			sum = sum.Add(tx.Amount)
+			if tx.BalanceAfter != tx.BalanceBefore.Add(tx.Amount) {
+				return false
+			}
+			if i > 0 && tx.BalanceBefore != txns[i-1].BalanceAfter {
+				return false
+			}
+			if tx.BalanceAfter.IsNegative() {
+				return false
+			}
+		}
+		return sum == balance
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyWalletMutationRejectsOverdraftAndZero(t *testing.T) {
+	if _, err := applyWalletMutation(money.MustParse("10.00"), money.MustParse("-10.01")); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("overdraft err = %v, want ErrInsufficientBalance", err)
+	}
+	if _, err := applyWalletMutation(money.MustParse("10.00"), 0); err == nil {
+		t.Fatal("zero amount accepted")
+	}
+	after, err := applyWalletMutation(money.MustParse("0.10"), money.MustParse("0.20"))
+	if err != nil || after != money.MustParse("0.30") {
+		t.Fatalf("0.10 + 0.20 = %v, %v", after, err)
+	}
+}
+
+func TestCalculateContainerPriceIsExact(t *testing.T) {
+	s := &PurchaseService{}
+	cases := []struct {
+		ram, cpu, hours int
+		want            string
+	}{
+		{1024, 1, 1, "600.00"},
+		{512, 10, 24, "30000.00"},
+		{100, 1, 1, "148.83"}, // 100/1024*500 = 48.828125 -> 48.83 after summing
+	}
+	for _, c := range cases {
+		got, err := s.CalculateContainerPrice(c.ram, c.cpu, c.hours)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != c.want {
+			t.Errorf("price(%d MB, %d%%, %dh) = %s, want %s", c.ram, c.cpu, c.hours, got, c.want)
+		}
+	}
+}
+
+// walletTestDB answers the customers row lock with the given balances and
+// accepts every write.
+func walletTestDB(t *testing.T, wallet, held string) (*WalletService, *fakeDB) {
+	t.Helper()
+	gdb, f := newFakeGorm(t, func(st fakeStmt) fakeReply {
+		if strings.HasPrefix(st.SQL, "SELECT") && strings.Contains(st.SQL, `FROM "customers"`) {
+			return fakeReply{
+				Columns: []string{"id", "wallet_balance", "held_balance"},
+				Rows:    [][]driver.Value{{"cust-1", wallet, held}},
+			}
+		}
+		return fakeReply{Affected: 1}
+	})
+	svc, err := NewWalletService(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, f
+}
+
+type walletInput = struct {
+	CustomerID       string
+	Amount           money.Amount
+	Type             string
+	ReferenceID      *string
+	ReferenceType    *string
+	Description      string
+	Metadata         string
+	CreatedByAdminID *string
+}
+
+func TestRecordTransactionWritesBalanceAndLedger(t *testing.T) {
+	svc, f := walletTestDB(t, "100.00", "0.00")
+	txn, err := svc.RecordTransaction(svc.db, walletInput{
+		CustomerID:  "cust-1",
+		Amount:      money.MustParse("-30.25"),
+		Type:        "PURCHASE",
+		Description: "Container setup fee",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txn.BalanceBefore != money.MustParse("100.00") || txn.BalanceAfter != money.MustParse("69.75") {
+		t.Fatalf("balance %s -> %s", txn.BalanceBefore, txn.BalanceAfter)
+	}
+
+	updates := f.matching(`UPDATE "customers"`, "wallet_balance")
+	if len(updates) != 1 || !containsValue(updates[0].Args, "69.75") {
+		t.Fatalf("customers update = %+v", updates)
+	}
+	inserts := f.matching(`INSERT INTO "wallet_transactions"`)
+	if len(inserts) != 1 {
+		t.Fatalf("wallet_transactions inserts = %d", len(inserts))
+	}
+	row := inserts[0].inserted()[0]
+	if row["balance_before"] != "100.00" || row["balance_after"] != "69.75" || row["amount"] != "-30.25" {
+		t.Fatalf("wallet transaction row = %v", row)
+	}
+
+	// The wallet account is new, so the existing balance is opened first;
+	// every entry balances and the wallet account ends at the new balance.
+	accounts := map[driver.Value]string{}
+	for _, st := range f.matching(`INSERT INTO "ledger_accounts"`) {
+		for _, r := range st.inserted() {
+			accounts[r["id"]] = r["code"].(string)
+		}
+	}
+	entries := map[driver.Value]money.Amount{}
+	var wallet money.Amount
+	for _, st := range f.matching(`INSERT INTO "journal_postings"`) {
+		for _, r := range st.inserted() {
+			amount := money.MustParse(r["amount"].(string))
+			entries[r["entry_id"]] = entries[r["entry_id"]].Add(amount)
+			if accounts[r["account_id"]] == customerWalletAccount("cust-1").Code {
+				wallet = wallet.Add(amount)
+			}
+		}
+	}
+	if len(entries) != 2 {
+		t.Fatalf("journal entries with postings = %d, want opening and purchase", len(entries))
+	}
+	for id, sum := range entries {
+		if !sum.IsZero() {
+			t.Errorf("entry %v does not balance: %s", id, sum)
+		}
+	}
+	// Liability accounts carry credits as negative postings.
+	if wallet != money.MustParse("-69.75") {
+		t.Errorf("wallet account = %s, want -69.75", wallet)
+	}
+}
+
+func TestRecordTransactionKeepsHeldFunds(t *testing.T) {
+	svc, f := walletTestDB(t, "100.00", "80.00")
+	_, err := svc.RecordTransaction(svc.db, walletInput{
+		CustomerID: "cust-1",
+		Amount:     money.MustParse("-30.00"),
+		Type:       "PURCHASE",
+	})
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("err = %v, want ErrInsufficientBalance", err)
+	}
+	if len(f.matching(`UPDATE "`)) != 0 || len(f.matching("INSERT INTO")) != 0 {
+		t.Fatalf("debit into held funds wrote: %+v", f.matching(""))
+	}
+}
@@ -0,0 +1,460 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go_framework/internal/money"
+	"go_framework/internal/scheduler"
+	"go_framework/plugins/billing/gateways"
+	"go_framework/plugins/billing/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Webhook inbox statuses
+const (
+	InboxReceived   = "RECEIVED"
+	InboxProcessed  = "PROCESSED"
+	InboxFailed     = "FAILED"
+	InboxRejected   = "REJECTED"   // signature or token check failed
+	InboxDuplicate  = "DUPLICATE"  // provider event already processed
+	InboxUnknown    = "UNKNOWN"    // no gateway with this slug
+	InboxProcessing = "PROCESSING" // provider event claimed by this entry
+)
+
+// GenericWebhookProvider is the inbox provider of /webhooks/payment.
+const GenericWebhookProvider = "payment"
+
+// UnknownWebhookPurgeJob is the scheduler name of the sweep that deletes old
+// UNKNOWN inbox entries.
+const UnknownWebhookPurgeJob = "billing.webhook-unknown-purge"
+
+// Webhooks for unknown slugs are unauthenticated, so only a bounded sample
+// of them is kept: a truncated body and headers, at most
+// maxUnknownWebhooks entries at a time, each for
+// BILLING_WEBHOOK_UNKNOWN_RETENTION.
+const (
+	unknownWebhookMaxBody          = 4 << 10
+	unknownWebhookMaxHeaders       = 32
+	unknownWebhookMaxHeaderValue   = 256
+	maxUnknownWebhooks             = 1000
+	defaultUnknownWebhookRetention = 24 * time.Hour
+	unknownWebhookPurgeInterval    = time.Hour
+)
+
+var (
+	ErrInboxEntryNotFound  = errors.New("webhook inbox entry not found")
+	ErrSignatureUnverified = errors.New("webhook signature was not verified on receipt")
+)
+
+// uniqueViolation is the Postgres error code for a unique index conflict.
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// redactedHeaders are replaced before headers are stored: they are static
+// secrets rather than per-request signatures.
+var redactedHeaders = map[string]bool{
+	"Authorization":    true,
+	"Cookie":           true,
+	"X-Callback-Token": true,
+}
+
+func encodeHeaders(h http.Header) string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		k = http.CanonicalHeaderKey(k)
+		if redactedHeaders[k] {
+			out[k] = "[redacted]"
+			continue
+		}
+		out[k] = strings.Join(v, ", ")
+	}
+	b, _ := json.Marshal(out)
+	return string(b)
+}
+
+func decodeHeaders(raw string) http.Header {
+	var m map[string]string
+	_ = json.Unmarshal([]byte(raw), &m)
+	h := http.Header{}
+	for k, v := range m {
+		h.Set(k, v)
+	}
+	return h
+}
+
+// ReceiveWebhook persists an inbound webhook and then processes it. The
+// returned error is the processing result, already recorded on the entry.
+// Webhooks for unknown gateway slugs are stored truncated as UNKNOWN for
+// inspection, unless maxUnknownWebhooks are already kept; the disabled
+// generic endpoint stores nothing. The signature is verified
+// here, while the live headers are at hand, and the verdict is stored.
+func (s *TopupService) ReceiveWebhook(provider string, header http.Header, body []byte) (*models.WebhookInboxEntry, error) {
+	entry := &models.WebhookInboxEntry{
+		Provider: provider,
+		Headers:  encodeHeaders(header),
+		Body:     string(body),
+		Status:   InboxReceived,
+	}
+	if provider == GenericWebhookProvider {
+		if !GenericWebhookAllowed() {
+			return nil, ErrWebhookDisabled
+		}
+	} else {
+		var gateway models.PaymentGateway
+		if err := s.db.Where("slug = ?", provider).First(&gateway).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			var stored int64
+			if err := s.db.Model(&models.WebhookInboxEntry{}).Where("status = ?", InboxUnknown).Count(&stored).Error; err != nil {
+				return nil, err
+			}
+			if stored >= maxUnknownWebhooks {
+				return nil, ErrGatewayNotFound
+			}
+			msg := ErrGatewayNotFound.Error()
+			entry.Provider = truncateUTF8(provider, 50)
+			entry.Headers = encodeHeaders(sampleHeaders(header))
+			entry.Body = truncateUTF8(string(body), unknownWebhookMaxBody)
+			entry.Status = InboxUnknown
+			entry.Error = &msg
+			if err := s.db.Create(entry).Error; err != nil {
+				return nil, err
+			}
+			return entry, ErrGatewayNotFound
+		}
+		entry.GatewayID = &gateway.ID
+	}
+	if err := s.db.Create(entry).Error; err != nil {
+		return nil, err
+	}
+	return entry, s.processInboxEntry(entry, header)
+}
+
+// sampleHeaders keeps the first unknownWebhookMaxHeaders headers, in key
+// order, with values cut to unknownWebhookMaxHeaderValue bytes.
+func sampleHeaders(h http.Header) http.Header {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > unknownWebhookMaxHeaders {
+		keys = keys[:unknownWebhookMaxHeaders]
+	}
+	out := make(http.Header, len(keys))
+	for _, k := range keys {
+		out[k] = []string{truncateUTF8(strings.Join(h[k], ", "), unknownWebhookMaxHeaderValue)}
+	}
+	return out
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+// PurgeUnknownWebhooks deletes UNKNOWN inbox entries received before
+// cutoff and returns how many were deleted.
+func (s *TopupService) PurgeUnknownWebhooks(cutoff time.Time) (int64, error) {
+	res := s.db.Where("status = ? AND created_at < ?", InboxUnknown, cutoff).Delete(&models.WebhookInboxEntry{})
+	return res.RowsAffected, res.Error
+}
+
+// RegisterUnknownWebhookPurgeJob schedules PurgeUnknownWebhooks hourly,
+// keeping entries for BILLING_WEBHOOK_UNKNOWN_RETENTION (default 24h).
+func RegisterUnknownWebhookPurgeJob() {
+	retention := defaultUnknownWebhookRetention
+	if v := strings.TrimSpace(os.Getenv("BILLING_WEBHOOK_UNKNOWN_RETENTION")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			retention = d
+		}
+	}
+	scheduler.Register(scheduler.Job{
+		Name:     UnknownWebhookPurgeJob,
+		Interval: unknownWebhookPurgeInterval,
+		Run: func(ctx context.Context) error {
+			svc, err := NewTopupServiceFromDefault()
+			if err != nil {
+				return err
+			}
+			n, err := svc.PurgeUnknownWebhooks(time.Now().Add(-retention))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("billing: purged %d unknown-gateway webhooks", n)
+			}
+			return nil
+		},
+	})
+}
+
+// ReplayWebhook processes a stored entry again, e.g. after a bug fix or once
+// the topup it refers to exists. The signature verdict stored on receipt is
+// reused: secret headers are redacted in storage, so an entry cannot be
+// verified again, and one that failed or was never verified stays refused.
+func (s *TopupService) ReplayWebhook(id string) (*models.WebhookInboxEntry, error) {
+	entry, err := s.GetWebhookInboxEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	return entry, s.processInboxEntry(entry, nil)
+}
+
+// ReprocessFailedWebhooks replays FAILED entries received since the given
+// time, oldest first.
+func (s *TopupService) ReprocessFailedWebhooks(since time.Time, limit int) (processed, failed int, err error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var entries []models.WebhookInboxEntry
+	if err := s.db.Where("status = ? AND created_at >= ?", InboxFailed, since).
+		Order("created_at ASC").Limit(limit).Find(&entries).Error; err != nil {
+		return 0, 0, err
+	}
+	for i := range entries {
+		if perr := s.processInboxEntry(&entries[i], nil); perr != nil {
+			failed++
+			continue
+		}
+		processed++
+	}
+	return processed, failed, nil
+}
+
+// GetWebhookInboxEntry - Get one inbox entry
+// Usage: Admin
+func (s *TopupService) GetWebhookInboxEntry(id string) (*models.WebhookInboxEntry, error) {
+	var entry models.WebhookInboxEntry
+	if err := s.db.Where("id = ?", id).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInboxEntryNotFound
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// ListWebhookInbox - Browse the webhook inbox, newest first
+// Usage: Admin
+func (s *TopupService) ListWebhookInbox(filters struct {
+	Provider   *string
+	Status     *string
+	ExternalID *string
+	Limit      int
+	Offset     int
+}) ([]models.WebhookInboxEntry, int64, error) {
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	query := s.db.Model(&models.WebhookInboxEntry{})
+	if filters.Provider != nil {
+		query = query.Where("provider = ?", *filters.Provider)
+	}
+	if filters.Status != nil {
+		query = query.Where("status = ?", *filters.Status)
+	}
+	if filters.ExternalID != nil {
+		query = query.Where("external_id = ?", *filters.ExternalID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.WebhookInboxEntry
+	if err := query.Order("created_at DESC").Limit(limit).Offset(filters.Offset).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// processInboxEntry decodes, verifies, de-duplicates and applies an entry,
+// then records the outcome on it. header is the live request's headers on
+// receipt and nil on replay, when the stored verdict is used instead.
+func (s *TopupService) processInboxEntry(entry *models.WebhookInboxEntry, header http.Header) error {
+	entry.Status = InboxReceived
+	procErr := s.applyInboxEntry(entry, header)
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"event_id":        entry.EventID,
+		"external_id":     entry.ExternalID,
+		"signature_valid": entry.SignatureValid,
+		"attempts":        gorm.Expr("attempts + 1"),
+		"updated_at":      now,
+	}
+	switch {
+	case procErr == nil:
+		if entry.Status != InboxDuplicate {
+			entry.Status = InboxProcessed
+		}
+		updates["error"] = nil
+		updates["processed_at"] = now
+	case errors.Is(procErr, ErrInvalidSignature), errors.Is(procErr, ErrSignatureUnverified):
+		entry.Status = InboxRejected
+		updates["error"] = procErr.Error()
+	default:
+		entry.Status = InboxFailed
+		updates["error"] = procErr.Error()
+	}
+	updates["status"] = entry.Status
+	if err := s.db.Model(entry).Updates(updates).Error; err != nil {
+		return err
+	}
+	entry.Attempts++
+	return procErr
+}
+
+func (s *TopupService) applyInboxEntry(entry *models.WebhookInboxEntry, header http.Header) error {
+	body := []byte(entry.Body)
+	if entry.Provider == GenericWebhookProvider {
+		return s.applyGenericWebhook(entry, header, body)
+	}
+
+	var gateway models.PaymentGateway
+	if err := s.db.Where("slug = ?", entry.Provider).First(&gateway).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrGatewayNotFound
+		}
+		return err
+	}
+	driver, err := gateways.ForGateway(&gateway)
+	if err != nil {
+		return err
+	}
+
+	ev, err := driver.ParseWebhook(body)
+	if err != nil {
+		return err
+	}
+	entry.ExternalID = &ev.ExternalID
+	if ev.EventID != "" {
+		entry.EventID = &ev.EventID
+	}
+
+	if err := checkInboxSignature(entry, header, func() error {
+		return driver.VerifyWebhook(&gateway, header, body, ev)
+	}); err != nil {
+		return err
+	}
+
+	if dup, err := s.claimWebhookEvent(entry); err != nil || dup {
+		return err
+	}
+
+	topup, err := s.GetTopupByExternalID(ev.ExternalID)
+	if err != nil {
+		return err
+	}
+	if topup.GatewayID != gateway.ID {
+		return ErrTopupNotFound
+	}
+	return s.applyPaymentStatus(ev.ExternalID, ev.Status, ev.PaidAmount, ev.PaymentMethod, ev.PaymentChannel, ev.Data)
+}
+
+// checkInboxSignature verifies an entry once, on receipt, and stores the
+// verdict on it; replays (nil header) reuse that verdict. A verify error
+// other than a bad signature (e.g. a misconfigured gateway) records nothing.
+func checkInboxSignature(entry *models.WebhookInboxEntry, header http.Header, verify func() error) error {
+	if entry.SignatureValid == nil {
+		if header == nil {
+			return ErrSignatureUnverified
+		}
+		verr := verify()
+		if verr != nil && !errors.Is(verr, ErrInvalidSignature) {
+			return verr
+		}
+		valid := verr == nil
+		entry.SignatureValid = &valid
+	}
+	if !*entry.SignatureValid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// claimWebhookEvent marks the entry PROCESSING for its provider event. The
+// partial unique index on (provider, event_id) lets only one entry hold a
+// PROCESSING or PROCESSED claim, so a redelivery that loses the race is
+// marked DUPLICATE. Entries without an event ID are never de-duplicated.
+func (s *TopupService) claimWebhookEvent(entry *models.WebhookInboxEntry) (bool, error) {
+	if entry.EventID == nil {
+		return false, nil
+	}
+	err := s.db.Model(entry).Updates(map[string]interface{}{
+		"event_id":   *entry.EventID,
+		"status":     InboxProcessing,
+		"updated_at": time.Now(),
+	}).Error
+	if isUniqueViolation(err) {
+		entry.Status = InboxDuplicate
+		return true, nil
+	}
+	return false, err
+}
+
+// applyGenericWebhook handles /webhooks/payment bodies:
+// {"external_id", "status", "amount", "event_id", "data"}.
+func (s *TopupService) applyGenericWebhook(entry *models.WebhookInboxEntry, header http.Header, body []byte) error {
+	if err := checkInboxSignature(entry, header, func() error {
+		return VerifyGenericWebhook(body, header.Get("X-Signature"))
+	}); err != nil {
+		return err
+	}
+
+	var payload struct {
+		EventID    string                 `json:"event_id"`
+		ExternalID string                 `json:"external_id"`
+		Status     string                 `json:"status"`
+		Amount     *money.Amount          `json:"amount"`
+		Data       map[string]interface{} `json:"data"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return gateways.ErrInvalidPayload
+	}
+	if payload.ExternalID == "" || payload.Status == "" {
+		return gateways.ErrInvalidPayload
+	}
+	entry.ExternalID = &payload.ExternalID
+	if payload.EventID != "" {
+		entry.EventID = &payload.EventID
+	}
+	if dup, err := s.claimWebhookEvent(entry); err != nil || dup {
+		return err
+	}
+
+	// Merge status into data
+	if payload.Data == nil {
+		payload.Data = make(map[string]interface{})
+	}
+	payload.Data["status"] = payload.Status
+	return s.ProcessWebhook(payload.ExternalID, payload.Amount, payload.Data)
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go_framework/plugins/billing/gateways"
+	"go_framework/plugins/billing/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestInboxHeadersRedactSecrets(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Callback-Token", "cb-secret")
+	h.Set("Authorization", "Basic abc")
+	h.Set("X-Signature", "deadbeef")
+	h.Add("Accept", "application/json")
+	h.Add("Accept", "text/plain")
+
+	got := decodeHeaders(encodeHeaders(h))
+	if v := got.Get("X-Callback-Token"); v != "[redacted]" {
+		t.Errorf("X-Callback-Token stored as %q", v)
+	}
+	if v := got.Get("Authorization"); v != "[redacted]" {
+		t.Errorf("Authorization stored as %q", v)
+	}
+	// Per-request signatures are kept so entries can be re-verified.
+	if v := got.Get("X-Signature"); v != "deadbeef" {
+		t.Errorf("X-Signature = %q", v)
+	}
+	if v := got.Get("Accept"); v != "application/json, text/plain" {
+		t.Errorf("Accept = %q", v)
+	}
+}
+
+// inboxTestDriver is a gateway driver that accepts every webhook and counts
+// signature checks.
+type inboxTestDriver struct{ verified int }
+
+func (d *inboxTestDriver) Name() string { return "inbox-test" }
+func (d *inboxTestDriver) CreateCharge(context.Context, *models.PaymentGateway, gateways.ChargeRequest) (*gateways.Charge, error) {
+	return nil, errors.New("not used")
+}
+func (d *inboxTestDriver) ParseWebhook([]byte) (*gateways.WebhookEvent, error) {
+	return &gateways.WebhookEvent{EventID: "evt-1", ExternalID: "topup-1", Status: gateways.StatusSuccess}, nil
+}
+func (d *inboxTestDriver) VerifyWebhook(*models.PaymentGateway, http.Header, []byte, *gateways.WebhookEvent) error {
+	d.verified++
+	return nil
+}
+func (d *inboxTestDriver) QueryStatus(context.Context, *models.PaymentGateway, string) (*gateways.StatusResult, error) {
+	return nil, errors.New("not used")
+}
+func (d *inboxTestDriver) Cancel(context.Context, *models.PaymentGateway, string) error { return nil }
+
+// inboxTestDB answers the gateway lookup and fails the event claim with a
+// unique violation when claimTaken is set.
+func inboxTestDB(t *testing.T, claimTaken bool) (*TopupService, *fakeDB) {
+	t.Helper()
+	gdb, f := newFakeGorm(t, func(st fakeStmt) fakeReply {
+		switch {
+		case strings.Contains(st.SQL, `FROM "payment_gateways"`):
+			return fakeReply{Columns: []string{"id", "slug"}, Rows: [][]driver.Value{{"gw-1", "inbox-test"}}}
+		case claimTaken && strings.HasPrefix(st.SQL, `UPDATE "webhook_inbox"`) && containsValue(st.Args, InboxProcessing):
+			return fakeReply{Err: &pgconn.PgError{Code: uniqueViolation}}
+		}
+		return fakeReply{Affected: 1}
+	})
+	svc, err := NewTopupService(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, f
+}
+
+func containsValue(args []driver.Value, want interface{}) bool {
+	for _, a := range args {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReceiveWebhookDuplicateEvent(t *testing.T) {
+	d := &inboxTestDriver{}
+	gateways.Register(d)
+	svc, f := inboxTestDB(t, true)
+
+	entry, err := svc.ReceiveWebhook("inbox-test", http.Header{"X-Callback-Token": {"secret"}}, []byte(`{}`))
+	if err != nil {
+		t.Fatalf("duplicate delivery err = %v", err)
+	}
+	if entry.Status != InboxDuplicate {
+		t.Errorf("status = %s, want DUPLICATE", entry.Status)
+	}
+	if d.verified != 1 || entry.SignatureValid == nil || !*entry.SignatureValid {
+		t.Errorf("verified %d times, verdict %v", d.verified, entry.SignatureValid)
+	}
+	if n := len(f.matching(`INSERT INTO "webhook_inbox"`)); n != 1 {
+		t.Errorf("stored %d entries, want 1", n)
+	}
+	if n := len(f.matching(`FROM "topup_requests"`)); n != 0 {
+		t.Error("a duplicate event must not reach the topup")
+	}
+}
+
+func TestReceiveWebhookStoresUnknownGateway(t *testing.T) {
+	gdb, f := newFakeGorm(t, func(st fakeStmt) fakeReply {
+		if strings.Contains(st.SQL, `FROM "payment_gateways"`) {
+			return fakeReply{Columns: []string{"id"}}
+		}
+		return fakeReply{Affected: 1}
+	})
+	svc, err := NewTopupService(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := strings.Repeat("x", unknownWebhookMaxBody+100)
+	header := http.Header{"X-Big": {strings.Repeat("v", 1000)}}
+	entry, err := svc.ReceiveWebhook("nope", header, []byte(body))
+	if !errors.Is(err, ErrGatewayNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+	if entry == nil || entry.Status != InboxUnknown {
+		t.Fatalf("entry = %+v", entry)
+	}
+	inserts := f.matching(`INSERT INTO "webhook_inbox"`)
+	if len(inserts) != 1 || !containsValue(inserts[0].Args, InboxUnknown) {
+		t.Errorf("unknown gateway webhook not stored as UNKNOWN: %+v", inserts)
+	}
+	if len(entry.Body) != unknownWebhookMaxBody {
+		t.Errorf("stored body is %d bytes, want %d", len(entry.Body), unknownWebhookMaxBody)
+	}
+	if v := decodeHeaders(entry.Headers).Get("X-Big"); len(v) != unknownWebhookMaxHeaderValue {
+		t.Errorf("stored header is %d bytes, want %d", len(v), unknownWebhookMaxHeaderValue)
+	}
+}
+
+func TestReceiveWebhookDropsUnknownGatewayOverCap(t *testing.T) {
+	gdb, f := newFakeGorm(t, func(st fakeStmt) fakeReply {
+		switch {
+		case strings.Contains(st.SQL, `FROM "payment_gateways"`):
+			return fakeReply{Columns: []string{"id"}}
+		case strings.Contains(st.SQL, "count(*)"):
+			return fakeReply{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(maxUnknownWebhooks)}}}
+		}
+		return fakeReply{Affected: 1}
+	})
+	svc, err := NewTopupService(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.ReceiveWebhook("nope", http.Header{}, []byte(`{}`)); !errors.Is(err, ErrGatewayNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+	if n := len(f.matching(`INSERT INTO "webhook_inbox"`)); n != 0 {
+		t.Errorf("stored %d entries past the cap", n)
+	}
+}
+
+func TestTruncateUTF8KeepsCharactersWhole(t *testing.T) {
+	if got := truncateUTF8("héllo", 2); got != "h" {
+		t.Errorf("truncateUTF8 = %q, want %q", got, "h")
+	}
+	if got := truncateUTF8("abc", 5); got != "abc" {
+		t.Errorf("truncateUTF8 = %q", got)
+	}
+}
+
+func TestReplayReusesStoredSignatureVerdict(t *testing.T) {
+	d := &inboxTestDriver{}
+	gateways.Register(d)
+	valid, invalid := true, false
+
+	cases := []struct {
+		name    string
+		verdict *bool
+		wantErr error
+		status  string
+	}{
+		{"rejected on receipt", &invalid, ErrInvalidSignature, InboxRejected},
+		{"never verified", nil, ErrSignatureUnverified, InboxRejected},
+		{"verified on receipt", &valid, nil, InboxDuplicate},
+	}
+	for _, c := range cases {
+		svc, _ := inboxTestDB(t, true)
+		entry := &models.WebhookInboxEntry{
+			ID:             "entry-1",
+			Provider:       "inbox-test",
+			Headers:        `{"X-Callback-Token":"[redacted]"}`,
+			Body:           `{}`,
+			SignatureValid: c.verdict,
+			Status:         InboxFailed,
+		}
+		err := svc.processInboxEntry(entry, nil)
+		if !errors.Is(err, c.wantErr) && !(c.wantErr == nil && err == nil) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.wantErr)
+		}
+		if entry.Status != c.status {
+			t.Errorf("%s: status = %s, want %s", c.name, entry.Status, c.status)
+		}
+	}
+	if d.verified != 0 {
+		t.Errorf("replay re-verified %d times against redacted headers", d.verified)
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+
+	"go_framework/plugins/billing/gateways"
+	"go_framework/plugins/billing/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidSignature     = gateways.ErrInvalidSignature
+	ErrAmountMismatch       = errors.New("webhook amount does not match topup total")
+	ErrGatewayMisconfigured = gateways.ErrGatewayMisconfigured
+	ErrWebhookDisabled      = errors.New("webhook endpoint disabled")
+)
+
+// GetTopupByExternalID loads a topup with its gateway, for webhook checks
+// that need the gateway's secrets before anything is written.
+func (s *TopupService) GetTopupByExternalID(externalID string) (*models.TopupRequest, error) {
+	var topup models.TopupRequest
+	if err := s.db.Preload("Gateway").Where("external_id = ?", externalID).First(&topup).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTopupNotFound
+		}
+		return nil, err
+	}
+	return &topup, nil
+}
+
+// GenericWebhookAllowed reports whether /webhooks/payment may be used: always
+// in development, otherwise only when BILLING_WEBHOOK_HMAC_SECRET is set.
+func GenericWebhookAllowed() bool {
+	return isDevelopment() || os.Getenv("BILLING_WEBHOOK_HMAC_SECRET") != ""
+}
+
+// VerifyGenericWebhook checks X-Signature = hex(HMAC-SHA256(secret, body)).
+// Without a configured secret only development mode is accepted.
+func VerifyGenericWebhook(body []byte, signature string) error {
+	secret := os.Getenv("BILLING_WEBHOOK_HMAC_SECRET")
+	if secret == "" {
+		if isDevelopment() {
+			return nil
+		}
+		return ErrWebhookDisabled
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	want := hex.EncodeToString(mac.Sum(nil))
+	if subtle.ConstantTimeCompare([]byte(want), []byte(strings.ToLower(signature))) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func isDevelopment() bool {
+	env := strings.ToLower(os.Getenv("APP_ENV"))
+	return env == "development" || env == "dev" || env == "local"
+}
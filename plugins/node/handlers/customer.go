@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"go_framework/internal/access"
 	"go_framework/plugins/node/models"
 	"go_framework/plugins/node/services"
 )
@@ -76,16 +77,10 @@ func CustomerListTemplates(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"templates": rows, "total_count": total, "limit": limit, "offset": offset})
 }
 
-// GET /api/containers - list customer's own containers
+// GET /api/containers - list the account's containers
 func CustomerListContainers(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-	if customerID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid customer_id"})
+	accountID, ok := access.Account(c, access.ViewContainers)
+	if !ok {
 		return
 	}
 
@@ -119,7 +114,7 @@ func CustomerListContainers(c *gin.Context) {
 		return
 	}
 
-	rows, total, err := svc.ListContainers(customerID, nodeID, templateID, status, limit, offset)
+	rows, total, err := svc.ListContainers(accountID, nodeID, templateID, status, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -132,16 +127,10 @@ func CustomerListContainers(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"containers": resp, "total_count": total, "limit": limit, "offset": offset})
 }
 
-// POST /api/containers - create container for customer
+// POST /api/containers - create container for the account
 func CustomerCreateContainer(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-	if customerID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid customer_id"})
+	accountID, ok := access.Account(c, access.ManageContainers)
+	if !ok {
 		return
 	}
 
@@ -158,7 +147,7 @@ func CustomerCreateContainer(c *gin.Context) {
 	}
 
 	row := &models.Container{
-		CustomerID:   customerID,
+		CustomerID:   accountID,
 		NodeID:       req.NodeID,
 		TemplateID:   &req.TemplateID,
 		Subdomain:    req.Subdomain,
@@ -174,15 +163,8 @@ func CustomerCreateContainer(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"container": containerResponse(row)})
 }
 
-// GET /api/containers/:id - get customer's own container
+// GET /api/containers/:id - get account's container
 func CustomerGetContainer(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-
 	id := c.Param("id")
 	svc, err := services.NewNodeServiceFromDefault()
 	if err != nil {
@@ -196,23 +178,15 @@ func CustomerGetContainer(c *gin.Context) {
 		return
 	}
 
-	if row.CustomerID != customerID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	if !access.Require(c, row.CustomerID, access.ViewContainers) {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"container": containerResponse(row)})
 }
 
-// PUT /api/containers/:id - update customer's own container
+// PUT /api/containers/:id - update account's container
 func CustomerUpdateContainer(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-
 	id := c.Param("id")
 	var req customerUpdateContainerReq
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -232,8 +206,7 @@ func CustomerUpdateContainer(c *gin.Context) {
 		return
 	}
 
-	if row.CustomerID != customerID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	if !access.Require(c, row.CustomerID, access.ManageContainers) {
 		return
 	}
 
@@ -254,15 +227,8 @@ func CustomerUpdateContainer(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"container": containerResponse(row)})
 }
 
-// DELETE /api/containers/:id - delete customer's own container
+// DELETE /api/containers/:id - delete account's container
 func CustomerDeleteContainer(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-
 	id := c.Param("id")
 	svc, err := services.NewNodeServiceFromDefault()
 	if err != nil {
@@ -276,8 +242,7 @@ func CustomerDeleteContainer(c *gin.Context) {
 		return
 	}
 
-	if row.CustomerID != customerID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	if !access.Require(c, row.CustomerID, access.ManageContainers) {
 		return
 	}
 
@@ -288,15 +253,8 @@ func CustomerDeleteContainer(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
-// POST /api/containers/:id/deploy - deploy customer's own container
+// POST /api/containers/:id/deploy - deploy account's container
 func CustomerDeployContainer(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-
 	id := c.Param("id")
 
 	var req deployContainerReq
@@ -313,14 +271,13 @@ func CustomerDeployContainer(c *gin.Context) {
 		return
 	}
 
-	// Verify ownership first
+	// Verify access first
 	container, err := svc.GetContainerByID(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "container not found"})
 		return
 	}
-	if container.CustomerID != customerID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	if !access.Require(c, container.CustomerID, access.ManageContainers) {
 		return
 	}
 
@@ -341,6 +298,8 @@ func CustomerDeployContainer(c *gin.Context) {
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		case errors.Is(err, services.ErrDeployRequest):
 			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDeployNotCharged):
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
 		default:
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
@@ -350,15 +309,8 @@ func CustomerDeployContainer(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"container": containerResponse(row)})
 }
 
-// POST /api/containers/:id/reconcile - reconcile customer's own container
+// POST /api/containers/:id/reconcile - reconcile account's container
 func CustomerReconcileContainer(c *gin.Context) {
-	customerIDVal, exists := c.Get("customer_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-		return
-	}
-	customerID, _ := customerIDVal.(string)
-
 	id := c.Param("id")
 	svc, err := services.NewNodeServiceFromDefault()
 	if err != nil {
@@ -366,14 +318,13 @@ func CustomerReconcileContainer(c *gin.Context) {
 		return
 	}
 
-	// Verify ownership first
+	// Verify access first
 	container, err := svc.GetContainerByID(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "container not found"})
 		return
 	}
-	if container.CustomerID != customerID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	if !access.Require(c, container.CustomerID, access.ManageContainers) {
 		return
 	}
 
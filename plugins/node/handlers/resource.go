@@ -678,6 +678,10 @@ func DeployContainer(c *gin.Context) {
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		case errors.Is(err, services.ErrDeployRequest):
 			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDeployNotCharged):
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDeployRejected):
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
 		default:
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
@@ -0,0 +1,4 @@
+-- 000006_container_suspension.down.sql
+-- PostgreSQL cannot drop a single enum value; STOPPED is left in place.
+ALTER TABLE containers
+  DROP COLUMN IF EXISTS stopped_reason;
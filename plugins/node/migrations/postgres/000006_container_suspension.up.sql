@@ -0,0 +1,6 @@
+-- 000006_container_suspension.up.sql
+-- Containers can be stopped (e.g. while the owner is suspended) and resumed later.
+ALTER TYPE container_status ADD VALUE IF NOT EXISTS 'STOPPED';
+
+ALTER TABLE containers
+  ADD COLUMN IF NOT EXISTS stopped_reason VARCHAR(50);
@@ -122,19 +122,22 @@ func (t *AppTemplate) BeforeCreate(tx *gorm.DB) error {
 }
 
 type Container struct {
-	ID           string    `gorm:"type:uuid;primaryKey" json:"id"`
-	CustomerID   string    `gorm:"type:uuid;not null" json:"customer_id"`
-	NodeID       *string   `gorm:"type:uuid" json:"node_id,omitempty"`
-	TemplateID   *string   `gorm:"type:uuid" json:"template_id,omitempty"`
-	ExternalID   *string   `gorm:"size:100" json:"external_id,omitempty"`
-	Subdomain    *string   `gorm:"size:255;uniqueIndex" json:"subdomain,omitempty"`
-	InternalPort *int      `json:"internal_port,omitempty"`
-	RamMB        int       `gorm:"not null" json:"ram_mb"`
-	CPUPercent   int       `gorm:"not null" json:"cpu_percent"`
-	Status       string    `gorm:"type:container_status;not null;default:PENDING" json:"status"`
-	EnvVars      string    `gorm:"type:jsonb" json:"env_vars"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           string  `gorm:"type:uuid;primaryKey" json:"id"`
+	CustomerID   string  `gorm:"type:uuid;not null" json:"customer_id"`
+	NodeID       *string `gorm:"type:uuid" json:"node_id,omitempty"`
+	TemplateID   *string `gorm:"type:uuid" json:"template_id,omitempty"`
+	ExternalID   *string `gorm:"size:100" json:"external_id,omitempty"`
+	Subdomain    *string `gorm:"size:255;uniqueIndex" json:"subdomain,omitempty"`
+	InternalPort *int    `json:"internal_port,omitempty"`
+	RamMB        int     `gorm:"not null" json:"ram_mb"`
+	CPUPercent   int     `gorm:"not null" json:"cpu_percent"`
+	Status       string  `gorm:"type:container_status;not null;default:PENDING" json:"status"`
+	// StoppedReason tells why a STOPPED container was stopped, so only
+	// containers stopped by the platform are resumed automatically.
+	StoppedReason *string   `gorm:"size:50" json:"stopped_reason,omitempty"`
+	EnvVars       string    `gorm:"type:jsonb" json:"env_vars"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 func (Container) TableName() string { return "containers" }
@@ -1,8 +1,10 @@
 package node
 
 import (
+	"go_framework/internal/idempotency"
 	"go_framework/internal/plugins"
 	pluginhandlers "go_framework/plugins/node/handlers"
+	pluginservices "go_framework/plugins/node/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cobra"
@@ -17,7 +19,14 @@ func New() plugins.Plugin { return &Plugin{} }
 
 func (p *Plugin) ID() string { return "node" }
 
-func (p *Plugin) RegisterServices(deps plugins.ServiceDeps) error { p.deps = deps; return nil }
+func (p *Plugin) RegisterServices(deps plugins.ServiceDeps) error {
+	p.deps = deps
+	pluginservices.RegisterClosureHooks()
+	pluginservices.RegisterSuspensionSubscribers()
+	pluginservices.RegisterSuspensionRetryJob()
+	pluginservices.RegisterUsageSubscribers()
+	return nil
+}
 
 func (p *Plugin) RegisterMiddleware() []plugins.MiddlewareDescriptor { return nil }
 
@@ -39,7 +48,7 @@ func (p *Plugin) RegisterRoutes(router *gin.Engine, admin *gin.RouterGroup, api
 	admin.GET("/node/containers/:id", pluginhandlers.GetContainer)
 	admin.PUT("/node/containers/:id", pluginhandlers.UpdateContainer)
 	admin.DELETE("/node/containers/:id", pluginhandlers.DeleteContainer)
-	admin.POST("/node/containers/:id/deploy", pluginhandlers.DeployContainer)
+	admin.POST("/node/containers/:id/deploy", idempotency.Middleware(), pluginhandlers.DeployContainer)
 	admin.POST("/node/containers/:id/reconcile", pluginhandlers.ReconcileContainer)
 
 	// Node proxy management (admin)
@@ -61,7 +70,7 @@ func (p *Plugin) RegisterRoutes(router *gin.Engine, admin *gin.RouterGroup, api
 		api.GET("/containers/:id", pluginhandlers.CustomerGetContainer)
 		api.PUT("/containers/:id", pluginhandlers.CustomerUpdateContainer)
 		api.DELETE("/containers/:id", pluginhandlers.CustomerDeleteContainer)
-		api.POST("/containers/:id/deploy", pluginhandlers.CustomerDeployContainer)
+		api.POST("/containers/:id/deploy", idempotency.Middleware(), pluginhandlers.CustomerDeployContainer)
 		api.POST("/containers/:id/reconcile", pluginhandlers.CustomerReconcileContainer)
 	}
 
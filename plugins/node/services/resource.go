@@ -2,15 +2,18 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"go_framework/internal/db"
+	"go_framework/internal/events"
 	"go_framework/plugins/node/models"
 
 	"gorm.io/gorm"
@@ -25,8 +28,23 @@ var (
 	ErrNoEligibleNode    = errors.New("no eligible node found")
 	ErrInvalidState      = errors.New("invalid container state for deploy")
 	ErrDeployRequest     = errors.New("deploy request failed")
+	ErrDeployRejected    = errors.New("deploy rejected")
+	ErrDeployNotCharged  = errors.New("deployed container could not be charged")
 )
 
+const (
+	// deployTimeout bounds the node agent's deploy call. Billing holds the
+	// setup fee well beyond it, see ContainerDeploying.
+	deployTimeout = 20 * time.Second
+	// deployedHookAttempts is how often the ContainerDeployed hooks run
+	// before the container is stopped as unpaid.
+	deployedHookAttempts = 3
+)
+
+// StoppedReasonSetupUnpaid marks containers stopped because their setup fee
+// could not be charged after the deploy.
+const StoppedReasonSetupUnpaid = "SETUP_FEE_UNPAID"
+
 type NodeService struct {
 	db *gorm.DB
 }
@@ -301,10 +319,19 @@ func (s *NodeService) DeleteContainer(id string) error {
 		return err
 	}
 
-	if container.NodeID != nil && *container.NodeID != "" && container.Status == "RUNNING" {
+	if container.NodeID != nil && *container.NodeID != "" && (container.Status == "RUNNING" || container.Status == "STOPPED") {
 		_ = s.releaseNodeResource(*container.NodeID, container.RamMB)
 	}
 
+	events.Publish(events.ContainerDeleted, events.ContainerEvent{
+		ContainerID: container.ID,
+		CustomerID:  container.CustomerID,
+		TemplateID:  deref(container.TemplateID),
+		RamMB:       container.RamMB,
+		CPUPercent:  container.CPUPercent,
+		At:          time.Now(),
+	})
+
 	return nil
 }
 
@@ -314,9 +341,26 @@ func (s *NodeService) DeployContainer(id string, regionCode string) (*models.Con
 		return nil, err
 	}
 
+	// Other plugins (billing) can veto the deploy, e.g. for an unpaid setup fee.
+	ev := events.ContainerEvent{
+		ContainerID: container.ID,
+		CustomerID:  container.CustomerID,
+		TemplateID:  deref(container.TemplateID),
+		RegionCode:  node.RegionCode,
+		RamMB:       container.RamMB,
+		CPUPercent:  container.CPUPercent,
+	}
+	if err := events.RunHooks(context.Background(), events.ContainerDeploying, ev); err != nil {
+		_ = s.failDeploy(container.ID, node.ID, container.RamMB)
+		// Hooks that ran before the veto may have reserved funds.
+		events.Publish(events.ContainerDeployFailed, ev)
+		return nil, fmt.Errorf("%w: %v", ErrDeployRejected, err)
+	}
+
 	externalID, internalPort, err := s.callNodeDeploy(node, container, template)
 	if err != nil {
 		_ = s.failDeploy(container.ID, node.ID, container.RamMB)
+		events.Publish(events.ContainerDeployFailed, ev)
 		return nil, err
 	}
 
@@ -324,9 +368,50 @@ func (s *NodeService) DeployContainer(id string, regionCode string) (*models.Con
 		return nil, err
 	}
 
+	// Billing captures the setup fee it held in ContainerDeploying. A
+	// container that cannot be charged does not keep running for free.
+	if err := s.runDeployedHooks(ev); err != nil {
+		log.Printf("[node] deployed hooks container=%s: %v", container.ID, err)
+		if stopErr := s.stopUnpaidContainer(container.ID); stopErr != nil {
+			log.Printf("[node] stop unpaid container=%s failed: %v", container.ID, stopErr)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrDeployNotCharged, err)
+	}
+
 	return s.GetContainerByID(container.ID)
 }
 
+// runDeployedHooks runs the ContainerDeployed hooks, retrying transient
+// failures; the billing capture is idempotent.
+func (s *NodeService) runDeployedHooks(ev events.ContainerEvent) error {
+	var err error
+	for attempt := 1; attempt <= deployedHookAttempts; attempt++ {
+		if err = events.RunHooks(context.Background(), events.ContainerDeployed, ev); err == nil {
+			return nil
+		}
+		if attempt < deployedHookAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	return err
+}
+
+// stopUnpaidContainer stops a freshly deployed container whose setup fee
+// could not be charged. Node RAM stays reserved, as for suspensions.
+func (s *NodeService) stopUnpaidContainer(containerID string) error {
+	row, err := s.GetContainerByID(containerID)
+	if err != nil {
+		return err
+	}
+	if err := s.callContainerAction(row, "stop"); err != nil {
+		return err
+	}
+	return s.db.Model(&models.Container{}).Where("id = ?", containerID).Updates(map[string]interface{}{
+		"status":         "STOPPED",
+		"stopped_reason": StoppedReasonSetupUnpaid,
+	}).Error
+}
+
 func (s *NodeService) prepareDeploy(containerID, regionCode string) (*models.Container, *models.Node, *models.AppTemplate, error) {
 	var selectedContainer models.Container
 	var selectedNode models.Node
@@ -340,7 +425,7 @@ func (s *NodeService) prepareDeploy(containerID, regionCode string) (*models.Con
 			return err
 		}
 
-		if selectedContainer.Status == "DEPLOYING" || selectedContainer.Status == "RUNNING" {
+		if selectedContainer.Status == "DEPLOYING" || selectedContainer.Status == "RUNNING" || selectedContainer.Status == "STOPPED" {
 			return fmt.Errorf("%w: current status %s", ErrInvalidState, selectedContainer.Status)
 		}
 
@@ -463,7 +548,7 @@ func (s *NodeService) callNodeDeploy(node *models.Node, container *models.Contai
 	req.Header.Set("Authorization", "Bearer "+node.APIKey)
 	req.Header.Set("X-API-Key", node.APIKey)
 
-	client := &http.Client{Timeout: 20 * time.Second}
+	client := &http.Client{Timeout: deployTimeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", nil, fmt.Errorf("%w: %v", ErrDeployRequest, err)
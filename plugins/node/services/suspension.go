@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go_framework/internal/events"
+	"go_framework/internal/scheduler"
+	"go_framework/plugins/node/models"
+)
+
+// SuspensionRetryJob is the scheduler name of the sweep that stops
+// containers a suspension failed to stop.
+const SuspensionRetryJob = "node.suspension-retry"
+
+const defaultSuspensionRetryInterval = 5 * time.Minute
+
+// StoppedReasonSuspended marks containers stopped because their owner was
+// suspended; only these are restarted on reinstatement.
+const StoppedReasonSuspended = "CUSTOMER_SUSPENDED"
+
+// StopCustomerContainers stops every RUNNING container of a customer. Node
+// RAM stays reserved so the containers can be resumed on the same node.
+// Containers whose agent call fails are left RUNNING and reported in the
+// returned error once the others have been stopped, so the caller can retry.
+func (s *NodeService) StopCustomerContainers(customerID, reason string) error {
+	var rows []models.Container
+	if err := s.db.Where("customer_id = ? AND status = ?", customerID, "RUNNING").Find(&rows).Error; err != nil {
+		return err
+	}
+	var failed []string
+	for i := range rows {
+		row := &rows[i]
+		if err := s.callContainerAction(row, "stop"); err != nil {
+			log.Printf("[node] stop container=%s failed: %v", row.ID, err)
+			failed = append(failed, row.ID)
+			continue
+		}
+		if err := s.db.Model(&models.Container{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"status":         "STOPPED",
+			"stopped_reason": reason,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d containers still running: %s", len(failed), len(rows), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// ResumeCustomerContainers restarts containers stopped for reason.
+func (s *NodeService) ResumeCustomerContainers(customerID, reason string) error {
+	var rows []models.Container
+	if err := s.db.Where("customer_id = ? AND status = ? AND stopped_reason = ?", customerID, "STOPPED", reason).Find(&rows).Error; err != nil {
+		return err
+	}
+	for i := range rows {
+		row := &rows[i]
+		if err := s.callContainerAction(row, "start"); err != nil {
+			log.Printf("[node] start container=%s failed: %v", row.ID, err)
+			continue
+		}
+		if err := s.db.Model(&models.Container{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"status":         "RUNNING",
+			"stopped_reason": nil,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callContainerAction calls POST {endpoint}/containers/{externalID}/{action}.
+func (s *NodeService) callContainerAction(container *models.Container, action string) error {
+	if container.NodeID == nil || *container.NodeID == "" || container.ExternalID == nil || *container.ExternalID == "" {
+		return fmt.Errorf("container %s is not deployed", container.ID)
+	}
+	var node models.Node
+	if err := s.db.Where("id = ?", *container.NodeID).First(&node).Error; err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimRight(node.APIEndpoint, "/") + "/containers/" + *container.ExternalID + "/" + action
+	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+node.APIKey)
+	req.Header.Set("X-API-Key", node.APIKey)
+
+	client := &http.Client{Timeout: 20 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("node API status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RegisterSuspensionSubscribers stops and resumes containers as customers are
+// suspended and reinstated. Stops that fail are retried by
+// RegisterSuspensionRetryJob.
+func RegisterSuspensionSubscribers() {
+	events.Subscribe(events.CustomerSuspended, func(ctx context.Context, payload interface{}) {
+		ev, ok := payload.(events.CustomerEvent)
+		if !ok {
+			return
+		}
+		svc, err := NewNodeServiceFromDefault()
+		if err != nil {
+			log.Printf("[node] stop containers customer=%s: %v", ev.CustomerID, err)
+			return
+		}
+		if err := svc.StopCustomerContainers(ev.CustomerID, StoppedReasonSuspended); err != nil {
+			log.Printf("[node] stop containers customer=%s: %v", ev.CustomerID, err)
+		}
+	})
+	events.Subscribe(events.CustomerReinstated, func(ctx context.Context, payload interface{}) {
+		ev, ok := payload.(events.CustomerEvent)
+		if !ok {
+			return
+		}
+		svc, err := NewNodeServiceFromDefault()
+		if err != nil {
+			log.Printf("[node] resume containers customer=%s: %v", ev.CustomerID, err)
+			return
+		}
+		if err := svc.ResumeCustomerContainers(ev.CustomerID, StoppedReasonSuspended); err != nil {
+			log.Printf("[node] resume containers customer=%s: %v", ev.CustomerID, err)
+		}
+	})
+}
+
+// RetrySuspendedStops stops containers still RUNNING for suspended or banned
+// customers, i.e. those whose agent call failed when the suspension was
+// published. It returns how many customers still have running containers.
+func (s *NodeService) RetrySuspendedStops(ctx context.Context) (int, error) {
+	var customerIDs []string
+	if err := s.db.Model(&models.Container{}).
+		Distinct("customer_id").
+		Where("status = ? AND customer_id IN (?)", "RUNNING",
+			s.db.Table("customers").Select("id").Where("status IN ?", []string{"SUSPENDED", "BANNED"})).
+		Pluck("customer_id", &customerIDs).Error; err != nil {
+		return 0, err
+	}
+	failed := 0
+	for _, id := range customerIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := s.StopCustomerContainers(id, StoppedReasonSuspended); err != nil {
+			log.Printf("[node] retry stop containers customer=%s: %v", id, err)
+			failed++
+		}
+	}
+	return failed, nil
+}
+
+// RegisterSuspensionRetryJob schedules RetrySuspendedStops every
+// NODE_STOP_RETRY_INTERVAL (default 5m).
+func RegisterSuspensionRetryJob() {
+	interval := defaultSuspensionRetryInterval
+	if v := strings.TrimSpace(os.Getenv("NODE_STOP_RETRY_INTERVAL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			interval = d
+		}
+	}
+	scheduler.Register(scheduler.Job{
+		Name:     SuspensionRetryJob,
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			svc, err := NewNodeServiceFromDefault()
+			if err != nil {
+				return err
+			}
+			failed, err := svc.RetrySuspendedStops(ctx)
+			if err != nil {
+				return err
+			}
+			if failed > 0 {
+				log.Printf("[node] suspension retry: %d customers still have running containers", failed)
+			}
+			return nil
+		},
+	})
+}
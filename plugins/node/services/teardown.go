@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go_framework/internal/events"
+	"go_framework/plugins/node/models"
+
+	"gorm.io/gorm"
+)
+
+// TeardownCustomerContainers removes every container owned by a customer:
+// the node agent is asked to destroy it, then the row is deleted, node RAM is
+// released and ContainerDeleted is published so billing settles its usage.
+// Containers the agent failed to remove keep their row and are reported in
+// the returned error once the others are gone, so the closure stays CLOSING
+// and can be finished later.
+func (s *NodeService) TeardownCustomerContainers(customerID string) error {
+	var rows []models.Container
+	if err := s.db.Where("customer_id = ?", customerID).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	var failed []string
+	for i := range rows {
+		row := &rows[i]
+		var node *models.Node
+		if row.NodeID != nil && *row.NodeID != "" {
+			var n models.Node
+			err := s.db.Where("id = ?", *row.NodeID).First(&n).Error
+			switch {
+			case err == nil:
+				node = &n
+			case !errors.Is(err, gorm.ErrRecordNotFound):
+				return err
+			}
+		}
+		if node != nil && row.ExternalID != nil && *row.ExternalID != "" {
+			if err := s.callNodeRemove(node, *row.ExternalID); err != nil {
+				log.Printf("[node] teardown: remove container=%s on node=%s failed: %v", row.ID, node.ID, err)
+				failed = append(failed, row.ID)
+				continue
+			}
+		}
+
+		if err := s.db.Delete(&models.Container{}, "id = ?", row.ID).Error; err != nil {
+			return err
+		}
+		if node != nil && (row.Status == "RUNNING" || row.Status == "DEPLOYING" || row.Status == "STOPPED") {
+			if err := s.releaseNodeResource(node.ID, row.RamMB); err != nil {
+				return err
+			}
+		}
+		events.Publish(events.ContainerDeleted, events.ContainerEvent{
+			ContainerID: row.ID,
+			CustomerID:  row.CustomerID,
+			TemplateID:  deref(row.TemplateID),
+			RamMB:       row.RamMB,
+			CPUPercent:  row.CPUPercent,
+			At:          time.Now(),
+		})
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d containers could not be removed: %s", len(failed), len(rows), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// callNodeRemove asks the node agent to destroy a container.
+func (s *NodeService) callNodeRemove(node *models.Node, externalID string) error {
+	endpoint := strings.TrimRight(node.APIEndpoint, "/") + "/containers/" + externalID
+	req, err := http.NewRequest(http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+node.APIKey)
+	req.Header.Set("X-API-Key", node.APIKey)
+
+	client := &http.Client{Timeout: 20 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("node API status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RegisterClosureHooks tears down customer containers when an account closes.
+func RegisterClosureHooks() {
+	events.RegisterHook(events.CustomerClosing, func(ctx context.Context, payload interface{}) error {
+		ev, ok := payload.(events.CustomerEvent)
+		if !ok {
+			return nil
+		}
+		svc, err := NewNodeServiceFromDefault()
+		if err != nil {
+			return err
+		}
+		return svc.TeardownCustomerContainers(ev.CustomerID)
+	})
+}
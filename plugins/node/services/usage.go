@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"go_framework/internal/events"
+)
+
+// StoppedReasonUnpaid marks containers stopped because their owner's usage
+// charges stayed unpaid past the grace period.
+const StoppedReasonUnpaid = "USAGE_UNPAID"
+
+// RegisterUsageSubscribers stops containers when billing reports unpaid
+// usage and resumes them once it is paid. The stop is a hook so billing only
+// records the customer as stopped once every container is down.
+func RegisterUsageSubscribers() {
+	events.RegisterHook(events.UsageOverdue, func(ctx context.Context, payload interface{}) error {
+		ev, ok := payload.(events.CustomerEvent)
+		if !ok {
+			return nil
+		}
+		svc, err := NewNodeServiceFromDefault()
+		if err != nil {
+			return err
+		}
+		return svc.StopCustomerContainers(ev.CustomerID, StoppedReasonUnpaid)
+	})
+	events.Subscribe(events.UsageSettled, func(ctx context.Context, payload interface{}) {
+		ev, ok := payload.(events.CustomerEvent)
+		if !ok {
+			return
+		}
+		svc, err := NewNodeServiceFromDefault()
+		if err != nil {
+			log.Printf("[node] resume unpaid containers customer=%s: %v", ev.CustomerID, err)
+			return
+		}
+		if err := svc.ResumeCustomerContainers(ev.CustomerID, StoppedReasonUnpaid); err != nil {
+			log.Printf("[node] resume unpaid containers customer=%s: %v", ev.CustomerID, err)
+		}
+	})
+}